@@ -0,0 +1,774 @@
+package ssai
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidMPD is returned for manifests that are not DASH MPDs the stitcher supports
+var ErrInvalidMPD = errors.New("invalid or unsupported DASH MPD")
+
+const (
+	scte35Scheme    = "urn:scte:scte35:2014:xml+bin"
+	scte35Namespace = "http://www.scte.org/schemas/35/2016"
+	scte35Timescale = 90000
+	assetIDScheme   = "urn:org:dashif:asset-id:2013"
+)
+
+// periodTail are the Period children that come after AssetIdentifier and EventStream
+var periodTail = []string{"ServiceDescription", "ContentProtection", "AdaptationSet", "Subset", "SupplementalProperty", "EmptyAdaptationSet", "GroupLabel", "Preselection"}
+
+// AdCreative is an ad encoded for DASH. Each creative is inserted as a Period.
+type AdCreative struct {
+	ID              string  // ad ID, written as the AssetIdentifier of the Period
+	Duration        float64 // seconds
+	BaseURL         string
+	Representations []AdRepresentation
+}
+
+// AdRepresentation is a rendition of an ad creative addressed by a numbered SegmentTemplate
+type AdRepresentation struct {
+	ID              string
+	ContentType     string // "video", "audio" or "text"
+	MimeType        string
+	Codecs          string
+	Lang            string
+	Bandwidth       int
+	Width           int
+	Height          int
+	Timescale       int
+	SegmentDuration int    // in timescale units
+	Initialization  string // SegmentTemplate@initialization
+	Media           string // SegmentTemplate@media, with $Number$
+}
+
+// contentPeriod is a Period of the content MPD with its timing in seconds
+type contentPeriod struct {
+	node     *xmlNode
+	id       string
+	start    float64
+	duration float64
+	open     bool // the last Period of a dynamic MPD, which has no end yet
+}
+
+// dashBreak is an ad break placed in the content
+type dashBreak struct {
+	AdBreak
+	eventID uint32
+	period  int     // index of the content Period the break is in, len(periods) after the last
+	offset  float64 // seconds into the Period
+	total   float64
+}
+
+// RewriteDASHManifest inserts ad breaks into a DASH MPD, one Period per ad
+// creative. Content Periods are split at the segment boundary of their video
+// AdaptationSet nearest to each break; presentationTimeOffset, startNumber and
+// SegmentTimeline are adjusted so each part addresses its own segments, and the
+// parts share an AssetIdentifier. Ad AdaptationSets reuse the ids of the
+// content's of the same type so players keep their decoders across Periods, and
+// the first ad Period of a break carries a SCTE-35 splice_insert in an
+// EventStream. In dynamic MPDs the open last Period takes no post-rolls.
+func RewriteDASHManifest(manifest string, adBreaks []AdBreak) (string, error) {
+	mpd, err := parseXMLTree(manifest)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidMPD, err)
+	}
+	if localName(mpd.Name) != "MPD" {
+		return "", fmt.Errorf("%w: root element is %s", ErrInvalidMPD, mpd.Name)
+	}
+	periods, err := contentPeriods(mpd)
+	if err != nil {
+		return "", err
+	}
+	breaks, err := placeDASHBreaks(adBreaks, periods)
+	if err != nil {
+		return "", err
+	}
+	if len(breaks) == 0 {
+		return manifest, nil
+	}
+
+	ids, nextID := assignAdaptationSetIDs(periods)
+	var stitched []*xmlNode
+	inserted := 0.0
+	next := 0
+	insertAds := func(period int, offset float64) {
+		for next < len(breaks) && breaks[next].period == period && breaks[next].offset == offset {
+			stitched = append(stitched, adPeriods(breaks[next], ids, &nextID)...)
+			inserted += breaks[next].total
+			next++
+		}
+	}
+	for i, p := range periods {
+		insertAds(i, 0)
+		from := 0.0
+		for piece := 1; ; piece++ {
+			to := -1.0
+			if next < len(breaks) && breaks[next].period == i {
+				to = breaks[next].offset
+			}
+			node, err := periodPiece(p, from, to, piece)
+			if err != nil {
+				return "", err
+			}
+			stitched = append(stitched, node)
+			if to < 0 {
+				break
+			}
+			insertAds(i, to)
+			from = to
+		}
+	}
+	insertAds(len(periods), 0)
+
+	// Replace the content Periods, then lay the Periods out back to back
+	var children []*xmlNode
+	for _, c := range mpd.Children {
+		if localName(c.Name) != "Period" {
+			children = append(children, c)
+		} else if stitched != nil {
+			children = append(children, stitched...)
+			stitched = nil
+		}
+	}
+	mpd.Children = children
+	start := periods[0].start
+	for _, period := range mpd.childrenNamed("Period") {
+		period.setAttr("start", formatXSDuration(start))
+		duration, ok := durationAttr(period, "duration")
+		if !ok {
+			break
+		}
+		start += duration
+	}
+	if total, ok := durationAttr(mpd, "mediaPresentationDuration"); ok {
+		mpd.setAttr("mediaPresentationDuration", formatXSDuration(total+inserted))
+	}
+	if _, ok := mpd.attr("xmlns:scte35"); !ok {
+		mpd.setAttr("xmlns:scte35", scte35Namespace)
+	}
+	return mpd.String(), nil
+}
+
+// contentPeriods returns the Periods of an MPD with their timing, giving Periods
+// without an id one
+func contentPeriods(mpd *xmlNode) ([]*contentPeriod, error) {
+	nodes := mpd.childrenNamed("Period")
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("%w: no Period", ErrInvalidMPD)
+	}
+	dynamic := false
+	if t, _ := mpd.attr("type"); t == "dynamic" {
+		dynamic = true
+	}
+	total, hasTotal := durationAttr(mpd, "mediaPresentationDuration")
+
+	periods := make([]*contentPeriod, len(nodes))
+	for i, node := range nodes {
+		p := &contentPeriod{node: node}
+		p.id, _ = node.attr("id")
+		if p.id == "" {
+			p.id = fmt.Sprintf("content-%d", i+1)
+			node.Attrs = append([]xmlAttr{{Name: "id", Value: p.id}}, node.Attrs...)
+		}
+		if start, ok := durationAttr(node, "start"); ok {
+			p.start = start
+		} else if i > 0 {
+			p.start = periods[i-1].start + periods[i-1].duration
+		}
+
+		duration, ok := durationAttr(node, "duration")
+		switch {
+		case ok:
+			p.duration = duration
+		case i < len(nodes)-1:
+			nextStart, ok := durationAttr(nodes[i+1], "start")
+			if !ok {
+				return nil, fmt.Errorf("%w: the duration of Period %s is unknown", ErrInvalidMPD, p.id)
+			}
+			p.duration = nextStart - p.start
+		case hasTotal:
+			p.duration = total - p.start
+		case dynamic:
+			p.open = true
+		default:
+			return nil, fmt.Errorf("%w: the duration of Period %s is unknown", ErrInvalidMPD, p.id)
+		}
+		periods[i] = p
+	}
+	return periods, nil
+}
+
+// placeDASHBreaks places the ad breaks that have creatives, in content order
+func placeDASHBreaks(adBreaks []AdBreak, periods []*contentPeriod) ([]*dashBreak, error) {
+	last := periods[len(periods)-1]
+	var breaks []*dashBreak
+	for i, b := range adBreaks {
+		placed := &dashBreak{AdBreak: b, eventID: uint32(i + 1)}
+		if placed.ID == "" {
+			placed.ID = fmt.Sprintf("ad-%d", i+1)
+		}
+		for _, c := range b.Creatives {
+			placed.total += c.Duration
+		}
+		if placed.total <= 0 {
+			continue
+		}
+
+		switch b.Type {
+		case "pre-roll":
+		case "post-roll":
+			if last.open {
+				continue
+			}
+			placed.period = len(periods)
+		default:
+			at := periods[0].start + math.Max(b.StartTime, 0)
+			placed.period = len(periods)
+			for j, p := range periods {
+				if p.open || at < p.start+p.duration {
+					placed.period = j
+					break
+				}
+			}
+			if placed.period == len(periods) {
+				break
+			}
+			p := periods[placed.period]
+			offset, err := nearestBoundary(p, math.Max(at-p.start, 0))
+			if err != nil {
+				return nil, err
+			}
+			if !p.open && offset >= p.duration {
+				placed.period++
+				offset = 0
+			}
+			placed.offset = offset
+		}
+		breaks = append(breaks, placed)
+	}
+	sort.SliceStable(breaks, func(i, j int) bool {
+		if breaks[i].period != breaks[j].period {
+			return breaks[i].period < breaks[j].period
+		}
+		return breaks[i].offset < breaks[j].offset
+	})
+	return breaks, nil
+}
+
+// nearestBoundary returns the segment boundary of the Period's video nearest to
+// an offset in seconds, the offset itself when the segments are not addressed
+// individually. Ties go to the earlier boundary.
+func nearestBoundary(p *contentPeriod, offset float64) (float64, error) {
+	templates, err := segmentTemplates(p.node)
+	if err != nil {
+		return 0, err
+	}
+	candidates := []float64{0}
+	if !p.open {
+		candidates = append(candidates, p.duration)
+	}
+
+	ref := referenceTemplate(templates)
+	if ref == nil || localName(ref.node.Name) != "SegmentTemplate" {
+		if !p.open {
+			offset = math.Min(offset, p.duration)
+		}
+		return offset, nil
+	}
+	timescale := float64(ref.int("timescale", 1))
+	pto := ref.int("presentationTimeOffset", 0)
+	target := pto + int64(math.Round(offset*timescale))
+	if timeline := ref.node.child("SegmentTimeline"); timeline != nil {
+		for _, e := range readTimeline(timeline) {
+			for k := int64(0); e.r < 0 || k <= e.r; k++ {
+				t := e.t + k*e.d
+				candidates = append(candidates, float64(t-pto)/timescale)
+				if t > target {
+					break
+				}
+			}
+		}
+	} else if d := ref.int("duration", 0); d > 0 {
+		k := float64((target - pto) / d)
+		candidates = append(candidates, k*float64(d)/timescale, (k+1)*float64(d)/timescale)
+	}
+
+	best := candidates[0]
+	for _, c := range candidates {
+		if c < 0 || (!p.open && c > p.duration) {
+			continue
+		}
+		if d := math.Abs(c - offset); d < math.Abs(best-offset) || (d == math.Abs(best-offset) && c < best) {
+			best = c
+		}
+	}
+	return best, nil
+}
+
+// periodPiece returns the part of a content Period between two offsets in
+// seconds; to is negative for the end of the Period
+func periodPiece(p *contentPeriod, from, to float64, piece int) (*xmlNode, error) {
+	node := p.node.clone()
+	if piece > 1 {
+		node.setAttr("id", fmt.Sprintf("%s-%d", p.id, piece))
+	}
+	switch {
+	case to >= 0:
+		node.setAttr("duration", formatXSDuration(to-from))
+	case p.open:
+		node.removeAttr("duration")
+	default:
+		node.setAttr("duration", formatXSDuration(p.duration-from))
+	}
+	if from == 0 && to < 0 {
+		return node, nil
+	}
+
+	templates, err := segmentTemplates(node)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range templates {
+		if t.addressing() {
+			trimTemplate(t, from, to)
+		}
+	}
+	for _, stream := range node.childrenNamed("EventStream") {
+		trimEvents(stream, from, to)
+	}
+	if node.child("AssetIdentifier") == nil {
+		node.insertBefore(&xmlNode{Name: "AssetIdentifier", Attrs: []xmlAttr{
+			{Name: "schemeIdUri", Value: assetIDScheme},
+			{Name: "value", Value: p.id},
+		}}, append([]string{"EventStream"}, periodTail...)...)
+	}
+	return node, nil
+}
+
+// segmentTemplate is a SegmentTemplate or SegmentBase with the templates of the
+// levels above it, from which it inherits attributes
+type segmentTemplate struct {
+	node          *xmlNode
+	inherited     []*xmlNode
+	adaptationSet *xmlNode
+}
+
+// int returns an integer attribute of the template or the templates it inherits from
+func (t segmentTemplate) int(name string, def int64) int64 {
+	levels := append(append([]*xmlNode(nil), t.inherited...), t.node)
+	for i := len(levels) - 1; i >= 0; i-- {
+		if value, ok := levels[i].attr(name); ok {
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				return n
+			}
+		}
+	}
+	return def
+}
+
+// addressing reports whether the template addresses segments itself rather than
+// only giving attributes to the templates below it
+func (t segmentTemplate) addressing() bool {
+	if localName(t.node.Name) == "SegmentBase" || t.node.child("SegmentTimeline") != nil {
+		return true
+	}
+	_, ok := t.node.attr("duration")
+	return ok
+}
+
+// segmentTemplates returns the segment templates of a Period, its AdaptationSets
+// and their Representations
+func segmentTemplates(period *xmlNode) ([]segmentTemplate, error) {
+	var templates []segmentTemplate
+	var visit func(n *xmlNode, inherited []*xmlNode, adaptationSet *xmlNode) error
+	visit = func(n *xmlNode, inherited []*xmlNode, adaptationSet *xmlNode) error {
+		for _, c := range n.Children {
+			switch localName(c.Name) {
+			case "SegmentList":
+				return fmt.Errorf("%w: SegmentList addressing is not supported", ErrInvalidMPD)
+			case "SegmentTemplate", "SegmentBase":
+				templates = append(templates, segmentTemplate{node: c, inherited: inherited, adaptationSet: adaptationSet})
+				inherited = append(append([]*xmlNode(nil), inherited...), c)
+			}
+		}
+		for _, c := range n.Children {
+			switch localName(c.Name) {
+			case "AdaptationSet":
+				if err := visit(c, inherited, c); err != nil {
+					return err
+				}
+			case "Representation":
+				if err := visit(c, inherited, adaptationSet); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	err := visit(period, nil, nil)
+	return templates, err
+}
+
+// referenceTemplate returns the template that places split points: the first
+// addressing template of the video, else of any AdaptationSet
+func referenceTemplate(templates []segmentTemplate) *segmentTemplate {
+	var fallback *segmentTemplate
+	for i, t := range templates {
+		if !t.addressing() {
+			continue
+		}
+		if t.adaptationSet != nil && adaptationSetType(t.adaptationSet) == "video" {
+			return &templates[i]
+		}
+		if fallback == nil {
+			fallback = &templates[i]
+		}
+	}
+	return fallback
+}
+
+// trimTemplate trims the segments a template addresses to those between two
+// offsets in seconds. Segments that straddle an offset stay in both parts.
+func trimTemplate(t segmentTemplate, from, to float64) {
+	timescale := float64(t.int("timescale", 1))
+	pto := t.int("presentationTimeOffset", 0)
+	start := pto + int64(math.Round(from*timescale))
+	if localName(t.node.Name) == "SegmentBase" {
+		if from > 0 {
+			t.node.setAttr("presentationTimeOffset", strconv.FormatInt(start, 10))
+		}
+		return
+	}
+
+	startNumber := t.int("startNumber", 1)
+	if timeline := t.node.child("SegmentTimeline"); timeline != nil {
+		entries := readTimeline(timeline)
+		if to >= 0 {
+			entries = trimTimelineEnd(entries, pto+int64(math.Round(to*timescale)))
+		}
+		if from > 0 {
+			var skipped int64
+			entries, skipped = trimTimelineStart(entries, start)
+			startNumber += skipped
+		}
+		writeTimeline(timeline, entries)
+	} else if d := t.int("duration", 0); d > 0 {
+		startNumber += (start - pto) / d
+	}
+	if from > 0 {
+		t.node.setAttr("presentationTimeOffset", strconv.FormatInt(start, 10))
+		t.node.setAttr("startNumber", strconv.FormatInt(startNumber, 10))
+	}
+}
+
+// timelineEntry is an S element of a SegmentTimeline with its start time resolved.
+// r is -1 for segments repeating until the end of the Period.
+type timelineEntry struct {
+	t, d, r int64
+}
+
+func readTimeline(timeline *xmlNode) []timelineEntry {
+	var entries []timelineEntry
+	next := int64(0)
+	for _, s := range timeline.childrenNamed("S") {
+		e := timelineEntry{t: next}
+		if value, ok := s.attr("t"); ok {
+			e.t, _ = strconv.ParseInt(value, 10, 64)
+		}
+		if value, ok := s.attr("d"); ok {
+			e.d, _ = strconv.ParseInt(value, 10, 64)
+		}
+		if value, ok := s.attr("r"); ok {
+			e.r, _ = strconv.ParseInt(value, 10, 64)
+		}
+		if e.d <= 0 {
+			continue
+		}
+		entries = append(entries, e)
+		next = e.t + (e.r+1)*e.d
+	}
+	return entries
+}
+
+func writeTimeline(timeline *xmlNode, entries []timelineEntry) {
+	prefix := strings.TrimSuffix(timeline.Name, "SegmentTimeline")
+	timeline.Children = nil
+	next := int64(-1)
+	for _, e := range entries {
+		s := &xmlNode{Name: prefix + "S"}
+		if e.t != next {
+			s.setAttr("t", strconv.FormatInt(e.t, 10))
+		}
+		s.setAttr("d", strconv.FormatInt(e.d, 10))
+		if e.r != 0 {
+			s.setAttr("r", strconv.FormatInt(e.r, 10))
+		}
+		timeline.Children = append(timeline.Children, s)
+		next = e.t + (e.r+1)*e.d
+	}
+}
+
+// trimTimelineStart drops the segments that end at or before a media time and
+// returns how many it dropped
+func trimTimelineStart(entries []timelineEntry, x int64) ([]timelineEntry, int64) {
+	var kept []timelineEntry
+	var skipped int64
+	for _, e := range entries {
+		k := int64(0)
+		if x > e.t {
+			k = (x - e.t) / e.d
+		}
+		if e.r >= 0 && k > e.r {
+			skipped += e.r + 1
+			continue
+		}
+		skipped += k
+		e.t += k * e.d
+		if e.r >= 0 {
+			e.r -= k
+		}
+		kept = append(kept, e)
+	}
+	return kept, skipped
+}
+
+// trimTimelineEnd drops the segments that start at or after a media time
+func trimTimelineEnd(entries []timelineEntry, x int64) []timelineEntry {
+	var kept []timelineEntry
+	for _, e := range entries {
+		if e.t >= x {
+			break
+		}
+		n := (x - e.t + e.d - 1) / e.d
+		if e.r >= 0 && n > e.r+1 {
+			n = e.r + 1
+		}
+		e.r = n - 1
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// trimEvents keeps the events of an EventStream that start between two offsets
+// in seconds; to is negative for the end of the Period
+func trimEvents(stream *xmlNode, from, to float64) {
+	timescale, pto := float64(1), int64(0)
+	if value, ok := stream.attr("timescale"); ok {
+		if n, err := strconv.ParseFloat(value, 64); err == nil && n > 0 {
+			timescale = n
+		}
+	}
+	if value, ok := stream.attr("presentationTimeOffset"); ok {
+		pto, _ = strconv.ParseInt(value, 10, 64)
+	}
+	lo := pto + int64(math.Round(from*timescale))
+	hi := pto + int64(math.Round(to*timescale))
+
+	var kept []*xmlNode
+	for _, c := range stream.Children {
+		if localName(c.Name) == "Event" {
+			value, _ := c.attr("presentationTime")
+			t, _ := strconv.ParseInt(value, 10, 64)
+			if (from > 0 && t < lo) || (to >= 0 && t >= hi) {
+				continue
+			}
+		}
+		kept = append(kept, c)
+	}
+	stream.Children = kept
+	if from > 0 {
+		stream.setAttr("presentationTimeOffset", strconv.FormatInt(lo, 10))
+	}
+}
+
+// assignAdaptationSetIDs gives AdaptationSets without an id one by their
+// position, the same in every Period, and returns the id of the first
+// AdaptationSet of each content type and the next free id
+func assignAdaptationSetIDs(periods []*contentPeriod) (map[string]string, int) {
+	maxID := -1
+	for _, p := range periods {
+		for _, set := range p.node.childrenNamed("AdaptationSet") {
+			if value, ok := set.attr("id"); ok {
+				if n, err := strconv.Atoi(value); err == nil && n > maxID {
+					maxID = n
+				}
+			}
+		}
+	}
+	nextID := maxID + 1
+	positions := 0
+	ids := make(map[string]string)
+	for _, p := range periods {
+		for i, set := range p.node.childrenNamed("AdaptationSet") {
+			if _, ok := set.attr("id"); !ok {
+				set.Attrs = append([]xmlAttr{{Name: "id", Value: strconv.Itoa(nextID + i)}}, set.Attrs...)
+				if i >= positions {
+					positions = i + 1
+				}
+			}
+			if _, ok := ids[adaptationSetType(set)]; !ok {
+				ids[adaptationSetType(set)], _ = set.attr("id")
+			}
+		}
+	}
+	return ids, nextID + positions
+}
+
+// adaptationSetType returns the content type of an AdaptationSet
+func adaptationSetType(set *xmlNode) string {
+	if value, ok := set.attr("contentType"); ok {
+		return value
+	}
+	mimeType, ok := set.attr("mimeType")
+	if !ok {
+		if rep := set.child("Representation"); rep != nil {
+			mimeType, _ = rep.attr("mimeType")
+		}
+	}
+	switch {
+	case mimeType == "application/ttml+xml", strings.HasPrefix(mimeType, "text/"):
+		return "text"
+	case strings.HasPrefix(mimeType, "application/mp4"):
+		if codecs, _ := set.attr("codecs"); strings.HasPrefix(codecs, "stpp") || strings.HasPrefix(codecs, "wvtt") {
+			return "text"
+		}
+	}
+	contentType, _, _ := strings.Cut(mimeType, "/")
+	return contentType
+}
+
+// adPeriods returns the Periods of an ad break, one per creative
+func adPeriods(b *dashBreak, ids map[string]string, nextID *int) []*xmlNode {
+	var periods []*xmlNode
+	for i, creative := range b.Creatives {
+		period := &xmlNode{Name: "Period", Attrs: []xmlAttr{
+			{Name: "id", Value: fmt.Sprintf("%s-%d", b.ID, i+1)},
+			{Name: "start", Value: ""},
+			{Name: "duration", Value: formatXSDuration(creative.Duration)},
+		}}
+		if creative.BaseURL != "" {
+			period.Children = append(period.Children, &xmlNode{Name: "BaseURL", Text: creative.BaseURL})
+		}
+		if creative.ID != "" {
+			period.Children = append(period.Children, &xmlNode{Name: "AssetIdentifier", Attrs: []xmlAttr{
+				{Name: "schemeIdUri", Value: assetIDScheme},
+				{Name: "value", Value: creative.ID},
+			}})
+		}
+		if i == 0 {
+			period.Children = append(period.Children, spliceEventStream(b))
+		}
+
+		sets := make(map[string]*xmlNode)
+		used := make(map[string]bool)
+		for _, r := range creative.Representations {
+			key := r.ContentType + "/" + r.Lang
+			set := sets[key]
+			if set == nil {
+				id, ok := ids[r.ContentType]
+				if !ok || used[id] {
+					id = strconv.Itoa(*nextID)
+					*nextID++
+				}
+				used[id] = true
+				set = &xmlNode{Name: "AdaptationSet", Attrs: []xmlAttr{
+					{Name: "id", Value: id},
+					{Name: "contentType", Value: r.ContentType},
+				}}
+				if r.MimeType != "" {
+					set.setAttr("mimeType", r.MimeType)
+				}
+				if r.Lang != "" {
+					set.setAttr("lang", r.Lang)
+				}
+				set.setAttr("segmentAlignment", "true")
+				sets[key] = set
+				period.Children = append(period.Children, set)
+			}
+
+			rep := &xmlNode{Name: "Representation", Attrs: []xmlAttr{
+				{Name: "id", Value: r.ID},
+				{Name: "bandwidth", Value: strconv.Itoa(r.Bandwidth)},
+			}}
+			if r.Codecs != "" {
+				rep.setAttr("codecs", r.Codecs)
+			}
+			if r.Width > 0 && r.Height > 0 {
+				rep.setAttr("width", strconv.Itoa(r.Width))
+				rep.setAttr("height", strconv.Itoa(r.Height))
+			}
+			timescale := r.Timescale
+			if timescale <= 0 {
+				timescale = 1
+			}
+			rep.Children = append(rep.Children, &xmlNode{Name: "SegmentTemplate", Attrs: []xmlAttr{
+				{Name: "timescale", Value: strconv.Itoa(timescale)},
+				{Name: "duration", Value: strconv.Itoa(r.SegmentDuration)},
+				{Name: "startNumber", Value: "1"},
+				{Name: "initialization", Value: r.Initialization},
+				{Name: "media", Value: r.Media},
+			}})
+			set.Children = append(set.Children, rep)
+		}
+		periods = append(periods, period)
+	}
+	return periods
+}
+
+// spliceEventStream returns the EventStream signalling an ad break with a
+// SCTE-35 splice_insert at the start of its first Period
+func spliceEventStream(b *dashBreak) *xmlNode {
+	signal := &xmlNode{Name: "scte35:Signal", Children: []*xmlNode{{
+		Name: "scte35:Binary",
+		Text: base64.StdEncoding.EncodeToString(scte35SpliceInsert(b.eventID, true, b.total)),
+	}}}
+	event := &xmlNode{Name: "Event", Attrs: []xmlAttr{
+		{Name: "presentationTime", Value: "0"},
+		{Name: "duration", Value: strconv.FormatInt(int64(math.Round(b.total*scte35Timescale)), 10)},
+		{Name: "id", Value: strconv.FormatUint(uint64(b.eventID), 10)},
+	}, Children: []*xmlNode{signal}}
+	return &xmlNode{Name: "EventStream", Attrs: []xmlAttr{
+		{Name: "schemeIdUri", Value: scte35Scheme},
+		{Name: "timescale", Value: strconv.Itoa(scte35Timescale)},
+	}, Children: []*xmlNode{event}}
+}
+
+var xsDurationPattern = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseXSDuration parses an xs:duration without years or months into seconds
+func parseXSDuration(value string) (float64, error) {
+	match := xsDurationPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("%w: bad duration %q", ErrInvalidMPD, value)
+	}
+	seconds := 0.0
+	for i, unit := range []float64{86400, 3600, 60, 1} {
+		if match[i+1] != "" {
+			n, _ := strconv.ParseFloat(match[i+1], 64)
+			seconds += n * unit
+		}
+	}
+	return seconds, nil
+}
+
+// formatXSDuration formats seconds as an xs:duration with millisecond precision
+func formatXSDuration(seconds float64) string {
+	return "PT" + strconv.FormatFloat(math.Round(seconds*1000)/1000, 'f', -1, 64) + "S"
+}
+
+// durationAttr returns an xs:duration attribute in seconds
+func durationAttr(n *xmlNode, name string) (float64, bool) {
+	value, ok := n.attr(name)
+	if !ok {
+		return 0, false
+	}
+	seconds, err := parseXSDuration(value)
+	return seconds, err == nil
+}
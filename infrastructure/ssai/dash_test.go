@@ -0,0 +1,231 @@
+package ssai
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func creative(id string, seconds float64) AdCreative {
+	return AdCreative{
+		ID:       id,
+		Duration: seconds,
+		BaseURL:  "https://ads.example.com/" + id + "/",
+		Representations: []AdRepresentation{
+			{ID: id + "-720p", ContentType: "video", MimeType: "video/mp4", Codecs: "avc1.64001f", Bandwidth: 2500000, Width: 1280, Height: 720,
+				Timescale: 90000, SegmentDuration: 180000, Initialization: "720p/init.mp4", Media: "720p/$Number$.m4s"},
+			{ID: id + "-audio", ContentType: "audio", MimeType: "audio/mp4", Codecs: "mp4a.40.2", Lang: "en", Bandwidth: 128000,
+				Timescale: 48000, SegmentDuration: 96000, Initialization: "audio/init.mp4", Media: "audio/$Number$.m4s"},
+		},
+	}
+}
+
+func TestRewriteDASHManifestGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		mpd    string
+		golden string
+		breaks []AdBreak
+	}{
+		{
+			name:   "static SegmentTimeline",
+			mpd:    "static_timeline.mpd",
+			golden: "static_timeline_ads.mpd",
+			breaks: []AdBreak{
+				{Type: "pre-roll", Creatives: []AdCreative{creative("pre", 10)}},
+				// 7s is nearest the video boundary at 6s
+				{ID: "mid", Type: "mid-roll", StartTime: 7, Creatives: []AdCreative{creative("mid-a", 15), creative("mid-b", 15)}},
+				{Type: "post-roll", Creatives: []AdCreative{creative("post", 6)}},
+			},
+		},
+		{
+			name:   "static numbered SegmentTemplate",
+			mpd:    "static_numbered.mpd",
+			golden: "static_numbered_ads.mpd",
+			breaks: []AdBreak{
+				{Type: "mid-roll", StartTime: 21, Creatives: []AdCreative{creative("mid", 20)}},
+			},
+		},
+		{
+			name:   "dynamic",
+			mpd:    "dynamic.mpd",
+			golden: "dynamic_ads.mpd",
+			breaks: []AdBreak{
+				{Type: "mid-roll", StartTime: 20, Creatives: []AdCreative{creative("live", 30)}},
+				{Type: "post-roll", Creatives: []AdCreative{creative("never", 30)}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RewriteDASHManifest(readPlaylist(t, tt.mpd), tt.breaks)
+			if err != nil {
+				t.Fatalf("RewriteDASHManifest: %v", err)
+			}
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatalf("write golden: %v", err)
+				}
+			}
+			if want := readPlaylist(t, tt.golden); got != want {
+				t.Fatalf("MPD mismatch (run with -update to refresh)\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestRewriteDASHManifestSplit(t *testing.T) {
+	got, err := RewriteDASHManifest(readPlaylist(t, "static_timeline.mpd"), []AdBreak{
+		{StartTime: 7, Creatives: []AdCreative{creative("mid", 15)}},
+	})
+	if err != nil {
+		t.Fatalf("RewriteDASHManifest: %v", err)
+	}
+	mpd, err := parseXMLTree(got)
+	if err != nil {
+		t.Fatalf("stitched MPD does not parse: %v", err)
+	}
+
+	periods := mpd.childrenNamed("Period")
+	if len(periods) != 3 {
+		t.Fatalf("expected content, ad and content Periods, got %d", len(periods))
+	}
+	wantStarts := []string{"PT0S", "PT6S", "PT21S"}
+	for i, p := range periods {
+		if start, _ := p.attr("start"); start != wantStarts[i] {
+			t.Fatalf("Period %d starts at %s, want %s", i, start, wantStarts[i])
+		}
+	}
+	if d, _ := mpd.attr("mediaPresentationDuration"); d != "PT35.5S" {
+		t.Fatalf("expected the presentation to grow by the ad, got %s", d)
+	}
+
+	// Both content parts keep the AdaptationSet ids and share an asset
+	for _, i := range []int{0, 2} {
+		if asset := periods[i].child("AssetIdentifier"); asset == nil {
+			t.Fatalf("expected an AssetIdentifier in Period %d", i)
+		} else if value, _ := asset.attr("value"); value != "0" {
+			t.Fatalf("expected the asset of the original Period, got %s", value)
+		}
+		if id, _ := periods[i].childrenNamed("AdaptationSet")[0].attr("id"); id != "0" {
+			t.Fatalf("expected AdaptationSet id 0 in Period %d, got %s", i, id)
+		}
+	}
+	// The ad reuses the content's video and audio ids
+	adSets := periods[1].childrenNamed("AdaptationSet")
+	if video, _ := adSets[0].attr("id"); video != "0" {
+		t.Fatalf("expected the ad video to reuse AdaptationSet id 0, got %s", video)
+	}
+	if audio, _ := adSets[1].attr("id"); audio != "1" {
+		t.Fatalf("expected the ad audio to reuse AdaptationSet id 1, got %s", audio)
+	}
+
+	// The second content part starts at the split with the segments after it
+	template := periods[2].childrenNamed("AdaptationSet")[0].child("Representation").child("SegmentTemplate")
+	if pto, _ := template.attr("presentationTimeOffset"); pto != "540000" {
+		t.Fatalf("expected presentationTimeOffset 540000, got %s", pto)
+	}
+	if number, _ := template.attr("startNumber"); number != "2" {
+		t.Fatalf("expected startNumber 2, got %s", number)
+	}
+	if start, _ := template.child("SegmentTimeline").child("S").attr("t"); start != "540000" {
+		t.Fatalf("expected the timeline to start at 540000, got %s", start)
+	}
+
+	events := periods[1].child("EventStream")
+	if scheme, _ := events.attr("schemeIdUri"); scheme != scte35Scheme {
+		t.Fatalf("expected SCTE-35 signalling in the ad Period, got %s", scheme)
+	}
+}
+
+func TestTrimTimeline(t *testing.T) {
+	entries := []timelineEntry{{t: 0, d: 10, r: 2}, {t: 30, d: 5, r: 0}}
+
+	tests := []struct {
+		name        string
+		from, to    int64
+		wantEntries []timelineEntry
+		wantSkipped int64
+	}{
+		{"at a boundary", 20, -1, []timelineEntry{{t: 20, d: 10, r: 0}, {t: 30, d: 5, r: 0}}, 2},
+		{"inside a segment", 25, -1, []timelineEntry{{t: 20, d: 10, r: 0}, {t: 30, d: 5, r: 0}}, 2},
+		{"past a repeat", 32, -1, []timelineEntry{{t: 30, d: 5, r: 0}}, 3},
+		{"end at a boundary", 0, 20, []timelineEntry{{t: 0, d: 10, r: 1}}, 0},
+		{"end inside a segment", 0, 21, []timelineEntry{{t: 0, d: 10, r: 2}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := entries
+			var skipped int64
+			if tt.to >= 0 {
+				got = trimTimelineEnd(got, tt.to)
+			}
+			if tt.from > 0 {
+				got, skipped = trimTimelineStart(got, tt.from)
+			}
+			if len(got) != len(tt.wantEntries) || skipped != tt.wantSkipped {
+				t.Fatalf("got %v skipping %d, want %v skipping %d", got, skipped, tt.wantEntries, tt.wantSkipped)
+			}
+			for i := range got {
+				if got[i] != tt.wantEntries[i] {
+					t.Fatalf("got %v, want %v", got, tt.wantEntries)
+				}
+			}
+		})
+	}
+
+	// Open timelines stay open after the split and close before it
+	open := []timelineEntry{{t: 100, d: 10, r: -1}}
+	if got, skipped := trimTimelineStart(open, 125); len(got) != 1 || got[0] != (timelineEntry{t: 120, d: 10, r: -1}) || skipped != 2 {
+		t.Fatalf("unexpected open timeline start %v skipping %d", got, skipped)
+	}
+	if got := trimTimelineEnd(open, 125); len(got) != 1 || got[0] != (timelineEntry{t: 100, d: 10, r: 2}) {
+		t.Fatalf("unexpected open timeline end %v", got)
+	}
+}
+
+func TestRewriteDASHManifestErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		mpd  string
+	}{
+		{"not XML", "#EXTM3U\n"},
+		{"not an MPD", `<?xml version="1.0"?><Playlist/>`},
+		{"no Period", `<MPD type="static" mediaPresentationDuration="PT10S"/>`},
+		{"unknown duration", `<MPD type="static"><Period id="1"/></MPD>`},
+		{"SegmentList", `<MPD type="static" mediaPresentationDuration="PT10S"><Period><AdaptationSet><SegmentList duration="2"/></AdaptationSet></Period></MPD>`},
+	}
+	breaks := []AdBreak{{StartTime: 4, Creatives: []AdCreative{creative("ad", 10)}}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := RewriteDASHManifest(tt.mpd, breaks); !errors.Is(err, ErrInvalidMPD) {
+				t.Fatalf("expected ErrInvalidMPD, got %v", err)
+			}
+		})
+	}
+}
+
+func TestXSDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"PT0S", 0},
+		{"PT1M0S", 60},
+		{"PT0H0M20.500S", 20.5},
+		{"P1DT1H", 90000},
+	}
+	for _, tt := range tests {
+		if got, err := parseXSDuration(tt.value); err != nil || got != tt.want {
+			t.Fatalf("parseXSDuration(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"P", "PT", "P1Y", "10s"} {
+		if _, err := parseXSDuration(bad); err == nil {
+			t.Fatalf("expected an error for %q", bad)
+		}
+	}
+}
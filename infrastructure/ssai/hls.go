@@ -0,0 +1,269 @@
+package ssai
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidPlaylist is returned for manifests that are not HLS media playlists
+var ErrInvalidPlaylist = errors.New("invalid HLS media playlist")
+
+// HLS tag names used by the parser and the stitcher
+const (
+	tagVersion          = "EXT-X-VERSION"
+	tagTargetDuration   = "EXT-X-TARGETDURATION"
+	tagMediaSequence    = "EXT-X-MEDIA-SEQUENCE"
+	tagEndList          = "EXT-X-ENDLIST"
+	tagDiscontinuity    = "EXT-X-DISCONTINUITY"
+	tagKey              = "EXT-X-KEY"
+	tagMap              = "EXT-X-MAP"
+	tagProgramDateTime  = "EXT-X-PROGRAM-DATE-TIME"
+	tagDateRange        = "EXT-X-DATERANGE"
+	programDateTimeForm = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// playlistTags are the tags that apply to the whole playlist rather than to the
+// segment that follows them
+var playlistTags = map[string]bool{
+	tagVersion:                     true,
+	tagTargetDuration:              true,
+	tagMediaSequence:               true,
+	"EXT-X-DISCONTINUITY-SEQUENCE": true,
+	"EXT-X-PLAYLIST-TYPE":          true,
+	"EXT-X-INDEPENDENT-SEGMENTS":   true,
+	"EXT-X-START":                  true,
+	"EXT-X-I-FRAMES-ONLY":          true,
+	"EXT-X-ALLOW-CACHE":            true,
+	"EXT-X-SERVER-CONTROL":         true,
+	"EXT-X-PART-INF":               true,
+	"EXT-X-DEFINE":                 true,
+}
+
+// masterTags only appear in master playlists
+var masterTags = map[string]bool{
+	"EXT-X-STREAM-INF":         true,
+	"EXT-X-I-FRAME-STREAM-INF": true,
+	"EXT-X-MEDIA":              true,
+	"EXT-X-SESSION-DATA":       true,
+	"EXT-X-SESSION-KEY":        true,
+}
+
+// Segment is a media segment of an HLS media playlist
+type Segment struct {
+	Duration float64  // EXTINF duration in seconds
+	Title    string   // EXTINF title
+	URI      string   // segment URI, absolute or relative to the playlist
+	Tags     []string // tags before the EXTINF line (KEY, MAP, BYTERANGE, PROGRAM-DATE-TIME, ...)
+}
+
+// MediaPlaylist is a parsed HLS media playlist. Tags are kept verbatim so a
+// playlist that is parsed and written again is unchanged apart from the
+// formatting of EXTINF durations.
+type MediaPlaylist struct {
+	Header   []string // playlist tags
+	Segments []Segment
+	Trailer  []string // tags after the last segment, other than EXT-X-ENDLIST
+	EndList  bool
+}
+
+// ParseMediaPlaylist parses an HLS media playlist. Comments and blank lines are
+// dropped; master playlists are rejected.
+func ParseMediaPlaylist(manifest string) (*MediaPlaylist, error) {
+	lines := strings.Split(strings.ReplaceAll(manifest, "\r\n", "\n"), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "#EXTM3U" {
+		return nil, fmt.Errorf("%w: missing #EXTM3U", ErrInvalidPlaylist)
+	}
+
+	playlist := &MediaPlaylist{}
+	var pending []string
+	var extinf *Segment
+	for n, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXTINF:"):
+			duration, title, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			seconds, err := strconv.ParseFloat(strings.TrimSpace(duration), 64)
+			if err != nil || seconds < 0 {
+				return nil, fmt.Errorf("%w: line %d: bad EXTINF %q", ErrInvalidPlaylist, n+2, line)
+			}
+			extinf = &Segment{Duration: seconds, Title: title}
+		case strings.HasPrefix(line, "#EXT"):
+			name := tagName(line)
+			switch {
+			case masterTags[name]:
+				return nil, fmt.Errorf("%w: %s is a master playlist tag", ErrInvalidPlaylist, name)
+			case name == tagEndList:
+				playlist.EndList = true
+			case playlistTags[name]:
+				playlist.Header = append(playlist.Header, line)
+			default:
+				pending = append(pending, line)
+			}
+		case strings.HasPrefix(line, "#"):
+			// Comment
+		default:
+			if extinf == nil {
+				return nil, fmt.Errorf("%w: line %d: segment %q has no EXTINF", ErrInvalidPlaylist, n+2, line)
+			}
+			extinf.URI = line
+			extinf.Tags = pending
+			playlist.Segments = append(playlist.Segments, *extinf)
+			pending, extinf = nil, nil
+		}
+	}
+	if extinf != nil {
+		return nil, fmt.Errorf("%w: EXTINF without a segment URI", ErrInvalidPlaylist)
+	}
+	playlist.Trailer = pending
+	return playlist, nil
+}
+
+// Duration returns the total duration of the playlist in seconds
+func (p *MediaPlaylist) Duration() float64 {
+	total := 0.0
+	for _, s := range p.Segments {
+		total += s.Duration
+	}
+	return total
+}
+
+// String writes the playlist
+func (p *MediaPlaylist) String() string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	for _, tag := range p.Header {
+		b.WriteString(tag + "\n")
+	}
+	for _, s := range p.Segments {
+		for _, tag := range s.Tags {
+			b.WriteString(tag + "\n")
+		}
+		fmt.Fprintf(&b, "#EXTINF:%s,%s\n", formatDuration(s.Duration), s.Title)
+		b.WriteString(s.URI + "\n")
+	}
+	for _, tag := range p.Trailer {
+		b.WriteString(tag + "\n")
+	}
+	if p.EndList {
+		b.WriteString("#" + tagEndList + "\n")
+	}
+	return b.String()
+}
+
+// headerInt returns the integer value of a playlist tag
+func (p *MediaPlaylist) headerInt(name string) (int, bool) {
+	for _, tag := range p.Header {
+		if tagName(tag) == name {
+			value, err := strconv.Atoi(tagValue(tag))
+			return value, err == nil
+		}
+	}
+	return 0, false
+}
+
+// raiseHeaderInt sets an integer playlist tag to at least min, adding it after
+// the existing tags when it is missing
+func (p *MediaPlaylist) raiseHeaderInt(name string, min int) {
+	for i, tag := range p.Header {
+		if tagName(tag) == name {
+			if value, err := strconv.Atoi(tagValue(tag)); err != nil || value < min {
+				p.Header[i] = fmt.Sprintf("#%s:%d", name, min)
+			}
+			return
+		}
+	}
+	p.Header = append(p.Header, fmt.Sprintf("#%s:%d", name, min))
+}
+
+// tagName returns the name of a tag line without the leading '#'
+func tagName(line string) string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(line, "#"), ":")
+	return name
+}
+
+// tagValue returns the part of a tag line after the colon
+func tagValue(line string) string {
+	_, value, _ := strings.Cut(line, ":")
+	return value
+}
+
+// findTag returns the last tag with the name
+func findTag(tags []string, name string) (string, bool) {
+	found, ok := "", false
+	for _, tag := range tags {
+		if tagName(tag) == name {
+			found, ok = tag, true
+		}
+	}
+	return found, ok
+}
+
+// withoutTags returns the tags other than those with the names
+func withoutTags(tags []string, names ...string) []string {
+	var kept []string
+	for _, tag := range tags {
+		drop := false
+		for _, name := range names {
+			if tagName(tag) == name {
+				drop = true
+			}
+		}
+		if !drop {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
+
+// parseAttributes parses an HLS attribute list such as METHOD=AES-128,URI="k".
+// Quotes are removed from quoted-string values.
+func parseAttributes(list string) map[string]string {
+	attrs := make(map[string]string)
+	for len(list) > 0 {
+		name, rest, ok := strings.Cut(list, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			rest = strings.TrimPrefix(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.TrimSpace(name)] = value
+		list = rest
+	}
+	return attrs
+}
+
+// parseDateTime parses an EXT-X-PROGRAM-DATE-TIME value, with or without a
+// colon in the zone offset
+func parseDateTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		t, err = time.Parse("2006-01-02T15:04:05.999999999Z0700", value)
+	}
+	return t, err
+}
+
+// formatDuration formats a duration in seconds with millisecond precision
+func formatDuration(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
+
+// addSeconds adds a fractional number of seconds to a time
+func addSeconds(t time.Time, seconds float64) time.Time {
+	return t.Add(time.Duration(math.Round(seconds * float64(time.Second))))
+}
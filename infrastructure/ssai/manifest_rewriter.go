@@ -3,88 +3,245 @@ package ssai
 /**
  * SSAI Manifest Rewriter
  * Issue #26: SSAI (Server-Side Ad Insertion) Setup
- * 
+ *
  * Rewrites HLS/DASH manifests to include ad breaks
  */
 
 import (
-	"strings"
 	"fmt"
+	"math"
+	"time"
 )
 
+// AdSegment is a media segment of an ad creative
+type AdSegment struct {
+	URI      string
+	Duration float64 // seconds
+}
+
 // AdBreak represents an ad break to insert
 type AdBreak struct {
-	StartTime   float64 // seconds from start
-	Duration    float64 // seconds
-	AdSegments  []string // URLs of ad segments
-	Type        string // "pre-roll", "mid-roll", "post-roll"
+	ID          string       // identifies the break in EXT-X-DATERANGE markers, "ad-<n>" when empty
+	StartTime   float64      // seconds from start
+	Duration    float64      // planned duration in seconds
+	AdSegments  []AdSegment  // segments without a duration get an equal share of the planned duration
+	InitSegment string       // EXT-X-MAP URI of fMP4 ad segments
+	Creatives   []AdCreative // DASH encodes of the ads, one Period each
+	Type        string       // "pre-roll", "mid-roll", "post-roll"
+}
+
+// RewriteHLSManifest inserts ad breaks into an HLS media playlist. Mid-rolls are
+// placed at the segment boundary nearest to their start time, between
+// EXT-X-DISCONTINUITY tags, and marked with EXT-X-DATERANGE SCTE35-OUT and
+// SCTE35-IN tags. Program date-times after a break are shifted by the ads before
+// them. The markers need a start date, so a playlist without program date-times
+// gets them, its first segment dated at start, or at the time of the request when
+// start is zero; live packagers date their segments themselves.
+// Encryption keys and init sections are cleared for the ads and restored after
+// them, with explicit IVs where the content derived them from media sequence
+// numbers that the ads have shifted.
+func RewriteHLSManifest(manifest string, adBreaks []AdBreak, start time.Time) (string, error) {
+	playlist, err := ParseMediaPlaylist(manifest)
+	if err != nil {
+		return "", err
+	}
+	if start.IsZero() {
+		start = time.Now()
+	}
+	return stitchHLS(playlist, adBreaks, start).String(), nil
 }
 
-// RewriteHLSManifest inserts ad breaks into HLS manifest
-func RewriteHLSManifest(manifest string, adBreaks []AdBreak) string {
-	lines := strings.Split(manifest, "\n")
-	var result []string
-	
-	result = append(result, "#EXTM3U")
-	result = append(result, "#EXT-X-VERSION:3")
-	
-	// Insert pre-roll ads
-	for _, adBreak := range adBreaks {
-		if adBreak.Type == "pre-roll" {
-			// Insert ad break before content
-			result = append(result, fmt.Sprintf("#EXT-X-CUE-OUT:%.1f", adBreak.Duration))
-			for _, segment := range adBreak.AdSegments {
-				result = append(result, "#EXTINF:6.0,")
-				result = append(result, segment)
+// hlsBreak is an ad break placed at a segment boundary
+type hlsBreak struct {
+	AdBreak
+	eventID   uint32
+	durations []float64
+	total     float64
+	start     time.Time
+}
+
+// stitchHLS returns a copy of the playlist with the ad breaks inserted. start
+// dates the first segment when no segment carries a program date-time.
+func stitchHLS(playlist *MediaPlaylist, adBreaks []AdBreak, start time.Time) *MediaPlaylist {
+	// starts[i] is the media time of the boundary before segment i
+	starts := make([]float64, len(playlist.Segments)+1)
+	for i, s := range playlist.Segments {
+		starts[i+1] = starts[i] + s.Duration
+	}
+
+	breaks := make(map[int][]*hlsBreak)
+	inserted := false
+	for i, b := range adBreaks {
+		if len(b.AdSegments) == 0 {
+			continue
+		}
+		placed := &hlsBreak{AdBreak: b, eventID: uint32(i + 1)}
+		if placed.ID == "" {
+			placed.ID = fmt.Sprintf("ad-%d", i+1)
+		}
+		for _, segment := range b.AdSegments {
+			duration := segment.Duration
+			if duration <= 0 {
+				duration = b.Duration / float64(len(b.AdSegments))
 			}
-			result = append(result, "#EXT-X-CUE-IN")
+			placed.durations = append(placed.durations, duration)
+			placed.total += duration
 		}
+		boundary := breakBoundary(b, starts)
+		breaks[boundary] = append(breaks[boundary], placed)
+		inserted = true
 	}
-	
-	// Insert content segments
-	for i, line := range lines {
-		if strings.HasPrefix(line, "#EXTINF") || strings.HasPrefix(line, "http") {
-			// Check if we need to insert mid-roll ad
-			currentTime := float64(i * 6) // Approximate time
-			for _, adBreak := range adBreaks {
-				if adBreak.Type == "mid-roll" && 
-				   currentTime >= adBreak.StartTime && 
-				   currentTime < adBreak.StartTime+adBreak.Duration {
-					// Insert mid-roll ad break
-					result = append(result, fmt.Sprintf("#EXT-X-CUE-OUT:%.1f", adBreak.Duration))
-					for _, segment := range adBreak.AdSegments {
-						result = append(result, "#EXTINF:6.0,")
-						result = append(result, segment)
-					}
-					result = append(result, "#EXT-X-CUE-IN")
-				}
+	if !inserted {
+		return playlist
+	}
+
+	// dates[i] is the program date-time of the boundary before segment i. Segments
+	// before the first dated one are dated back from it.
+	dates := make([]time.Time, len(starts))
+	date := start.UTC()
+	for i, s := range playlist.Segments {
+		if tag, ok := findTag(s.Tags, tagProgramDateTime); ok {
+			if t, err := parseDateTime(tagValue(tag)); err == nil {
+				date = addSeconds(t, -starts[i])
+				break
 			}
-			result = append(result, line)
-		} else if strings.HasPrefix(line, "#EXT") {
-			result = append(result, line)
 		}
 	}
-	
-	// Insert post-roll ads
-	for _, adBreak := range adBreaks {
-		if adBreak.Type == "post-roll" {
-			result = append(result, fmt.Sprintf("#EXT-X-CUE-OUT:%.1f", adBreak.Duration))
-			for _, segment := range adBreak.AdSegments {
-				result = append(result, "#EXTINF:6.0,")
-				result = append(result, segment)
+	for i, s := range playlist.Segments {
+		if tag, ok := findTag(s.Tags, tagProgramDateTime); ok {
+			if t, err := parseDateTime(tagValue(tag)); err == nil {
+				date = t
 			}
-			result = append(result, "#EXT-X-CUE-IN")
 		}
+		dates[i] = date
+		date = addSeconds(date, s.Duration)
+	}
+	dates[len(playlist.Segments)] = date
+
+	mediaSequence, _ := playlist.headerInt(tagMediaSequence)
+	stitched := &MediaPlaylist{
+		Header:  append([]string(nil), playlist.Header...),
+		Trailer: playlist.Trailer,
+		EndList: playlist.EndList,
 	}
-	
-	result = append(result, "#EXT-X-ENDLIST")
-	return strings.Join(result, "\n")
+	usesMap := false
+	offset := 0.0 // seconds of ads inserted so far
+	var contentKey, contentMap string
+	for i := 0; i <= len(playlist.Segments); i++ {
+		atBoundary := breaks[i]
+		for _, b := range atBoundary {
+			var tags []string
+			if len(stitched.Segments) > 0 {
+				tags = append(tags, "#"+tagDiscontinuity)
+			}
+			if encrypts(contentKey) {
+				tags = append(tags, "#"+tagKey+":METHOD=NONE")
+			}
+			if b.InitSegment != "" {
+				tags = append(tags, fmt.Sprintf(`#%s:URI="%s"`, tagMap, b.InitSegment))
+				usesMap = true
+			}
+			b.start = addSeconds(dates[i], offset)
+			tags = append(tags,
+				"#"+tagProgramDateTime+":"+b.start.Format(programDateTimeForm),
+				dateRangeTag(b, "SCTE35-OUT", scte35SpliceInsert(b.eventID, true, b.total)),
+			)
+			for j, segment := range b.AdSegments {
+				stitched.Segments = append(stitched.Segments, Segment{Duration: b.durations[j], URI: segment.URI, Tags: tags})
+				tags = nil
+			}
+			offset += b.total
+		}
+		if i == len(playlist.Segments) {
+			break
+		}
+
+		segment := playlist.Segments[i]
+		own := segment.Tags
+		if tag, ok := findTag(own, tagKey); ok {
+			contentKey = tag
+		}
+		if tag, ok := findTag(own, tagMap); ok {
+			contentMap = tag
+		}
+
+		var tags []string
+		if len(atBoundary) > 0 {
+			if _, ok := findTag(own, tagDiscontinuity); !ok {
+				tags = append(tags, "#"+tagDiscontinuity)
+			}
+			if _, ok := findTag(own, tagMap); !ok && contentMap != "" {
+				tags = append(tags, contentMap)
+			}
+		}
+		_, ownKey := findTag(own, tagKey)
+		shifted := offset > 0 && encrypts(contentKey) && parseAttributes(tagValue(contentKey))["IV"] == ""
+		if shifted {
+			// The IV of an AES-128 segment without one is its media sequence number
+			tags = append(tags, fmt.Sprintf("%s,IV=0x%032X", contentKey, mediaSequence+i))
+			own = withoutTags(own, tagKey)
+		} else if len(atBoundary) > 0 && !ownKey && contentKey != "" {
+			tags = append(tags, contentKey)
+		}
+		if _, ok := findTag(own, tagProgramDateTime); ok || len(atBoundary) > 0 || len(stitched.Segments) == 0 {
+			tags = append(tags, "#"+tagProgramDateTime+":"+addSeconds(dates[i], offset).Format(programDateTimeForm))
+			own = withoutTags(own, tagProgramDateTime)
+		}
+		for _, b := range atBoundary {
+			tags = append(tags, dateRangeTag(b, "SCTE35-IN", scte35SpliceInsert(b.eventID, false, 0)))
+		}
+
+		segment.Tags = append(tags, own...)
+		stitched.Segments = append(stitched.Segments, segment)
+	}
+
+	// Fractional EXTINF durations need version 3, EXT-X-MAP in a media playlist version 6
+	version := 3
+	if usesMap {
+		version = 6
+	}
+	stitched.raiseHeaderInt(tagVersion, version)
+	targetDuration := 0
+	for _, s := range stitched.Segments {
+		if d := int(math.Round(s.Duration)); d > targetDuration {
+			targetDuration = d
+		}
+	}
+	stitched.raiseHeaderInt(tagTargetDuration, targetDuration)
+	return stitched
 }
 
-// RewriteDASHManifest inserts ad breaks into DASH manifest
-func RewriteDASHManifest(manifest string, adBreaks []AdBreak) string {
-	// TODO: Implement DASH manifest rewriting
-	// Insert ad periods between content periods
-	return manifest
+// breakBoundary returns the index of the segment an ad break is inserted before,
+// len(starts)-1 for the end of the playlist. Ties go to the earlier boundary.
+func breakBoundary(b AdBreak, starts []float64) int {
+	switch b.Type {
+	case "pre-roll":
+		return 0
+	case "post-roll":
+		return len(starts) - 1
+	}
+	nearest := 0
+	for i, start := range starts {
+		if math.Abs(start-b.StartTime) < math.Abs(starts[nearest]-b.StartTime) {
+			nearest = i
+		}
+	}
+	return nearest
+}
+
+// encrypts reports whether an EXT-X-KEY tag encrypts the segments it applies to
+func encrypts(keyTag string) bool {
+	return keyTag != "" && parseAttributes(tagValue(keyTag))["METHOD"] != "NONE"
 }
 
+// dateRangeTag returns the EXT-X-DATERANGE tag marking the start or the end of an
+// ad break. Both carry the same attributes apart from the SCTE-35 command.
+func dateRangeTag(b *hlsBreak, scte35Attribute string, splice []byte) string {
+	planned := b.Duration
+	if planned <= 0 {
+		planned = b.total
+	}
+	return fmt.Sprintf(`#%s:ID="%s",START-DATE="%s",PLANNED-DURATION=%s,DURATION=%s,%s=0x%X`,
+		tagDateRange, b.ID, b.start.Format(programDateTimeForm), formatDuration(planned), formatDuration(b.total),
+		scte35Attribute, splice)
+}
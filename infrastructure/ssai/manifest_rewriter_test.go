@@ -0,0 +1,199 @@
+package ssai
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// testStart dates playlists without program date-times
+var testStart = time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+
+func readPlaylist(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func adSegments(prefix string, durations ...float64) []AdSegment {
+	var segments []AdSegment
+	for i, d := range durations {
+		segments = append(segments, AdSegment{URI: prefix + "/" + string(rune('a'+i)) + ".ts", Duration: d})
+	}
+	return segments
+}
+
+func TestRewriteHLSManifestGolden(t *testing.T) {
+	tests := []struct {
+		name     string
+		playlist string
+		golden   string
+		breaks   []AdBreak
+	}{
+		{
+			name:     "pre-roll, mid-roll and post-roll",
+			playlist: "vod.m3u8",
+			golden:   "vod_ads.m3u8",
+			breaks: []AdBreak{
+				{Type: "pre-roll", Duration: 15, AdSegments: adSegments("https://ads.example.com/pre", 7.5, 7.5)},
+				// 14s is nearest the boundary after two segments (12.012s)
+				{ID: "break-mid", Type: "mid-roll", StartTime: 14, Duration: 30, AdSegments: adSegments("https://ads.example.com/mid", 10, 10, 9.97)},
+				{Type: "post-roll", Duration: 10, AdSegments: adSegments("https://ads.example.com/post", 0, 0)},
+			},
+		},
+		{
+			name:     "AES-128 without IVs",
+			playlist: "vod_aes.m3u8",
+			golden:   "vod_aes_ads.m3u8",
+			breaks: []AdBreak{
+				{Type: "mid-roll", StartTime: 20, Duration: 15, AdSegments: adSegments("https://ads.example.com/mid", 15)},
+			},
+		},
+		{
+			name:     "fMP4 with program date-times",
+			playlist: "fmp4_pdt.m3u8",
+			golden:   "fmp4_pdt_ads.m3u8",
+			breaks: []AdBreak{
+				{Type: "mid-roll", StartTime: 7, Duration: 12, InitSegment: "https://ads.example.com/mid/init.mp4",
+					AdSegments: []AdSegment{{URI: "https://ads.example.com/mid/1.m4s", Duration: 6}, {URI: "https://ads.example.com/mid/2.m4s", Duration: 6}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RewriteHLSManifest(readPlaylist(t, tt.playlist), tt.breaks, testStart)
+			if err != nil {
+				t.Fatalf("RewriteHLSManifest: %v", err)
+			}
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatalf("write golden: %v", err)
+				}
+			}
+			if want := readPlaylist(t, tt.golden); got != want {
+				t.Fatalf("playlist mismatch (run with -update to refresh)\ngot:\n%s\nwant:\n%s", got, want)
+			}
+			if _, err := ParseMediaPlaylist(got); err != nil {
+				t.Fatalf("stitched playlist does not parse: %v", err)
+			}
+		})
+	}
+}
+
+func TestRewriteHLSManifestTiming(t *testing.T) {
+	tests := []struct {
+		name      string
+		breaks    []AdBreak
+		wantAfter string // content segment the first ad follows, "" for a pre-roll
+		duration  float64
+	}{
+		{"nearest boundary rounds down", []AdBreak{{StartTime: 14, AdSegments: adSegments("ad", 5)}}, "segment_00001.ts", 5},
+		{"nearest boundary rounds up", []AdBreak{{StartTime: 17, AdSegments: adSegments("ad", 5)}}, "segment_00002.ts", 5},
+		{"start time zero", []AdBreak{{StartTime: 0, AdSegments: adSegments("ad", 5)}}, "", 5},
+		{"past the end", []AdBreak{{StartTime: 600, AdSegments: adSegments("ad", 5)}}, "segment_00005.ts", 5},
+		{"equal shares of the planned duration", []AdBreak{{StartTime: 6, Duration: 20, AdSegments: adSegments("ad", 0, 0, 0, 0)}}, "segment_00000.ts", 20},
+	}
+
+	content := readPlaylist(t, "vod.m3u8")
+	source, _ := ParseMediaPlaylist(content)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest, err := RewriteHLSManifest(content, tt.breaks, testStart)
+			if err != nil {
+				t.Fatalf("RewriteHLSManifest: %v", err)
+			}
+			stitched, _ := ParseMediaPlaylist(manifest)
+
+			after := ""
+			for _, s := range stitched.Segments {
+				if strings.HasPrefix(s.URI, "ad/") {
+					break
+				}
+				after = s.URI
+			}
+			if after != tt.wantAfter {
+				t.Fatalf("expected the break after %q, got %q", tt.wantAfter, after)
+			}
+			if got := stitched.Duration() - source.Duration(); got < tt.duration-0.001 || got > tt.duration+0.001 {
+				t.Fatalf("expected %.3fs of ads, got %.3fs", tt.duration, got)
+			}
+		})
+	}
+}
+
+func TestRewriteHLSManifestDatesBackFromProgramDateTime(t *testing.T) {
+	content := "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6.000,\na.ts\n#EXT-X-PROGRAM-DATE-TIME:2024-05-01T12:00:06.000Z\n#EXTINF:6.000,\nb.ts\n"
+	got, err := RewriteHLSManifest(content, []AdBreak{{Type: "pre-roll", AdSegments: adSegments("ad", 5)}}, testStart)
+	if err != nil {
+		t.Fatalf("RewriteHLSManifest: %v", err)
+	}
+	// The break is dated from the playlist's own program date-times, not from testStart
+	if !strings.Contains(got, `START-DATE="2024-05-01T12:00:00.000Z"`) || !strings.Contains(got, "#EXT-X-PROGRAM-DATE-TIME:2024-05-01T12:00:05.000Z\n") {
+		t.Fatalf("expected dates derived from the playlist, got:\n%s", got)
+	}
+}
+
+func TestRewriteHLSManifestWithoutBreaks(t *testing.T) {
+	content := readPlaylist(t, "fmp4_pdt.m3u8")
+	got, err := RewriteHLSManifest(content, []AdBreak{{StartTime: 4}}, testStart)
+	if err != nil {
+		t.Fatalf("RewriteHLSManifest: %v", err)
+	}
+	if got != content {
+		t.Fatalf("expected breaks without segments to leave the playlist unchanged, got:\n%s", got)
+	}
+}
+
+func TestParseMediaPlaylistErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{"not a playlist", "segment.ts\n"},
+		{"master playlist", "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n720p.m3u8\n"},
+		{"segment without EXTINF", "#EXTM3U\n#EXT-X-TARGETDURATION:6\nsegment.ts\n"},
+		{"bad duration", "#EXTM3U\n#EXTINF:six,\nsegment.ts\n"},
+		{"EXTINF without a segment", "#EXTM3U\n#EXTINF:6.0,\n#EXT-X-ENDLIST\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := RewriteHLSManifest(tt.manifest, nil, testStart); !errors.Is(err, ErrInvalidPlaylist) {
+				t.Fatalf("expected ErrInvalidPlaylist, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSCTE35SpliceInsert(t *testing.T) {
+	out := scte35SpliceInsert(1, true, 30)
+	if out[0] != 0xFC || out[13] != 0x05 {
+		t.Fatalf("expected a splice_insert section, got %X", out)
+	}
+	if sectionLength := int(out[1]&0x0F)<<8 | int(out[2]); sectionLength != len(out)-3 {
+		t.Fatalf("section_length %d does not match %d bytes", sectionLength, len(out)-3)
+	}
+	// The CRC over a section including its CRC is zero
+	if crc32MPEG2(out) != 0 {
+		t.Fatalf("bad CRC in %X", out)
+	}
+	// break_duration in 90 kHz ticks
+	if ticks := int(out[20]&0x01)<<32 | int(out[21])<<24 | int(out[22])<<16 | int(out[23])<<8 | int(out[24]); ticks != 2700000 {
+		t.Fatalf("expected 2700000 ticks, got %d", ticks)
+	}
+
+	in := scte35SpliceInsert(1, false, 0)
+	if in[19]&0x80 != 0 || in[19]&0x20 != 0 || len(in) != len(out)-5 {
+		t.Fatalf("expected a return splice without a duration, got %X", in)
+	}
+}
@@ -0,0 +1,61 @@
+package ssai
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// scte35SpliceInsert encodes a SCTE-35 splice_info_section carrying an immediate
+// program splice_insert. Out-of-network splices carry the break duration with
+// auto-return set; return splices carry no duration.
+func scte35SpliceInsert(eventID uint32, outOfNetwork bool, duration float64) []byte {
+	command := binary.BigEndian.AppendUint32(nil, eventID)
+	command = append(command, 0x7F) // splice_event_cancel_indicator=0, reserved
+
+	flags := byte(0x40 | 0x10 | 0x0F) // program_splice_flag, splice_immediate_flag, reserved
+	if outOfNetwork {
+		flags |= 0x80
+	}
+	if outOfNetwork && duration > 0 {
+		flags |= 0x20 // duration_flag
+	}
+	command = append(command, flags)
+	if flags&0x20 != 0 {
+		ticks := uint64(math.Round(duration*90000)) & (1<<33 - 1)
+		// auto_return=1, reserved, 33-bit duration in 90 kHz ticks
+		command = append(command, 0x80|0x7E|byte(ticks>>32), byte(ticks>>24), byte(ticks>>16), byte(ticks>>8), byte(ticks))
+	}
+	command = append(command, 0x00, 0x00, 0x00, 0x00) // unique_program_id, avail_num, avails_expected
+
+	// protocol_version through splice_command_length, the command, the empty
+	// descriptor loop and the CRC
+	sectionLength := 1 + 5 + 1 + 3 + 1 + len(command) + 2 + 4
+	section := []byte{
+		0xFC, // table_id
+		0x30 | byte(sectionLength>>8&0x0F), byte(sectionLength),
+		0x00,                         // protocol_version
+		0x00, 0x00, 0x00, 0x00, 0x00, // encrypted_packet, encryption_algorithm, pts_adjustment
+		0x00,                                                        // cw_index
+		0xFF, 0xF0 | byte(len(command)>>8&0x0F), byte(len(command)), // tier, splice_command_length
+		0x05, // splice_insert
+	}
+	section = append(section, command...)
+	section = append(section, 0x00, 0x00) // descriptor_loop_length
+	return binary.BigEndian.AppendUint32(section, crc32MPEG2(section))
+}
+
+// crc32MPEG2 computes the CRC-32/MPEG-2 checksum used by SCTE-35 sections
+func crc32MPEG2(data []byte) uint32 {
+	crc := uint32(0xFFFFFFFF)
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,201 @@
+package ssai
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// xmlAttr is an attribute of an XML element. The name keeps its namespace prefix.
+type xmlAttr struct {
+	Name  string
+	Value string
+}
+
+// xmlNode is an element of an XML document. Names keep their namespace prefixes
+// and attributes their order, so a document is written back the way it was read
+// apart from whitespace and comments.
+type xmlNode struct {
+	Name     string
+	Attrs    []xmlAttr
+	Children []*xmlNode
+	Text     string
+}
+
+// parseXMLTree parses an XML document into its root element
+func parseXMLTree(document string) (*xmlNode, error) {
+	decoder := xml.NewDecoder(strings.NewReader(document))
+	var root *xmlNode
+	var stack []*xmlNode
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{Name: qualifiedName(t.Name)}
+			for _, a := range t.Attr {
+				node.Attrs = append(node.Attrs, xmlAttr{Name: qualifiedName(a.Name), Value: a.Value})
+			}
+			switch {
+			case len(stack) > 0:
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, node)
+			case root == nil:
+				root = node
+			default:
+				return nil, errors.New("more than one root element")
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) == 0 || stack[len(stack)-1].Name != qualifiedName(t.Name) {
+				return nil, fmt.Errorf("unexpected end element %s", qualifiedName(t.Name))
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += strings.TrimSpace(string(t))
+			}
+		}
+	}
+	if root == nil || len(stack) > 0 {
+		return nil, errors.New("incomplete document")
+	}
+	return root, nil
+}
+
+// String writes the element as an indented XML document
+func (n *xmlNode) String() string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	n.write(&b, 0)
+	return b.String()
+}
+
+func (n *xmlNode) write(b *strings.Builder, depth int) {
+	indent := strings.Repeat("  ", depth)
+	b.WriteString(indent + "<" + n.Name)
+	for _, a := range n.Attrs {
+		b.WriteString(" " + a.Name + `="`)
+		xml.EscapeText(b, []byte(a.Value))
+		b.WriteString(`"`)
+	}
+	switch {
+	case len(n.Children) == 0 && n.Text == "":
+		b.WriteString("/>\n")
+	case len(n.Children) == 0:
+		b.WriteString(">")
+		xml.EscapeText(b, []byte(n.Text))
+		b.WriteString("</" + n.Name + ">\n")
+	default:
+		b.WriteString(">\n")
+		if n.Text != "" {
+			b.WriteString(indent + "  ")
+			xml.EscapeText(b, []byte(n.Text))
+			b.WriteString("\n")
+		}
+		for _, c := range n.Children {
+			c.write(b, depth+1)
+		}
+		b.WriteString(indent + "</" + n.Name + ">\n")
+	}
+}
+
+// clone returns a deep copy of the element
+func (n *xmlNode) clone() *xmlNode {
+	c := &xmlNode{Name: n.Name, Text: n.Text, Attrs: append([]xmlAttr(nil), n.Attrs...)}
+	for _, child := range n.Children {
+		c.Children = append(c.Children, child.clone())
+	}
+	return c
+}
+
+// attr returns the value of an attribute
+func (n *xmlNode) attr(name string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// setAttr sets an attribute, adding it after the existing ones when it is missing
+func (n *xmlNode) setAttr(name, value string) {
+	for i, a := range n.Attrs {
+		if a.Name == name {
+			n.Attrs[i].Value = value
+			return
+		}
+	}
+	n.Attrs = append(n.Attrs, xmlAttr{Name: name, Value: value})
+}
+
+// removeAttr removes an attribute
+func (n *xmlNode) removeAttr(name string) {
+	for i, a := range n.Attrs {
+		if a.Name == name {
+			n.Attrs = append(n.Attrs[:i], n.Attrs[i+1:]...)
+			return
+		}
+	}
+}
+
+// child returns the first child element with the local name
+func (n *xmlNode) child(name string) *xmlNode {
+	for _, c := range n.Children {
+		if localName(c.Name) == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// childrenNamed returns the child elements with the local name
+func (n *xmlNode) childrenNamed(name string) []*xmlNode {
+	var children []*xmlNode
+	for _, c := range n.Children {
+		if localName(c.Name) == name {
+			children = append(children, c)
+		}
+	}
+	return children
+}
+
+// insertBefore inserts a child before the first child with one of the local
+// names, or appends it when there is none. Schemas such as the MPD's fix the
+// order of child elements.
+func (n *xmlNode) insertBefore(child *xmlNode, names ...string) {
+	for i, c := range n.Children {
+		for _, name := range names {
+			if localName(c.Name) == name {
+				n.Children = append(n.Children[:i], append([]*xmlNode{child}, n.Children[i:]...)...)
+				return
+			}
+		}
+	}
+	n.Children = append(n.Children, child)
+}
+
+// qualifiedName returns a name with its namespace prefix, as returned by RawToken
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// localName returns a name without its namespace prefix
+func localName(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -50,6 +50,43 @@ func (c *RedisClient) Get(ctx context.Context, key string, dest interface{}) err
 	return json.Unmarshal([]byte(val), dest)
 }
 
+// Incr increments the counter at key and returns its new value. A new counter
+// expires after expiration, so counters count within a fixed window.
+func (c *RedisClient) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	pipe := c.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, expiration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// incrBelow increments KEYS[1] unless it has reached ARGV[1], setting a new
+// counter to expire after ARGV[2] milliseconds, and returns 1 if it did
+var incrBelow = redis.NewScript(`
+local count = tonumber(redis.call("GET", KEYS[1]) or "0")
+if count >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) == -1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// IncrBelow increments the counter at key unless it has already reached limit,
+// reporting whether it did. The check and the increment are atomic, and a new
+// counter expires after expiration like with Incr.
+func (c *RedisClient) IncrBelow(ctx context.Context, key string, limit int64, expiration time.Duration) (bool, error) {
+	incremented, err := incrBelow.Run(ctx, c.client, []string{key}, limit, expiration.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return incremented == 1, nil
+}
+
 func (c *RedisClient) Del(ctx context.Context, key string) error {
 	return c.client.Del(ctx, key).Err()
 }
@@ -12,6 +12,9 @@ const insecureDefaultJWTSecret = "dev-only-change-me"
 
 // Config holds application configuration
 type Config struct {
+	// Environment is ENVIRONMENT, or APP_ENV, in lower case; "development" when
+	// neither is set
+	Environment        string
 	Server             ServerConfig
 	Database           DatabaseConfig
 	Redis              RedisConfig
@@ -27,6 +30,12 @@ type ServerConfig struct {
 	Host         string
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+	// TrustedProxies are the proxy IPs or CIDRs whose forwarding headers name the
+	// client IP. None are trusted by default, so the client IP is the peer address.
+	TrustedProxies []string
+	// TrustedPlatform is a header set by the platform in front of the service
+	// carrying the client IP, e.g. CF-Connecting-IP
+	TrustedPlatform string
 }
 
 // DatabaseConfig holds database configuration
@@ -62,11 +71,16 @@ type LoggingConfig struct {
 // Load loads configuration from environment variables
 func Load() *Config {
 	cfg := &Config{
+		Environment: strings.ToLower(strings.TrimSpace(getEnv("ENVIRONMENT", getEnv("APP_ENV", "development")))),
 		Server: ServerConfig{
 			Port:         getEnv("SERVER_PORT", "8080"),
 			Host:         getEnv("SERVER_HOST", "0.0.0.0"),
 			ReadTimeout:  getDurationEnv("SERVER_READ_TIMEOUT", 30*time.Second),
 			WriteTimeout: getDurationEnv("SERVER_WRITE_TIMEOUT", 30*time.Second),
+
+			// Comma-separated IPs or CIDRs, e.g. the load balancer's subnet
+			TrustedProxies:  getListEnv("SERVER_TRUSTED_PROXIES"),
+			TrustedPlatform: getEnv("SERVER_TRUSTED_PLATFORM", ""),
 		},
 		Database: DatabaseConfig{
 			URI:            getEnv("DATABASE_URI", "mongodb://localhost:27017"),
@@ -101,19 +115,23 @@ func Load() *Config {
 	return cfg
 }
 
+// IsDevelopment reports whether the service runs in a development environment,
+// where insecure defaults and debugging aids such as gRPC reflection are allowed
+func (c *Config) IsDevelopment() bool {
+	return c.Environment == "development" || c.Environment == "dev"
+}
+
 func (c *Config) validate() error {
-	environment := strings.ToLower(strings.TrimSpace(getEnv("ENVIRONMENT", getEnv("APP_ENV", "development"))))
-	isProduction := environment == "production" || environment == "prod"
-	if !isProduction {
+	if c.IsDevelopment() {
 		return nil
 	}
 
 	if c.JWT.SecretKey == "" || c.JWT.SecretKey == insecureDefaultJWTSecret {
-		return fmt.Errorf("JWT_SECRET_KEY must be configured in production")
+		return fmt.Errorf("JWT_SECRET_KEY must be configured outside development")
 	}
 
 	if len(c.JWT.SecretKey) < 32 {
-		return fmt.Errorf("JWT_SECRET_KEY must be at least 32 characters in production")
+		return fmt.Errorf("JWT_SECRET_KEY must be at least 32 characters outside development")
 	}
 
 	return nil
@@ -126,6 +144,16 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getListEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getIntEnv(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
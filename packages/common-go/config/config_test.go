@@ -46,6 +46,16 @@ func TestLoadPanicsInProductionWhenSecretTooShort(t *testing.T) {
 	}, "production short JWT secret should fail fast")
 }
 
+func TestLoadPanicsInStagingWhenSecretMissing(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "staging")
+	t.Setenv("APP_ENV", "")
+	t.Setenv("JWT_SECRET_KEY", "")
+
+	assertPanics(t, func() {
+		Load()
+	}, "only development may use the fallback secret")
+}
+
 func TestLoadAllowsDevelopmentDefaults(t *testing.T) {
 	t.Setenv("ENVIRONMENT", "development")
 	t.Setenv("APP_ENV", "")
@@ -65,3 +75,39 @@ func TestLoadAllowsStrongProductionSecret(t *testing.T) {
 		Load()
 	}, "production should allow 32+ character secret")
 }
+
+func TestLoadTrustedProxies(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "development")
+	t.Setenv("SERVER_TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.10,")
+
+	cfg := Load()
+	if len(cfg.Server.TrustedProxies) != 2 || cfg.Server.TrustedProxies[0] != "10.0.0.0/8" || cfg.Server.TrustedProxies[1] != "192.168.1.10" {
+		t.Fatalf("expected two trusted proxies, got %q", cfg.Server.TrustedProxies)
+	}
+
+	t.Setenv("SERVER_TRUSTED_PROXIES", "")
+	if cfg := Load(); cfg.Server.TrustedProxies != nil {
+		t.Fatalf("expected no trusted proxies by default, got %q", cfg.Server.TrustedProxies)
+	}
+}
+
+func TestIsDevelopment(t *testing.T) {
+	t.Setenv("JWT_SECRET_KEY", "12345678901234567890123456789012")
+	for _, tt := range []struct {
+		environment, appEnv string
+		want                bool
+	}{
+		{"", "", true},
+		{"development", "", true},
+		{" Dev ", "", true},
+		{"", "dev", true},
+		{"production", "", false},
+		{"staging", "development", false},
+	} {
+		t.Setenv("ENVIRONMENT", tt.environment)
+		t.Setenv("APP_ENV", tt.appEnv)
+		if got := Load().IsDevelopment(); got != tt.want {
+			t.Errorf("ENVIRONMENT=%q APP_ENV=%q: expected IsDevelopment %v, got %v", tt.environment, tt.appEnv, tt.want, got)
+		}
+	}
+}
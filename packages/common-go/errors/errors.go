@@ -16,6 +16,7 @@ const (
 	ErrorCodeConflict       ErrorCode = "CONFLICT"
 	ErrorCodeInternal       ErrorCode = "INTERNAL_ERROR"
 	ErrorCodeServiceUnavailable ErrorCode = "SERVICE_UNAVAILABLE"
+	ErrorCodeGeoBlocked     ErrorCode = "geo_blocked" // same value as the entitlement reason clients already handle
 )
 
 // AppError represents an application error
@@ -63,6 +64,11 @@ func NewNotFoundError(message string) *AppError {
 	return NewAppError(ErrorCodeNotFound, message, http.StatusNotFound)
 }
 
+// NewGeoBlockedError creates an error for content not licensed in the viewer's territory
+func NewGeoBlockedError(message string) *AppError {
+	return NewAppError(ErrorCodeGeoBlocked, message, http.StatusForbidden)
+}
+
 // NewConflictError creates a conflict error
 func NewConflictError(message string) *AppError {
 	return NewAppError(ErrorCodeConflict, message, http.StatusConflict)
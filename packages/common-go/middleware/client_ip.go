@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// TrustClientIPs sets where a router takes client IPs from. Forwarding headers
+// only count when set by one of trustedProxies, and a platform header such as
+// CF-Connecting-IP only when trustedPlatform names it. With neither, the client
+// IP is the peer address, so clients cannot claim another address.
+func TrustClientIPs(router *gin.Engine, trustedProxies []string, trustedPlatform string) error {
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		return err
+	}
+	router.TrustedPlatform = trustedPlatform
+	return nil
+}
@@ -76,8 +76,11 @@ type GetContentResponse struct {
 	Duration       int64                  `protobuf:"varint,4,opt,name=duration,proto3" json:"duration,omitempty"`
 	IsDrmProtected bool                   `protobuf:"varint,5,opt,name=is_drm_protected,json=isDrmProtected,proto3" json:"is_drm_protected,omitempty"`
 	DrmType        string                 `protobuf:"bytes,6,opt,name=drm_type,json=drmType,proto3" json:"drm_type,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+	// ISO 3166-1 alpha-2 codes; an empty allow list means every territory not denied
+	AllowedTerritories []string `protobuf:"bytes,7,rep,name=allowed_territories,json=allowedTerritories,proto3" json:"allowed_territories,omitempty"`
+	BlockedTerritories []string `protobuf:"bytes,8,rep,name=blocked_territories,json=blockedTerritories,proto3" json:"blocked_territories,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
 func (x *GetContentResponse) Reset() {
@@ -152,6 +155,20 @@ func (x *GetContentResponse) GetDrmType() string {
 	return ""
 }
 
+func (x *GetContentResponse) GetAllowedTerritories() []string {
+	if x != nil {
+		return x.AllowedTerritories
+	}
+	return nil
+}
+
+func (x *GetContentResponse) GetBlockedTerritories() []string {
+	if x != nil {
+		return x.BlockedTerritories
+	}
+	return nil
+}
+
 var File_content_content_proto protoreflect.FileDescriptor
 
 const file_content_content_proto_rawDesc = "" +
@@ -159,14 +176,16 @@ const file_content_content_proto_rawDesc = "" +
 	"\x15content/content.proto\x12\acontent\x1a\x1fgoogle/protobuf/timestamp.proto\"2\n" +
 	"\x11GetContentRequest\x12\x1d\n" +
 	"\n" +
-	"content_id\x18\x01 \x01(\tR\tcontentId\"\xbd\x01\n" +
+	"content_id\x18\x01 \x01(\tR\tcontentId\"\x9f\x02\n" +
 	"\x12GetContentResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
 	"\x05title\x18\x02 \x01(\tR\x05title\x12 \n" +
 	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x1a\n" +
 	"\bduration\x18\x04 \x01(\x03R\bduration\x12(\n" +
 	"\x10is_drm_protected\x18\x05 \x01(\bR\x0eisDrmProtected\x12\x19\n" +
-	"\bdrm_type\x18\x06 \x01(\tR\adrmType2W\n" +
+	"\bdrm_type\x18\x06 \x01(\tR\adrmType\x12/\n" +
+	"\x13allowed_territories\x18\a \x03(\tR\x12allowedTerritories\x12/\n" +
+	"\x13blocked_territories\x18\b \x03(\tR\x12blockedTerritories2W\n" +
 	"\x0eContentService\x12E\n" +
 	"\n" +
 	"GetContent\x12\x1a.content.GetContentRequest\x1a\x1b.content.GetContentResponseB-Z+github.com/streamverse/proto/gen/go/contentb\x06proto3"
@@ -66,10 +66,21 @@ func (x *GetSubscriptionRequest) GetUserId() string {
 	return ""
 }
 
-// The response message containing the subscription status.
+// The response message containing the subscription status and plan limits.
 type GetSubscriptionResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	IsActive      bool                   `protobuf:"varint,1,opt,name=is_active,json=isActive,proto3" json:"is_active,omitempty"`
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	IsActive bool                   `protobuf:"varint,1,opt,name=is_active,json=isActive,proto3" json:"is_active,omitempty"`
+	PlanId   string                 `protobuf:"bytes,2,opt,name=plan_id,json=planId,proto3" json:"plan_id,omitempty"`
+	// Maximum number of concurrent streams allowed by the plan.
+	MaxStreams int32 `protobuf:"varint,3,opt,name=max_streams,json=maxStreams,proto3" json:"max_streams,omitempty"`
+	// Highest playback quality allowed by the plan, e.g. "720p" or "4K".
+	Quality string `protobuf:"bytes,4,opt,name=quality,proto3" json:"quality,omitempty"`
+	// Plan features, e.g. "downloads".
+	Features []string `protobuf:"bytes,5,rep,name=features,proto3" json:"features,omitempty"`
+	// Devices that may hold offline downloads at once; 0 when the plan has no downloads.
+	MaxDownloadDevices int32 `protobuf:"varint,6,opt,name=max_download_devices,json=maxDownloadDevices,proto3" json:"max_download_devices,omitempty"`
+	// Offline downloads the account may hold at once across its devices.
+	MaxDownloads  int32 `protobuf:"varint,7,opt,name=max_downloads,json=maxDownloads,proto3" json:"max_downloads,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -111,6 +122,48 @@ func (x *GetSubscriptionResponse) GetIsActive() bool {
 	return false
 }
 
+func (x *GetSubscriptionResponse) GetPlanId() string {
+	if x != nil {
+		return x.PlanId
+	}
+	return ""
+}
+
+func (x *GetSubscriptionResponse) GetMaxStreams() int32 {
+	if x != nil {
+		return x.MaxStreams
+	}
+	return 0
+}
+
+func (x *GetSubscriptionResponse) GetQuality() string {
+	if x != nil {
+		return x.Quality
+	}
+	return ""
+}
+
+func (x *GetSubscriptionResponse) GetFeatures() []string {
+	if x != nil {
+		return x.Features
+	}
+	return nil
+}
+
+func (x *GetSubscriptionResponse) GetMaxDownloadDevices() int32 {
+	if x != nil {
+		return x.MaxDownloadDevices
+	}
+	return 0
+}
+
+func (x *GetSubscriptionResponse) GetMaxDownloads() int32 {
+	if x != nil {
+		return x.MaxDownloads
+	}
+	return 0
+}
+
 // The request message containing the user ID.
 type CheckConcurrentStreamsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -207,9 +260,16 @@ const file_payment_payment_proto_rawDesc = "" +
 	"\n" +
 	"\x15payment/payment.proto\x12\apayment\"1\n" +
 	"\x16GetSubscriptionRequest\x12\x17\n" +
-	"\auser_id\x18\x01 \x01(\tR\x06userId\"6\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\"\xfd\x01\n" +
 	"\x17GetSubscriptionResponse\x12\x1b\n" +
-	"\tis_active\x18\x01 \x01(\bR\bisActive\"8\n" +
+	"\tis_active\x18\x01 \x01(\bR\bisActive\x12\x17\n" +
+	"\aplan_id\x18\x02 \x01(\tR\x06planId\x12\x1f\n" +
+	"\vmax_streams\x18\x03 \x01(\x05R\n" +
+	"maxStreams\x12\x18\n" +
+	"\aquality\x18\x04 \x01(\tR\aquality\x12\x1a\n" +
+	"\bfeatures\x18\x05 \x03(\tR\bfeatures\x120\n" +
+	"\x14max_download_devices\x18\x06 \x01(\x05R\x12maxDownloadDevices\x12#\n" +
+	"\rmax_downloads\x18\a \x01(\x05R\fmaxDownloads\"8\n" +
 	"\x1dCheckConcurrentStreamsRequest\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\tR\x06userId\"G\n" +
 	"\x1eCheckConcurrentStreamsResponse\x12%\n" +
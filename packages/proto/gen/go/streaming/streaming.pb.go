@@ -9,7 +9,7 @@ package streaming
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
-	_ "google.golang.org/protobuf/types/known/timestamppb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -28,6 +28,8 @@ type GetManifestRequest struct {
 	ContentId     string                 `protobuf:"bytes,1,opt,name=content_id,json=contentId,proto3" json:"content_id,omitempty"`
 	UserId        string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
 	Format        string                 `protobuf:"bytes,3,opt,name=format,proto3" json:"format,omitempty"`
+	ClientIp      string                 `protobuf:"bytes,4,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`          // viewer's address, for geo-restrictions
+	DrmSecurity   string                 `protobuf:"bytes,5,opt,name=drm_security,json=drmSecurity,proto3" json:"drm_security,omitempty"` // "hardware" or "software" DRM, caps the quality offered
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -83,6 +85,20 @@ func (x *GetManifestRequest) GetFormat() string {
 	return ""
 }
 
+func (x *GetManifestRequest) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+func (x *GetManifestRequest) GetDrmSecurity() string {
+	if x != nil {
+		return x.DrmSecurity
+	}
+	return ""
+}
+
 // The response message containing the manifest.
 type GetManifestResponse struct {
 	state          protoimpl.MessageState `protogen:"open.v1"`
@@ -242,6 +258,8 @@ type SubtitleTrack struct {
 	Label         string                 `protobuf:"bytes,2,opt,name=label,proto3" json:"label,omitempty"`
 	Url           string                 `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`
 	Format        string                 `protobuf:"bytes,4,opt,name=format,proto3" json:"format,omitempty"`
+	Kind          string                 `protobuf:"bytes,5,opt,name=kind,proto3" json:"kind,omitempty"` // "subtitles", "captions" or "forced"
+	Default       bool                   `protobuf:"varint,6,opt,name=default,proto3" json:"default,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -304,6 +322,20 @@ func (x *SubtitleTrack) GetFormat() string {
 	return ""
 }
 
+func (x *SubtitleTrack) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *SubtitleTrack) GetDefault() bool {
+	if x != nil {
+		return x.Default
+	}
+	return false
+}
+
 type DRMInfo struct {
 	state          protoimpl.MessageState `protogen:"open.v1"`
 	Type           string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
@@ -364,16 +396,454 @@ func (x *DRMInfo) GetCertificateUrl() string {
 	return ""
 }
 
+type CreateSessionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	ContentId     string                 `protobuf:"bytes,2,opt,name=content_id,json=contentId,proto3" json:"content_id,omitempty"`
+	ProfileId     string                 `protobuf:"bytes,3,opt,name=profile_id,json=profileId,proto3" json:"profile_id,omitempty"` // sub-profile whose watch history the session updates
+	DeviceId      string                 `protobuf:"bytes,4,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	DeviceType    string                 `protobuf:"bytes,5,opt,name=device_type,json=deviceType,proto3" json:"device_type,omitempty"` // "mobile", "tablet", "desktop" or "tv"
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSessionRequest) Reset() {
+	*x = CreateSessionRequest{}
+	mi := &file_streaming_streaming_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSessionRequest) ProtoMessage() {}
+
+func (x *CreateSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_streaming_streaming_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSessionRequest.ProtoReflect.Descriptor instead.
+func (*CreateSessionRequest) Descriptor() ([]byte, []int) {
+	return file_streaming_streaming_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CreateSessionRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *CreateSessionRequest) GetContentId() string {
+	if x != nil {
+		return x.ContentId
+	}
+	return ""
+}
+
+func (x *CreateSessionRequest) GetProfileId() string {
+	if x != nil {
+		return x.ProfileId
+	}
+	return ""
+}
+
+func (x *CreateSessionRequest) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *CreateSessionRequest) GetDeviceType() string {
+	if x != nil {
+		return x.DeviceType
+	}
+	return ""
+}
+
+type CreateSessionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Session       *PlaybackSession       `protobuf:"bytes,1,opt,name=session,proto3" json:"session,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSessionResponse) Reset() {
+	*x = CreateSessionResponse{}
+	mi := &file_streaming_streaming_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSessionResponse) ProtoMessage() {}
+
+func (x *CreateSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_streaming_streaming_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSessionResponse.ProtoReflect.Descriptor instead.
+func (*CreateSessionResponse) Descriptor() ([]byte, []int) {
+	return file_streaming_streaming_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreateSessionResponse) GetSession() *PlaybackSession {
+	if x != nil {
+		return x.Session
+	}
+	return nil
+}
+
+type HeartbeatRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	UserId        string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Bandwidth     int64                  `protobuf:"varint,3,opt,name=bandwidth,proto3" json:"bandwidth,omitempty"` // measured throughput in bps, 0 when unknown
+	State         string                 `protobuf:"bytes,4,opt,name=state,proto3" json:"state,omitempty"`          // "playing", "paused", "stalled" or "ended"; empty keeps the state
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HeartbeatRequest) Reset() {
+	*x = HeartbeatRequest{}
+	mi := &file_streaming_streaming_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HeartbeatRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HeartbeatRequest) ProtoMessage() {}
+
+func (x *HeartbeatRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_streaming_streaming_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HeartbeatRequest.ProtoReflect.Descriptor instead.
+func (*HeartbeatRequest) Descriptor() ([]byte, []int) {
+	return file_streaming_streaming_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *HeartbeatRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *HeartbeatRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *HeartbeatRequest) GetBandwidth() int64 {
+	if x != nil {
+		return x.Bandwidth
+	}
+	return 0
+}
+
+func (x *HeartbeatRequest) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+type HeartbeatResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HeartbeatResponse) Reset() {
+	*x = HeartbeatResponse{}
+	mi := &file_streaming_streaming_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HeartbeatResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HeartbeatResponse) ProtoMessage() {}
+
+func (x *HeartbeatResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_streaming_streaming_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HeartbeatResponse.ProtoReflect.Descriptor instead.
+func (*HeartbeatResponse) Descriptor() ([]byte, []int) {
+	return file_streaming_streaming_proto_rawDescGZIP(), []int{8}
+}
+
+type EndSessionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	UserId        string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EndSessionRequest) Reset() {
+	*x = EndSessionRequest{}
+	mi := &file_streaming_streaming_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EndSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EndSessionRequest) ProtoMessage() {}
+
+func (x *EndSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_streaming_streaming_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EndSessionRequest.ProtoReflect.Descriptor instead.
+func (*EndSessionRequest) Descriptor() ([]byte, []int) {
+	return file_streaming_streaming_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *EndSessionRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *EndSessionRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+type EndSessionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EndSessionResponse) Reset() {
+	*x = EndSessionResponse{}
+	mi := &file_streaming_streaming_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EndSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EndSessionResponse) ProtoMessage() {}
+
+func (x *EndSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_streaming_streaming_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EndSessionResponse.ProtoReflect.Descriptor instead.
+func (*EndSessionResponse) Descriptor() ([]byte, []int) {
+	return file_streaming_streaming_proto_rawDescGZIP(), []int{10}
+}
+
+type PlaybackSession struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserId        string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	ProfileId     string                 `protobuf:"bytes,3,opt,name=profile_id,json=profileId,proto3" json:"profile_id,omitempty"`
+	ContentId     string                 `protobuf:"bytes,4,opt,name=content_id,json=contentId,proto3" json:"content_id,omitempty"`
+	DeviceId      string                 `protobuf:"bytes,5,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	DeviceType    string                 `protobuf:"bytes,6,opt,name=device_type,json=deviceType,proto3" json:"device_type,omitempty"`
+	State         string                 `protobuf:"bytes,7,opt,name=state,proto3" json:"state,omitempty"`
+	Position      int64                  `protobuf:"varint,8,opt,name=position,proto3" json:"position,omitempty"` // milliseconds
+	StreamUrl     string                 `protobuf:"bytes,9,opt,name=stream_url,json=streamUrl,proto3" json:"stream_url,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	LastHeartbeat *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=last_heartbeat,json=lastHeartbeat,proto3" json:"last_heartbeat,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlaybackSession) Reset() {
+	*x = PlaybackSession{}
+	mi := &file_streaming_streaming_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlaybackSession) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlaybackSession) ProtoMessage() {}
+
+func (x *PlaybackSession) ProtoReflect() protoreflect.Message {
+	mi := &file_streaming_streaming_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlaybackSession.ProtoReflect.Descriptor instead.
+func (*PlaybackSession) Descriptor() ([]byte, []int) {
+	return file_streaming_streaming_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *PlaybackSession) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *PlaybackSession) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *PlaybackSession) GetProfileId() string {
+	if x != nil {
+		return x.ProfileId
+	}
+	return ""
+}
+
+func (x *PlaybackSession) GetContentId() string {
+	if x != nil {
+		return x.ContentId
+	}
+	return ""
+}
+
+func (x *PlaybackSession) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *PlaybackSession) GetDeviceType() string {
+	if x != nil {
+		return x.DeviceType
+	}
+	return ""
+}
+
+func (x *PlaybackSession) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *PlaybackSession) GetPosition() int64 {
+	if x != nil {
+		return x.Position
+	}
+	return 0
+}
+
+func (x *PlaybackSession) GetStreamUrl() string {
+	if x != nil {
+		return x.StreamUrl
+	}
+	return ""
+}
+
+func (x *PlaybackSession) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *PlaybackSession) GetLastHeartbeat() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastHeartbeat
+	}
+	return nil
+}
+
 var File_streaming_streaming_proto protoreflect.FileDescriptor
 
 const file_streaming_streaming_proto_rawDesc = "" +
 	"\n" +
-	"\x19streaming/streaming.proto\x12\tstreaming\x1a\x1fgoogle/protobuf/timestamp.proto\"d\n" +
+	"\x19streaming/streaming.proto\x12\tstreaming\x1a\x1fgoogle/protobuf/timestamp.proto\"\xa4\x01\n" +
 	"\x12GetManifestRequest\x12\x1d\n" +
 	"\n" +
 	"content_id\x18\x01 \x01(\tR\tcontentId\x12\x17\n" +
 	"\auser_id\x18\x02 \x01(\tR\x06userId\x12\x16\n" +
-	"\x06format\x18\x03 \x01(\tR\x06format\"\x82\x02\n" +
+	"\x06format\x18\x03 \x01(\tR\x06format\x12\x1b\n" +
+	"\tclient_ip\x18\x04 \x01(\tR\bclientIp\x12!\n" +
+	"\fdrm_security\x18\x05 \x01(\tR\vdrmSecurity\"\x82\x02\n" +
 	"\x13GetManifestResponse\x12!\n" +
 	"\fmanifest_url\x18\x01 \x01(\tR\vmanifestUrl\x12\x16\n" +
 	"\x06format\x18\x02 \x01(\tR\x06format\x12>\n" +
@@ -387,19 +857,66 @@ const file_streaming_streaming_proto_rawDesc = "" +
 	"resolution\x12\x18\n" +
 	"\abitrate\x18\x03 \x01(\x05R\abitrate\x12\x14\n" +
 	"\x05codec\x18\x04 \x01(\tR\x05codec\x12\x10\n" +
-	"\x03url\x18\x05 \x01(\tR\x03url\"k\n" +
+	"\x03url\x18\x05 \x01(\tR\x03url\"\x99\x01\n" +
 	"\rSubtitleTrack\x12\x1a\n" +
 	"\blanguage\x18\x01 \x01(\tR\blanguage\x12\x14\n" +
 	"\x05label\x18\x02 \x01(\tR\x05label\x12\x10\n" +
 	"\x03url\x18\x03 \x01(\tR\x03url\x12\x16\n" +
-	"\x06format\x18\x04 \x01(\tR\x06format\"g\n" +
+	"\x06format\x18\x04 \x01(\tR\x06format\x12\x12\n" +
+	"\x04kind\x18\x05 \x01(\tR\x04kind\x12\x18\n" +
+	"\adefault\x18\x06 \x01(\bR\adefault\"g\n" +
 	"\aDRMInfo\x12\x12\n" +
 	"\x04type\x18\x01 \x01(\tR\x04type\x12\x1f\n" +
 	"\vlicense_url\x18\x02 \x01(\tR\n" +
 	"licenseUrl\x12'\n" +
-	"\x0fcertificate_url\x18\x03 \x01(\tR\x0ecertificateUrl2`\n" +
+	"\x0fcertificate_url\x18\x03 \x01(\tR\x0ecertificateUrl\"\xab\x01\n" +
+	"\x14CreateSessionRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1d\n" +
+	"\n" +
+	"content_id\x18\x02 \x01(\tR\tcontentId\x12\x1d\n" +
+	"\n" +
+	"profile_id\x18\x03 \x01(\tR\tprofileId\x12\x1b\n" +
+	"\tdevice_id\x18\x04 \x01(\tR\bdeviceId\x12\x1f\n" +
+	"\vdevice_type\x18\x05 \x01(\tR\n" +
+	"deviceType\"M\n" +
+	"\x15CreateSessionResponse\x124\n" +
+	"\asession\x18\x01 \x01(\v2\x1a.streaming.PlaybackSessionR\asession\"~\n" +
+	"\x10HeartbeatRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\tR\x06userId\x12\x1c\n" +
+	"\tbandwidth\x18\x03 \x01(\x03R\tbandwidth\x12\x14\n" +
+	"\x05state\x18\x04 \x01(\tR\x05state\"\x13\n" +
+	"\x11HeartbeatResponse\"K\n" +
+	"\x11EndSessionRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\tR\x06userId\"\x14\n" +
+	"\x12EndSessionResponse\"\x85\x03\n" +
+	"\x0fPlaybackSession\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\tR\x06userId\x12\x1d\n" +
+	"\n" +
+	"profile_id\x18\x03 \x01(\tR\tprofileId\x12\x1d\n" +
+	"\n" +
+	"content_id\x18\x04 \x01(\tR\tcontentId\x12\x1b\n" +
+	"\tdevice_id\x18\x05 \x01(\tR\bdeviceId\x12\x1f\n" +
+	"\vdevice_type\x18\x06 \x01(\tR\n" +
+	"deviceType\x12\x14\n" +
+	"\x05state\x18\a \x01(\tR\x05state\x12\x1a\n" +
+	"\bposition\x18\b \x01(\x03R\bposition\x12\x1d\n" +
+	"\n" +
+	"stream_url\x18\t \x01(\tR\tstreamUrl\x129\n" +
+	"\n" +
+	"created_at\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12A\n" +
+	"\x0elast_heartbeat\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\rlastHeartbeat2\xc7\x02\n" +
 	"\x10StreamingService\x12L\n" +
-	"\vGetManifest\x12\x1d.streaming.GetManifestRequest\x1a\x1e.streaming.GetManifestResponseB/Z-github.com/streamverse/proto/gen/go/streamingb\x06proto3"
+	"\vGetManifest\x12\x1d.streaming.GetManifestRequest\x1a\x1e.streaming.GetManifestResponse\x12R\n" +
+	"\rCreateSession\x12\x1f.streaming.CreateSessionRequest\x1a .streaming.CreateSessionResponse\x12F\n" +
+	"\tHeartbeat\x12\x1b.streaming.HeartbeatRequest\x1a\x1c.streaming.HeartbeatResponse\x12I\n" +
+	"\n" +
+	"EndSession\x12\x1c.streaming.EndSessionRequest\x1a\x1d.streaming.EndSessionResponseB/Z-github.com/streamverse/proto/gen/go/streamingb\x06proto3"
 
 var (
 	file_streaming_streaming_proto_rawDescOnce sync.Once
@@ -413,25 +930,42 @@ func file_streaming_streaming_proto_rawDescGZIP() []byte {
 	return file_streaming_streaming_proto_rawDescData
 }
 
-var file_streaming_streaming_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_streaming_streaming_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_streaming_streaming_proto_goTypes = []any{
-	(*GetManifestRequest)(nil),  // 0: streaming.GetManifestRequest
-	(*GetManifestResponse)(nil), // 1: streaming.GetManifestResponse
-	(*QualityLevel)(nil),        // 2: streaming.QualityLevel
-	(*SubtitleTrack)(nil),       // 3: streaming.SubtitleTrack
-	(*DRMInfo)(nil),             // 4: streaming.DRMInfo
+	(*GetManifestRequest)(nil),    // 0: streaming.GetManifestRequest
+	(*GetManifestResponse)(nil),   // 1: streaming.GetManifestResponse
+	(*QualityLevel)(nil),          // 2: streaming.QualityLevel
+	(*SubtitleTrack)(nil),         // 3: streaming.SubtitleTrack
+	(*DRMInfo)(nil),               // 4: streaming.DRMInfo
+	(*CreateSessionRequest)(nil),  // 5: streaming.CreateSessionRequest
+	(*CreateSessionResponse)(nil), // 6: streaming.CreateSessionResponse
+	(*HeartbeatRequest)(nil),      // 7: streaming.HeartbeatRequest
+	(*HeartbeatResponse)(nil),     // 8: streaming.HeartbeatResponse
+	(*EndSessionRequest)(nil),     // 9: streaming.EndSessionRequest
+	(*EndSessionResponse)(nil),    // 10: streaming.EndSessionResponse
+	(*PlaybackSession)(nil),       // 11: streaming.PlaybackSession
+	(*timestamppb.Timestamp)(nil), // 12: google.protobuf.Timestamp
 }
 var file_streaming_streaming_proto_depIdxs = []int32{
-	2, // 0: streaming.GetManifestResponse.quality_levels:type_name -> streaming.QualityLevel
-	3, // 1: streaming.GetManifestResponse.subtitle_tracks:type_name -> streaming.SubtitleTrack
-	4, // 2: streaming.GetManifestResponse.drm_info:type_name -> streaming.DRMInfo
-	0, // 3: streaming.StreamingService.GetManifest:input_type -> streaming.GetManifestRequest
-	1, // 4: streaming.StreamingService.GetManifest:output_type -> streaming.GetManifestResponse
-	4, // [4:5] is the sub-list for method output_type
-	3, // [3:4] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	2,  // 0: streaming.GetManifestResponse.quality_levels:type_name -> streaming.QualityLevel
+	3,  // 1: streaming.GetManifestResponse.subtitle_tracks:type_name -> streaming.SubtitleTrack
+	4,  // 2: streaming.GetManifestResponse.drm_info:type_name -> streaming.DRMInfo
+	11, // 3: streaming.CreateSessionResponse.session:type_name -> streaming.PlaybackSession
+	12, // 4: streaming.PlaybackSession.created_at:type_name -> google.protobuf.Timestamp
+	12, // 5: streaming.PlaybackSession.last_heartbeat:type_name -> google.protobuf.Timestamp
+	0,  // 6: streaming.StreamingService.GetManifest:input_type -> streaming.GetManifestRequest
+	5,  // 7: streaming.StreamingService.CreateSession:input_type -> streaming.CreateSessionRequest
+	7,  // 8: streaming.StreamingService.Heartbeat:input_type -> streaming.HeartbeatRequest
+	9,  // 9: streaming.StreamingService.EndSession:input_type -> streaming.EndSessionRequest
+	1,  // 10: streaming.StreamingService.GetManifest:output_type -> streaming.GetManifestResponse
+	6,  // 11: streaming.StreamingService.CreateSession:output_type -> streaming.CreateSessionResponse
+	8,  // 12: streaming.StreamingService.Heartbeat:output_type -> streaming.HeartbeatResponse
+	10, // 13: streaming.StreamingService.EndSession:output_type -> streaming.EndSessionResponse
+	10, // [10:14] is the sub-list for method output_type
+	6,  // [6:10] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_streaming_streaming_proto_init() }
@@ -445,7 +979,7 @@ func file_streaming_streaming_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_streaming_streaming_proto_rawDesc), len(file_streaming_streaming_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   5,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
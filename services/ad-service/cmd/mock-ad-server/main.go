@@ -0,0 +1,23 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/streamverse/ad-service/internal/mockadserver"
+)
+
+// A local VAST/VMAP ad server. Point the ad service at it with
+// AD_SERVER_URL=http://localhost:8095/ads (the default).
+func main() {
+	port := os.Getenv("MOCK_AD_SERVER_PORT")
+	if port == "" {
+		port = "8095"
+	}
+
+	log.Printf("Mock ad server listening on :%s", port)
+	if err := http.ListenAndServe(":"+port, mockadserver.NewHandler()); err != nil {
+		log.Fatalf("Mock ad server failed: %v", err)
+	}
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	stderrors "errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/streamverse/common-go/errors"
@@ -35,9 +38,13 @@ func (h *AdHandler) GetAds(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	req.UserID = userID.(string)
 
-	response, err := h.service.GetAds(c.Request.Context(), &req)
+	response, err := h.service.GetAds(c.Request.Context(), &req, c.GetHeader("Authorization"))
 	if err != nil {
 		h.logger.Error("Failed to get ads", logger.Error(err))
+		if stderrors.Is(err, service.ErrAdServerUnavailable) {
+			c.JSON(http.StatusBadGateway, errors.NewAppError(errors.ErrorCodeServiceUnavailable, "Ad server unavailable", http.StatusBadGateway))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to get ads"))
 		return
 	}
@@ -45,7 +52,8 @@ func (h *AdHandler) GetAds(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// TrackAdEvent handles POST /api/v1/ads/track
+// TrackAdEvent handles POST /api/v1/ads/track. Events with a deliveryId call
+// the ad's tracking URLs for the event from the server.
 func (h *AdHandler) TrackAdEvent(c *gin.Context) {
 	var tracking models.AdTracking
 	if err := c.ShouldBindJSON(&tracking); err != nil {
@@ -57,11 +65,125 @@ func (h *AdHandler) TrackAdEvent(c *gin.Context) {
 	tracking.UserID = userID.(string)
 
 	if err := h.service.TrackAdEvent(c.Request.Context(), &tracking); err != nil {
-		h.logger.Error("Failed to track ad event", logger.Error(err))
-		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to track event"))
+		switch {
+		case stderrors.Is(err, service.ErrInvalidEvent):
+			c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+		case stderrors.Is(err, service.ErrDeliveryNotFound):
+			c.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
+		default:
+			h.logger.Error("Failed to track ad event", logger.Error(err))
+			c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to track event"))
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Event tracked"})
 }
 
+// ReportProgress handles POST /api/v1/ads/progress, where SSAI players report
+// how far into an ad playback has got so the server can fire its beacons
+func (h *AdHandler) ReportProgress(c *gin.Context) {
+	var req struct {
+		DeliveryID string   `json:"deliveryId" binding:"required"`
+		Position   *float64 `json:"position" binding:"required"` // seconds into the ad
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+		return
+	}
+	if *req.Position < 0 {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError("position must not be negative"))
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	events, err := h.service.ReportProgress(c.Request.Context(), userID.(string), req.DeliveryID, *req.Position)
+	if err != nil {
+		if stderrors.Is(err, service.ErrDeliveryNotFound) {
+			c.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
+			return
+		}
+		h.logger.Error("Failed to report ad progress", logger.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to report progress"))
+		return
+	}
+
+	if events == nil {
+		events = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// ListTracking handles GET /api/v1/ads/tracking for billing reconciliation
+func (h *AdHandler) ListTracking(c *gin.Context) {
+	filter, ok := trackingFilter(c)
+	if !ok {
+		return
+	}
+	filter.Limit = 1000
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || limit <= 0 || limit > 1000 {
+			c.JSON(http.StatusBadRequest, errors.NewInvalidInputError("limit must be between 1 and 1000"))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	events, err := h.service.ListTracking(c.Request.Context(), filter)
+	if err != nil {
+		h.logger.Error("Failed to list ad tracking", logger.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to list tracking events"))
+		return
+	}
+
+	if events == nil {
+		events = []models.AdTracking{}
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// ReportTracking handles GET /api/v1/ads/tracking/report, counting events per
+// ad, campaign or content item (groupBy) for billing reconciliation
+func (h *AdHandler) ReportTracking(c *gin.Context) {
+	filter, ok := trackingFilter(c)
+	if !ok {
+		return
+	}
+
+	rows, err := h.service.ReportTracking(c.Request.Context(), filter, c.DefaultQuery("groupBy", "campaign"))
+	if err != nil {
+		if stderrors.Is(err, service.ErrInvalidGroupBy) {
+			c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+			return
+		}
+		h.logger.Error("Failed to report ad tracking", logger.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to report tracking events"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rows": rows})
+}
+
+// trackingFilter reads the adId, campaignId, contentId, eventType, from and to
+// (RFC 3339) query parameters, answering 400 when they are invalid
+func trackingFilter(c *gin.Context) (models.TrackingFilter, bool) {
+	filter := models.TrackingFilter{
+		AdID:       c.Query("adId"),
+		CampaignID: c.Query("campaignId"),
+		ContentID:  c.Query("contentId"),
+		EventType:  c.Query("eventType"),
+	}
+	for name, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := c.Query(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(name+" must be an RFC 3339 time"))
+				return filter, false
+			}
+			*dst = t
+		}
+	}
+	return filter, true
+}
@@ -0,0 +1,119 @@
+// Package beacon fires ad tracking beacons from the server, as SSAI requires
+// when players cannot call the ad server's tracking URLs themselves.
+package beacon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/streamverse/ad-service/internal/netguard"
+)
+
+// ErrForbiddenURL is returned for beacon URLs that are not http or https, or
+// that lead to an internal address
+var ErrForbiddenURL = errors.New("beacon URL not allowed")
+
+// Result is the outcome of firing a beacon
+type Result struct {
+	URL        string
+	StatusCode int // status of the last attempt, 0 when no response was received
+	Attempts   int
+	Err        error
+	SentAt     time.Time
+}
+
+// Sender fires beacons, retrying failures that may be temporary
+type Sender struct {
+	httpClient  *http.Client
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// NewSender creates a sender whose attempts time out after timeout and that
+// tries each beacon up to maxAttempts times, waiting backoff before the first
+// retry and twice as long before each further one. Beacon URLs come from ad
+// responses, so apart from trusted, the host:port of the configured ad server,
+// they may only reach public addresses.
+func NewSender(trusted string, timeout time.Duration, maxAttempts int, backoff time.Duration) *Sender {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	return &Sender{
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: netguard.Transport(trusted),
+		},
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+	}
+}
+
+// Send fires a beacon with a GET request. Connection errors, 429 and 5xx
+// responses are retried; other responses are final. Any 2xx or 3xx status is
+// a success, as tracking pixels often redirect to third parties.
+func (s *Sender) Send(ctx context.Context, url string) Result {
+	result := Result{URL: url}
+	if !netguard.IsHTTP(url) {
+		result.Err = fmt.Errorf("%w: %q", ErrForbiddenURL, url)
+		return result
+	}
+	wait := s.backoff
+	for result.Attempts < s.maxAttempts {
+		if result.Attempts > 0 {
+			select {
+			case <-ctx.Done():
+				result.Err = ctx.Err()
+				return result
+			case <-time.After(wait):
+			}
+			wait *= 2
+		}
+		result.Attempts++
+
+		var retry bool
+		result.StatusCode, retry, result.Err = s.send(ctx, url)
+		if result.Err == nil {
+			result.SentAt = time.Now()
+			return result
+		}
+		if !retry {
+			return result
+		}
+	}
+	return result
+}
+
+// send makes one attempt, reporting whether a failure may be retried
+func (s *Sender) send(ctx context.Context, url string) (int, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, false, err
+	}
+
+	res, err := s.httpClient.Do(req)
+	if errors.Is(err, netguard.ErrNotPublic) {
+		return 0, false, fmt.Errorf("%w: %v", ErrForbiddenURL, err)
+	}
+	if err != nil {
+		return 0, ctx.Err() == nil, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	switch {
+	case res.StatusCode < 400:
+		return res.StatusCode, false, nil
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return res.StatusCode, true, fmt.Errorf("beacon returned status %d", res.StatusCode)
+	default:
+		return res.StatusCode, false, fmt.Errorf("beacon returned status %d", res.StatusCode)
+	}
+}
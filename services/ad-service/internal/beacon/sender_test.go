@@ -0,0 +1,99 @@
+package beacon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int // response status of each attempt, the last one repeating
+		wantAttempts int
+		wantStatus   int
+		wantErr      bool
+	}{
+		{"pixel", []int{http.StatusOK}, 1, http.StatusOK, false},
+		{"no content", []int{http.StatusNoContent}, 1, http.StatusNoContent, false},
+		{"retried server error", []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}, 3, http.StatusOK, false},
+		{"retried rate limit", []int{http.StatusTooManyRequests, http.StatusOK}, 2, http.StatusOK, false},
+		{"gives up", []int{http.StatusInternalServerError}, 3, http.StatusInternalServerError, true},
+		{"not retried", []int{http.StatusNotFound, http.StatusOK}, 1, http.StatusNotFound, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(atomic.AddInt32(&calls, 1)) - 1
+				if n >= len(tt.statuses) {
+					n = len(tt.statuses) - 1
+				}
+				w.WriteHeader(tt.statuses[n])
+			}))
+			defer server.Close()
+
+			result := NewSender(server.Listener.Addr().String(), time.Second, 3, time.Millisecond).Send(context.Background(), server.URL+"/pixel")
+			if result.Attempts != tt.wantAttempts || result.StatusCode != tt.wantStatus || (result.Err != nil) != tt.wantErr {
+				t.Fatalf("expected %d attempts ending in %d (error %v), got %+v", tt.wantAttempts, tt.wantStatus, tt.wantErr, result)
+			}
+			if result.SentAt.IsZero() == !tt.wantErr {
+				t.Fatalf("expected SentAt only on success, got %v", result.SentAt)
+			}
+		})
+	}
+}
+
+func TestSendFollowsRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pixel" {
+			http.Redirect(w, r, "/third-party", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	result := NewSender(server.Listener.Addr().String(), time.Second, 1, 0).Send(context.Background(), server.URL+"/pixel")
+	if result.Err != nil || result.StatusCode != http.StatusOK {
+		t.Fatalf("expected the redirect to be followed, got %+v", result)
+	}
+}
+
+func TestSendRetriesConnectionErrorsUntilCancelled(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL + "/pixel"
+	address := server.Listener.Addr().String()
+	server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result := NewSender(address, time.Second, 10, 20*time.Millisecond).Send(ctx, url)
+	if result.Err == nil || result.Attempts < 2 || result.Attempts >= 10 {
+		t.Fatalf("expected retries to stop when the context ends, got %+v", result)
+	}
+}
+
+func TestSendOnlyReachesPublicAddresses(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("internal service reached at %s", r.URL)
+	}))
+	defer internal.Close()
+
+	sender := NewSender("", time.Second, 3, time.Millisecond)
+	for _, url := range []string{
+		internal.URL + "/admin",
+		"http://127.0.0.1:9/pixel",
+		"http://169.254.169.254/latest/meta-data/",
+		"file:///etc/passwd",
+	} {
+		result := sender.Send(context.Background(), url)
+		if !errors.Is(result.Err, ErrForbiddenURL) || result.Attempts > 1 {
+			t.Fatalf("expected %s to be refused without retries, got %+v", url, result)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package adserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/streamverse/ad-service/internal/netguard"
+	"github.com/streamverse/ad-service/internal/vast"
+)
+
+// maxResponseSize bounds the VAST and VMAP documents read from ad servers
+const maxResponseSize = 2 << 20
+
+// ErrForbiddenURL is returned for ad tag URIs that are not http or https, or that
+// lead to an internal address
+var ErrForbiddenURL = errors.New("ad tag URI not allowed")
+
+// Client requests ads from a VAST/VMAP ad server and fetches the ad tags that
+// VAST wrappers and VMAP breaks point to. Those come from third parties, so
+// apart from the configured ad server they may only reach public addresses.
+type Client struct {
+	tagURL     string
+	httpClient *http.Client
+}
+
+// NewClient creates an ad server client for an ad tag URL, which defaults to the
+// local mock ad server. Requests time out after timeout, 5s when it is not
+// positive; VAST recommends players give up on slow ad servers well before a
+// viewer notices.
+func NewClient(tagURL string, timeout time.Duration) *Client {
+	tagURL = strings.TrimSpace(tagURL)
+	if tagURL == "" {
+		tagURL = "http://localhost:8095/ads"
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &Client{
+		tagURL: tagURL,
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: netguard.Transport(netguard.DialAddress(tagURL)),
+		},
+	}
+}
+
+// Address returns the host:port of the configured ad server
+func (c *Client) Address() string {
+	return netguard.DialAddress(c.tagURL)
+}
+
+// RequestAds requests ads from the ad tag URL with targeting parameters added to
+// its query, and returns the VAST or VMAP response
+func (c *Client) RequestAds(ctx context.Context, params url.Values) ([]byte, error) {
+	tag, err := url.Parse(c.tagURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ad tag URL: %w", err)
+	}
+	query := tag.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	tag.RawQuery = query.Encode()
+	return c.Fetch(ctx, tag.String())
+}
+
+// Fetch gets the document at an ad tag URI, replacing the [CACHEBUSTING] and
+// [TIMESTAMP] macros first. A 204 No Content response is an empty document.
+func (c *Client) Fetch(ctx context.Context, tagURI string) ([]byte, error) {
+	tagURI = vast.ExpandMacros(tagURI, nil)
+	if !netguard.IsHTTP(tagURI) {
+		return nil, fmt.Errorf("%w: %q", ErrForbiddenURL, tagURI)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tagURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/xml, text/xml")
+
+	res, err := c.httpClient.Do(req)
+	if errors.Is(err, netguard.ErrNotPublic) {
+		return nil, fmt.Errorf("%w: %v", ErrForbiddenURL, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("ad server returned status %d", res.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxResponseSize {
+		return nil, fmt.Errorf("ad server response larger than %d bytes", maxResponseSize)
+	}
+	return body, nil
+}
@@ -0,0 +1,48 @@
+package adserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchOnlyReachesPublicAddresses(t *testing.T) {
+	adServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+			return
+		}
+		w.Write([]byte(`<VAST version="4.0"/>`))
+	}))
+	defer adServer.Close()
+	// An internal service on another port of the same host
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("internal service reached at %s", r.URL)
+	}))
+	defer internal.Close()
+
+	client := NewClient(adServer.URL+"/ads", 0)
+	if _, err := client.Fetch(context.Background(), adServer.URL+"/wrapper"); err != nil {
+		t.Fatalf("expected the configured ad server to be reachable, got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		uri  string
+	}{
+		{"loopback", internal.URL + "/admin"},
+		{"redirect to loopback", adServer.URL + "/redirect?to=" + internal.URL + "/admin"},
+		{"link-local metadata", "http://169.254.169.254/latest/meta-data/"},
+		{"private", "http://10.0.0.1/"},
+		{"file", "file:///etc/passwd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.Fetch(context.Background(), tt.uri); !errors.Is(err, ErrForbiddenURL) {
+				t.Fatalf("expected ErrForbiddenURL, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// FeatureAdSupported is the plan feature of plans that show ads
+const FeatureAdSupported = "ad-supported"
+
+// Entitlement is a subscription or purchase record of a user. Subscriptions
+// carry the features of their plan.
+type Entitlement struct {
+	Type      string     `json:"type"` // "subscription" or "purchase"
+	PlanID    string     `json:"plan_id,omitempty"`
+	ContentID string     `json:"content_id,omitempty"`
+	Status    string     `json:"status"`
+	Features  []string   `json:"features,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// EntitlementsClient fetches a user's entitlements from the payment service
+// HTTP API
+type EntitlementsClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewEntitlementsClient creates a payment-service entitlements client.
+func NewEntitlementsClient(baseURL string) *EntitlementsClient {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	return &EntitlementsClient{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+// GetUserEntitlements retrieves the entitlements of a user, on behalf of the
+// caller identified by authHeader.
+func (c *EntitlementsClient) GetUserEntitlements(ctx context.Context, userID, authHeader string) ([]Entitlement, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("user id is required")
+	}
+	if authHeader == "" {
+		return nil, fmt.Errorf("authorization header is required")
+	}
+
+	endpoint := fmt.Sprintf("%s/payments/entitlements/%s", c.baseURL, url.PathEscape(userID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authHeader)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("payment service returned status %d", res.StatusCode)
+	}
+
+	var payload struct {
+		Entitlements []Entitlement `json:"entitlements"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	return payload.Entitlements, nil
+}
@@ -0,0 +1,65 @@
+// Package mockadserver is a local VAST/VMAP ad server for development and tests.
+// It serves canned responses that exercise wrappers, ad pods, companions and
+// VMAP schedules without an account on a real ad server.
+package mockadserver
+
+import (
+	"embed"
+	"net/http"
+	"strings"
+)
+
+//go:embed responses/*.xml
+var responses embed.FS
+
+// routes maps request paths to canned responses
+var routes = map[string]string{
+	"/ads/wrapper": "responses/wrapper.xml",
+	"/ads/pod":     "responses/pod.xml",
+	"/ads/vmap":    "responses/vmap.xml",
+	"/ads/empty":   "responses/empty.xml",
+}
+
+// NewHandler returns the mock ad server. GET /ads is the ad tag: it answers with
+// a VMAP schedule when the request has format=vmap and otherwise with a VAST
+// wrapper leading to a two-ad pod. Tracking URLs under /beacon/ answer 204 No
+// Content. {{base}} in the responses is replaced with the
+// server's own address so wrappers and VMAP ad tags point back at it.
+func NewHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		path := r.URL.Path
+		if strings.HasPrefix(path, "/beacon/") {
+			// Tracking URLs of the canned ads
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if path == "/ads" {
+			path = "/ads/wrapper"
+			if r.URL.Query().Get("format") == "vmap" {
+				path = "/ads/vmap"
+			}
+		}
+		name, ok := routes[path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := responses.ReadFile(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.Write([]byte(strings.ReplaceAll(string(data), "{{base}}", scheme+"://"+r.Host)))
+	})
+}
@@ -0,0 +1,90 @@
+// Package netguard keeps requests to third-party URLs, such as the ad tags and
+// tracking beacons of ad responses, off internal networks.
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrNotPublic is returned when a connection would reach an internal address
+var ErrNotPublic = errors.New("address is not public")
+
+// nonPublicPrefixes are ranges IsGlobalUnicast lets through that are not
+// reachable on the internet
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+}
+
+// Transport returns an HTTP transport that connects to public addresses only,
+// apart from trusted, the host:port of a configured server ("" for none)
+func Transport(trusted string) *http.Transport {
+	dialer := &publicDialer{trusted: trusted}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // a proxy would dial the URLs in the dialer's place
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// publicDialer connects to public addresses only, apart from the trusted one.
+// Addresses are checked after name resolution, so names resolving to internal
+// addresses and redirects to them are refused too.
+type publicDialer struct {
+	trusted string
+}
+
+func (d *publicDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if address != d.trusted {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !IsPublic(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrNotPublic, address)
+			}
+			return nil
+		}
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+// IsPublic reports whether an address is routable on the internet: not
+// loopback, private, link-local, multicast or unspecified
+func IsPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsHTTP reports whether a URL has an http or https scheme
+func IsHTTP(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// DialAddress returns the host:port a URL is dialled at, "" when it has no host
+func DialAddress(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
@@ -0,0 +1,111 @@
+package vast
+
+import "strings"
+
+// MediaPreferences describe the media files a player or stitcher can use
+type MediaPreferences struct {
+	MimeTypes  []string // accepted types, most preferred first; empty accepts any
+	Codecs     []string // accepted RFC 6381 codec prefixes such as "avc1"; empty accepts any
+	Width      int      // target dimensions, 0 for no preference
+	Height     int
+	MaxBitrate int // kbps, 0 for no limit
+}
+
+// SelectMediaFile returns the media file of a linear creative that best fits the
+// preferences, or nil when none can be played. Interactive files (VPAID, SIMID)
+// and files of other types or codecs are skipped. Of the rest, files within the
+// bitrate limit win, or the lowest bitrate when none are, then the more
+// preferred type, then files that fit the target dimensions, the largest first,
+// or the smallest when none fit, then the highest bitrate.
+func SelectMediaFile(files []MediaFile, prefs MediaPreferences) *MediaFile {
+	var best *MediaFile
+	bestRank := 0
+	for i := range files {
+		f := &files[i]
+		if f.URL == "" || f.APIFramework != "" {
+			continue
+		}
+		rank, ok := typeRank(f.Type, prefs.MimeTypes)
+		if !ok || !acceptsCodec(f.Codec, prefs.Codecs) {
+			continue
+		}
+		if best == nil || betterMediaFile(f, rank, best, bestRank, prefs) {
+			best, bestRank = f, rank
+		}
+	}
+	return best
+}
+
+// betterMediaFile reports whether a is a better fit than b
+func betterMediaFile(a *MediaFile, aRank int, b *MediaFile, bRank int, prefs MediaPreferences) bool {
+	aBitrate, bBitrate := bitrate(a), bitrate(b)
+	aWithin := prefs.MaxBitrate == 0 || aBitrate <= prefs.MaxBitrate
+	bWithin := prefs.MaxBitrate == 0 || bBitrate <= prefs.MaxBitrate
+	if aWithin != bWithin {
+		return aWithin
+	}
+	if !aWithin && aBitrate != bBitrate {
+		return aBitrate < bBitrate
+	}
+	if aRank != bRank {
+		return aRank < bRank
+	}
+
+	aFits, bFits := fits(a, prefs), fits(b, prefs)
+	if aFits != bFits {
+		return aFits
+	}
+	if aArea, bArea := a.Width*a.Height, b.Width*b.Height; aArea != bArea {
+		return aArea > bArea == aFits
+	}
+	return aBitrate > bBitrate
+}
+
+// bitrate returns the bitrate of a file, the upper bound of the range of an
+// adaptive stream
+func bitrate(f *MediaFile) int {
+	if f.Bitrate > 0 {
+		return f.Bitrate
+	}
+	return f.MaxBitrate
+}
+
+// fits reports whether a file is no larger than the target dimensions
+func fits(f *MediaFile, prefs MediaPreferences) bool {
+	return (prefs.Width == 0 || f.Width <= prefs.Width) && (prefs.Height == 0 || f.Height <= prefs.Height)
+}
+
+// typeRank returns the position of a MIME type among the accepted types
+func typeRank(mimeType string, accepted []string) (int, bool) {
+	if len(accepted) == 0 {
+		return 0, true
+	}
+	for i, t := range accepted {
+		if strings.EqualFold(t, mimeType) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// acceptsCodec reports whether each codec of a file matches an accepted codec.
+// Files that do not state their codecs are accepted.
+func acceptsCodec(codecs string, accepted []string) bool {
+	if codecs == "" || len(accepted) == 0 {
+		return true
+	}
+	for _, codec := range strings.Split(codecs, ",") {
+		codec = strings.ToLower(strings.TrimSpace(codec))
+		ok := false
+		for _, prefix := range accepted {
+			if strings.HasPrefix(codec, strings.ToLower(prefix)) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
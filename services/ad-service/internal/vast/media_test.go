@@ -0,0 +1,46 @@
+package vast
+
+import "testing"
+
+func TestSelectMediaFile(t *testing.T) {
+	files := []MediaFile{
+		{Type: "application/javascript", APIFramework: "VPAID", Width: 1920, Height: 1080, URL: "vpaid.js"},
+		{Type: "video/mp4", Bitrate: 500, Width: 640, Height: 360, Codec: "avc1.42E01E,mp4a.40.2", URL: "360.mp4"},
+		{Type: "video/mp4", Bitrate: 1500, Width: 1280, Height: 720, Codec: "avc1.4D401F,mp4a.40.2", URL: "720.mp4"},
+		{Type: "video/mp4", Bitrate: 4500, Width: 1920, Height: 1080, Codec: "avc1.640028,mp4a.40.2", URL: "1080.mp4"},
+		{Type: "video/mp4", Bitrate: 3000, Width: 1920, Height: 1080, Codec: "hvc1.1.6.L120.90,mp4a.40.2", URL: "1080_hevc.mp4"},
+		{Type: "video/webm", Bitrate: 1200, Width: 1280, Height: 720, URL: "720.webm"},
+		{Type: "application/x-mpegURL", Delivery: "streaming", MinBitrate: 400, MaxBitrate: 4000, Width: 1920, Height: 1080, URL: "master.m3u8"},
+	}
+
+	tests := []struct {
+		name  string
+		prefs MediaPreferences
+		want  string
+	}{
+		{"largest without preferences", MediaPreferences{}, "1080.mp4"},
+		{"type preference", MediaPreferences{MimeTypes: []string{"application/x-mpegURL", "video/mp4"}}, "master.m3u8"},
+		{"fits the player", MediaPreferences{MimeTypes: []string{"video/mp4"}, Width: 1280, Height: 720}, "720.mp4"},
+		{"smallest when none fit", MediaPreferences{MimeTypes: []string{"video/mp4"}, Width: 320, Height: 180}, "360.mp4"},
+		{"bitrate limit", MediaPreferences{MimeTypes: []string{"video/mp4"}, MaxBitrate: 3500}, "1080_hevc.mp4"},
+		{"bitrate limit before type", MediaPreferences{MimeTypes: []string{"video/webm", "video/mp4"}, MaxBitrate: 1000}, "360.mp4"},
+		{"lowest bitrate over the limit", MediaPreferences{MimeTypes: []string{"video/mp4"}, MaxBitrate: 100}, "360.mp4"},
+		{"codecs", MediaPreferences{Codecs: []string{"avc1", "mp4a"}, MaxBitrate: 3500}, "720.mp4"},
+		{"codecs and types", MediaPreferences{MimeTypes: []string{"video/mp4"}, Codecs: []string{"hvc1", "mp4a"}}, "1080_hevc.mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SelectMediaFile(files, tt.prefs)
+			if got == nil || got.URL != tt.want {
+				t.Fatalf("expected %s, got %+v", tt.want, got)
+			}
+		})
+	}
+
+	if got := SelectMediaFile(files, MediaPreferences{MimeTypes: []string{"video/ogg"}}); got != nil {
+		t.Fatalf("expected no file of an unlisted type, got %+v", got)
+	}
+	if got := SelectMediaFile(files[:1], MediaPreferences{}); got != nil {
+		t.Fatalf("expected VPAID files to be skipped, got %+v", got)
+	}
+}
@@ -0,0 +1,172 @@
+package vast
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+// DefaultMaxWrapperDepth is the number of wrappers followed before giving up,
+// the limit VAST 4 recommends
+const DefaultMaxWrapperDepth = 5
+
+// Fetcher fetches the VAST response of an ad tag URI
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) ([]byte, error)
+}
+
+// Resolver follows VAST wrappers to the inline ads they lead to
+type Resolver struct {
+	fetcher  Fetcher
+	maxDepth int
+}
+
+// NewResolver creates a resolver that follows at most maxDepth wrappers,
+// DefaultMaxWrapperDepth when maxDepth is not positive
+func NewResolver(fetcher Fetcher, maxDepth int) *Resolver {
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxWrapperDepth
+	}
+	return &Resolver{fetcher: fetcher, maxDepth: maxDepth}
+}
+
+// Resolve returns the inline ads to play for a VAST response. An ad pod is
+// returned in sequence order, with stand-alone ads taking the place of pod ads
+// that failed to resolve; otherwise the first stand-alone ad that resolves is
+// returned, or the pod a stand-alone wrapper leads to. The impressions, error URLs and linear tracking of the wrappers are
+// merged into the inline ads they led to. An empty response resolves to no ads;
+// an error is returned when ads were offered but none resolved.
+func (r *Resolver) Resolve(ctx context.Context, doc *VAST) ([]Ad, error) {
+	return r.resolve(ctx, doc, 0, nil, true)
+}
+
+func (r *Resolver) resolve(ctx context.Context, doc *VAST, depth int, chain []*Wrapper, allowMultiple bool) ([]Ad, error) {
+	var pod, standalone []Ad
+	for _, ad := range doc.Ads {
+		if ad.Sequence > 0 && allowMultiple {
+			pod = append(pod, ad)
+		} else {
+			standalone = append(standalone, ad)
+		}
+	}
+	sort.SliceStable(pod, func(i, j int) bool { return pod[i].Sequence < pod[j].Sequence })
+
+	var firstErr error
+	next := 0 // next stand-alone ad to try
+	fallback := func() []Ad {
+		for next < len(standalone) {
+			ad := standalone[next]
+			next++
+			resolved, err := r.resolveAd(ctx, ad, depth, chain)
+			if err == nil && len(resolved) > 0 {
+				return resolved
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		return nil
+	}
+
+	var ads []Ad
+	for _, ad := range pod {
+		resolved, err := r.resolveAd(ctx, ad, depth, chain)
+		if err != nil || len(resolved) == 0 {
+			if firstErr == nil {
+				firstErr = err
+			}
+			resolved = fallback()
+		}
+		for _, a := range resolved {
+			a.Sequence = ad.Sequence
+			ads = append(ads, a)
+		}
+	}
+	if len(pod) == 0 {
+		ads = fallback()
+	}
+
+	if len(ads) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return ads, nil
+}
+
+// resolveAd resolves an inline ad or the wrapper chain of an ad
+func (r *Resolver) resolveAd(ctx context.Context, ad Ad, depth int, chain []*Wrapper) ([]Ad, error) {
+	switch {
+	case ad.InLine != nil:
+		ad.InLine = mergeWrappers(*ad.InLine, chain)
+		return []Ad{ad}, nil
+	case ad.Wrapper == nil:
+		return nil, fmt.Errorf("%w: ad %q is neither inline nor a wrapper", ErrInvalidDocument, ad.ID)
+	case depth >= r.maxDepth:
+		return nil, ErrWrapperLimit
+	case ad.Wrapper.VASTAdTagURI == "":
+		return nil, fmt.Errorf("%w: wrapper %q has no VASTAdTagURI", ErrInvalidDocument, ad.ID)
+	case !isHTTP(ad.Wrapper.VASTAdTagURI):
+		return nil, fmt.Errorf("%w: wrapper %q has a VASTAdTagURI that is not http or https", ErrInvalidDocument, ad.ID)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := r.fetcher.Fetch(ctx, ad.Wrapper.VASTAdTagURI)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if follow := ad.Wrapper.FollowAdditionalWrappers; follow != nil && !*follow {
+		for _, a := range doc.Ads {
+			if a.Wrapper != nil {
+				return nil, fmt.Errorf("%w: wrapper %q does not allow further wrappers", ErrWrapperLimit, ad.ID)
+			}
+		}
+	}
+	allowMultiple := ad.Wrapper.AllowMultipleAds == nil || *ad.Wrapper.AllowMultipleAds
+	chain = append(chain[:len(chain):len(chain)], ad.Wrapper)
+	return r.resolve(ctx, doc, depth+1, chain, allowMultiple)
+}
+
+// isHTTP reports whether a URI is an http or https URL
+func isHTTP(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// mergeWrappers returns a copy of an inline ad with the impressions, error URLs
+// and linear tracking of the wrappers that led to it
+func mergeWrappers(inline InLine, chain []*Wrapper) *InLine {
+	inline.Impressions = append([]string(nil), inline.Impressions...)
+	inline.Errors = append([]string(nil), inline.Errors...)
+	inline.Creatives = append([]Creative(nil), inline.Creatives...)
+	for i := range inline.Creatives {
+		if linear := inline.Creatives[i].Linear; linear != nil {
+			copied := *linear
+			copied.TrackingEvents = append([]Tracking(nil), linear.TrackingEvents...)
+			copied.ClickTracking = append([]string(nil), linear.ClickTracking...)
+			inline.Creatives[i].Linear = &copied
+		}
+	}
+
+	for _, wrapper := range chain {
+		inline.Impressions = append(inline.Impressions, wrapper.Impressions...)
+		inline.Errors = append(inline.Errors, wrapper.Errors...)
+		for _, wc := range wrapper.Creatives {
+			if wc.Linear == nil {
+				continue
+			}
+			for i := range inline.Creatives {
+				if linear := inline.Creatives[i].Linear; linear != nil {
+					linear.TrackingEvents = append(linear.TrackingEvents, wc.Linear.TrackingEvents...)
+					linear.ClickTracking = append(linear.ClickTracking, wc.Linear.ClickTracking...)
+				}
+			}
+		}
+	}
+	return &inline
+}
@@ -0,0 +1,165 @@
+package vast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// fetcherFunc adapts a function to the Fetcher interface
+type fetcherFunc func(ctx context.Context, url string) ([]byte, error)
+
+func (f fetcherFunc) Fetch(ctx context.Context, url string) ([]byte, error) {
+	return f(ctx, url)
+}
+
+// documents serves VAST responses by URL
+func documents(docs map[string][]byte) Fetcher {
+	return fetcherFunc(func(_ context.Context, url string) ([]byte, error) {
+		data, ok := docs[url]
+		if !ok {
+			return nil, fmt.Errorf("no document at %s", url)
+		}
+		return data, nil
+	})
+}
+
+func wrapperTo(url string, attrs string) []byte {
+	return []byte(fmt.Sprintf(`<VAST version="4.0"><Ad id="w"><Wrapper %s><AdSystem>Hop</AdSystem>
+<VASTAdTagURI><![CDATA[%s]]></VASTAdTagURI><Impression><![CDATA[%s#impression]]></Impression></Wrapper></Ad></VAST>`, attrs, url, url))
+}
+
+func TestResolveWrapper(t *testing.T) {
+	fetcher := documents(map[string][]byte{
+		"https://exchange.example.com/vast/inline": readTestdata(t, "vast2_inline.xml"),
+	})
+	doc, err := Parse(readTestdata(t, "vast3_wrapper.xml"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	ads, err := NewResolver(fetcher, 0).Resolve(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if len(ads) != 1 || ads[0].InLine == nil || ads[0].InLine.AdTitle != "Summer Sale" {
+		t.Fatalf("expected the inline ad behind the wrapper, got %+v", ads)
+	}
+	inline := ads[0].InLine
+	if len(inline.Impressions) != 2 || inline.Impressions[1] != "https://exchange.example.com/impression" {
+		t.Fatalf("expected the wrapper impression to be merged, got %q", inline.Impressions)
+	}
+	if len(inline.Errors) != 2 {
+		t.Fatalf("expected the wrapper error URL to be merged, got %q", inline.Errors)
+	}
+	linear := inline.Creatives[0].Linear
+	if len(linear.TrackingEvents) != 7 || linear.TrackingEvents[6].Offset != "00:00:05" {
+		t.Fatalf("expected the wrapper tracking to be merged, got %+v", linear.TrackingEvents)
+	}
+	if len(linear.ClickTracking) != 2 {
+		t.Fatalf("expected the wrapper click tracking to be merged, got %q", linear.ClickTracking)
+	}
+	if inline.Creatives[1].Linear != nil {
+		t.Fatalf("expected the companion creative to stay without a linear part")
+	}
+}
+
+func TestResolveWrapperLimit(t *testing.T) {
+	docs := map[string][]byte{}
+	for i := 1; i <= 3; i++ {
+		docs[fmt.Sprintf("https://hop%d.example.com", i)] = wrapperTo(fmt.Sprintf("https://hop%d.example.com", i+1), "")
+	}
+	docs["https://hop4.example.com"] = readTestdata(t, "vast2_inline.xml")
+	doc, _ := Parse(wrapperTo("https://hop1.example.com", ""))
+
+	ads, err := NewResolver(documents(docs), 4).Resolve(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if got := len(ads[0].InLine.Impressions); got != 5 {
+		t.Fatalf("expected the impressions of four wrappers and the ad, got %d", got)
+	}
+
+	if _, err := NewResolver(documents(docs), 3).Resolve(context.Background(), doc); !errors.Is(err, ErrWrapperLimit) {
+		t.Fatalf("expected ErrWrapperLimit, got %v", err)
+	}
+}
+
+func TestResolveFollowAdditionalWrappers(t *testing.T) {
+	docs := map[string][]byte{
+		"https://hop1.example.com": wrapperTo("https://hop2.example.com", ""),
+		"https://hop2.example.com": readTestdata(t, "vast2_inline.xml"),
+	}
+	doc, _ := Parse(wrapperTo("https://hop1.example.com", `followAdditionalWrappers="false"`))
+	if _, err := NewResolver(documents(docs), 0).Resolve(context.Background(), doc); !errors.Is(err, ErrWrapperLimit) {
+		t.Fatalf("expected ErrWrapperLimit, got %v", err)
+	}
+}
+
+func TestResolveWrapperSchemes(t *testing.T) {
+	for _, uri := range []string{"file:///etc/passwd", "gopher://ads.example.com/vast", "//ads.example.com/vast"} {
+		fetcher := fetcherFunc(func(_ context.Context, url string) ([]byte, error) {
+			t.Fatalf("fetched %s", url)
+			return nil, nil
+		})
+		doc := &VAST{Version: "4.0", Ads: []Ad{{ID: "w", Wrapper: &Wrapper{VASTAdTagURI: uri}}}}
+		if _, err := NewResolver(fetcher, 0).Resolve(context.Background(), doc); !errors.Is(err, ErrInvalidDocument) {
+			t.Fatalf("expected %s to be refused, got %v", uri, err)
+		}
+	}
+}
+
+func TestResolvePod(t *testing.T) {
+	doc, _ := Parse(readTestdata(t, "vast4_pod.xml"))
+	ads, err := NewResolver(documents(nil), 0).Resolve(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if len(ads) != 2 || ads[0].ID != "pod-1" || ads[1].ID != "pod-2" {
+		t.Fatalf("expected the pod in sequence order, got %+v", ads)
+	}
+}
+
+func TestResolvePodFallback(t *testing.T) {
+	doc := &VAST{Version: "4.0", Ads: []Ad{
+		{ID: "pod-1", Sequence: 1, InLine: &InLine{AdTitle: "first"}},
+		{ID: "pod-2", Sequence: 2, Wrapper: &Wrapper{VASTAdTagURI: "https://broken.example.com"}},
+		{ID: "buffet-1", InLine: &InLine{AdTitle: "fallback"}},
+		{ID: "buffet-2", InLine: &InLine{AdTitle: "unused"}},
+	}}
+	ads, err := NewResolver(documents(nil), 0).Resolve(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if len(ads) != 2 || ads[1].ID != "buffet-1" || ads[1].Sequence != 2 {
+		t.Fatalf("expected a stand-alone ad in place of the failed pod ad, got %+v", ads)
+	}
+}
+
+func TestResolveStandalone(t *testing.T) {
+	doc := &VAST{Version: "3.0", Ads: []Ad{
+		{ID: "broken", Wrapper: &Wrapper{VASTAdTagURI: "https://broken.example.com"}},
+		{ID: "first", InLine: &InLine{}},
+		{ID: "second", InLine: &InLine{}},
+	}}
+	ads, err := NewResolver(documents(nil), 0).Resolve(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if len(ads) != 1 || ads[0].ID != "first" {
+		t.Fatalf("expected the first stand-alone ad that resolves, got %+v", ads)
+	}
+}
+
+func TestResolveNoAds(t *testing.T) {
+	ads, err := NewResolver(documents(nil), 0).Resolve(context.Background(), &VAST{Version: "4.0"})
+	if err != nil || len(ads) != 0 {
+		t.Fatalf("expected no ads without an error, got %+v, %v", ads, err)
+	}
+
+	doc := &VAST{Version: "4.0", Ads: []Ad{{ID: "broken", Wrapper: &Wrapper{VASTAdTagURI: "https://broken.example.com"}}}}
+	if _, err := NewResolver(documents(nil), 0).Resolve(context.Background(), doc); err == nil {
+		t.Fatalf("expected an error when no offered ad resolves")
+	}
+}
@@ -0,0 +1,309 @@
+// Package vast parses IAB VAST 2.0-4.x ad responses and VMAP ad schedules and
+// resolves VAST wrappers to the inline ads they lead to.
+package vast
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidDocument is returned for documents that are not VAST 2-4 or VMAP 1
+	ErrInvalidDocument = errors.New("invalid VAST or VMAP document")
+	// ErrWrapperLimit is returned when a wrapper chain is deeper than the resolver follows
+	ErrWrapperLimit = errors.New("VAST wrapper limit reached")
+)
+
+// VAST is a VAST 2.0 to 4.x response
+type VAST struct {
+	XMLName xml.Name `xml:"VAST"`
+	Version string   `xml:"version,attr"`
+	Ads     []Ad     `xml:"Ad"`
+	Errors  []string `xml:"Error"` // called when the response has no ads
+}
+
+// Ad is an inline ad or a wrapper pointing to another VAST response. Ads with a
+// sequence form an ad pod; ads without one are stand-alone.
+type Ad struct {
+	ID       string   `xml:"id,attr"`
+	Sequence int      `xml:"sequence,attr"`
+	InLine   *InLine  `xml:"InLine"`
+	Wrapper  *Wrapper `xml:"Wrapper"`
+}
+
+// InLine is an ad with its creatives
+type InLine struct {
+	AdSystem    string      `xml:"AdSystem"`
+	AdTitle     string      `xml:"AdTitle"`
+	AdServingID string      `xml:"AdServingId"`
+	Impressions []string    `xml:"Impression"`
+	Errors      []string    `xml:"Error"`
+	Categories  []Category  `xml:"Category"`
+	Creatives   []Creative  `xml:"Creatives>Creative"`
+	Extensions  []Extension `xml:"Extensions>Extension"`
+}
+
+// CampaignID returns the campaign of an ad, given by ad servers as the text of
+// an extension of type "campaign"
+func (i *InLine) CampaignID() string {
+	for _, e := range i.Extensions {
+		if strings.EqualFold(e.Type, "campaign") {
+			return e.Value
+		}
+	}
+	return ""
+}
+
+// Wrapper points to another VAST response and adds its own tracking to the ads
+// found there
+type Wrapper struct {
+	AdSystem                 string     `xml:"AdSystem"`
+	VASTAdTagURI             string     `xml:"VASTAdTagURI"`
+	Impressions              []string   `xml:"Impression"`
+	Errors                   []string   `xml:"Error"`
+	Creatives                []Creative `xml:"Creatives>Creative"`
+	FollowAdditionalWrappers *bool      `xml:"followAdditionalWrappers,attr"`
+	AllowMultipleAds         *bool      `xml:"allowMultipleAds,attr"`
+}
+
+// Category is a category of the advertiser's business, such as an IAB content
+// category code. VAST 4 ads may have several.
+type Category struct {
+	Authority string `xml:"authority,attr"`
+	Value     string `xml:",chardata"`
+}
+
+// Extension is ad server specific data. Only the text of simple extensions is
+// kept.
+type Extension struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Creative is a linear creative, a set of companion ads or both
+type Creative struct {
+	ID            string         `xml:"id,attr"`
+	AdID          string         `xml:"adId,attr"`
+	Sequence      int            `xml:"sequence,attr"`
+	UniversalAdID *UniversalAdID `xml:"UniversalAdId"`
+	Linear        *Linear        `xml:"Linear"`
+	Companions    []Companion    `xml:"CompanionAds>Companion"`
+}
+
+// UniversalAdID identifies a creative across ad systems (VAST 4)
+type UniversalAdID struct {
+	IDRegistry string `xml:"idRegistry,attr"`
+	Value      string `xml:",chardata"`
+}
+
+// Linear is a video ad
+type Linear struct {
+	SkipOffset     string      `xml:"skipoffset,attr"`
+	Duration       string      `xml:"Duration"`
+	MediaFiles     []MediaFile `xml:"MediaFiles>MediaFile"`
+	Mezzanine      []string    `xml:"MediaFiles>Mezzanine"`
+	TrackingEvents []Tracking  `xml:"TrackingEvents>Tracking"`
+	ClickThrough   string      `xml:"VideoClicks>ClickThrough"`
+	ClickTracking  []string    `xml:"VideoClicks>ClickTracking"`
+}
+
+// MediaFile is an encode of a linear creative
+type MediaFile struct {
+	ID           string `xml:"id,attr"`
+	Delivery     string `xml:"delivery,attr"` // "progressive" or "streaming"
+	Type         string `xml:"type,attr"`
+	Width        int    `xml:"width,attr"`
+	Height       int    `xml:"height,attr"`
+	Codec        string `xml:"codec,attr"`
+	Bitrate      int    `xml:"bitrate,attr"` // kbps
+	MinBitrate   int    `xml:"minBitrate,attr"`
+	MaxBitrate   int    `xml:"maxBitrate,attr"`
+	APIFramework string `xml:"apiFramework,attr"`
+	URL          string `xml:",chardata"`
+}
+
+// Tracking is a tracking URL called on a playback event
+type Tracking struct {
+	Event  string `xml:"event,attr"`
+	Offset string `xml:"offset,attr"` // for "progress" events
+	URL    string `xml:",chardata"`
+}
+
+// Companion is a display ad shown alongside a linear ad
+type Companion struct {
+	ID              string           `xml:"id,attr"`
+	Width           int              `xml:"width,attr"`
+	Height          int              `xml:"height,attr"`
+	StaticResources []StaticResource `xml:"StaticResource"`
+	IFrameResources []string         `xml:"IFrameResource"`
+	HTMLResources   []string         `xml:"HTMLResource"`
+	ClickThrough    string           `xml:"CompanionClickThrough"`
+	ClickTracking   []string         `xml:"CompanionClickTracking"`
+	TrackingEvents  []Tracking       `xml:"TrackingEvents>Tracking"`
+}
+
+// StaticResource is an image or script resource of a companion ad
+type StaticResource struct {
+	CreativeType string `xml:"creativeType,attr"`
+	URL          string `xml:",chardata"`
+}
+
+// Parse parses a VAST 2.0 to 4.x response
+func Parse(data []byte) (*VAST, error) {
+	var doc VAST
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	major, _, _ := strings.Cut(doc.Version, ".")
+	if major != "2" && major != "3" && major != "4" {
+		return nil, fmt.Errorf("%w: unsupported VAST version %q", ErrInvalidDocument, doc.Version)
+	}
+	doc.trim()
+	return &doc, nil
+}
+
+// RootElement returns the local name of the root element of an XML document,
+// "VAST" or "VMAP" for ad responses
+func RootElement(data []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local
+		}
+	}
+}
+
+// ParseDuration parses a VAST time of the form HH:MM:SS or HH:MM:SS.mmm
+func ParseDuration(value string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("%w: bad time %q", ErrInvalidDocument, value)
+	}
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	seconds, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil || hours < 0 || minutes < 0 || minutes > 59 || seconds < 0 || seconds >= 60 {
+		return 0, fmt.Errorf("%w: bad time %q", ErrInvalidDocument, value)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second)), nil
+}
+
+// FormatTime formats a duration as a VAST time, HH:MM:SS.mmm
+func FormatTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// ExpandMacros replaces the VAST macros of a tracking or ad tag URI:
+// [CACHEBUSTING] and [TIMESTAMP] always, and the macros named in values, such
+// as "ADPLAYHEAD", with their URL-encoded value. Other macros are left as they
+// are.
+func ExpandMacros(uri string, values map[string]string) string {
+	if !strings.Contains(uri, "[") {
+		return uri
+	}
+	replacements := []string{
+		"[CACHEBUSTING]", fmt.Sprintf("%08d", rand.Intn(100000000)),
+		"[TIMESTAMP]", url.QueryEscape(time.Now().UTC().Format("2006-01-02T15:04:05.000Z07:00")),
+	}
+	for name, value := range values {
+		replacements = append(replacements, "["+name+"]", url.QueryEscape(value))
+	}
+	return strings.NewReplacer(replacements...).Replace(uri)
+}
+
+// ParseOffset parses a skip or progress offset, a VAST time or a percentage of
+// the creative's duration
+func ParseOffset(value string, duration time.Duration) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		p, err := strconv.ParseFloat(percent, 64)
+		if err != nil || p < 0 || p > 100 {
+			return 0, fmt.Errorf("%w: bad offset %q", ErrInvalidDocument, value)
+		}
+		return time.Duration(float64(duration) * p / 100), nil
+	}
+	return ParseDuration(value)
+}
+
+// trim removes the whitespace around URLs and other text, which VAST responses
+// commonly put around CDATA sections
+func (v *VAST) trim() {
+	trimAll(v.Errors)
+	for i := range v.Ads {
+		if inline := v.Ads[i].InLine; inline != nil {
+			inline.AdSystem = strings.TrimSpace(inline.AdSystem)
+			inline.AdTitle = strings.TrimSpace(inline.AdTitle)
+			inline.AdServingID = strings.TrimSpace(inline.AdServingID)
+			trimAll(inline.Impressions)
+			trimAll(inline.Errors)
+			trimCreatives(inline.Creatives)
+			for j := range inline.Categories {
+				inline.Categories[j].Value = strings.TrimSpace(inline.Categories[j].Value)
+			}
+			for j := range inline.Extensions {
+				inline.Extensions[j].Value = strings.TrimSpace(inline.Extensions[j].Value)
+			}
+		}
+		if wrapper := v.Ads[i].Wrapper; wrapper != nil {
+			wrapper.AdSystem = strings.TrimSpace(wrapper.AdSystem)
+			wrapper.VASTAdTagURI = strings.TrimSpace(wrapper.VASTAdTagURI)
+			trimAll(wrapper.Impressions)
+			trimAll(wrapper.Errors)
+			trimCreatives(wrapper.Creatives)
+		}
+	}
+}
+
+func trimCreatives(creatives []Creative) {
+	for i := range creatives {
+		c := &creatives[i]
+		if c.UniversalAdID != nil {
+			c.UniversalAdID.Value = strings.TrimSpace(c.UniversalAdID.Value)
+		}
+		if l := c.Linear; l != nil {
+			l.Duration = strings.TrimSpace(l.Duration)
+			l.ClickThrough = strings.TrimSpace(l.ClickThrough)
+			trimAll(l.Mezzanine)
+			trimAll(l.ClickTracking)
+			trimTracking(l.TrackingEvents)
+			for j := range l.MediaFiles {
+				l.MediaFiles[j].URL = strings.TrimSpace(l.MediaFiles[j].URL)
+			}
+		}
+		for j := range c.Companions {
+			companion := &c.Companions[j]
+			companion.ClickThrough = strings.TrimSpace(companion.ClickThrough)
+			trimAll(companion.IFrameResources)
+			trimAll(companion.HTMLResources)
+			trimAll(companion.ClickTracking)
+			trimTracking(companion.TrackingEvents)
+			for k := range companion.StaticResources {
+				companion.StaticResources[k].URL = strings.TrimSpace(companion.StaticResources[k].URL)
+			}
+		}
+	}
+}
+
+func trimTracking(events []Tracking) {
+	for i := range events {
+		events[i].URL = strings.TrimSpace(events[i].URL)
+	}
+}
+
+func trimAll(values []string) {
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+}
@@ -0,0 +1,223 @@
+package vast
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return data
+}
+
+func TestParseVAST2Inline(t *testing.T) {
+	doc, err := Parse(readTestdata(t, "vast2_inline.xml"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(doc.Ads) != 1 || doc.Ads[0].InLine == nil {
+		t.Fatalf("expected one inline ad, got %+v", doc.Ads)
+	}
+	inline := doc.Ads[0].InLine
+	if inline.AdTitle != "Summer Sale" || inline.AdSystem != "StreamVerse Ads" {
+		t.Fatalf("unexpected ad %q from %q", inline.AdTitle, inline.AdSystem)
+	}
+	if len(inline.Impressions) != 1 || inline.Impressions[0] != "https://ads.example.com/impression?ad=2001" {
+		t.Fatalf("expected the trimmed impression URL, got %q", inline.Impressions)
+	}
+	if len(inline.Creatives) != 2 {
+		t.Fatalf("expected a linear and a companion creative, got %d", len(inline.Creatives))
+	}
+
+	linear := inline.Creatives[0].Linear
+	if linear == nil || linear.Duration != "00:00:15" || len(linear.MediaFiles) != 4 || len(linear.TrackingEvents) != 5 {
+		t.Fatalf("unexpected linear creative %+v", linear)
+	}
+	if linear.ClickThrough != "https://shop.example.com/summer" || linear.ClickTracking[0] != "https://ads.example.com/click?ad=2001" {
+		t.Fatalf("unexpected video clicks %q %q", linear.ClickThrough, linear.ClickTracking)
+	}
+	if vpaid := linear.MediaFiles[0]; vpaid.APIFramework != "VPAID" || vpaid.URL != "https://ads.example.com/vpaid.js" {
+		t.Fatalf("unexpected VPAID media file %+v", vpaid)
+	}
+
+	companions := inline.Creatives[1].Companions
+	if len(companions) != 1 || companions[0].Width != 300 || companions[0].StaticResources[0].CreativeType != "image/png" {
+		t.Fatalf("unexpected companions %+v", companions)
+	}
+}
+
+func TestParseVAST4Pod(t *testing.T) {
+	doc, err := Parse(readTestdata(t, "vast4_pod.xml"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if doc.Version != "4.1" || len(doc.Ads) != 3 {
+		t.Fatalf("expected 3 ads in a VAST 4.1 response, got %d in %q", len(doc.Ads), doc.Version)
+	}
+	ad := doc.Ads[0]
+	if ad.Sequence != 2 || ad.InLine.AdServingID != "serving-4002" {
+		t.Fatalf("unexpected first ad %+v", ad)
+	}
+	creative := ad.InLine.Creatives[0]
+	if creative.AdID != "car-30" || creative.UniversalAdID == nil || creative.UniversalAdID.Value != "CAR00030000H" {
+		t.Fatalf("unexpected creative ids %+v", creative)
+	}
+	if campaign := ad.InLine.CampaignID(); campaign != "spring-cars" {
+		t.Fatalf("expected the campaign extension, got %q", campaign)
+	}
+	if len(ad.InLine.Categories) != 1 || ad.InLine.Categories[0].Value != "IAB2" {
+		t.Fatalf("expected the IAB2 category, got %+v", ad.InLine.Categories)
+	}
+	if creative.Linear.SkipOffset != "00:00:05" || len(creative.Linear.Mezzanine) != 1 {
+		t.Fatalf("unexpected linear creative %+v", creative.Linear)
+	}
+	if hls := creative.Linear.MediaFiles[0]; hls.Delivery != "streaming" || hls.MaxBitrate != 4000 || hls.Codec != "avc1.640028,mp4a.40.2" {
+		t.Fatalf("unexpected streaming media file %+v", hls)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"not XML", "no ads today"},
+		{"VMAP", `<vmap:VMAP xmlns:vmap="http://www.iab.net/videosuite/vmap" version="1.0"/>`},
+		{"VAST 1", `<VideoAdServingTemplate/>`},
+		{"unsupported version", `<VAST version="5.0"/>`},
+		{"missing version", `<VAST/>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.doc)); !errors.Is(err, ErrInvalidDocument) {
+				t.Fatalf("expected ErrInvalidDocument, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEmptyResponse(t *testing.T) {
+	doc, err := Parse([]byte(`<VAST version="4.0"><Error><![CDATA[https://ads.example.com/error?code=303]]></Error></VAST>`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(doc.Ads) != 0 || len(doc.Errors) != 1 {
+		t.Fatalf("expected a no-ad response with an error URL, got %+v", doc)
+	}
+}
+
+func TestRootElement(t *testing.T) {
+	if got := RootElement(readTestdata(t, "vmap.xml")); got != "VMAP" {
+		t.Fatalf("expected VMAP, got %q", got)
+	}
+	if got := RootElement(readTestdata(t, "vast4_pod.xml")); got != "VAST" {
+		t.Fatalf("expected VAST, got %q", got)
+	}
+	if got := RootElement([]byte("")); got != "" {
+		t.Fatalf("expected no root element, got %q", got)
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	got := ExpandMacros("https://t.example.com/e?cb=[CACHEBUSTING]&ts=[TIMESTAMP]&ph=[ADPLAYHEAD]&err=[ERRORCODE]",
+		map[string]string{"ADPLAYHEAD": FormatTime(62500 * time.Millisecond)})
+	if strings.Contains(got, "[CACHEBUSTING]") || strings.Contains(got, "[TIMESTAMP]") {
+		t.Fatalf("expected cache-busting and timestamp macros to be replaced, got %s", got)
+	}
+	if !strings.Contains(got, "ph=00%3A01%3A02.500") || !strings.Contains(got, "err=[ERRORCODE]") {
+		t.Fatalf("expected the encoded playhead and unknown macros left alone, got %s", got)
+	}
+}
+
+func TestParseDurationAndOffset(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"00:00:15", 15 * time.Second, false},
+		{"00:01:02.500", 62500 * time.Millisecond, false},
+		{"01:00:00", time.Hour, false},
+		{"25%", 5 * time.Second, false},
+		{"100%", 20 * time.Second, false},
+		{"15", 0, true},
+		{"00:60:00", 0, true},
+		{"150%", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseOffset(tt.value, 20*time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseVMAP(t *testing.T) {
+	doc, err := ParseVMAP(readTestdata(t, "vmap.xml"))
+	if err != nil {
+		t.Fatalf("ParseVMAP: %v", err)
+	}
+	if len(doc.AdBreaks) != 4 {
+		t.Fatalf("expected 4 breaks, got %d", len(doc.AdBreaks))
+	}
+	pre := doc.AdBreaks[0]
+	if pre.BreakID != "preroll" || pre.AdSource.VASTAdData == nil || len(pre.AdSource.VASTAdData.VAST.Ads) != 1 {
+		t.Fatalf("expected an embedded VAST response in the pre-roll, got %+v", pre.AdSource)
+	}
+	if len(pre.TrackingEvents) != 1 || pre.TrackingEvents[0].Event != "breakStart" {
+		t.Fatalf("unexpected break tracking %+v", pre.TrackingEvents)
+	}
+	if uri := doc.AdBreaks[1].AdSource.AdTagURI; uri != "https://ads.example.com/vast?pos=mid" {
+		t.Fatalf("expected the trimmed ad tag URI, got %q", uri)
+	}
+
+	if _, err := ParseVMAP(readTestdata(t, "vast4_pod.xml")); !errors.Is(err, ErrInvalidDocument) {
+		t.Fatalf("expected ErrInvalidDocument for a VAST response, got %v", err)
+	}
+}
+
+func TestAdBreakPosition(t *testing.T) {
+	tests := []struct {
+		offset     string
+		content    time.Duration
+		wantPos    string
+		wantOffset time.Duration
+		wantErr    bool
+	}{
+		{"start", 0, PreRoll, 0, false},
+		{"00:00:00.000", time.Hour, PreRoll, 0, false},
+		{"00:10:00.000", time.Hour, MidRoll, 10 * time.Minute, false},
+		{"00:10:00.000", 0, MidRoll, 10 * time.Minute, false},
+		{"50%", time.Hour, MidRoll, 30 * time.Minute, false},
+		{"end", time.Hour, PostRoll, time.Hour, false},
+		{"100%", 0, PostRoll, 0, false},
+		{"01:30:00", time.Hour, PostRoll, time.Hour, false},
+		{"50%", 0, "", 0, true},
+		{"#2", time.Hour, "", 0, true},
+		{"soon", time.Hour, "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.offset, func(t *testing.T) {
+			pos, offset, err := AdBreak{TimeOffset: tt.offset}.Position(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if pos != tt.wantPos || offset != tt.wantOffset {
+				t.Fatalf("expected %s at %v, got %s at %v", tt.wantPos, tt.wantOffset, pos, offset)
+			}
+		})
+	}
+}
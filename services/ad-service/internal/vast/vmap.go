@@ -0,0 +1,115 @@
+package vast
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Positions of VMAP ad breaks
+const (
+	PreRoll  = "pre-roll"
+	MidRoll  = "mid-roll"
+	PostRoll = "post-roll"
+)
+
+// VMAP is an IAB VMAP 1.0 ad schedule. Elements are matched by local name, so
+// documents with and without the vmap namespace prefix both parse.
+type VMAP struct {
+	XMLName  xml.Name  `xml:"VMAP"`
+	Version  string    `xml:"version,attr"`
+	AdBreaks []AdBreak `xml:"AdBreak"`
+}
+
+// AdBreak is a scheduled ad break and the ads to play in it
+type AdBreak struct {
+	TimeOffset     string     `xml:"timeOffset,attr"` // "start", "end", HH:MM:SS.mmm, "n%" or "#n"
+	BreakType      string     `xml:"breakType,attr"`  // "linear", "nonlinear" or "display"
+	BreakID        string     `xml:"breakId,attr"`
+	AdSource       *AdSource  `xml:"AdSource"`
+	TrackingEvents []Tracking `xml:"TrackingEvents>Tracking"` // breakStart, breakEnd and error
+}
+
+// AdSource holds the VAST response of a break, inline or by reference
+type AdSource struct {
+	ID               string      `xml:"id,attr"`
+	AllowMultipleAds *bool       `xml:"allowMultipleAds,attr"`
+	FollowRedirects  *bool       `xml:"followRedirects,attr"`
+	VASTAdData       *VASTAdData `xml:"VASTAdData"`
+	AdTagURI         string      `xml:"AdTagURI"`
+}
+
+// VASTAdData is a VAST response embedded in a VMAP document
+type VASTAdData struct {
+	VAST VAST `xml:"VAST"`
+}
+
+// ParseVMAP parses a VMAP 1.0 document and the VAST responses embedded in it
+func ParseVMAP(data []byte) (*VMAP, error) {
+	var doc VMAP
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	if major, _, _ := strings.Cut(doc.Version, "."); major != "1" {
+		return nil, fmt.Errorf("%w: unsupported VMAP version %q", ErrInvalidDocument, doc.Version)
+	}
+	for i := range doc.AdBreaks {
+		b := &doc.AdBreaks[i]
+		b.TimeOffset = strings.TrimSpace(b.TimeOffset)
+		trimTracking(b.TrackingEvents)
+		if b.AdSource == nil {
+			continue
+		}
+		b.AdSource.AdTagURI = strings.TrimSpace(b.AdSource.AdTagURI)
+		if data := b.AdSource.VASTAdData; data != nil {
+			data.VAST.trim()
+		}
+	}
+	return &doc, nil
+}
+
+// Position returns where a break plays and, for mid-rolls, its offset into the
+// content. Percentages are resolved against the content duration and fail when
+// it is unknown; breaks placed by their position among the content's cue points
+// ("#n") cannot be placed by the server.
+func (b AdBreak) Position(contentDuration time.Duration) (string, time.Duration, error) {
+	switch b.TimeOffset {
+	case "start":
+		return PreRoll, 0, nil
+	case "end":
+		return PostRoll, contentDuration, nil
+	}
+	if strings.HasPrefix(b.TimeOffset, "#") {
+		return "", 0, fmt.Errorf("%w: cannot place break at cue point %s", ErrInvalidDocument, b.TimeOffset)
+	}
+
+	var offset time.Duration
+	if percent, ok := strings.CutSuffix(b.TimeOffset, "%"); ok {
+		p, err := strconv.ParseFloat(percent, 64)
+		if err != nil || p < 0 || p > 100 {
+			return "", 0, fmt.Errorf("%w: bad time offset %q", ErrInvalidDocument, b.TimeOffset)
+		}
+		if p == 100 {
+			return PostRoll, contentDuration, nil
+		}
+		if p > 0 && contentDuration <= 0 {
+			return "", 0, fmt.Errorf("%w: time offset %s needs the content duration", ErrInvalidDocument, b.TimeOffset)
+		}
+		offset = time.Duration(float64(contentDuration) * p / 100)
+	} else {
+		var err error
+		if offset, err = ParseDuration(b.TimeOffset); err != nil {
+			return "", 0, err
+		}
+	}
+
+	switch {
+	case offset == 0:
+		return PreRoll, 0, nil
+	case contentDuration > 0 && offset >= contentDuration:
+		return PostRoll, contentDuration, nil
+	}
+	return MidRoll, offset, nil
+}
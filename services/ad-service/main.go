@@ -9,11 +9,14 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/streamverse/common-go/cache"
 	"github.com/streamverse/common-go/config"
 	"github.com/streamverse/common-go/database"
 	"github.com/streamverse/common-go/logger"
 	"github.com/streamverse/common-go/middleware"
 	adHandler "github.com/streamverse/ad-service/handlers"
+	"github.com/streamverse/ad-service/internal/clients/adserver"
+	"github.com/streamverse/ad-service/internal/clients/payment"
 	"github.com/streamverse/ad-service/repository"
 	"github.com/streamverse/ad-service/service"
 )
@@ -40,7 +43,29 @@ func main() {
 	defer db.Disconnect(context.Background())
 
 	adRepo := repository.NewAdRepository(db)
-	adService := service.NewAdService(adRepo)
+
+	// Ad-free decisions and frequency caps live in Redis
+	redisClient := cache.NewRedisClient(
+		cfg.Redis.Host+":"+cfg.Redis.Port,
+		cfg.Redis.Password,
+		cfg.Redis.DB,
+		log,
+	)
+	defer redisClient.Close()
+	eligibilityRepo := repository.NewEligibilityRepository(redisClient)
+
+	// VAST/VMAP ad tag, the local mock ad server (cmd/mock-ad-server) by default
+	adServerTimeout, _ := time.ParseDuration(os.Getenv("AD_SERVER_TIMEOUT"))
+	adServerClient := adserver.NewClient(os.Getenv("AD_SERVER_URL"), adServerTimeout)
+
+	adService := service.NewAdService(
+		adRepo,
+		eligibilityRepo,
+		adServerClient,
+		payment.NewEntitlementsClient(os.Getenv("PAYMENT_SERVICE_URL")),
+		service.TrackingConfigFromEnv(),
+		service.EligibilityConfigFromEnv(),
+	)
 	adHandler := adHandler.NewAdHandler(adService, log)
 
 	router := gin.Default()
@@ -55,6 +80,9 @@ func main() {
 	{
 		api.POST("/request", adHandler.GetAds)
 		api.POST("/track", adHandler.TrackAdEvent)
+		api.POST("/progress", adHandler.ReportProgress)
+		api.GET("/tracking", middleware.RequireRole("admin"), adHandler.ListTracking)
+		api.GET("/tracking/report", middleware.RequireRole("admin"), adHandler.ReportTracking)
 	}
 
 	srv := &http.Server{
@@ -85,6 +113,9 @@ func main() {
 		log.Fatal("Server forced to shutdown", logger.Error(err))
 	}
 
+	// Let beacons of the last reported events go out
+	adService.Wait()
+
 	log.Info("Server exited")
 }
 
@@ -8,37 +8,164 @@ import (
 
 // AdRequest represents an ad request
 type AdRequest struct {
-	ContentID   string `json:"contentId"`
-	UserID      string `json:"userId"`
-	DeviceType  string `json:"deviceType"`
-	Position    string `json:"position"` // "pre-roll", "mid-roll", "post-roll"
-	CuePoint    int64  `json:"cuePoint,omitempty"` // For mid-roll
+	ContentID       string   `json:"contentId"`
+	UserID          string   `json:"userId"`
+	DeviceType      string   `json:"deviceType"`
+	Position        string   `json:"position"` // "pre-roll", "mid-roll", "post-roll"
+	CuePoint        int64    `json:"cuePoint,omitempty"` // For mid-roll, seconds into the content
+	ContentDuration int64    `json:"contentDuration,omitempty"` // Seconds, places VMAP breaks given as percentages
+	Width           int      `json:"width,omitempty"` // Player or stitcher dimensions for media file selection
+	Height          int      `json:"height,omitempty"`
+	MaxBitrate      int      `json:"maxBitrate,omitempty"` // kbps
+	MimeTypes       []string `json:"mimeTypes,omitempty"` // Accepted media types, most preferred first
+	Codecs          []string `json:"codecs,omitempty"` // Accepted codec prefixes such as "avc1"
 }
 
 // AdResponse represents ad response
 type AdResponse struct {
-	Ads         []Ad   `json:"ads"`
-	AdPodURL    string `json:"adPodUrl,omitempty"` // For SSAI
-	SkipAllowed bool   `json:"skipAllowed"`
+	Ads         []Ad      `json:"ads"` // The ads of the requested position
+	Breaks      []AdBreak `json:"breaks,omitempty"` // Every break of a VMAP schedule
+	AdPodURL    string    `json:"adPodUrl,omitempty"` // For SSAI
+	SkipAllowed bool      `json:"skipAllowed"`
 }
 
-// Ad represents an ad
+// AdBreak is a break of a VMAP ad schedule
+type AdBreak struct {
+	ID         string          `json:"id"`
+	Position   string          `json:"position"` // "pre-roll", "mid-roll", "post-roll"
+	TimeOffset float64         `json:"timeOffset"` // Seconds into the content
+	Ads        []Ad            `json:"ads"`
+	Tracking   []TrackingEvent `json:"tracking,omitempty"` // breakStart, breakEnd, error
+}
+
+// Ad represents a resolved linear ad, ready to stitch
 type Ad struct {
-	ID          string `json:"id"`
-	Title       string `json:"title"`
-	VASTURL     string `json:"vastUrl"`
-	Duration    int    `json:"duration"`
-	ClickURL    string `json:"clickUrl,omitempty"`
-	ImpressURL  string `json:"impressUrl,omitempty"`
+	ID            string          `json:"id"`
+	Sequence      int             `json:"sequence,omitempty"` // Position in an ad pod
+	AdSystem      string          `json:"adSystem,omitempty"`
+	Title         string          `json:"title"`
+	CreativeID    string          `json:"creativeId,omitempty"`
+	CampaignID    string          `json:"campaignId,omitempty"`
+	Categories    []string        `json:"categories,omitempty"` // Advertiser categories such as IAB codes
+	DeliveryID    string          `json:"deliveryId,omitempty"` // Identifies this ad to /progress and /track
+	UniversalAdID string          `json:"universalAdId,omitempty"`
+	Duration      float64         `json:"duration"` // Seconds
+	SkipOffset    float64         `json:"skipOffset,omitempty"` // Seconds, 0 when not skippable
+	MediaFile     *MediaFile      `json:"mediaFile"`
+	Mezzanine     string          `json:"mezzanine,omitempty"` // Source file for transcoding into the stream's ladder
+	ClickURL      string          `json:"clickUrl,omitempty"`
+	ClickTracking []string        `json:"clickTracking,omitempty"`
+	Impressions   []string        `json:"impressions,omitempty"`
+	ErrorURLs     []string        `json:"errorUrls,omitempty"`
+	Tracking      []TrackingEvent `json:"tracking,omitempty"`
+	Companions    []Companion     `json:"companions,omitempty"`
+}
+
+// MediaFile is the encode of an ad selected for the requesting player
+type MediaFile struct {
+	URL      string `json:"url"`
+	Type     string `json:"type"`
+	Delivery string `json:"delivery,omitempty"` // "progressive" or "streaming"
+	Codec    string `json:"codec,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	Bitrate  int    `json:"bitrate,omitempty"` // kbps
+}
+
+// TrackingEvent is a URL to call on a playback event
+type TrackingEvent struct {
+	Event  string  `json:"event"`
+	Offset float64 `json:"offset,omitempty"` // Seconds, for "progress" events
+	URL    string  `json:"url"`
+}
+
+// Companion is a display ad shown alongside a linear ad
+type Companion struct {
+	ID            string          `json:"id,omitempty"`
+	Width         int             `json:"width"`
+	Height        int             `json:"height"`
+	ResourceType  string          `json:"resourceType"` // "static", "iframe", "html"
+	CreativeType  string          `json:"creativeType,omitempty"` // MIME type of a static resource
+	Resource      string          `json:"resource"`
+	ClickURL      string          `json:"clickUrl,omitempty"`
+	ClickTracking []string        `json:"clickTracking,omitempty"`
+	Tracking      []TrackingEvent `json:"tracking,omitempty"`
 }
 
-// AdTracking represents ad tracking event
+// Ad event types. Players report them, and for SSAI the server derives the
+// impression and quartiles from reported playback progress.
+const (
+	EventImpression    = "impression"
+	EventStart         = "start"
+	EventFirstQuartile = "firstQuartile"
+	EventMidpoint      = "midpoint"
+	EventThirdQuartile = "thirdQuartile"
+	EventComplete      = "complete"
+	EventClick         = "click"
+	EventSkip          = "skip"
+)
+
+// Sources of tracking events
+const (
+	TrackingSourceClient = "client" // reported by the player
+	TrackingSourceServer = "server" // derived from playback progress
+)
+
+// AdTracking represents ad tracking event. There is at most one event of each
+// type per delivery.
 type AdTracking struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	AdID        string             `bson:"ad_id" json:"adId"`
-	UserID      string             `bson:"user_id" json:"userId"`
-	EventType   string             `bson:"event_type" json:"eventType"` // "impression", "click", "complete", "skip"
-	ContentID   string             `bson:"content_id" json:"contentId"`
-	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	AdID          string             `bson:"ad_id" json:"adId"`
+	CampaignID    string             `bson:"campaign_id,omitempty" json:"campaignId,omitempty"`
+	CreativeID    string             `bson:"creative_id,omitempty" json:"creativeId,omitempty"`
+	DeliveryID    string             `bson:"delivery_id,omitempty" json:"deliveryId,omitempty"`
+	UserID        string             `bson:"user_id" json:"userId"`
+	EventType     string             `bson:"event_type" json:"eventType"` // "impression", "start", quartiles, "complete", "click", "skip"
+	ContentID     string             `bson:"content_id" json:"contentId"`
+	Source        string             `bson:"source" json:"source"` // "client" or "server"
+	Beacons       []BeaconResult     `bson:"beacons,omitempty" json:"beacons,omitempty"`
+	BeaconsFailed int                `bson:"beacons_failed" json:"beaconsFailed"`
+	CreatedAt     time.Time          `bson:"created_at" json:"createdAt"`
 }
 
+// BeaconResult is the outcome of calling a tracking URL of an event
+type BeaconResult struct {
+	URL        string     `bson:"url" json:"url"`
+	StatusCode int        `bson:"status_code,omitempty" json:"statusCode,omitempty"`
+	Attempts   int        `bson:"attempts" json:"attempts"`
+	Error      string     `bson:"error,omitempty" json:"error,omitempty"`
+	SentAt     *time.Time `bson:"sent_at,omitempty" json:"sentAt,omitempty"`
+}
+
+// AdDelivery is an ad served to a viewer, holding the tracking URLs the server
+// calls as the viewer's playback of the ad is reported
+type AdDelivery struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	AdID       string              `bson:"ad_id" json:"adId"`
+	CampaignID string              `bson:"campaign_id,omitempty" json:"campaignId,omitempty"`
+	CreativeID string              `bson:"creative_id,omitempty" json:"creativeId,omitempty"`
+	UserID     string              `bson:"user_id" json:"userId"`
+	ContentID  string              `bson:"content_id" json:"contentId"`
+	Duration   float64             `bson:"duration" json:"duration"` // Seconds
+	Beacons    map[string][]string `bson:"beacons" json:"beacons"` // Tracking URLs by event type
+	CreatedAt  time.Time           `bson:"created_at" json:"createdAt"`
+	ExpiresAt  time.Time           `bson:"expires_at" json:"expiresAt"`
+}
+
+// TrackingFilter selects tracking events for reconciliation
+type TrackingFilter struct {
+	AdID       string
+	CampaignID string
+	ContentID  string
+	EventType  string
+	From       time.Time // inclusive, zero for no lower bound
+	To         time.Time // exclusive, zero for no upper bound
+	Limit      int64
+}
+
+// TrackingReportRow counts the tracking events of an ad, campaign or content item
+type TrackingReportRow struct {
+	Key           string           `json:"key"`
+	Events        map[string]int64 `json:"events"` // by event type
+	BeaconsFailed int64            `json:"beaconsFailed"` // tracking URLs that could not be called
+}
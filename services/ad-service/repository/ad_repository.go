@@ -2,47 +2,194 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"github.com/streamverse/common-go/database"
 	"github.com/streamverse/ad-service/models"
 )
 
-// AdRepository handles ad operations
+// AdRepository stores ad deliveries and tracking events. A delivery has at
+// most one event of each type, enforced by a partial unique index.
 type AdRepository struct {
-	adCollection      interface{}
-	trackingCollection interface{}
+	deliveryCollection *mongo.Collection
+	trackingCollection *mongo.Collection
 }
 
 // NewAdRepository creates a new ad repository
 func NewAdRepository(db *database.MongoDB) *AdRepository {
-	return &AdRepository{
-		adCollection:       db.Collection("ads"),
-		trackingCollection: db.Collection("ad_tracking"),
-	}
-}
-
-// GetAdsByTargeting retrieves ads based on targeting
-func (r *AdRepository) GetAdsByTargeting(ctx context.Context, req *models.AdRequest) []models.Ad {
-	// Mock implementation - would integrate with Google Ad Manager
-	return []models.Ad{
-		{
-			ID:        "ad1",
-			Title:     "Sample Ad",
-			VASTURL:   "https://ads.example.com/vast.xml",
-			Duration:  30,
-			ClickURL:  "https://ads.example.com/click",
-			ImpressURL: "https://ads.example.com/impress",
+	deliveries := db.Collection("ad_deliveries")
+	tracking := db.Collection("ad_tracking")
+
+	_, _ = deliveries.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	)
+	_, _ = tracking.Indexes().CreateMany(
+		context.Background(),
+		[]mongo.IndexModel{
+			{
+				Keys: bson.D{{Key: "delivery_id", Value: 1}, {Key: "event_type", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"delivery_id": bson.M{"$type": "string"}}),
+			},
+			{Keys: bson.D{{Key: "ad_id", Value: 1}, {Key: "created_at", Value: 1}}},
+			{Keys: bson.D{{Key: "campaign_id", Value: 1}, {Key: "created_at", Value: 1}}},
+			{Keys: bson.D{{Key: "content_id", Value: 1}, {Key: "created_at", Value: 1}}},
 		},
+	)
+
+	return &AdRepository{
+		deliveryCollection: deliveries,
+		trackingCollection: tracking,
 	}
 }
 
-// CreateTracking creates ad tracking event
-func (r *AdRepository) CreateTracking(ctx context.Context, tracking *models.AdTracking) error {
+// CreateDelivery stores an ad delivery
+func (r *AdRepository) CreateDelivery(ctx context.Context, delivery *models.AdDelivery) error {
+	if delivery.ID.IsZero() {
+		delivery.ID = primitive.NewObjectID()
+	}
+	_, err := r.deliveryCollection.InsertOne(ctx, delivery)
+	return err
+}
+
+// GetDelivery retrieves an ad delivery by ID, or nil if there is none
+func (r *AdRepository) GetDelivery(ctx context.Context, id string) (*models.AdDelivery, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+	var delivery models.AdDelivery
+	err = r.deliveryCollection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&delivery)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// CreateTracking stores a tracking event, reporting false if the delivery
+// already has an event of the type
+func (r *AdRepository) CreateTracking(ctx context.Context, tracking *models.AdTracking) (bool, error) {
 	tracking.ID = primitive.NewObjectID()
 	tracking.CreatedAt = time.Now()
-	// Would insert to MongoDB
-	return nil
+	_, err := r.trackingCollection.InsertOne(ctx, tracking)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UpdateTrackingBeacons records the outcome of calling the tracking URLs of an event
+func (r *AdRepository) UpdateTrackingBeacons(ctx context.Context, id primitive.ObjectID, beacons []models.BeaconResult) error {
+	failed := 0
+	for _, b := range beacons {
+		if b.Error != "" {
+			failed++
+		}
+	}
+	_, err := r.trackingCollection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"beacons":        beacons,
+		"beacons_failed": failed,
+	}})
+	return err
 }
 
+// ListTracking retrieves tracking events, oldest first
+func (r *AdRepository) ListTracking(ctx context.Context, filter models.TrackingFilter) ([]models.AdTracking, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+	cursor, err := r.trackingCollection.Find(ctx, trackingQuery(filter), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var events []models.AdTracking
+	if err = cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ReportTracking counts tracking events by event type and by the value of a
+// field, "ad_id", "campaign_id" or "content_id"
+func (r *AdRepository) ReportTracking(ctx context.Context, filter models.TrackingFilter, field string) ([]models.TrackingReportRow, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: trackingQuery(filter)}},
+		{{Key: "$group", Value: bson.M{
+			"_id":            bson.M{"key": "$" + field, "event_type": "$event_type"},
+			"count":          bson.M{"$sum": 1},
+			"beacons_failed": bson.M{"$sum": "$beacons_failed"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.key", Value: 1}}}},
+	}
+
+	cursor, err := r.trackingCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		ID struct {
+			Key       string `bson:"key"`
+			EventType string `bson:"event_type"`
+		} `bson:"_id"`
+		Count         int64 `bson:"count"`
+		BeaconsFailed int64 `bson:"beacons_failed"`
+	}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	rows := []models.TrackingReportRow{}
+	for _, g := range groups {
+		if len(rows) == 0 || rows[len(rows)-1].Key != g.ID.Key {
+			rows = append(rows, models.TrackingReportRow{Key: g.ID.Key, Events: map[string]int64{}})
+		}
+		row := &rows[len(rows)-1]
+		row.Events[g.ID.EventType] += g.Count
+		row.BeaconsFailed += g.BeaconsFailed
+	}
+	return rows, nil
+}
+
+func trackingQuery(filter models.TrackingFilter) bson.M {
+	query := bson.M{}
+	if filter.AdID != "" {
+		query["ad_id"] = filter.AdID
+	}
+	if filter.CampaignID != "" {
+		query["campaign_id"] = filter.CampaignID
+	}
+	if filter.ContentID != "" {
+		query["content_id"] = filter.ContentID
+	}
+	if filter.EventType != "" {
+		query["event_type"] = filter.EventType
+	}
+	createdAt := bson.M{}
+	if !filter.From.IsZero() {
+		createdAt["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		createdAt["$lt"] = filter.To
+	}
+	if len(createdAt) > 0 {
+		query["created_at"] = createdAt
+	}
+	return query
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/streamverse/common-go/cache"
+)
+
+// EligibilityRepository keeps the short-lived state deciding which ads a user
+// may see in Redis: cached ad-free decisions and per-campaign impression
+// counters, both expiring with their TTL window.
+type EligibilityRepository struct {
+	cache *cache.RedisClient
+}
+
+// NewEligibilityRepository creates a new eligibility repository
+func NewEligibilityRepository(cache *cache.RedisClient) *EligibilityRepository {
+	return &EligibilityRepository{cache: cache}
+}
+
+// GetAdFree returns the cached ad-free decision for a user. found is false when
+// there is none.
+func (r *EligibilityRepository) GetAdFree(ctx context.Context, userID string) (adFree, found bool, err error) {
+	if err := r.cache.Get(ctx, adFreeKey(userID), &adFree); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+	return adFree, true, nil
+}
+
+// SetAdFree caches the ad-free decision for a user
+func (r *EligibilityRepository) SetAdFree(ctx context.Context, userID string, adFree bool, ttl time.Duration) error {
+	return r.cache.Set(ctx, adFreeKey(userID), adFree, ttl)
+}
+
+// ReserveCampaignImpression counts an ad of a campaign served to the user unless
+// limit ads were already counted in the window, reporting whether it did. The
+// check and the increment are atomic, so concurrent requests cannot both take
+// the last impression. The window starts with the first ad counted and lasts
+// window.
+func (r *EligibilityRepository) ReserveCampaignImpression(ctx context.Context, userID, campaignID string, limit int, window time.Duration) (bool, error) {
+	return r.cache.IncrBelow(ctx, frequencyKey(userID, campaignID), int64(limit), window)
+}
+
+func adFreeKey(userID string) string {
+	return fmt.Sprintf("ads:adfree:%s", userID)
+}
+
+func frequencyKey(userID, campaignID string) string {
+	return fmt.Sprintf("ads:frequency:%s:%s", userID, campaignID)
+}
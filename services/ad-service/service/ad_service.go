@@ -1,47 +1,231 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
 
+	"github.com/streamverse/ad-service/internal/beacon"
+	"github.com/streamverse/ad-service/internal/clients/adserver"
+	"github.com/streamverse/ad-service/internal/vast"
 	"github.com/streamverse/ad-service/models"
-	"github.com/streamverse/ad-service/repository"
 )
 
+// ErrAdServerUnavailable is returned when the ad server cannot be reached or
+// does not answer with VAST or VMAP
+var ErrAdServerUnavailable = errors.New("ad server unavailable")
+
 // AdService handles ad business logic
 type AdService struct {
-	repo *repository.AdRepository
+	repo         adStore
+	cache        eligibilityStore
+	adServer     *adserver.Client
+	entitlements entitlementSource
+	resolver     *vast.Resolver
+	tracking     TrackingConfig
+	eligibility  EligibilityConfig
+	beacons      *beaconQueue
 }
 
 // NewAdService creates a new ad service
-func NewAdService(repo *repository.AdRepository) *AdService {
+func NewAdService(
+	repo adStore,
+	cache eligibilityStore,
+	adServer *adserver.Client,
+	entitlements entitlementSource,
+	tracking TrackingConfig,
+	eligibility EligibilityConfig,
+) *AdService {
 	return &AdService{
-		repo: repo,
+		repo:         repo,
+		cache:        cache,
+		adServer:     adServer,
+		entitlements: entitlements,
+		resolver:     vast.NewResolver(adServer, vast.DefaultMaxWrapperDepth),
+		tracking:     tracking,
+		eligibility:  eligibility,
+		beacons: &beaconQueue{
+			sender: beacon.NewSender(adServerAddress(adServer), tracking.BeaconTimeout, tracking.BeaconAttempts, tracking.BeaconBackoff),
+		},
+	}
+}
+
+// adServerAddress returns the host:port beacons may reach the ad server at
+// although it is internal, "" without an ad server
+func adServerAddress(adServer *adserver.Client) string {
+	if adServer == nil {
+		return ""
 	}
+	return adServer.Address()
 }
 
-// GetAds retrieves ads for a content request
-func (s *AdService) GetAds(ctx context.Context, req *models.AdRequest) (*models.AdResponse, error) {
-	// Check if user has ad-free subscription
-	if s.isAdFreeUser(ctx, req.UserID) {
+// GetAds requests ads from the ad server and resolves them into creatives ready
+// for stitching. A VAST response answers the requested position; a VMAP
+// response is resolved break by break and the break matching the requested
+// position supplies the ads. Ads that fail to resolve or have no usable media
+// file are left out, as are ads of campaigns that reached the user's frequency
+// cap and ads sharing a category with an earlier ad of their pod, so a request
+// may be answered with no ads. Users of ad-free plans get none; authHeader
+// identifies the caller to the payment service.
+func (s *AdService) GetAds(ctx context.Context, req *models.AdRequest, authHeader string) (*models.AdResponse, error) {
+	if s.isAdFreeUser(ctx, req.UserID, authHeader) {
 		return &models.AdResponse{Ads: []models.Ad{}}, nil
 	}
 
-	// Get targeted ads
-	ads := s.repo.GetAdsByTargeting(ctx, req)
+	data, err := s.adServer.RequestAds(ctx, targetingParams(req))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAdServerUnavailable, err)
+	}
 
-	return &models.AdResponse{
-		Ads:         ads,
+	response := &models.AdResponse{
+		Ads:         []models.Ad{},
 		SkipAllowed: req.Position == "pre-roll",
-	}, nil
+	}
+	prefs := mediaPreferences(req)
+	caps := s.frequencyCaps(ctx, req.UserID)
+	switch vast.RootElement(data) {
+	case "VAST":
+		doc, err := vast.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrAdServerUnavailable, err)
+		}
+		response.Ads = s.selectAds(s.resolveAds(ctx, doc, prefs), caps, 0)
+	case "VMAP":
+		schedule, err := vast.ParseVMAP(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrAdServerUnavailable, err)
+		}
+		response.Breaks = s.resolveBreaks(ctx, schedule, req, prefs, caps)
+		if b := matchingBreak(response.Breaks, req); b != nil {
+			response.Ads = b.Ads
+		}
+	default:
+		if len(bytes.TrimSpace(data)) > 0 {
+			return nil, fmt.Errorf("%w: response is neither VAST nor VMAP", ErrAdServerUnavailable)
+		}
+		// No fill
+	}
+
+	if err := s.registerDeliveries(ctx, req, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
 }
 
-// TrackAdEvent tracks an ad event
-func (s *AdService) TrackAdEvent(ctx context.Context, tracking *models.AdTracking) error {
-	return s.repo.CreateTracking(ctx, tracking)
+// resolveAds follows the wrappers of a VAST response and converts the ads
+func (s *AdService) resolveAds(ctx context.Context, doc *vast.VAST, prefs vast.MediaPreferences) []models.Ad {
+	ads, err := s.resolver.Resolve(ctx, doc)
+	if err != nil {
+		// Log error
+		return []models.Ad{}
+	}
+	return toAds(ads, prefs)
 }
 
-func (s *AdService) isAdFreeUser(ctx context.Context, userID string) bool {
-	// TODO: Check subscription status
-	return false
+// resolveBreaks resolves the linear breaks of a VMAP schedule. Breaks that
+// cannot be placed or end up without ads are left out. Frequency caps count the
+// ads of every break.
+func (s *AdService) resolveBreaks(ctx context.Context, schedule *vast.VMAP, req *models.AdRequest, prefs vast.MediaPreferences, caps *frequencyCaps) []models.AdBreak {
+	contentDuration := time.Duration(req.ContentDuration) * time.Second
+	var breaks []models.AdBreak
+	for i, b := range schedule.AdBreaks {
+		if (b.BreakType != "" && b.BreakType != "linear") || b.AdSource == nil {
+			continue
+		}
+		position, offset, err := b.Position(contentDuration)
+		if err != nil {
+			continue
+		}
+
+		var doc *vast.VAST
+		switch source := b.AdSource; {
+		case source.VASTAdData != nil:
+			doc = &source.VASTAdData.VAST
+		case source.AdTagURI != "":
+			data, err := s.adServer.Fetch(ctx, source.AdTagURI)
+			if err != nil {
+				// Log error
+				continue
+			}
+			if doc, err = vast.Parse(data); err != nil {
+				// Log error
+				continue
+			}
+		default:
+			continue
+		}
+
+		maxAds := 0
+		if multiple := b.AdSource.AllowMultipleAds; multiple != nil && !*multiple {
+			maxAds = 1
+		}
+		ads := s.selectAds(s.resolveAds(ctx, doc, prefs), caps, maxAds)
+		if len(ads) == 0 {
+			continue
+		}
+
+		id := b.BreakID
+		if id == "" {
+			id = "break-" + strconv.Itoa(i+1)
+		}
+		var tracking []models.TrackingEvent
+		for _, t := range b.TrackingEvents {
+			if t.URL != "" {
+				tracking = append(tracking, models.TrackingEvent{Event: t.Event, URL: t.URL})
+			}
+		}
+		breaks = append(breaks, models.AdBreak{
+			ID:         id,
+			Position:   position,
+			TimeOffset: offset.Seconds(),
+			Ads:        ads,
+			Tracking:   tracking,
+		})
+	}
+	return breaks
+}
+
+// matchingBreak returns the break for the requested position: the first
+// pre-roll or post-roll, or the mid-roll nearest the cue point
+func matchingBreak(breaks []models.AdBreak, req *models.AdRequest) *models.AdBreak {
+	var match *models.AdBreak
+	for i := range breaks {
+		b := &breaks[i]
+		if b.Position != req.Position {
+			continue
+		}
+		if b.Position != vast.MidRoll {
+			return b
+		}
+		cuePoint := float64(req.CuePoint)
+		if match == nil || math.Abs(b.TimeOffset-cuePoint) < math.Abs(match.TimeOffset-cuePoint) {
+			match = b
+		}
+	}
+	return match
+}
+
+// targetingParams returns the query parameters sent to the ad server. User ids
+// are not shared with ad servers.
+func targetingParams(req *models.AdRequest) url.Values {
+	params := url.Values{}
+	params.Set("content_id", req.ContentID)
+	params.Set("position", req.Position)
+	if req.DeviceType != "" {
+		params.Set("device_type", req.DeviceType)
+	}
+	if req.Position == vast.MidRoll {
+		params.Set("cue_point", strconv.FormatInt(req.CuePoint, 10))
+	}
+	if req.ContentDuration > 0 {
+		params.Set("content_duration", strconv.FormatInt(req.ContentDuration, 10))
+	}
+	return params
 }
 
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/streamverse/ad-service/internal/clients/adserver"
+	"github.com/streamverse/ad-service/internal/mockadserver"
+	"github.com/streamverse/ad-service/models"
+)
+
+func newTestService(t *testing.T, handler http.Handler, tagPath string, timeout time.Duration) *AdService {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewAdService(&memoryAdStore{}, newMemoryEligibilityStore(), adserver.NewClient(server.URL+tagPath, timeout), noEntitlements, testTrackingConfig, testEligibilityConfig)
+}
+
+func TestGetAdsResolvesVASTWrapper(t *testing.T) {
+	svc := newTestService(t, mockadserver.NewHandler(), "/ads", 0)
+	resp, err := svc.GetAds(context.Background(), &models.AdRequest{
+		ContentID: "content-1",
+		Position:  "pre-roll",
+		Width:     1280,
+		Height:    720,
+		MimeTypes: []string{"video/mp4"},
+	}, "")
+	if err != nil {
+		t.Fatalf("GetAds: %v", err)
+	}
+	if len(resp.Ads) != 2 || resp.Ads[0].ID != "mock-ad-1" || resp.Ads[1].Sequence != 2 {
+		t.Fatalf("expected the two-ad pod behind the wrapper, got %+v", resp.Ads)
+	}
+
+	ad := resp.Ads[0]
+	if ad.MediaFile == nil || !strings.HasSuffix(ad.MediaFile.URL, "/premium-15/720p.mp4") {
+		t.Fatalf("expected the 720p MP4, got %+v", ad.MediaFile)
+	}
+	if ad.Duration != 15 || ad.SkipOffset != 5 || ad.UniversalAdID != "STVP00015000H" || ad.Mezzanine == "" {
+		t.Fatalf("unexpected creative details %+v", ad)
+	}
+	if len(ad.Impressions) != 2 || len(ad.Companions) != 1 || ad.Companions[0].ResourceType != "static" {
+		t.Fatalf("expected merged impressions and a static companion, got %+v", ad)
+	}
+	starts := 0
+	for _, tracking := range ad.Tracking {
+		if tracking.Event == "start" {
+			starts++
+		}
+		if strings.Contains(tracking.URL, "[CACHEBUSTING]") || strings.Contains(tracking.URL, "{{base}}") {
+			t.Fatalf("unexpanded tracking URL %s", tracking.URL)
+		}
+	}
+	if starts != 2 {
+		t.Fatalf("expected the ad's and the wrapper's start tracking, got %d", starts)
+	}
+	if progress := resp.Ads[1].Tracking[1]; progress.Event != "progress" || progress.Offset != 10 {
+		t.Fatalf("expected a progress event at 10s, got %+v", progress)
+	}
+}
+
+func TestGetAdsResolvesVMAP(t *testing.T) {
+	svc := newTestService(t, mockadserver.NewHandler(), "/ads?format=vmap", 0)
+	resp, err := svc.GetAds(context.Background(), &models.AdRequest{ContentID: "content-1", Position: "mid-roll", CuePoint: 590}, "")
+	if err != nil {
+		t.Fatalf("GetAds: %v", err)
+	}
+	// The post-roll's ad tag has no fill
+	if len(resp.Breaks) != 2 {
+		t.Fatalf("expected a pre-roll and a mid-roll break, got %+v", resp.Breaks)
+	}
+	pre, mid := resp.Breaks[0], resp.Breaks[1]
+	if pre.Position != "pre-roll" || len(pre.Ads) != 1 || len(pre.Tracking) != 2 {
+		t.Fatalf("unexpected pre-roll %+v", pre)
+	}
+	if mid.Position != "mid-roll" || mid.TimeOffset != 600 || len(mid.Ads) != 2 {
+		t.Fatalf("unexpected mid-roll %+v", mid)
+	}
+	if len(resp.Ads) != 2 || resp.Ads[0].ID != "mock-ad-1" {
+		t.Fatalf("expected the mid-roll ads for the cue point, got %+v", resp.Ads)
+	}
+	if resp.Ads[0].MediaFile == nil || !strings.HasSuffix(resp.Ads[0].MediaFile.URL, "/master.m3u8") {
+		t.Fatalf("expected the first of the largest files without preferences, got %+v", resp.Ads[0].MediaFile)
+	}
+}
+
+func TestGetAdsAdServerFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		timeout time.Duration
+		wantErr bool
+	}{
+		{"server error", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) }, 0, true},
+		{"not XML", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<html>oops</html>")) }, 0, true},
+		{"invalid VAST", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`<VAST version="9"/>`)) }, 0, true},
+		{"timeout", func(w http.ResponseWriter, r *http.Request) { time.Sleep(200 * time.Millisecond) }, 50 * time.Millisecond, true},
+		{"no content", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }, 0, false},
+		{"no fill", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`<VAST version="4.0"/>`)) }, 0, false},
+		{"broken wrapper", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`<VAST version="3.0"><Ad><Wrapper><VASTAdTagURI>http://127.0.0.1:1/vast</VASTAdTagURI></Wrapper></Ad></VAST>`))
+		}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, tt.handler, "/ads", tt.timeout)
+			resp, err := svc.GetAds(context.Background(), &models.AdRequest{ContentID: "content-1", Position: "pre-roll"}, "")
+			if tt.wantErr {
+				if !errors.Is(err, ErrAdServerUnavailable) {
+					t.Fatalf("expected ErrAdServerUnavailable, got %v", err)
+				}
+				return
+			}
+			if err != nil || resp.Ads == nil || len(resp.Ads) != 0 {
+				t.Fatalf("expected an empty ad list, got %+v, %v", resp, err)
+			}
+		})
+	}
+}
+
+func TestGetAdsTargetingParams(t *testing.T) {
+	var query map[string][]string
+	svc := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.WriteHeader(http.StatusNoContent)
+	}), "/ads?network=123", 0)
+
+	_, err := svc.GetAds(context.Background(), &models.AdRequest{
+		ContentID: "content-1", UserID: "user-1", DeviceType: "tv", Position: "mid-roll", CuePoint: 600,
+	}, "")
+	if err != nil {
+		t.Fatalf("GetAds: %v", err)
+	}
+	want := map[string]string{"network": "123", "content_id": "content-1", "device_type": "tv", "position": "mid-roll", "cue_point": "600"}
+	for name, value := range want {
+		if got := query[name]; len(got) != 1 || got[0] != value {
+			t.Fatalf("expected %s=%s, got %q", name, value, got)
+		}
+	}
+	for name, values := range query {
+		for _, v := range values {
+			if v == "user-1" {
+				t.Fatalf("user id sent to the ad server as %s", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"time"
+
+	"github.com/streamverse/ad-service/internal/vast"
+	"github.com/streamverse/ad-service/models"
+)
+
+// mediaPreferences returns the media file preferences of an ad request
+func mediaPreferences(req *models.AdRequest) vast.MediaPreferences {
+	return vast.MediaPreferences{
+		MimeTypes:  req.MimeTypes,
+		Codecs:     req.Codecs,
+		Width:      req.Width,
+		Height:     req.Height,
+		MaxBitrate: req.MaxBitrate,
+	}
+}
+
+// toAds converts resolved VAST ads, dropping those without a linear creative the
+// requesting player can use
+func toAds(ads []vast.Ad, prefs vast.MediaPreferences) []models.Ad {
+	converted := []models.Ad{}
+	for _, ad := range ads {
+		if a, ok := toAd(ad, prefs); ok {
+			converted = append(converted, a)
+		}
+	}
+	return converted
+}
+
+// toAd converts an inline ad. The first linear creative with a usable media file
+// is the ad; companions are taken from every creative.
+func toAd(ad vast.Ad, prefs vast.MediaPreferences) (models.Ad, bool) {
+	inline := ad.InLine
+	if inline == nil {
+		return models.Ad{}, false
+	}
+	converted := models.Ad{
+		ID:          ad.ID,
+		Sequence:    ad.Sequence,
+		AdSystem:    inline.AdSystem,
+		Title:       inline.AdTitle,
+		CampaignID:  inline.CampaignID(),
+		Impressions: nonEmpty(inline.Impressions),
+		ErrorURLs:   nonEmpty(inline.Errors),
+	}
+
+	for _, category := range inline.Categories {
+		if category.Value != "" {
+			converted.Categories = append(converted.Categories, category.Value)
+		}
+	}
+
+	for _, creative := range inline.Creatives {
+		for _, companion := range creative.Companions {
+			if c, ok := toCompanion(companion); ok {
+				converted.Companions = append(converted.Companions, c)
+			}
+		}
+
+		linear := creative.Linear
+		if linear == nil || converted.MediaFile != nil {
+			continue
+		}
+		file := vast.SelectMediaFile(linear.MediaFiles, prefs)
+		duration, err := vast.ParseDuration(linear.Duration)
+		if file == nil || err != nil {
+			continue
+		}
+
+		converted.CreativeID = creative.ID
+		if converted.CreativeID == "" {
+			converted.CreativeID = creative.AdID
+		}
+		if creative.UniversalAdID != nil {
+			converted.UniversalAdID = creative.UniversalAdID.Value
+		}
+		converted.Duration = duration.Seconds()
+		if linear.SkipOffset != "" {
+			if skip, err := vast.ParseOffset(linear.SkipOffset, duration); err == nil && skip < duration {
+				converted.SkipOffset = skip.Seconds()
+			}
+		}
+		bitrate := file.Bitrate
+		if bitrate == 0 {
+			bitrate = file.MaxBitrate
+		}
+		converted.MediaFile = &models.MediaFile{
+			URL:      file.URL,
+			Type:     file.Type,
+			Delivery: file.Delivery,
+			Codec:    file.Codec,
+			Width:    file.Width,
+			Height:   file.Height,
+			Bitrate:  bitrate,
+		}
+		if mezzanine := nonEmpty(linear.Mezzanine); len(mezzanine) > 0 {
+			converted.Mezzanine = mezzanine[0]
+		}
+		converted.ClickURL = linear.ClickThrough
+		converted.ClickTracking = nonEmpty(linear.ClickTracking)
+		converted.Tracking = toTrackingEvents(linear.TrackingEvents, duration)
+	}
+	return converted, converted.MediaFile != nil
+}
+
+// toCompanion converts a companion ad, preferring a static resource
+func toCompanion(companion vast.Companion) (models.Companion, bool) {
+	converted := models.Companion{
+		ID:            companion.ID,
+		Width:         companion.Width,
+		Height:        companion.Height,
+		ClickURL:      companion.ClickThrough,
+		ClickTracking: nonEmpty(companion.ClickTracking),
+		Tracking:      toTrackingEvents(companion.TrackingEvents, 0),
+	}
+	switch {
+	case len(companion.StaticResources) > 0 && companion.StaticResources[0].URL != "":
+		converted.ResourceType = "static"
+		converted.CreativeType = companion.StaticResources[0].CreativeType
+		converted.Resource = companion.StaticResources[0].URL
+	case len(nonEmpty(companion.IFrameResources)) > 0:
+		converted.ResourceType = "iframe"
+		converted.Resource = nonEmpty(companion.IFrameResources)[0]
+	case len(nonEmpty(companion.HTMLResources)) > 0:
+		converted.ResourceType = "html"
+		converted.Resource = nonEmpty(companion.HTMLResources)[0]
+	default:
+		return models.Companion{}, false
+	}
+	return converted, true
+}
+
+// toTrackingEvents converts tracking URLs, resolving progress offsets against
+// the creative's duration
+func toTrackingEvents(events []vast.Tracking, duration time.Duration) []models.TrackingEvent {
+	var converted []models.TrackingEvent
+	for _, event := range events {
+		if event.URL == "" {
+			continue
+		}
+		tracking := models.TrackingEvent{Event: event.Event, URL: event.URL}
+		if event.Offset != "" {
+			offset, err := vast.ParseOffset(event.Offset, duration)
+			if err != nil {
+				continue
+			}
+			tracking.Offset = offset.Seconds()
+		}
+		converted = append(converted, tracking)
+	}
+	return converted
+}
+
+// nonEmpty returns the values that are not empty
+func nonEmpty(values []string) []string {
+	var kept []string
+	for _, v := range values {
+		if v != "" {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
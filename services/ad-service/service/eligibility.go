@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/streamverse/ad-service/internal/clients/payment"
+	"github.com/streamverse/ad-service/models"
+)
+
+// eligibilityStore keeps cached ad-free decisions and per-user campaign
+// impression counters, in Redis in production
+type eligibilityStore interface {
+	GetAdFree(ctx context.Context, userID string) (adFree, found bool, err error)
+	SetAdFree(ctx context.Context, userID string, adFree bool, ttl time.Duration) error
+	// ReserveCampaignImpression atomically counts an ad of the campaign served to
+	// the user unless limit ads were already counted in the window, and reports
+	// whether it did
+	ReserveCampaignImpression(ctx context.Context, userID, campaignID string, limit int, window time.Duration) (bool, error)
+}
+
+type entitlementSource interface {
+	GetUserEntitlements(ctx context.Context, userID, authHeader string) ([]payment.Entitlement, error)
+}
+
+// EligibilityConfig controls which users and campaigns ads are served to.
+type EligibilityConfig struct {
+	EntitlementTTL        time.Duration // how long an ad-free decision is cached; plan changes apply after it
+	FrequencyCap          int           // ads of a campaign served per user and window, 0 for no cap
+	FrequencyWindow       time.Duration
+	CompetitiveSeparation bool // keep ads of the same advertiser category out of one pod
+}
+
+// EligibilityConfigFromEnv builds eligibility config from environment variables.
+func EligibilityConfigFromEnv() EligibilityConfig {
+	cfg := EligibilityConfig{
+		EntitlementTTL:        5 * time.Minute,
+		FrequencyCap:          3,
+		FrequencyWindow:       24 * time.Hour,
+		CompetitiveSeparation: true,
+	}
+
+	if v := strings.TrimSpace(os.Getenv("AD_ENTITLEMENT_CACHE_TTL")); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			cfg.EntitlementTTL = parsed
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("AD_FREQUENCY_CAP")); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			cfg.FrequencyCap = parsed
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("AD_FREQUENCY_WINDOW")); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			cfg.FrequencyWindow = parsed
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("AD_COMPETITIVE_SEPARATION")); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			cfg.CompetitiveSeparation = parsed
+		}
+	}
+
+	return cfg
+}
+
+// isAdFreeUser reports whether the user's plan is free of ads: an active
+// subscription to a plan without the ad-supported feature. Decisions are cached
+// for the entitlement TTL. When the payment service cannot be asked the user
+// gets ads, and the decision is not cached so the next request asks again.
+func (s *AdService) isAdFreeUser(ctx context.Context, userID, authHeader string) bool {
+	if userID == "" {
+		return false
+	}
+
+	// A cache that cannot be read falls back to the payment service
+	if adFree, found, err := s.cache.GetAdFree(ctx, userID); err == nil && found {
+		return adFree
+	}
+
+	if authHeader == "" {
+		return false
+	}
+	entitlements, err := s.entitlements.GetUserEntitlements(ctx, userID, authHeader)
+	if err != nil {
+		// Log error
+		return false
+	}
+
+	adFree := adFreeEntitlements(entitlements)
+	if err := s.cache.SetAdFree(ctx, userID, adFree, s.eligibility.EntitlementTTL); err != nil {
+		// Log error
+	}
+	return adFree
+}
+
+// adFreeEntitlements reports whether the entitlements include an active
+// subscription to an ad-free plan. Subscriptions without plan features, from payment
+// services that do not send them, are taken to show ads.
+func adFreeEntitlements(entitlements []payment.Entitlement) bool {
+	for _, e := range entitlements {
+		if e.Type != "subscription" || e.Status != "active" || e.Features == nil {
+			continue
+		}
+		if e.ExpiresAt != nil && e.ExpiresAt.Before(time.Now()) {
+			continue
+		}
+		adSupported := false
+		for _, feature := range e.Features {
+			if feature == payment.FeatureAdSupported {
+				adSupported = true
+			}
+		}
+		if !adSupported {
+			return true
+		}
+	}
+	return false
+}
+
+// frequencyCaps counts the ads of each campaign served to a user in the current
+// window against the frequency cap. An ad counts when it is selected, reserving
+// its place with an atomic check-and-increment, so concurrent requests cannot all
+// pass the cap and ads left out do not count; served ads count whether or not
+// they are played.
+type frequencyCaps struct {
+	ctx    context.Context
+	store  eligibilityStore
+	userID string
+	limit  int
+	window time.Duration
+}
+
+// frequencyCaps starts the frequency caps of an ad request. Anonymous requests
+// are not capped.
+func (s *AdService) frequencyCaps(ctx context.Context, userID string) *frequencyCaps {
+	limit := s.eligibility.FrequencyCap
+	if userID == "" {
+		limit = 0
+	}
+	return &frequencyCaps{ctx: ctx, store: s.cache, userID: userID, limit: limit, window: s.eligibility.FrequencyWindow}
+}
+
+// allow reports whether an ad of the campaign may still be served, and counts it
+// when it may. Ads without a campaign are not capped, and an ad whose count
+// cannot be reserved is served.
+func (f *frequencyCaps) allow(campaignID string) bool {
+	if f.limit <= 0 || campaignID == "" {
+		return true
+	}
+	reserved, err := f.store.ReserveCampaignImpression(f.ctx, f.userID, campaignID, f.limit, f.window)
+	if err != nil {
+		// Log error
+		return true
+	}
+	return reserved
+}
+
+// selectAds keeps the ads of a pod that may be served, in order, up to maxAds
+// ads when maxAds is positive. Ads of capped campaigns are dropped, and with
+// competitive separation so are ads sharing a category with an earlier ad.
+func (s *AdService) selectAds(ads []models.Ad, caps *frequencyCaps, maxAds int) []models.Ad {
+	selected := []models.Ad{}
+	categories := map[string]bool{}
+	for _, ad := range ads {
+		if maxAds > 0 && len(selected) == maxAds {
+			break
+		}
+		if s.eligibility.CompetitiveSeparation && sharesCategory(ad, categories) {
+			continue
+		}
+		if !caps.allow(ad.CampaignID) {
+			continue
+		}
+		for _, category := range ad.Categories {
+			categories[category] = true
+		}
+		selected = append(selected, ad)
+	}
+	return selected
+}
+
+func sharesCategory(ad models.Ad, categories map[string]bool) bool {
+	for _, category := range ad.Categories {
+		if categories[category] {
+			return true
+		}
+	}
+	return false
+}
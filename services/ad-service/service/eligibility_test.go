@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streamverse/ad-service/internal/clients/adserver"
+	"github.com/streamverse/ad-service/internal/clients/payment"
+	"github.com/streamverse/ad-service/internal/mockadserver"
+	"github.com/streamverse/ad-service/models"
+)
+
+var testEligibilityConfig = EligibilityConfig{
+	EntitlementTTL:        time.Minute,
+	FrequencyCap:          3,
+	FrequencyWindow:       time.Hour,
+	CompetitiveSeparation: true,
+}
+
+// memoryEligibilityStore keeps ad-free decisions and impression counters in
+// memory
+type memoryEligibilityStore struct {
+	mu     sync.Mutex
+	adFree map[string]bool
+	counts map[string]int64 // by user and campaign
+}
+
+func newMemoryEligibilityStore() *memoryEligibilityStore {
+	return &memoryEligibilityStore{adFree: map[string]bool{}, counts: map[string]int64{}}
+}
+
+func (s *memoryEligibilityStore) GetAdFree(ctx context.Context, userID string) (bool, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	adFree, found := s.adFree[userID]
+	return adFree, found, nil
+}
+
+func (s *memoryEligibilityStore) SetAdFree(ctx context.Context, userID string, adFree bool, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adFree[userID] = adFree
+	return nil
+}
+
+func (s *memoryEligibilityStore) ReserveCampaignImpression(ctx context.Context, userID, campaignID string, limit int, window time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts[userID+"/"+campaignID] >= int64(limit) {
+		return false, nil
+	}
+	s.counts[userID+"/"+campaignID]++
+	return true, nil
+}
+
+// entitlementsFunc adapts a function to the payment service's entitlements
+type entitlementsFunc func(ctx context.Context, userID, authHeader string) ([]payment.Entitlement, error)
+
+func (f entitlementsFunc) GetUserEntitlements(ctx context.Context, userID, authHeader string) ([]payment.Entitlement, error) {
+	return f(ctx, userID, authHeader)
+}
+
+var noEntitlements = entitlementsFunc(func(ctx context.Context, userID, authHeader string) ([]payment.Entitlement, error) {
+	return nil, nil
+})
+
+func newEligibilityTest(t *testing.T, handler http.Handler, tagPath string, entitlements entitlementSource, cfg EligibilityConfig) (*AdService, *memoryEligibilityStore) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	store := newMemoryEligibilityStore()
+	return NewAdService(&memoryAdStore{}, store, adserver.NewClient(server.URL+tagPath, 0), entitlements, testTrackingConfig, cfg), store
+}
+
+func subscription(planID string, features ...string) payment.Entitlement {
+	return payment.Entitlement{Type: "subscription", PlanID: planID, Status: "active", Features: features}
+}
+
+func TestGetAdsSkipsAdFreePlans(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name         string
+		entitlements []payment.Entitlement
+		err          error
+		wantAds      bool
+	}{
+		{"ad-free plan", []payment.Entitlement{subscription("tier2", "720p", "2 screens", "downloads")}, nil, false},
+		{"ad-supported plan", []payment.Entitlement{subscription("tier1", "480p", "1 screen", payment.FeatureAdSupported)}, nil, true},
+		{"no subscription", []payment.Entitlement{{Type: "purchase", ContentID: "content-1", Status: "completed"}}, nil, true},
+		{"plan without features", []payment.Entitlement{{Type: "subscription", PlanID: "tier3", Status: "active"}}, nil, true},
+		{"expired subscription", []payment.Entitlement{{Type: "subscription", PlanID: "tier3", Status: "active", Features: []string{"4K"}, ExpiresAt: &expired}}, nil, true},
+		{"payment service down", nil, errors.New("connection refused"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			svc, store := newEligibilityTest(t, mockadserver.NewHandler(), "/ads", entitlementsFunc(func(ctx context.Context, userID, authHeader string) ([]payment.Entitlement, error) {
+				calls++
+				if userID != "user-1" || authHeader != "Bearer token" {
+					t.Fatalf("unexpected entitlements request for %q with %q", userID, authHeader)
+				}
+				return tt.entitlements, tt.err
+			}), testEligibilityConfig)
+
+			for i := 0; i < 2; i++ {
+				resp, err := svc.GetAds(context.Background(), &models.AdRequest{UserID: "user-1", ContentID: "content-1", Position: "pre-roll"}, "Bearer token")
+				if err != nil {
+					t.Fatalf("GetAds: %v", err)
+				}
+				if got := len(resp.Ads) > 0; got != tt.wantAds {
+					t.Fatalf("expected ads %v, got %+v", tt.wantAds, resp.Ads)
+				}
+			}
+
+			// Decisions are cached, failures are not
+			_, cached, _ := store.GetAdFree(context.Background(), "user-1")
+			if wantCalls := map[bool]int{true: 2, false: 1}[tt.err != nil]; calls != wantCalls || cached == (tt.err != nil) {
+				t.Fatalf("expected %d entitlement requests and cached=%v, got %d and %v", wantCalls, tt.err == nil, calls, cached)
+			}
+		})
+	}
+}
+
+func TestGetAdsWithoutAuthorizationShowsAds(t *testing.T) {
+	svc, _ := newEligibilityTest(t, mockadserver.NewHandler(), "/ads", entitlementsFunc(func(ctx context.Context, userID, authHeader string) ([]payment.Entitlement, error) {
+		t.Fatalf("entitlements requested without an authorization header")
+		return nil, nil
+	}), testEligibilityConfig)
+	resp, err := svc.GetAds(context.Background(), &models.AdRequest{UserID: "user-1", ContentID: "content-1", Position: "pre-roll"}, "")
+	if err != nil || len(resp.Ads) != 2 {
+		t.Fatalf("expected the pod, got %+v, %v", resp, err)
+	}
+}
+
+func TestGetAdsFrequencyCap(t *testing.T) {
+	cfg := testEligibilityConfig
+	cfg.FrequencyCap = 2
+	svc, store := newEligibilityTest(t, mockadserver.NewHandler(), "/ads", noEntitlements, cfg)
+	ctx := context.Background()
+	request := func(userID string) []models.Ad {
+		t.Helper()
+		resp, err := svc.GetAds(ctx, &models.AdRequest{UserID: userID, ContentID: "content-1", Position: "pre-roll"}, "")
+		if err != nil {
+			t.Fatalf("GetAds: %v", err)
+		}
+		return resp.Ads
+	}
+
+	// Each served ad counts, whether or not it is played
+	for i := 0; i < 2; i++ {
+		if ads := request("user-1"); len(ads) != 2 {
+			t.Fatalf("expected the pod before the cap is reached, got %+v", ads)
+		}
+	}
+	if ads := request("user-1"); len(ads) != 0 {
+		t.Fatalf("expected capped campaigns to be left out, got %+v", ads)
+	}
+	// Ads left out are not counted
+	if got := store.counts["user-1/mock-campaign-premium"]; got != 2 {
+		t.Fatalf("expected the count to stop at the cap, got %d", got)
+	}
+	if ads := request("user-2"); len(ads) != 2 {
+		t.Fatalf("expected caps per user, got %+v", ads)
+	}
+	if ads := request(""); len(ads) != 2 {
+		t.Fatalf("expected anonymous requests not to be capped, got %+v", ads)
+	}
+}
+
+func TestGetAdsFrequencyCapConcurrentRequests(t *testing.T) {
+	cfg := testEligibilityConfig
+	cfg.FrequencyCap = 1
+	svc, store := newEligibilityTest(t, mockadserver.NewHandler(), "/ads", noEntitlements, cfg)
+
+	// Both requests read the campaigns uncapped; only one may serve them
+	var wg sync.WaitGroup
+	served := make([]int, 2)
+	for i := range served {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := svc.GetAds(context.Background(), &models.AdRequest{UserID: "user-1", ContentID: "content-1", Position: "pre-roll"}, "")
+			if err != nil {
+				t.Errorf("GetAds: %v", err)
+				return
+			}
+			served[i] = len(resp.Ads)
+		}(i)
+	}
+	wg.Wait()
+
+	if served[0]+served[1] != 2 {
+		t.Fatalf("expected each campaign served once across both requests, got %v", served)
+	}
+	if got := store.counts["user-1/mock-campaign-premium"]; got != 1 {
+		t.Fatalf("expected only the served ad to be counted, got %d", got)
+	}
+}
+
+// categoryPod is a pod of two ads of one car campaign and a travel ad
+const categoryPod = `<VAST version="4.1">
+  <Ad id="car-1" sequence="1"><InLine><AdSystem>test</AdSystem><AdTitle>Car 1</AdTitle>
+    <Category authority="https://www.iabtechlab.com/categoryauthority">IAB2</Category>
+    <Creatives><Creative><Linear><Duration>00:00:15</Duration><MediaFiles>
+      <MediaFile delivery="progressive" type="video/mp4" width="1280" height="720">https://cdn.example.com/car1.mp4</MediaFile>
+    </MediaFiles></Linear></Creative></Creatives>
+    <Extensions><Extension type="campaign">cars</Extension></Extensions></InLine></Ad>
+  <Ad id="car-2" sequence="2"><InLine><AdSystem>test</AdSystem><AdTitle>Car 2</AdTitle>
+    <Category authority="https://www.iabtechlab.com/categoryauthority">IAB2</Category>
+    <Creatives><Creative><Linear><Duration>00:00:15</Duration><MediaFiles>
+      <MediaFile delivery="progressive" type="video/mp4" width="1280" height="720">https://cdn.example.com/car2.mp4</MediaFile>
+    </MediaFiles></Linear></Creative></Creatives>
+    <Extensions><Extension type="campaign">cars</Extension></Extensions></InLine></Ad>
+  <Ad id="travel-1" sequence="3"><InLine><AdSystem>test</AdSystem><AdTitle>Travel</AdTitle>
+    <Category authority="https://www.iabtechlab.com/categoryauthority">IAB20</Category>
+    <Creatives><Creative><Linear><Duration>00:00:15</Duration><MediaFiles>
+      <MediaFile delivery="progressive" type="video/mp4" width="1280" height="720">https://cdn.example.com/travel.mp4</MediaFile>
+    </MediaFiles></Linear></Creative></Creatives></InLine></Ad>
+</VAST>`
+
+func TestGetAdsCompetitiveSeparation(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(categoryPod))
+	})
+	tests := []struct {
+		name       string
+		separation bool
+		cap        int
+		want       string
+	}{
+		{"separated", true, 0, "car-1,travel-1"},
+		{"not separated", false, 0, "car-1,car-2,travel-1"},
+		// Ads of the response count towards the cap
+		{"capped within the pod", false, 1, "car-1,travel-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testEligibilityConfig
+			cfg.CompetitiveSeparation = tt.separation
+			cfg.FrequencyCap = tt.cap
+			svc, _ := newEligibilityTest(t, handler, "/ads", noEntitlements, cfg)
+			resp, err := svc.GetAds(context.Background(), &models.AdRequest{UserID: "user-1", ContentID: "content-1", Position: "pre-roll"}, "")
+			if err != nil {
+				t.Fatalf("GetAds: %v", err)
+			}
+			var ids []string
+			for _, ad := range resp.Ads {
+				ids = append(ids, ad.ID)
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streamverse/ad-service/internal/beacon"
+	"github.com/streamverse/ad-service/internal/vast"
+	"github.com/streamverse/ad-service/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrDeliveryNotFound is returned for an unknown or expired delivery, or one of another user
+	ErrDeliveryNotFound = errors.New("ad delivery not found")
+	// ErrInvalidEvent is returned for an event type the service does not track
+	ErrInvalidEvent = errors.New("invalid ad event type")
+	// ErrInvalidGroupBy is returned for a tracking report grouped by an unknown field
+	ErrInvalidGroupBy = errors.New("groupBy must be ad, campaign or content")
+)
+
+// completeTolerance is how far short of an ad's end a reported position still
+// completes it; players report positions a frame or a timer tick late
+const completeTolerance = 0.5 // seconds
+
+// trackedEvents are the event types the service stores, in playback order
+var trackedEvents = []string{
+	models.EventImpression,
+	models.EventStart,
+	models.EventFirstQuartile,
+	models.EventMidpoint,
+	models.EventThirdQuartile,
+	models.EventComplete,
+	models.EventClick,
+	models.EventSkip,
+}
+
+// progressEvents are the events reached at a fraction of an ad's duration
+var progressEvents = []struct {
+	event    string
+	fraction float64
+}{
+	{models.EventImpression, 0},
+	{models.EventStart, 0},
+	{models.EventFirstQuartile, 0.25},
+	{models.EventMidpoint, 0.5},
+	{models.EventThirdQuartile, 0.75},
+	{models.EventComplete, 1},
+}
+
+type adStore interface {
+	CreateDelivery(ctx context.Context, delivery *models.AdDelivery) error
+	GetDelivery(ctx context.Context, id string) (*models.AdDelivery, error)
+	CreateTracking(ctx context.Context, tracking *models.AdTracking) (bool, error)
+	UpdateTrackingBeacons(ctx context.Context, id primitive.ObjectID, beacons []models.BeaconResult) error
+	ListTracking(ctx context.Context, filter models.TrackingFilter) ([]models.AdTracking, error)
+	ReportTracking(ctx context.Context, filter models.TrackingFilter, field string) ([]models.TrackingReportRow, error)
+}
+
+// TrackingConfig controls ad deliveries and server-side beacons.
+type TrackingConfig struct {
+	DeliveryTTL    time.Duration // how long the playback of a served ad can be reported
+	BeaconTimeout  time.Duration
+	BeaconAttempts int
+	BeaconBackoff  time.Duration // wait before the first retry, doubling for each further one
+}
+
+// TrackingConfigFromEnv builds tracking config from environment variables.
+func TrackingConfigFromEnv() TrackingConfig {
+	cfg := TrackingConfig{
+		DeliveryTTL:    24 * time.Hour,
+		BeaconTimeout:  5 * time.Second,
+		BeaconAttempts: 3,
+		BeaconBackoff:  time.Second,
+	}
+
+	if v := strings.TrimSpace(os.Getenv("AD_DELIVERY_TTL")); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			cfg.DeliveryTTL = parsed
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("AD_BEACON_TIMEOUT")); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			cfg.BeaconTimeout = parsed
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("AD_BEACON_ATTEMPTS")); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			cfg.BeaconAttempts = parsed
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("AD_BEACON_BACKOFF")); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed >= 0 {
+			cfg.BeaconBackoff = parsed
+		}
+	}
+
+	return cfg
+}
+
+// beaconQueue fires beacons in the background so reporting playback does not
+// wait on third-party tracking servers
+type beaconQueue struct {
+	sender   *beacon.Sender
+	inflight sync.WaitGroup
+}
+
+// registerDeliveries stores a delivery for each ad of a response so its
+// playback can be reported, and gives the ads their delivery IDs
+func (s *AdService) registerDeliveries(ctx context.Context, req *models.AdRequest, response *models.AdResponse) error {
+	register := func(ads []models.Ad) error {
+		for i := range ads {
+			ad := &ads[i]
+			if ad.DeliveryID != "" {
+				continue
+			}
+			delivery := &models.AdDelivery{
+				AdID:       ad.ID,
+				CampaignID: ad.CampaignID,
+				CreativeID: ad.CreativeID,
+				UserID:     req.UserID,
+				ContentID:  req.ContentID,
+				Duration:   ad.Duration,
+				Beacons:    deliveryBeacons(ad),
+				CreatedAt:  time.Now(),
+			}
+			delivery.ExpiresAt = delivery.CreatedAt.Add(s.tracking.DeliveryTTL)
+			if err := s.repo.CreateDelivery(ctx, delivery); err != nil {
+				return err
+			}
+			ad.DeliveryID = delivery.ID.Hex()
+		}
+		return nil
+	}
+
+	// The ads of the matching VMAP break are the break's own
+	for i := range response.Breaks {
+		if err := register(response.Breaks[i].Ads); err != nil {
+			return err
+		}
+	}
+	return register(response.Ads)
+}
+
+// deliveryBeacons returns the tracking URLs of an ad by event type
+func deliveryBeacons(ad *models.Ad) map[string][]string {
+	beacons := map[string][]string{}
+	if len(ad.Impressions) > 0 {
+		beacons[models.EventImpression] = ad.Impressions
+	}
+	if len(ad.ClickTracking) > 0 {
+		beacons[models.EventClick] = ad.ClickTracking
+	}
+	for _, t := range ad.Tracking {
+		if isTrackedEvent(t.Event) && t.Event != models.EventImpression && t.Event != models.EventClick {
+			beacons[t.Event] = append(beacons[t.Event], t.URL)
+		}
+	}
+	return beacons
+}
+
+// ReportProgress records the events a viewer's playback of an ad has reached,
+// the impression and start as soon as it plays and then its quartiles, and
+// calls their tracking URLs. Events already tracked for the delivery are
+// skipped, so players may report progress as often as they like. It returns
+// the events newly tracked.
+func (s *AdService) ReportProgress(ctx context.Context, userID, deliveryID string, position float64) ([]string, error) {
+	delivery, err := s.userDelivery(ctx, userID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	var reached []string
+	for _, p := range progressEvents {
+		at := p.fraction * delivery.Duration
+		if p.event == models.EventComplete {
+			at -= completeTolerance
+		}
+		if position >= at {
+			reached = append(reached, p.event)
+		}
+	}
+	return s.trackDeliveryEvents(ctx, delivery, reached, models.TrackingSourceServer, position)
+}
+
+// TrackAdEvent tracks an ad event reported by a player. Events of a delivery
+// call the delivery's tracking URLs for the event and are tracked once;
+// events without a delivery are only stored.
+func (s *AdService) TrackAdEvent(ctx context.Context, tracking *models.AdTracking) error {
+	if !isTrackedEvent(tracking.EventType) {
+		return ErrInvalidEvent
+	}
+
+	if tracking.DeliveryID == "" {
+		tracking.Source = models.TrackingSourceClient
+		tracking.Beacons, tracking.BeaconsFailed = nil, 0
+		_, err := s.repo.CreateTracking(ctx, tracking)
+		return err
+	}
+
+	delivery, err := s.userDelivery(ctx, tracking.UserID, tracking.DeliveryID)
+	if err != nil {
+		return err
+	}
+	_, err = s.trackDeliveryEvents(ctx, delivery, []string{tracking.EventType}, models.TrackingSourceClient, -1)
+	return err
+}
+
+// ListTracking retrieves tracking events for reconciliation
+func (s *AdService) ListTracking(ctx context.Context, filter models.TrackingFilter) ([]models.AdTracking, error) {
+	return s.repo.ListTracking(ctx, filter)
+}
+
+// ReportTracking counts tracking events per ad, campaign or content item
+func (s *AdService) ReportTracking(ctx context.Context, filter models.TrackingFilter, groupBy string) ([]models.TrackingReportRow, error) {
+	field, ok := map[string]string{
+		"ad":       "ad_id",
+		"campaign": "campaign_id",
+		"content":  "content_id",
+	}[groupBy]
+	if !ok {
+		return nil, ErrInvalidGroupBy
+	}
+	return s.repo.ReportTracking(ctx, filter, field)
+}
+
+// Wait blocks until the beacons of the events tracked so far have been fired.
+func (s *AdService) Wait() {
+	s.beacons.inflight.Wait()
+}
+
+// userDelivery retrieves a delivery of the user
+func (s *AdService) userDelivery(ctx context.Context, userID, deliveryID string) (*models.AdDelivery, error) {
+	delivery, err := s.repo.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil || delivery.UserID != userID || time.Now().After(delivery.ExpiresAt) {
+		return nil, ErrDeliveryNotFound
+	}
+	return delivery, nil
+}
+
+// trackDeliveryEvents stores the events of a delivery not tracked before and
+// fires their beacons in order. position is the playhead within the ad, or
+// negative when unknown.
+func (s *AdService) trackDeliveryEvents(ctx context.Context, delivery *models.AdDelivery, events []string, source string, position float64) ([]string, error) {
+	var tracked []string
+	var records []*models.AdTracking
+	for _, event := range events {
+		record := &models.AdTracking{
+			AdID:       delivery.AdID,
+			CampaignID: delivery.CampaignID,
+			CreativeID: delivery.CreativeID,
+			DeliveryID: delivery.ID.Hex(),
+			UserID:     delivery.UserID,
+			EventType:  event,
+			ContentID:  delivery.ContentID,
+			Source:     source,
+		}
+		created, err := s.repo.CreateTracking(ctx, record)
+		if err != nil {
+			return tracked, err
+		}
+		if created {
+			tracked = append(tracked, event)
+			records = append(records, record)
+		}
+	}
+
+	macros := map[string]string{}
+	if position >= 0 {
+		macros["ADPLAYHEAD"] = vast.FormatTime(time.Duration(position * float64(time.Second)))
+	}
+	s.fireBeacons(delivery, records, macros)
+	return tracked, nil
+}
+
+// fireBeacons calls the tracking URLs of each event in the background, one
+// event after the other, and stores the outcome with the event
+func (s *AdService) fireBeacons(delivery *models.AdDelivery, records []*models.AdTracking, macros map[string]string) {
+	if len(records) == 0 {
+		return
+	}
+
+	s.beacons.inflight.Add(1)
+	go func() {
+		defer s.beacons.inflight.Done()
+		ctx := context.Background()
+		for _, record := range records {
+			urls := delivery.Beacons[record.EventType]
+			if len(urls) == 0 {
+				continue
+			}
+
+			results := make([]models.BeaconResult, len(urls))
+			var wg sync.WaitGroup
+			for i, url := range urls {
+				wg.Add(1)
+				go func(i int, url string) {
+					defer wg.Done()
+					results[i] = beaconResult(s.beacons.sender.Send(ctx, vast.ExpandMacros(url, macros)))
+				}(i, url)
+			}
+			wg.Wait()
+
+			if err := s.repo.UpdateTrackingBeacons(ctx, record.ID, results); err != nil {
+				// Log error
+				continue
+			}
+		}
+	}()
+}
+
+func beaconResult(r beacon.Result) models.BeaconResult {
+	result := models.BeaconResult{URL: r.URL, StatusCode: r.StatusCode, Attempts: r.Attempts}
+	if r.Err != nil {
+		result.Error = r.Err.Error()
+	} else {
+		sentAt := r.SentAt
+		result.SentAt = &sentAt
+	}
+	return result
+}
+
+func isTrackedEvent(event string) bool {
+	for _, e := range trackedEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streamverse/ad-service/internal/clients/adserver"
+	"github.com/streamverse/ad-service/internal/mockadserver"
+	"github.com/streamverse/ad-service/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var testTrackingConfig = TrackingConfig{
+	DeliveryTTL:    time.Hour,
+	BeaconTimeout:  time.Second,
+	BeaconAttempts: 2,
+	BeaconBackoff:  time.Millisecond,
+}
+
+// memoryAdStore keeps deliveries and tracking events in memory. Beacons are
+// recorded from background goroutines, hence the lock.
+type memoryAdStore struct {
+	mu         sync.Mutex
+	deliveries []models.AdDelivery
+	tracking   []models.AdTracking
+}
+
+func (s *memoryAdStore) CreateDelivery(ctx context.Context, delivery *models.AdDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delivery.ID = primitive.NewObjectID()
+	s.deliveries = append(s.deliveries, *delivery)
+	return nil
+}
+
+func (s *memoryAdStore) GetDelivery(ctx context.Context, id string) (*models.AdDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.deliveries {
+		if s.deliveries[i].ID.Hex() == id {
+			d := s.deliveries[i]
+			return &d, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryAdStore) CreateTracking(ctx context.Context, tracking *models.AdTracking) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tracking {
+		if tracking.DeliveryID != "" && t.DeliveryID == tracking.DeliveryID && t.EventType == tracking.EventType {
+			return false, nil
+		}
+	}
+	tracking.ID = primitive.NewObjectID()
+	tracking.CreatedAt = time.Now()
+	s.tracking = append(s.tracking, *tracking)
+	return true, nil
+}
+
+func (s *memoryAdStore) UpdateTrackingBeacons(ctx context.Context, id primitive.ObjectID, beacons []models.BeaconResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.tracking {
+		if s.tracking[i].ID == id {
+			s.tracking[i].Beacons = beacons
+			s.tracking[i].BeaconsFailed = 0
+			for _, b := range beacons {
+				if b.Error != "" {
+					s.tracking[i].BeaconsFailed++
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *memoryAdStore) ListTracking(ctx context.Context, filter models.TrackingFilter) ([]models.AdTracking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []models.AdTracking
+	for _, t := range s.tracking {
+		if (filter.AdID == "" || t.AdID == filter.AdID) && (filter.CampaignID == "" || t.CampaignID == filter.CampaignID) &&
+			(filter.ContentID == "" || t.ContentID == filter.ContentID) && (filter.EventType == "" || t.EventType == filter.EventType) {
+			events = append(events, t)
+		}
+	}
+	return events, nil
+}
+
+func (s *memoryAdStore) ReportTracking(ctx context.Context, filter models.TrackingFilter, field string) ([]models.TrackingReportRow, error) {
+	events, _ := s.ListTracking(ctx, filter)
+	rows := map[string]*models.TrackingReportRow{}
+	var keys []string
+	for _, t := range events {
+		key := map[string]string{"ad_id": t.AdID, "campaign_id": t.CampaignID, "content_id": t.ContentID}[field]
+		if rows[key] == nil {
+			rows[key] = &models.TrackingReportRow{Key: key, Events: map[string]int64{}}
+			keys = append(keys, key)
+		}
+		rows[key].Events[t.EventType]++
+		rows[key].BeaconsFailed += int64(t.BeaconsFailed)
+	}
+	sort.Strings(keys)
+	var report []models.TrackingReportRow
+	for _, key := range keys {
+		report = append(report, *rows[key])
+	}
+	return report, nil
+}
+
+// event returns the tracking event of a delivery
+func (s *memoryAdStore) event(deliveryID, eventType string) *models.AdTracking {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tracking {
+		if t.DeliveryID == deliveryID && t.EventType == eventType {
+			return &t
+		}
+	}
+	return nil
+}
+
+// beaconRecorder counts requests to the mock ad server's beacon URLs
+type beaconRecorder struct {
+	mu    sync.Mutex
+	hits  map[string]int // by path and query
+	next  http.Handler
+	fails map[string]bool // paths answered with 500
+}
+
+func (r *beaconRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if strings.HasPrefix(req.URL.Path, "/beacon/") {
+		r.mu.Lock()
+		r.hits[req.URL.Path+"?"+req.URL.RawQuery]++
+		fail := r.fails[req.URL.Path]
+		r.mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	r.next.ServeHTTP(w, req)
+}
+
+func (r *beaconRecorder) count(pathAndQuery string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hits[pathAndQuery]
+}
+
+func newTrackingTest(t *testing.T) (*AdService, *memoryAdStore, *beaconRecorder, []models.Ad) {
+	t.Helper()
+	recorder := &beaconRecorder{hits: map[string]int{}, fails: map[string]bool{}, next: mockadserver.NewHandler()}
+	svc := newTestService(t, recorder, "/ads", 0)
+	resp, err := svc.GetAds(context.Background(), &models.AdRequest{UserID: "user-1", ContentID: "content-1", Position: "pre-roll"}, "")
+	if err != nil {
+		t.Fatalf("GetAds: %v", err)
+	}
+	return svc, svc.repo.(*memoryAdStore), recorder, resp.Ads
+}
+
+func TestGetAdsRegistersDeliveries(t *testing.T) {
+	_, store, _, ads := newTrackingTest(t)
+	if len(ads) != 2 || ads[0].DeliveryID == "" || ads[0].DeliveryID == ads[1].DeliveryID {
+		t.Fatalf("expected a delivery per ad, got %+v", ads)
+	}
+	delivery, _ := store.GetDelivery(context.Background(), ads[0].DeliveryID)
+	if delivery == nil || delivery.UserID != "user-1" || delivery.CampaignID != "mock-campaign-premium" || delivery.Duration != 15 {
+		t.Fatalf("unexpected delivery %+v", delivery)
+	}
+	if got := len(delivery.Beacons[models.EventImpression]); got != 2 {
+		t.Fatalf("expected the ad's and the wrapper's impressions, got %d", got)
+	}
+	if got := len(delivery.Beacons[models.EventStart]); got != 2 {
+		t.Fatalf("expected the ad's and the wrapper's start beacons, got %d", got)
+	}
+}
+
+func TestReportProgressFiresBeaconsOnce(t *testing.T) {
+	svc, store, recorder, ads := newTrackingTest(t)
+	ctx := context.Background()
+	deliveryID := ads[0].DeliveryID // 15s
+
+	steps := []struct {
+		position float64
+		want     []string
+	}{
+		{0, []string{models.EventImpression, models.EventStart}},
+		{8, []string{models.EventFirstQuartile, models.EventMidpoint}},
+		{8, nil},
+		{14.6, []string{models.EventThirdQuartile, models.EventComplete}},
+		{15, nil},
+	}
+	for _, step := range steps {
+		events, err := svc.ReportProgress(ctx, "user-1", deliveryID, step.position)
+		if err != nil {
+			t.Fatalf("ReportProgress(%v): %v", step.position, err)
+		}
+		if strings.Join(events, ",") != strings.Join(step.want, ",") {
+			t.Fatalf("at %vs expected %v, got %v", step.position, step.want, events)
+		}
+	}
+	svc.Wait()
+
+	for _, beacon := range []string{
+		"/beacon/impression?ad=mock-ad-1", "/beacon/impression?ad=mock-wrapper",
+		"/beacon/start?ad=mock-ad-1", "/beacon/start?ad=mock-wrapper",
+		"/beacon/firstQuartile?ad=mock-ad-1", "/beacon/midpoint?ad=mock-ad-1",
+		"/beacon/thirdQuartile?ad=mock-ad-1", "/beacon/complete?ad=mock-ad-1", "/beacon/complete?ad=mock-wrapper",
+	} {
+		if got := recorder.count(beacon); got != 1 {
+			t.Fatalf("expected %s to be called once, got %d", beacon, got)
+		}
+	}
+	if recorder.count("/beacon/skip?ad=mock-ad-1") != 0 {
+		t.Fatalf("expected no skip beacon")
+	}
+
+	impression := store.event(deliveryID, models.EventImpression)
+	if impression == nil || impression.Source != models.TrackingSourceServer || impression.CampaignID != "mock-campaign-premium" {
+		t.Fatalf("unexpected impression record %+v", impression)
+	}
+	if len(impression.Beacons) != 2 || impression.BeaconsFailed != 0 || impression.Beacons[0].SentAt == nil {
+		t.Fatalf("expected two delivered beacons, got %+v", impression.Beacons)
+	}
+}
+
+func TestReportProgressRecordsFailedBeacons(t *testing.T) {
+	svc, store, recorder, ads := newTrackingTest(t)
+	recorder.fails["/beacon/start"] = true
+
+	if _, err := svc.ReportProgress(context.Background(), "user-1", ads[1].DeliveryID, 1); err != nil {
+		t.Fatalf("ReportProgress: %v", err)
+	}
+	svc.Wait()
+
+	start := store.event(ads[1].DeliveryID, models.EventStart)
+	// The wrapper's start beacon fails as well
+	if start == nil || start.BeaconsFailed != 2 || start.Beacons[0].Attempts != 2 || start.Beacons[0].StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected failed beacons after two attempts, got %+v", start)
+	}
+	if got := recorder.count("/beacon/start?ad=mock-ad-2"); got != 2 {
+		t.Fatalf("expected the beacon to be retried once, got %d calls", got)
+	}
+}
+
+func TestReportProgressExpandsPlayhead(t *testing.T) {
+	var query string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		query = r.URL.RawQuery
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	// Beacons may reach the ad server although it listens on loopback
+	store := &memoryAdStore{}
+	svc := NewAdService(store, newMemoryEligibilityStore(), adserver.NewClient(server.URL, 0), noEntitlements, testTrackingConfig, testEligibilityConfig)
+	delivery := &models.AdDelivery{
+		UserID:    "user-1",
+		Duration:  30,
+		Beacons:   map[string][]string{models.EventMidpoint: {server.URL + "/mid?t=[ADPLAYHEAD]"}},
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	store.CreateDelivery(context.Background(), delivery)
+
+	if _, err := svc.ReportProgress(context.Background(), "user-1", delivery.ID.Hex(), 15.25); err != nil {
+		t.Fatalf("ReportProgress: %v", err)
+	}
+	svc.Wait()
+	if query != "t=00%3A00%3A15.250" {
+		t.Fatalf("expected the playhead in the beacon, got %q", query)
+	}
+}
+
+func TestReportProgressUnknownDeliveries(t *testing.T) {
+	svc, store, _, ads := newTrackingTest(t)
+	expired := &models.AdDelivery{UserID: "user-1", Duration: 10, ExpiresAt: time.Now().Add(-time.Minute)}
+	store.CreateDelivery(context.Background(), expired)
+
+	tests := []struct {
+		name       string
+		userID     string
+		deliveryID string
+	}{
+		{"unknown", "user-1", primitive.NewObjectID().Hex()},
+		{"another user's", "user-2", ads[0].DeliveryID},
+		{"expired", "user-1", expired.ID.Hex()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.ReportProgress(context.Background(), tt.userID, tt.deliveryID, 1); !errors.Is(err, ErrDeliveryNotFound) {
+				t.Fatalf("expected ErrDeliveryNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTrackAdEvent(t *testing.T) {
+	svc, store, recorder, ads := newTrackingTest(t)
+	ctx := context.Background()
+
+	click := &models.AdTracking{UserID: "user-1", DeliveryID: ads[0].DeliveryID, EventType: models.EventClick}
+	for i := 0; i < 2; i++ {
+		if err := svc.TrackAdEvent(ctx, click); err != nil {
+			t.Fatalf("TrackAdEvent: %v", err)
+		}
+	}
+	svc.Wait()
+	if got := recorder.count("/beacon/click?ad=mock-ad-1"); got != 1 {
+		t.Fatalf("expected the click tracking to be called once, got %d", got)
+	}
+	if record := store.event(ads[0].DeliveryID, models.EventClick); record == nil || record.Source != models.TrackingSourceClient || record.AdID != "mock-ad-1" {
+		t.Fatalf("unexpected click record %+v", record)
+	}
+
+	legacy := &models.AdTracking{UserID: "user-1", AdID: "ad-9", ContentID: "content-1", EventType: models.EventImpression, Source: "server"}
+	if err := svc.TrackAdEvent(ctx, legacy); err != nil || legacy.Source != models.TrackingSourceClient {
+		t.Fatalf("expected an event without a delivery to be stored as reported by the client, got %v, %+v", err, legacy)
+	}
+
+	if err := svc.TrackAdEvent(ctx, &models.AdTracking{UserID: "user-1", EventType: "rewind"}); !errors.Is(err, ErrInvalidEvent) {
+		t.Fatalf("expected ErrInvalidEvent, got %v", err)
+	}
+	skip := &models.AdTracking{UserID: "user-2", DeliveryID: ads[0].DeliveryID, EventType: models.EventSkip}
+	if err := svc.TrackAdEvent(ctx, skip); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Fatalf("expected ErrDeliveryNotFound for another user's delivery, got %v", err)
+	}
+}
+
+func TestReportTracking(t *testing.T) {
+	svc, _, _, ads := newTrackingTest(t)
+	ctx := context.Background()
+	svc.ReportProgress(ctx, "user-1", ads[0].DeliveryID, 15)
+	svc.ReportProgress(ctx, "user-1", ads[1].DeliveryID, 0)
+	svc.Wait()
+
+	rows, err := svc.ReportTracking(ctx, models.TrackingFilter{ContentID: "content-1"}, "campaign")
+	if err != nil {
+		t.Fatalf("ReportTracking: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Key != "mock-campaign-originals" || rows[1].Events[models.EventComplete] != 1 {
+		t.Fatalf("unexpected report %+v", rows)
+	}
+	if _, err := svc.ReportTracking(ctx, models.TrackingFilter{}, "user"); !errors.Is(err, ErrInvalidGroupBy) {
+		t.Fatalf("expected ErrInvalidGroupBy, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	stderrors "errors"
 	"net/http"
 	"strings"
 
@@ -242,8 +243,12 @@ func (h *AuthHandler) RevokeDevice(c *gin.Context) {
 	}
 
 	deviceID := c.Param("id")
-	if err := h.service.RevokeDevice(c.Request.Context(), userID.(string), deviceID); err != nil {
+	if err := h.service.RevokeDevice(c.Request.Context(), userID.(string), deviceID, c.GetHeader("Authorization")); err != nil {
 		h.logger.Error("Failed to revoke device", logger.Error(err))
+		if stderrors.Is(err, service.ErrDownloadRevocationFailed) {
+			c.JSON(http.StatusBadGateway, errors.NewAppError(errors.ErrorCodeInternal, "Failed to revoke downloads on the device", http.StatusBadGateway))
+			return
+		}
 		c.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
 		return
 	}
@@ -55,7 +55,10 @@ func main() {
 	}
 
 	// Initialize service
-	authService := service.NewAuthService(userRepo, tokenRepo, deviceRepo, cfg.JWT.SecretKey, oauthVerifier)
+	// Removing a device revokes its offline downloads in the streaming service
+	streamingClient := service.NewStreamingClient(os.Getenv("STREAMING_SERVICE_URL"))
+
+	authService := service.NewAuthService(userRepo, tokenRepo, deviceRepo, cfg.JWT.SecretKey, oauthVerifier, streamingClient)
 
 	// Initialize handlers
 	authHandler := authHandler.NewAuthHandler(authService, log)
@@ -82,6 +85,8 @@ func main() {
 		auth.GET("/validate", middleware.AuthMiddleware(cfg.JWT.SecretKey), authHandler.Validate)
 		auth.POST("/mfa/setup", middleware.AuthMiddleware(cfg.JWT.SecretKey), authHandler.SetupMFA)
 		auth.POST("/mfa/verify", middleware.AuthMiddleware(cfg.JWT.SecretKey), authHandler.VerifyMFA)
+		auth.GET("/devices", middleware.AuthMiddleware(cfg.JWT.SecretKey), authHandler.GetDevices)
+		auth.DELETE("/devices/:id", middleware.AuthMiddleware(cfg.JWT.SecretKey), authHandler.RevokeDevice)
 		auth.POST("/oauth/google", rateLimiter.RateLimit(), authHandler.OAuthGoogle)
 		auth.POST("/oauth/apple", rateLimiter.RateLimit(), authHandler.OAuthApple)
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -21,6 +22,10 @@ const (
 	refreshExpiration = 7 * 24 * time.Hour
 )
 
+// ErrDownloadRevocationFailed is returned when a device's offline downloads could not
+// be revoked; the device is kept so removing it can be retried
+var ErrDownloadRevocationFailed = errors.New("failed to revoke device downloads")
+
 // AuthService handles authentication business logic
 type AuthService struct {
 	userRepo   authUserRepository
@@ -28,6 +33,7 @@ type AuthService struct {
 	deviceRepo authDeviceRepository
 	jwtSecret  string
 	oauth      oauthVerifier
+	downloads  downloadRevoker
 }
 
 // NewAuthService creates a new auth service
@@ -37,6 +43,7 @@ func NewAuthService(
 	deviceRepo authDeviceRepository,
 	jwtSecret string,
 	oauth oauthVerifier,
+	downloads downloadRevoker,
 ) *AuthService {
 	return &AuthService{
 		userRepo:   userRepo,
@@ -44,6 +51,7 @@ func NewAuthService(
 		deviceRepo: deviceRepo,
 		jwtSecret:  jwtSecret,
 		oauth:      oauth,
+		downloads:  downloads,
 	}
 }
 
@@ -76,6 +84,10 @@ type oauthVerifier interface {
 	Verify(ctx context.Context, provider, token string) (*OAuthIdentity, error)
 }
 
+type downloadRevoker interface {
+	RevokeDeviceDownloads(ctx context.Context, deviceID, authHeader string) error
+}
+
 // Register registers a new user
 func (s *AuthService) Register(ctx context.Context, email, password, name string) (*models.User, error) {
 	// Validate email
@@ -316,8 +328,15 @@ func (s *AuthService) GetDevices(ctx context.Context, userID string) ([]models.D
 	return s.deviceRepo.GetDevicesByUserID(ctx, userID)
 }
 
-// RevokeDevice revokes a device
-func (s *AuthService) RevokeDevice(ctx context.Context, userID, deviceID string) error {
+// RevokeDevice revokes a device and the offline downloads it holds. Downloads are
+// revoked first, on behalf of the caller identified by authHeader, so a failure
+// leaves the device in place to retry.
+func (s *AuthService) RevokeDevice(ctx context.Context, userID, deviceID, authHeader string) error {
+	if s.downloads != nil {
+		if err := s.downloads.RevokeDeviceDownloads(ctx, deviceID, authHeader); err != nil {
+			return fmt.Errorf("%w: %v", ErrDownloadRevocationFailed, err)
+		}
+	}
 	return s.deviceRepo.DeleteDevice(ctx, deviceID, userID)
 }
 
@@ -422,6 +422,34 @@ func TestContractOAuthLoginRejectsMissingSubject(t *testing.T) {
 	}
 }
 
+func TestContractRevokeDeviceRevokesDownloadsFirst(t *testing.T) {
+	deviceRepo := &testAuthDeviceRepo{}
+	revoker := &testDownloadRevoker{err: errors.New("streaming unavailable")}
+	authService := &AuthService{
+		deviceRepo: deviceRepo,
+		downloads:  revoker,
+	}
+
+	err := authService.RevokeDevice(context.Background(), "user-1", "device-1", "Bearer token")
+	if !errors.Is(err, ErrDownloadRevocationFailed) {
+		t.Fatalf("expected download revocation failure, got %v", err)
+	}
+	if len(deviceRepo.deleted) != 0 {
+		t.Fatalf("expected the device to be kept when downloads were not revoked")
+	}
+
+	revoker.err = nil
+	if err := authService.RevokeDevice(context.Background(), "user-1", "device-1", "Bearer token"); err != nil {
+		t.Fatalf("expected device revocation to succeed, got %v", err)
+	}
+	if len(revoker.devices) != 2 || revoker.devices[1] != "device-1" || revoker.authHeader != "Bearer token" {
+		t.Fatalf("expected downloads of device-1 to be revoked for the caller, got %v", revoker.devices)
+	}
+	if len(deviceRepo.deleted) != 1 || deviceRepo.deleted[0] != "device-1" {
+		t.Fatalf("expected device-1 to be deleted, got %v", deviceRepo.deleted)
+	}
+}
+
 type testOAuthVerifier struct {
 	identity *OAuthIdentity
 	err      error
@@ -517,6 +545,7 @@ func (r *testAuthUserRepo) LockAccount(ctx context.Context, id string, until tim
 
 type testAuthDeviceRepo struct {
 	created []*models.Device
+	deleted []string
 }
 
 func (r *testAuthDeviceRepo) CreateDevice(ctx context.Context, device *models.Device) error {
@@ -530,5 +559,18 @@ func (r *testAuthDeviceRepo) GetDevicesByUserID(ctx context.Context, userID stri
 }
 
 func (r *testAuthDeviceRepo) DeleteDevice(ctx context.Context, deviceID, userID string) error {
+	r.deleted = append(r.deleted, deviceID)
 	return nil
 }
+
+type testDownloadRevoker struct {
+	err        error
+	devices    []string
+	authHeader string
+}
+
+func (r *testDownloadRevoker) RevokeDeviceDownloads(ctx context.Context, deviceID, authHeader string) error {
+	r.devices = append(r.devices, deviceID)
+	r.authHeader = authHeader
+	return r.err
+}
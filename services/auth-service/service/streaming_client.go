@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// StreamingClient calls the streaming service HTTP API on behalf of a user.
+type StreamingClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewStreamingClient creates a streaming-service client.
+func NewStreamingClient(baseURL string) *StreamingClient {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	return &StreamingClient{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+// RevokeDeviceDownloads revokes the offline downloads of a device, so their
+// licenses are no longer issued or renewed.
+func (c *StreamingClient) RevokeDeviceDownloads(ctx context.Context, deviceID, authHeader string) error {
+	if deviceID == "" {
+		return fmt.Errorf("device id is required")
+	}
+	if authHeader == "" {
+		return fmt.Errorf("authorization header is required")
+	}
+
+	endpoint := fmt.Sprintf("%s/streaming/devices/%s/downloads", c.baseURL, url.PathEscape(deviceID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authHeader)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("streaming service returned status %d", res.StatusCode)
+	}
+	return nil
+}
@@ -26,6 +26,8 @@ type Content struct {
 	Directors     []string            `bson:"directors" json:"directors"`
 	Tags          []string            `bson:"tags" json:"tags"`
 	Status        string              `bson:"status" json:"status"` // "draft", "published", "archived"
+	AllowedTerritories []string       `bson:"allowed_territories,omitempty" json:"allowedTerritories,omitempty"` // ISO 3166-1 alpha-2; empty allows all
+	BlockedTerritories []string       `bson:"blocked_territories,omitempty" json:"blockedTerritories,omitempty"`
 	CreatedAt     time.Time           `bson:"created_at" json:"createdAt"`
 	UpdatedAt     time.Time           `bson:"updated_at" json:"updatedAt"`
 }
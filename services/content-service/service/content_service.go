@@ -215,7 +215,7 @@ func evaluateLocalEntitlement(content *models.Content, contentID, userID, countr
 		entitlement.LicenseURL = defaultLicenseURL()
 	}
 
-	if isGeoBlocked(countryCode) {
+	if isGeoBlocked(countryCode) || !territoryAllowed(content, countryCode) {
 		entitlement.HasAccess = false
 		entitlement.Reason = "geo_blocked"
 		entitlement.ExpiresAt = nil
@@ -239,6 +239,28 @@ func isGeoBlocked(countryCode string) bool {
 	return false
 }
 
+// territoryAllowed applies the content's own territory lists. With an allow list,
+// viewers whose country is unknown are refused.
+func territoryAllowed(content *models.Content, countryCode string) bool {
+	countryCode = strings.ToUpper(strings.TrimSpace(countryCode))
+	if countryCode != "" && containsCountry(content.BlockedTerritories, countryCode) {
+		return false
+	}
+	if len(content.AllowedTerritories) == 0 {
+		return true
+	}
+	return countryCode != "" && containsCountry(content.AllowedTerritories, countryCode)
+}
+
+func containsCountry(countries []string, countryCode string) bool {
+	for _, country := range countries {
+		if strings.ToUpper(strings.TrimSpace(country)) == countryCode {
+			return true
+		}
+	}
+	return false
+}
+
 func evaluateEntitlements(records []map[string]interface{}, contentID string) (planID string, hasSubscription bool, hasPurchase bool, purchaseExpiresAt *time.Time) {
 	for _, record := range records {
 		recordType := strings.ToLower(toString(record["type"]))
@@ -139,3 +139,21 @@ func TestToStringAndToTimeHandleUnexpectedInput(t *testing.T) {
 		t.Fatalf("expected invalid date string to return nil")
 	}
 }
+
+func TestLocalEntitlementAppliesContentTerritories(t *testing.T) {
+	content := &models.Content{
+		Category:           "free",
+		AllowedTerritories: []string{"US", "CA"},
+		BlockedTerritories: []string{"ca"},
+	}
+
+	if entitlement := evaluateLocalEntitlement(content, "content-1", "user-1", "us", nil); !entitlement.HasAccess {
+		t.Fatalf("expected allowed territory to have access, got %+v", entitlement)
+	}
+	for _, country := range []string{"CA", "GB", ""} {
+		entitlement := evaluateLocalEntitlement(content, "content-1", "user-1", country, nil)
+		if entitlement.HasAccess || entitlement.Reason != "geo_blocked" {
+			t.Fatalf("expected %q to be geo blocked, got %+v", country, entitlement)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/streamverse/payment-service/models"
+	"github.com/streamverse/proto/gen/go/payment"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SubscriptionProvider is the part of the payment service exposed over gRPC
+type SubscriptionProvider interface {
+	GetSubscriptionPlan(ctx context.Context, userID string) (*models.Subscription, *models.Plan, error)
+}
+
+// PaymentServer implements the payment gRPC API used by other services.
+// CheckConcurrentStreams is left unimplemented: playback sessions are tracked by
+// streaming-service, which enforces the plan's max_streams itself.
+type PaymentServer struct {
+	payment.UnimplementedPaymentServiceServer
+	service SubscriptionProvider
+}
+
+// NewPaymentServer creates a new payment gRPC server
+func NewPaymentServer(service SubscriptionProvider) *PaymentServer {
+	return &PaymentServer{service: service}
+}
+
+// GetSubscription returns the subscription status and plan limits of a user
+func (s *PaymentServer) GetSubscription(ctx context.Context, req *payment.GetSubscriptionRequest) (*payment.GetSubscriptionResponse, error) {
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	subscription, plan, err := s.service.GetSubscriptionPlan(ctx, req.GetUserId())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &payment.GetSubscriptionResponse{IsActive: false}, nil
+	}
+	if err != nil && subscription == nil {
+		return nil, status.Errorf(codes.Internal, "failed to load subscription: %v", err)
+	}
+
+	resp := &payment.GetSubscriptionResponse{
+		IsActive: subscription.Status == "active",
+		PlanId:   subscription.PlanID,
+	}
+	if plan != nil {
+		resp.MaxStreams = int32(plan.MaxStreams)
+		resp.Quality = plan.Quality
+		resp.Features = plan.Features
+		resp.MaxDownloadDevices = int32(plan.MaxDownloadDevices)
+		resp.MaxDownloads = int32(plan.MaxDownloads)
+	}
+	return resp, nil
+}
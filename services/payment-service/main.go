@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,8 +15,11 @@ import (
 	"github.com/streamverse/common-go/logger"
 	"github.com/streamverse/common-go/middleware"
 	paymentHandler "github.com/streamverse/payment-service/handlers"
+	"github.com/streamverse/payment-service/internal/grpcserver"
 	"github.com/streamverse/payment-service/repository"
 	"github.com/streamverse/payment-service/service"
+	"github.com/streamverse/proto/gen/go/payment"
+	"google.golang.org/grpc"
 )
 
 func main() {
@@ -88,6 +92,27 @@ func main() {
 
 	log.Info("Payment service started", logger.String("address", srv.Addr))
 
+	// gRPC API for other services (streaming-service reads plan limits here)
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "50053"
+	}
+	grpcAddr := cfg.Server.Host + ":" + grpcPort
+	grpcListener, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		log.Fatal("Failed to listen for gRPC", logger.Error(err))
+	}
+	grpcServer := grpc.NewServer()
+	payment.RegisterPaymentServiceServer(grpcServer, grpcserver.NewPaymentServer(paymentService))
+
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatal("Failed to start gRPC server", logger.Error(err))
+		}
+	}()
+
+	log.Info("Payment gRPC server started", logger.String("address", grpcAddr))
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -98,6 +123,8 @@ func main() {
 	defer cancel()
 	workerCancel()
 
+	grpcServer.GracefulStop()
+
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatal("Server forced to shutdown", logger.Error(err))
 	}
@@ -32,6 +32,9 @@ type Plan struct {
 	Features   []string `bson:"features" json:"features"`
 	MaxStreams int      `bson:"max_streams" json:"maxStreams"`
 	Quality    string   `bson:"quality" json:"quality"`
+	// Offline download quotas, for plans with the "downloads" feature
+	MaxDownloadDevices int `bson:"max_download_devices,omitempty" json:"maxDownloadDevices,omitempty"`
+	MaxDownloads       int `bson:"max_downloads,omitempty" json:"maxDownloads,omitempty"`
 }
 
 // Payment represents a payment transaction
@@ -36,24 +36,28 @@ func NewPaymentRepository(db *database.MongoDB) *PaymentRepository {
 		Quality:    "480p",
 	}
 	tier2 := &models.Plan{
-		ID:         "tier2",
-		Name:       "Pro",
-		Price:      12.99,
-		Currency:   "USD",
-		Interval:   "month",
-		Features:   []string{"720p", "2 screens", "downloads"},
-		MaxStreams: 2,
-		Quality:    "720p",
+		ID:                 "tier2",
+		Name:               "Pro",
+		Price:              12.99,
+		Currency:           "USD",
+		Interval:           "month",
+		Features:           []string{"720p", "2 screens", "downloads"},
+		MaxStreams:         2,
+		Quality:            "720p",
+		MaxDownloadDevices: 2,
+		MaxDownloads:       25,
 	}
 	tier3 := &models.Plan{
-		ID:         "tier3",
-		Name:       "Premium",
-		Price:      19.99,
-		Currency:   "USD",
-		Interval:   "month",
-		Features:   []string{"4K", "4 screens", "downloads", "priority support"},
-		MaxStreams: 4,
-		Quality:    "4K",
+		ID:                 "tier3",
+		Name:               "Premium",
+		Price:              19.99,
+		Currency:           "USD",
+		Interval:           "month",
+		Features:           []string{"4K", "4 screens", "downloads", "priority support"},
+		MaxStreams:         4,
+		Quality:            "4K",
+		MaxDownloadDevices: 4,
+		MaxDownloads:       100,
 	}
 
 	plans := map[string]*models.Plan{
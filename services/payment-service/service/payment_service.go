@@ -84,6 +84,19 @@ func (s *PaymentService) GetSubscription(ctx context.Context, userID string) (*m
 	return s.repo.GetSubscriptionByUserID(ctx, userID)
 }
 
+// GetSubscriptionPlan retrieves a user's subscription together with its plan
+func (s *PaymentService) GetSubscriptionPlan(ctx context.Context, userID string) (*models.Subscription, *models.Plan, error) {
+	subscription, err := s.repo.GetSubscriptionByUserID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, err := s.repo.GetPlan(subscription.PlanID)
+	if err != nil {
+		return subscription, nil, err
+	}
+	return subscription, plan, nil
+}
+
 // CancelSubscription cancels a subscription - Issue #16
 func (s *PaymentService) CancelSubscription(ctx context.Context, userID, subscriptionID string) error {
 	return s.repo.CancelSubscription(ctx, userID, subscriptionID)
@@ -121,12 +134,17 @@ func (s *PaymentService) GetUserEntitlements(ctx context.Context, userID string)
 	entitlements := []map[string]interface{}{}
 
 	if subscription != nil && subscription.Status == "active" {
-		entitlements = append(entitlements, map[string]interface{}{
+		entitlement := map[string]interface{}{
 			"type":       "subscription",
 			"plan_id":    subscription.PlanID,
 			"status":     subscription.Status,
 			"expires_at": subscription.CurrentPeriodEnd,
-		})
+		}
+		// Plan features let other services, such as ad-service, act on the plan
+		if plan, err := s.repo.GetPlan(subscription.PlanID); err == nil {
+			entitlement["features"] = plan.Features
+		}
+		entitlements = append(entitlements, entitlement)
 	}
 
 	purchases, err := s.repo.GetActivePurchasesByUserID(ctx, userID)
@@ -48,7 +48,11 @@ func main() {
 	if cdnBaseURL == "" {
 		cdnBaseURL = "https://cdn.streamverse.io"
 	}
-	schedulerService := service.NewSchedulerService(schedulerRepo, cdnBaseURL)
+	streamingBaseURL := os.Getenv("STREAMING_SERVICE_URL")
+	if streamingBaseURL == "" {
+		streamingBaseURL = "https://api.streamverse.io"
+	}
+	schedulerService := service.NewSchedulerService(schedulerRepo, cdnBaseURL, streamingBaseURL)
 
 	// Initialize handlers
 	schedulerHandler := schedulerHandler.NewSchedulerHandler(schedulerService, log)
@@ -65,14 +69,14 @@ func main() {
 	// Scheduler routes - Issue #27: All endpoints public (can add auth if needed)
 	api := router.Group("/scheduler")
 	{
-		api.GET("/channels", schedulerHandler.ListChannels)                              // GET /scheduler/channels
-		api.GET("/channels/:channel_id/epg", schedulerHandler.GetChannelEPG)            // GET /scheduler/channels/{channel_id}/epg
-		api.GET("/channels/:channel_id/manifest", schedulerHandler.GetChannelManifest)   // GET /scheduler/channels/{channel_id}/manifest
-		api.GET("/channels/:channel_id/now", schedulerHandler.GetCurrentScheduleEntry)   // GET /scheduler/channels/{channel_id}/now
+		api.GET("/channels", schedulerHandler.ListChannels)                            // GET /scheduler/channels
+		api.GET("/channels/:channel_id/epg", schedulerHandler.GetChannelEPG)           // GET /scheduler/channels/{channel_id}/epg
+		api.GET("/channels/:channel_id/manifest", schedulerHandler.GetChannelManifest) // GET /scheduler/channels/{channel_id}/manifest
+		api.GET("/channels/:channel_id/now", schedulerHandler.GetCurrentScheduleEntry) // GET /scheduler/channels/{channel_id}/now
 		// Admin routes (optional - add auth middleware)
-		api.POST("/schedule", schedulerHandler.CreateScheduleEntry)                      // POST /scheduler/schedule
-		api.PUT("/schedule/:id", schedulerHandler.UpdateScheduleEntry)                  // PUT /scheduler/schedule/{id}
-		api.DELETE("/schedule/:id", schedulerHandler.DeleteScheduleEntry)               // DELETE /scheduler/schedule/{id}
+		api.POST("/schedule", schedulerHandler.CreateScheduleEntry)       // POST /scheduler/schedule
+		api.PUT("/schedule/:id", schedulerHandler.UpdateScheduleEntry)    // PUT /scheduler/schedule/{id}
+		api.DELETE("/schedule/:id", schedulerHandler.DeleteScheduleEntry) // DELETE /scheduler/schedule/{id}
 	}
 
 	// Start server
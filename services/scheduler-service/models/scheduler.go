@@ -14,8 +14,9 @@ type Channel struct {
 	Description string             `bson:"description,omitempty" json:"description,omitempty"`
 	Type        string             `bson:"type" json:"type"` // "fast" or "live"
 	ManifestURL string             `bson:"manifest_url,omitempty" json:"manifestUrl,omitempty"`
-	IngestURL   string             `bson:"ingest_url,omitempty" json:"ingestUrl,omitempty"` // For live channels
-	Status      string             `bson:"status" json:"status"` // "active", "inactive"
+	IngestURL   string             `bson:"ingest_url,omitempty" json:"ingestUrl,omitempty"`   // For live channels
+	LowLatency  bool               `bson:"low_latency,omitempty" json:"lowLatency,omitempty"` // Live channels packaged as LL-HLS / LL-DASH
+	Status      string             `bson:"status" json:"status"`                              // "active", "inactive"
 	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
 	UpdatedAt   time.Time          `bson:"updated_at" json:"updatedAt"`
 }
@@ -37,10 +38,10 @@ type ScheduleEntry struct {
 
 // EPG represents an Electronic Program Guide for a channel
 type EPG struct {
-	ChannelID string          `json:"channelId"`
-	ChannelName string         `json:"channelName"`
-	Schedule   []EPGEntry      `json:"schedule"`
-	GeneratedAt time.Time      `json:"generatedAt"`
+	ChannelID   string     `json:"channelId"`
+	ChannelName string     `json:"channelName"`
+	Schedule    []EPGEntry `json:"schedule"`
+	GeneratedAt time.Time  `json:"generatedAt"`
 }
 
 // EPGEntry represents a single EPG entry
@@ -55,8 +56,9 @@ type EPGEntry struct {
 
 // ChannelManifest represents a streaming manifest for a channel
 type ChannelManifest struct {
-	ChannelID string `json:"channelId"`
-	ManifestURL string `json:"manifestUrl"`
-	Type       string `json:"type"` // "hls" or "dash"
+	ChannelID       string `json:"channelId"`
+	ManifestURL     string `json:"manifestUrl"`
+	Type            string `json:"type"` // "hls" or "dash"
+	DASHManifestURL string `json:"dashManifestUrl,omitempty"`
+	LowLatency      bool   `json:"lowLatency,omitempty"`
 }
-
@@ -11,15 +11,17 @@ import (
 
 // SchedulerService handles scheduler business logic
 type SchedulerService struct {
-	repo *repository.SchedulerRepository
-	cdnBaseURL string // TODO: Load from config
+	repo             *repository.SchedulerRepository
+	cdnBaseURL       string // TODO: Load from config
+	streamingBaseURL string
 }
 
 // NewSchedulerService creates a new scheduler service
-func NewSchedulerService(repo *repository.SchedulerRepository, cdnBaseURL string) *SchedulerService {
+func NewSchedulerService(repo *repository.SchedulerRepository, cdnBaseURL, streamingBaseURL string) *SchedulerService {
 	return &SchedulerService{
-		repo: repo,
-		cdnBaseURL: cdnBaseURL,
+		repo:             repo,
+		cdnBaseURL:       cdnBaseURL,
+		streamingBaseURL: streamingBaseURL,
 	}
 }
 
@@ -73,7 +75,7 @@ func (s *SchedulerService) GetChannelEPG(ctx context.Context, channelID string)
 	return &models.EPG{
 		ChannelID:   channel.ChannelID,
 		ChannelName: channel.Name,
-		Schedule:   epgEntries,
+		Schedule:    epgEntries,
 		GeneratedAt: time.Now(),
 	}, nil
 }
@@ -85,6 +87,18 @@ func (s *SchedulerService) GetChannelManifest(ctx context.Context, channelID str
 		return nil, fmt.Errorf("channel not found: %w", err)
 	}
 
+	// Low-latency live channels are served by the streaming service's LL-HLS / LL-DASH endpoints
+	if channel.Type == "live" && channel.LowLatency {
+		liveBaseURL := fmt.Sprintf("%s/streaming/live/%s", s.streamingBaseURL, channelID)
+		return &models.ChannelManifest{
+			ChannelID:       channelID,
+			ManifestURL:     liveBaseURL + "/master.m3u8",
+			Type:            "hls",
+			DASHManifestURL: liveBaseURL + "/manifest.mpd",
+			LowLatency:      true,
+		}, nil
+	}
+
 	if channel.Type == "live" && channel.ManifestURL != "" {
 		return &models.ChannelManifest{
 			ChannelID:   channelID,
@@ -129,4 +143,3 @@ func (s *SchedulerService) DeleteScheduleEntry(ctx context.Context, entryID stri
 func (s *SchedulerService) GetCurrentScheduleEntry(ctx context.Context, channelID string) (*models.ScheduleEntry, error) {
 	return s.repo.GetCurrentScheduleEntry(ctx, channelID)
 }
-
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/streamverse/common-go/errors"
+	"github.com/streamverse/common-go/logger"
+	"github.com/streamverse/streaming-service/service"
+	"go.uber.org/zap"
+)
+
+// DownloadHandler handles offline download requests
+type DownloadHandler struct {
+	downloads *service.DownloadManager
+	logger    *logger.Logger
+}
+
+// NewDownloadHandler creates a new download handler
+func NewDownloadHandler(downloads *service.DownloadManager, logger *logger.Logger) *DownloadHandler {
+	return &DownloadHandler{
+		downloads: downloads,
+		logger:    logger,
+	}
+}
+
+// RequestDownload handles POST /streaming/downloads for the device in X-Device-ID.
+// Requesting a title the device already holds renews its offline license.
+func (h *DownloadHandler) RequestDownload(c *gin.Context) {
+	var req struct {
+		ContentID string `json:"content_id" binding:"required"`
+		Quality   string `json:"quality"` // optional, e.g. "720p" to save storage
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+		return
+	}
+
+	tenantID := c.GetString("org_id")
+	if tenantID == "" {
+		tenantID = c.GetHeader("X-Tenant-ID")
+	}
+
+	ticket, err := h.downloads.RequestDownload(c.Request.Context(), &service.DownloadRequest{
+		UserID:      c.GetString("user_id"),
+		ContentID:   req.ContentID,
+		DeviceID:    c.GetHeader("X-Device-ID"),
+		DeviceType:  deviceType(c),
+		DRMSecurity: drmSecurity(c),
+		TenantID:    tenantID,
+		ClientIP:    c.ClientIP(),
+		Quality:     req.Quality,
+	})
+	if err != nil {
+		switch {
+		case stderrors.Is(err, service.ErrDeviceRequired):
+			c.JSON(http.StatusBadRequest, errors.NewInvalidInputError("X-Device-ID header is required"))
+		case stderrors.Is(err, service.ErrGeoBlocked):
+			c.JSON(http.StatusForbidden, errors.NewGeoBlockedError(err.Error()))
+		case stderrors.Is(err, service.ErrNoActiveSubscription):
+			c.JSON(http.StatusForbidden, errors.NewForbiddenError("An active subscription is required"))
+		case stderrors.Is(err, service.ErrDownloadsNotIncluded):
+			c.JSON(http.StatusForbidden, errors.NewForbiddenError("Your plan does not include downloads"))
+		case stderrors.Is(err, service.ErrDownloadDeviceLimit):
+			c.JSON(http.StatusConflict, errors.NewConflictError("Download device limit reached for your plan"))
+		case stderrors.Is(err, service.ErrDownloadLimit):
+			c.JSON(http.StatusConflict, errors.NewConflictError("Download limit reached for your plan"))
+		case stderrors.Is(err, service.ErrDownloadRevoked):
+			c.JSON(http.StatusGone, errors.NewAppError(errors.ErrorCodeNotFound, "Download has been revoked", http.StatusGone))
+		default:
+			h.logger.Error("Failed to request download", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to request download"))
+		}
+		return
+	}
+
+	// Downloads are fetched through the playback proxy like streams
+	ticket.HLSURL = "/streaming/play/" + ticket.Token + "/master.m3u8"
+	ticket.DASHURL = "/streaming/play/" + ticket.Token + "/manifest.mpd"
+
+	c.JSON(http.StatusOK, ticket)
+}
+
+// ListDownloads handles GET /streaming/downloads, optionally for one device_id.
+// Devices delete the downloads listed as revoked.
+func (h *DownloadHandler) ListDownloads(c *gin.Context) {
+	downloads, err := h.downloads.ListDownloads(c.Request.Context(), c.GetString("user_id"), c.Query("device_id"))
+	if err != nil {
+		h.logger.Error("Failed to list downloads", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to list downloads"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"downloads": downloads})
+}
+
+// DeleteDownload handles DELETE /streaming/downloads/:download_id
+func (h *DownloadHandler) DeleteDownload(c *gin.Context) {
+	if err := h.downloads.DeleteDownload(c.Request.Context(), c.GetString("user_id"), c.Param("download_id")); err != nil {
+		if stderrors.Is(err, service.ErrDownloadNotFound) {
+			c.JSON(http.StatusNotFound, errors.NewNotFoundError("Download not found"))
+			return
+		}
+		h.logger.Error("Failed to delete download", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to delete download"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Download deleted"})
+}
+
+// RevokeDevice handles DELETE /streaming/devices/:device_id/downloads, called when
+// a device is removed from the account
+func (h *DownloadHandler) RevokeDevice(c *gin.Context) {
+	userID := c.GetString("user_id")
+	deviceID := c.Param("device_id")
+
+	revoked, err := h.downloads.RevokeDevice(c.Request.Context(), userID, deviceID)
+	if err != nil {
+		h.logger.Error("Failed to revoke device downloads", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to revoke downloads"))
+		return
+	}
+
+	h.logger.Info("Device downloads revoked", zap.String("user_id", userID), zap.String("device_id", deviceID), zap.Int64("downloads", revoked))
+	c.JSON(http.StatusOK, gin.H{"message": "Downloads revoked", "revoked": revoked})
+}
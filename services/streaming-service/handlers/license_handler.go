@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/streamverse/common-go/errors"
+	"github.com/streamverse/common-go/logger"
+	"github.com/streamverse/streaming-service/internal/license"
+	"github.com/streamverse/streaming-service/service"
+	"go.uber.org/zap"
+)
+
+// maxChallengeSize bounds license challenges; real ones are a few kilobytes
+const maxChallengeSize = 64 << 10
+
+// LicenseHandler handles DRM license requests
+type LicenseHandler struct {
+	proxy  *service.LicenseProxy
+	logger *logger.Logger
+}
+
+// NewLicenseHandler creates a new license handler
+func NewLicenseHandler(proxy *service.LicenseProxy, logger *logger.Logger) *LicenseHandler {
+	return &LicenseHandler{
+		proxy:  proxy,
+		logger: logger,
+	}
+}
+
+// AcquireLicense handles POST /streaming/license/:system. The body is the raw
+// challenge from the CDM (the SPC for FairPlay) and the playback token comes in
+// X-Playback-Token or the token query parameter.
+func (h *LicenseHandler) AcquireLicense(c *gin.Context) {
+	challenge, err := io.ReadAll(io.LimitReader(c.Request.Body, maxChallengeSize+1))
+	if err != nil || len(challenge) == 0 || len(challenge) > maxChallengeSize {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError("Invalid license challenge"))
+		return
+	}
+
+	token := c.GetHeader("X-Playback-Token")
+	if token == "" {
+		token = c.Query("token")
+	}
+	userID, _ := c.Get("user_id")
+	userIDStr, _ := userID.(string)
+
+	licenseBytes, err := h.proxy.Acquire(c.Request.Context(), &service.LicenseRequest{
+		System:        c.Param("system"),
+		Challenge:     challenge,
+		PlaybackToken: token,
+		AuthHeader:    c.GetHeader("Authorization"),
+		UserID:        userIDStr,
+		ClientIP:      c.ClientIP(),
+		DeviceID:      c.GetHeader("X-Device-ID"),
+	})
+	if err != nil {
+		switch {
+		case stderrors.Is(err, license.ErrUnsupportedSystem):
+			c.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
+		case stderrors.Is(err, service.ErrGeoBlocked):
+			c.JSON(http.StatusForbidden, errors.NewGeoBlockedError(err.Error()))
+		case stderrors.Is(err, service.ErrLicenseDenied), stderrors.Is(err, service.ErrTokenMismatch),
+			stderrors.Is(err, service.ErrDownloadNotFound):
+			c.JSON(http.StatusForbidden, errors.NewForbiddenError(err.Error()))
+		case stderrors.Is(err, license.ErrRentalExpired), stderrors.Is(err, service.ErrSessionEnded),
+			stderrors.Is(err, service.ErrDownloadRevoked):
+			c.JSON(http.StatusGone, errors.NewAppError(errors.ErrorCodeNotFound, err.Error(), http.StatusGone))
+		case stderrors.Is(err, service.ErrTokenRevoked):
+			c.JSON(http.StatusUnauthorized, errors.NewUnauthorizedError("Token has been revoked"))
+		case stderrors.Is(err, service.ErrLicenseUnauthorized):
+			c.JSON(http.StatusUnauthorized, errors.NewUnauthorizedError("Invalid token"))
+		default:
+			h.logger.Error("Failed to acquire license", zap.Error(err))
+			c.JSON(http.StatusBadGateway, errors.NewAppError(errors.ErrorCodeInternal, "Failed to acquire license", http.StatusBadGateway))
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/octet-stream", licenseBytes)
+}
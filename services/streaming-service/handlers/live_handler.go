@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/streamverse/common-go/errors"
+	"github.com/streamverse/streaming-service/models"
+	"github.com/streamverse/streaming-service/service"
+	"go.uber.org/zap"
+)
+
+// StartLiveStream handles PUT /streaming/live/:channel_id (packager)
+func (h *StreamingHandler) StartLiveStream(c *gin.Context) {
+	var stream models.LiveStream
+	if err := c.ShouldBindJSON(&stream); err != nil {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+		return
+	}
+	stream.ChannelID = c.Param("channel_id")
+
+	if err := h.service.StartLiveStream(c.Request.Context(), &stream); err != nil {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Live stream started"})
+}
+
+// StopLiveStream handles DELETE /streaming/live/:channel_id (packager)
+func (h *StreamingHandler) StopLiveStream(c *gin.Context) {
+	h.service.StopLiveStream(c.Request.Context(), c.Param("channel_id"))
+	c.JSON(http.StatusOK, gin.H{"message": "Live stream stopped"})
+}
+
+// AppendLivePart handles POST /streaming/live/:channel_id/:rendition/parts (packager)
+func (h *StreamingHandler) AppendLivePart(c *gin.Context) {
+	var part models.LivePart
+	if err := c.ShouldBindJSON(&part); err != nil {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+		return
+	}
+
+	if err := h.service.AppendLivePart(c.Request.Context(), c.Param("channel_id"), c.Param("rendition"), part); err != nil {
+		h.respondLiveError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// CompleteLiveSegment handles POST /streaming/live/:channel_id/:rendition/segments (packager)
+func (h *StreamingHandler) CompleteLiveSegment(c *gin.Context) {
+	var segment models.LiveSegment
+	if err := c.ShouldBindJSON(&segment); err != nil {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+		return
+	}
+
+	if err := h.service.CompleteLiveSegment(c.Request.Context(), c.Param("channel_id"), c.Param("rendition"), segment); err != nil {
+		h.respondLiveError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetLiveManifest handles GET /streaming/live/:channel_id/:token/:file where file
+// is master.m3u8, manifest.mpd or <rendition>.m3u8 (with optional _HLS_msn/_HLS_part).
+// The manifest token is issued for the channel like for any other content, and
+// as a path segment it stays on the relative URIs of the rendition playlists.
+func (h *StreamingHandler) GetLiveManifest(c *gin.Context) {
+	channelID := c.Param("channel_id")
+	file := c.Param("file")
+
+	claims, err := h.service.AuthorizeToken(c.Request.Context(), c.Param("token"), channelID, c.ClientIP(), c.GetHeader("X-Device-ID"))
+	if err != nil {
+		h.respondTokenError(c, err)
+		return
+	}
+
+	switch {
+	case file == "master.m3u8":
+		manifest, err := h.service.GenerateLiveHLSManifest(c.Request.Context(), channelID, claims, c.ClientIP())
+		if err != nil {
+			h.respondLiveError(c, err)
+			return
+		}
+		c.Header("Content-Type", "application/vnd.apple.mpegurl")
+		c.String(http.StatusOK, manifest)
+	case file == "manifest.mpd":
+		manifest, err := h.service.GenerateLLDASHManifest(c.Request.Context(), channelID, claims, c.ClientIP())
+		if err != nil {
+			h.respondLiveError(c, err)
+			return
+		}
+		c.Header("Content-Type", "application/dash+xml")
+		c.String(http.StatusOK, manifest)
+	case strings.HasSuffix(file, ".m3u8"):
+		h.getLLHLSPlaylist(c, channelID, strings.TrimSuffix(file, ".m3u8"), claims)
+	default:
+		c.JSON(http.StatusNotFound, errors.NewNotFoundError("Unknown manifest format"))
+	}
+}
+
+func (h *StreamingHandler) getLLHLSPlaylist(c *gin.Context, channelID, rendition string, claims *models.StreamingClaims) {
+	msn, part := int64(-1), -1
+	if value := c.Query("_HLS_msn"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, errors.NewInvalidInputError("Invalid _HLS_msn"))
+			return
+		}
+		msn = parsed
+	}
+	if value := c.Query("_HLS_part"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || msn < 0 {
+			c.JSON(http.StatusBadRequest, errors.NewInvalidInputError("Invalid _HLS_part"))
+			return
+		}
+		part = parsed
+	}
+
+	playlist, err := h.service.GenerateLLHLSPlaylist(c.Request.Context(), channelID, rendition, claims, c.ClientIP(), msn, part)
+	if err != nil {
+		h.respondLiveError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/vnd.apple.mpegurl")
+	c.String(http.StatusOK, playlist)
+}
+
+func (h *StreamingHandler) respondLiveError(c *gin.Context, err error) {
+	switch {
+	case stderrors.Is(err, service.ErrLiveStreamNotFound):
+		c.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
+	case stderrors.Is(err, service.ErrGeoBlocked):
+		c.JSON(http.StatusForbidden, errors.NewGeoBlockedError(err.Error()))
+	case stderrors.Is(err, service.ErrLiveRequestTooFar):
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+	case stderrors.Is(err, service.ErrLiveReloadTimeout):
+		c.JSON(http.StatusServiceUnavailable, errors.NewAppError(errors.ErrorCodeServiceUnavailable, err.Error(), http.StatusServiceUnavailable))
+	default:
+		h.logger.Error("Live stream request failed", zap.Error(err))
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+	}
+}
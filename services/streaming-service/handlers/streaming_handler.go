@@ -1,14 +1,19 @@
 package handlers
 
 import (
+	stderrors "errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/streamverse/common-go/errors"
 	"github.com/streamverse/common-go/logger"
+	"github.com/streamverse/streaming-service/internal/qoe"
 	"github.com/streamverse/streaming-service/models"
 	"github.com/streamverse/streaming-service/service"
+	"github.com/streamverse/streaming-service/utils"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
 )
 
@@ -26,22 +31,39 @@ func NewStreamingHandler(service *service.StreamingService, logger *logger.Logge
 	}
 }
 
-// GetHLSManifest handles GET /streaming/manifest/:content_id/:token.m3u8 - Issue #14
-func (h *StreamingHandler) GetHLSManifest(c *gin.Context) {
+// GetManifestFile handles GET /streaming/manifest/:content_id/:token.(m3u8|mpd) - Issue #14
+// The token and the format share the last path segment, so dispatch on the extension.
+// New clients use the playback proxy (GetPlaybackFile) instead.
+func (h *StreamingHandler) GetManifestFile(c *gin.Context) {
+	file := c.Param("token")
+	switch {
+	case strings.HasSuffix(file, ".m3u8"):
+		h.GetHLSManifest(c, strings.TrimSuffix(file, ".m3u8"))
+	case strings.HasSuffix(file, ".mpd"):
+		h.GetDASHManifest(c, strings.TrimSuffix(file, ".mpd"))
+	default:
+		c.JSON(http.StatusNotFound, errors.NewNotFoundError("Unknown manifest format"))
+	}
+}
+
+// GetHLSManifest serves the HLS multivariant playlist for a manifest token
+func (h *StreamingHandler) GetHLSManifest(c *gin.Context, token string) {
 	contentID := c.Param("content_id")
-	token := c.Param("token")
 
-	// Validate token
-	userID, err := h.service.ValidateToken(c.Request.Context(), token)
+	// Validate token and its binding to this content, network and device
+	claims, err := h.service.AuthorizeToken(c.Request.Context(), token, contentID, c.ClientIP(), c.GetHeader("X-Device-ID"))
 	if err != nil {
-		h.logger.Error("Invalid token", zap.Error(err))
-		c.JSON(http.StatusUnauthorized, errors.NewUnauthorizedError("Invalid token"))
+		h.respondTokenError(c, err)
 		return
 	}
 
-	// Generate HLS manifest
-	manifest, err := h.service.GenerateHLSManifest(c.Request.Context(), contentID, userID)
+	// Generate HLS manifest; media playlists live under <token>/ so relative URIs keep the token
+	manifest, err := h.service.GenerateHLSManifest(c.Request.Context(), contentID, claims, c.ClientIP(), token+"/", steeringURI(contentID, token))
 	if err != nil {
+		if stderrors.Is(err, service.ErrGeoBlocked) {
+			c.JSON(http.StatusForbidden, errors.NewGeoBlockedError(err.Error()))
+			return
+		}
 		h.logger.Error("Failed to get manifest", zap.Error(err))
 		c.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
 		return
@@ -52,22 +74,55 @@ func (h *StreamingHandler) GetHLSManifest(c *gin.Context) {
 	c.String(http.StatusOK, manifest)
 }
 
-// GetDASHManifest handles GET /streaming/manifest/:content_id/:token.mpd - Issue #14
-func (h *StreamingHandler) GetDASHManifest(c *gin.Context) {
+// GetHLSMediaPlaylist handles GET /streaming/manifest/:content_id/:token/:rendition.m3u8
+func (h *StreamingHandler) GetHLSMediaPlaylist(c *gin.Context) {
 	contentID := c.Param("content_id")
-	token := c.Param("token")
+	renditionName, ok := strings.CutSuffix(c.Param("rendition"), ".m3u8")
+	if !ok {
+		c.JSON(http.StatusNotFound, errors.NewNotFoundError("Unknown playlist format"))
+		return
+	}
 
-	// Validate token
-	userID, err := h.service.ValidateToken(c.Request.Context(), token)
+	claims, err := h.service.AuthorizeToken(c.Request.Context(), c.Param("token"), contentID, c.ClientIP(), c.GetHeader("X-Device-ID"))
 	if err != nil {
-		h.logger.Error("Invalid token", zap.Error(err))
-		c.JSON(http.StatusUnauthorized, errors.NewUnauthorizedError("Invalid token"))
+		h.respondTokenError(c, err)
+		return
+	}
+
+	// pathway is set when the player is steering between CDNs
+	playlist, err := h.service.GenerateHLSMediaPlaylist(c.Request.Context(), contentID, renditionName, claims, c.ClientIP(), c.Query("pathway"))
+	if err != nil {
+		if stderrors.Is(err, service.ErrGeoBlocked) {
+			c.JSON(http.StatusForbidden, errors.NewGeoBlockedError(err.Error()))
+			return
+		}
+		h.logger.Error("Failed to get media playlist", zap.Error(err))
+		c.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
+		return
+	}
+
+	c.Header("Content-Type", "application/vnd.apple.mpegurl")
+	c.String(http.StatusOK, playlist)
+}
+
+// GetDASHManifest serves the DASH MPD for a manifest token
+func (h *StreamingHandler) GetDASHManifest(c *gin.Context, token string) {
+	contentID := c.Param("content_id")
+
+	// Validate token and its binding to this content, network and device
+	claims, err := h.service.AuthorizeToken(c.Request.Context(), token, contentID, c.ClientIP(), c.GetHeader("X-Device-ID"))
+	if err != nil {
+		h.respondTokenError(c, err)
 		return
 	}
 
 	// Generate DASH manifest
-	manifest, err := h.service.GenerateDASHManifest(c.Request.Context(), contentID, userID)
+	manifest, err := h.service.GenerateDASHManifest(c.Request.Context(), contentID, claims, c.ClientIP(), steeringURI(contentID, token))
 	if err != nil {
+		if stderrors.Is(err, service.ErrGeoBlocked) {
+			c.JSON(http.StatusForbidden, errors.NewGeoBlockedError(err.Error()))
+			return
+		}
 		h.logger.Error("Failed to get manifest", zap.Error(err))
 		c.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
 		return
@@ -78,30 +133,184 @@ func (h *StreamingHandler) GetDASHManifest(c *gin.Context) {
 	c.String(http.StatusOK, manifest)
 }
 
+// GetPlaybackFile handles GET /streaming/play/:token/:file, the playback proxy. The
+// token is a path segment of its own, so the multivariant playlist, MPD, media
+// playlists, thumbnail track and steering manifest all reference each other with
+// relative URIs and every request is authorized against the session's token.
+func (h *StreamingHandler) GetPlaybackFile(c *gin.Context) {
+	token := c.Param("token")
+	file := c.Param("file")
+
+	claims, err := h.service.AuthorizePlaybackToken(c.Request.Context(), token, c.ClientIP(), c.GetHeader("X-Device-ID"))
+	if err != nil {
+		h.respondTokenError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	switch {
+	case file == "master.m3u8":
+		manifest, err := h.service.GenerateHLSManifest(ctx, claims.ContentID, claims, c.ClientIP(), "", "steering.json")
+		h.respondManifest(c, "application/vnd.apple.mpegurl", manifest, err)
+	case file == "manifest.mpd":
+		manifest, err := h.service.GenerateDASHManifest(ctx, claims.ContentID, claims, c.ClientIP(), "steering.json")
+		h.respondManifest(c, "application/dash+xml", manifest, err)
+	case file == "steering.json":
+		c.JSON(http.StatusOK, h.service.SteeringManifest(claims, c.ClientIP(), c.Query("format")))
+	case strings.HasSuffix(file, ".m3u8"):
+		// pathway is set when the player is steering between CDNs
+		playlist, err := h.service.GenerateHLSMediaPlaylist(ctx, claims.ContentID, strings.TrimSuffix(file, ".m3u8"), claims, c.ClientIP(), c.Query("pathway"))
+		h.respondManifest(c, "application/vnd.apple.mpegurl", playlist, err)
+	case file == "thumbnails.vtt":
+		track, err := h.service.GenerateThumbnailVTT(ctx, claims.ContentID, claims, c.ClientIP(), c.Query("pathway"))
+		h.respondManifest(c, "text/vtt", track, err)
+	default:
+		c.JSON(http.StatusNotFound, errors.NewNotFoundError("Unknown playback file"))
+	}
+}
+
+// respondManifest writes a generated manifest or maps its error
+func (h *StreamingHandler) respondManifest(c *gin.Context, contentType, manifest string, err error) {
+	if err != nil {
+		if stderrors.Is(err, service.ErrGeoBlocked) {
+			c.JSON(http.StatusForbidden, errors.NewGeoBlockedError(err.Error()))
+			return
+		}
+		h.logger.Error("Failed to get manifest", zap.Error(err))
+		c.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
+		return
+	}
+
+	// Manifests are cut per session and carry fresh CDN tokens
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Content-Type", contentType)
+	c.String(http.StatusOK, manifest)
+}
+
+// GetSteeringManifest handles GET /streaming/steering/:content_id/:token, the content
+// steering server players poll for the CDN order during a session
+func (h *StreamingHandler) GetSteeringManifest(c *gin.Context) {
+	contentID := c.Param("content_id")
+
+	claims, err := h.service.AuthorizeToken(c.Request.Context(), c.Param("token"), contentID, c.ClientIP(), c.GetHeader("X-Device-ID"))
+	if err != nil {
+		h.respondTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.service.SteeringManifest(claims, c.ClientIP(), c.Query("format")))
+}
+
+// steeringURI locates the steering server relative to /streaming/manifest/:content_id/
+func steeringURI(contentID, token string) string {
+	return "../../steering/" + contentID + "/" + token
+}
+
 // GenerateToken handles POST /streaming/token - Issue #14
 func (h *StreamingHandler) GenerateToken(c *gin.Context) {
 	var req struct {
 		ContentID string `json:"content_id" binding:"required"`
+		SessionID string `json:"session_id"` // optional; ties the token to a playback session
+		// Optional overrides of the user's track preferences for this playback
+		SubtitleLanguage *string `json:"subtitle_language"`
+		AudioLanguage    *string `json:"audio_language"`
+		ClosedCaptions   *bool   `json:"closed_captions"`
+		AudioDescription *bool   `json:"audio_description"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
 		return
 	}
 
+	tracks := h.service.TrackPreferences(c.Request.Context(), c.GetHeader("Authorization"))
+	if req.SubtitleLanguage != nil {
+		tracks.SubtitleLanguage = *req.SubtitleLanguage
+	}
+	if req.AudioLanguage != nil {
+		tracks.AudioLanguage = *req.AudioLanguage
+	}
+	if req.ClosedCaptions != nil {
+		tracks.ClosedCaptions = *req.ClosedCaptions
+	}
+	if req.AudioDescription != nil {
+		tracks.AudioDescription = *req.AudioDescription
+	}
+
 	userID, _ := c.Get("user_id")
 	deviceID := c.GetHeader("X-Device-ID")
 	ip := c.ClientIP()
 
-	token, err := h.service.GenerateToken(c.Request.Context(), req.ContentID, userID.(string), ip, deviceID)
+	// The organization of the authenticated user is the tenant; fall back to the tenant header
+	tenantID := c.GetString("org_id")
+	if tenantID == "" {
+		tenantID = c.GetHeader("X-Tenant-ID")
+	}
+
+	token, err := h.service.GenerateToken(c.Request.Context(), req.ContentID, userID.(string), tenantID, req.SessionID, ip, deviceID, deviceType(c), drmSecurity(c), tracks)
 	if err != nil {
+		switch {
+		case stderrors.Is(err, service.ErrGeoBlocked):
+			c.JSON(http.StatusForbidden, errors.NewGeoBlockedError(err.Error()))
+			return
+		case stderrors.Is(err, service.ErrNoActiveSubscription):
+			c.JSON(http.StatusForbidden, errors.NewForbiddenError("An active subscription is required"))
+			return
+		case stderrors.Is(err, service.ErrInvalidSession):
+			c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+			return
+		case stderrors.Is(err, service.ErrSessionEnded):
+			c.JSON(http.StatusGone, errors.NewAppError(errors.ErrorCodeNotFound, "Playback session has ended", http.StatusGone))
+			return
+		}
 		h.logger.Error("Failed to generate token", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to generate token"))
 		return
 	}
 
+	// The token is a directory of the playback proxy, so relative child URIs keep it
+	token.HLSURL = "/streaming/play/" + token.Token + "/master.m3u8"
+	token.DASHURL = "/streaming/play/" + token.Token + "/manifest.mpd"
+	token.ThumbnailsURL = "/streaming/play/" + token.Token + "/thumbnails.vtt"
+
 	c.JSON(http.StatusOK, token)
 }
 
+// RevokeToken handles POST /streaming/tokens/revoke, letting support kill a leaked manifest token
+func (h *StreamingHandler) RevokeToken(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+		return
+	}
+
+	claims, err := h.service.RevokeToken(c.Request.Context(), req.Token)
+	if err != nil {
+		h.logger.Error("Failed to revoke token", zap.Error(err))
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+		return
+	}
+
+	h.logger.Info("Manifest token revoked", zap.String("token_id", claims.TokenID), zap.String("user_id", claims.UserID))
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked", "tokenId": claims.TokenID})
+}
+
+// respondTokenError maps manifest token failures to responses
+func (h *StreamingHandler) respondTokenError(c *gin.Context, err error) {
+	switch {
+	case stderrors.Is(err, service.ErrTokenMismatch):
+		c.JSON(http.StatusForbidden, errors.NewForbiddenError(err.Error()))
+	case stderrors.Is(err, service.ErrTokenRevoked):
+		c.JSON(http.StatusUnauthorized, errors.NewUnauthorizedError("Token has been revoked"))
+	case stderrors.Is(err, service.ErrSessionEnded):
+		c.JSON(http.StatusGone, errors.NewAppError(errors.ErrorCodeNotFound, "Playback session has ended", http.StatusGone))
+	default:
+		h.logger.Error("Invalid token", zap.Error(err))
+		c.JSON(http.StatusUnauthorized, errors.NewUnauthorizedError("Invalid token"))
+	}
+}
+
 // SubmitQoE handles POST /streaming/qoe - Issue #14
 func (h *StreamingHandler) SubmitQoE(c *gin.Context) {
 	var event models.QoEEvent
@@ -112,11 +321,28 @@ func (h *StreamingHandler) SubmitQoE(c *gin.Context) {
 
 	userID, _ := c.Get("user_id")
 	event.UserID = userID.(string)
+	if event.DeviceID == "" {
+		event.DeviceID = c.GetHeader("X-Device-ID")
+	}
 	if event.Timestamp.IsZero() {
 		event.Timestamp = time.Now()
 	}
 
-	if err := h.service.SubmitQoE(c.Request.Context(), &event); err != nil {
+	tenantID := c.GetString("org_id")
+	if tenantID == "" {
+		tenantID = c.GetHeader("X-Tenant-ID")
+	}
+
+	if err := h.service.SubmitQoE(c.Request.Context(), &event, tenantID); err != nil {
+		if stderrors.Is(err, service.ErrInvalidSession) {
+			c.JSON(http.StatusForbidden, errors.NewForbiddenError(err.Error()))
+			return
+		}
+		if stderrors.Is(err, qoe.ErrBackpressure) {
+			c.Header("Retry-After", "1")
+			c.JSON(http.StatusServiceUnavailable, errors.NewAppError(errors.ErrorCodeServiceUnavailable, "QoE pipeline is busy, retry later", http.StatusServiceUnavailable))
+			return
+		}
 		h.logger.Error("Failed to submit QoE", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to submit QoE"))
 		return
@@ -125,14 +351,81 @@ func (h *StreamingHandler) SubmitQoE(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "QoE event recorded"})
 }
 
+// GetSessionQoE handles GET /streaming/sessions/:sessionId/qoe
+func (h *StreamingHandler) GetSessionQoE(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	metrics, err := h.service.GetSessionQoE(c.Request.Context(), sessionID)
+	if err != nil {
+		if stderrors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, errors.NewNotFoundError("No QoE data for session"))
+			return
+		}
+		h.logger.Error("Failed to get session QoE", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to get session QoE"))
+		return
+	}
+
+	// Viewers may only read their own sessions; admins may read any
+	if metrics.UserID != c.GetString("user_id") && !hasRole(c, "admin") {
+		c.JSON(http.StatusNotFound, errors.NewNotFoundError("No QoE data for session"))
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
+// GetContentQoE handles GET /streaming/qoe/content/:content_id
+func (h *StreamingHandler) GetContentQoE(c *gin.Context) {
+	contentID := c.Param("content_id")
+
+	rollup, err := h.service.GetContentQoE(c.Request.Context(), contentID)
+	if err != nil {
+		if stderrors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, errors.NewNotFoundError("No QoE data for content"))
+			return
+		}
+		h.logger.Error("Failed to get content QoE", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to get content QoE"))
+		return
+	}
+
+	c.JSON(http.StatusOK, rollup)
+}
+
+// GetCPIX handles GET /streaming/drm/:content_id/cpix, giving packagers the
+// content keys of a title as a CPIX document
+func (h *StreamingHandler) GetCPIX(c *gin.Context) {
+	contentID := c.Param("content_id")
+
+	document, err := h.service.ExportCPIX(c.Request.Context(), contentID)
+	if err != nil {
+		h.logger.Error("Failed to export CPIX", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to export content keys"))
+		return
+	}
+
+	// The document carries keys in clear
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/xml", document)
+}
+
 // GetManifest handles GET /api/v1/streaming/:contentId/manifest (deprecated, kept for backward compatibility)
 func (h *StreamingHandler) GetManifest(c *gin.Context) {
 	contentID := c.Param("contentId")
 	format := c.DefaultQuery("format", "hls")
 	userID, _ := c.Get("user_id")
 
-	manifest, err := h.service.GetManifest(c.Request.Context(), contentID, format, userID.(string))
+	manifest, err := h.service.GetManifest(c.Request.Context(), contentID, format, userID.(string), c.ClientIP(), drmSecurity(c))
 	if err != nil {
+		switch {
+		case stderrors.Is(err, service.ErrGeoBlocked):
+			c.JSON(http.StatusForbidden, errors.NewGeoBlockedError(err.Error()))
+			return
+		case stderrors.Is(err, service.ErrNoActiveSubscription):
+			c.JSON(http.StatusForbidden, errors.NewForbiddenError("An active subscription is required"))
+			return
+		}
 		h.logger.Error("Failed to get manifest", zap.Error(err))
 		c.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
 		return
@@ -152,8 +445,16 @@ func (h *StreamingHandler) CreateSession(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	deviceID := c.GetHeader("X-Device-ID")
 
-	session, err := h.service.CreateSession(c.Request.Context(), userID.(string), req.ContentID, deviceID)
+	session, err := h.service.CreateSession(c.Request.Context(), userID.(string), req.ProfileID, req.ContentID, deviceID, deviceType(c))
 	if err != nil {
+		switch {
+		case stderrors.Is(err, service.ErrStreamLimitReached):
+			c.JSON(http.StatusConflict, errors.NewConflictError("Concurrent stream limit reached for your plan"))
+			return
+		case stderrors.Is(err, service.ErrNoActiveSubscription):
+			c.JSON(http.StatusForbidden, errors.NewForbiddenError("An active subscription is required"))
+			return
+		}
 		h.logger.Error("Failed to create session", zap.Error(err))
 		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
 		return
@@ -174,7 +475,12 @@ func (h *StreamingHandler) UpdatePosition(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdateSessionPosition(c.Request.Context(), sessionID, req.Position); err != nil {
+	userID, _ := c.Get("user_id")
+	if err := h.service.UpdateSessionPosition(c.Request.Context(), sessionID, userID.(string), req.Position); err != nil {
+		if stderrors.Is(err, service.ErrInvalidSession) {
+			c.JSON(http.StatusForbidden, errors.NewForbiddenError("Playback session belongs to another user"))
+			return
+		}
 		h.logger.Error("Failed to update position", zap.Error(err))
 		c.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
 		return
@@ -187,7 +493,36 @@ func (h *StreamingHandler) UpdatePosition(c *gin.Context) {
 func (h *StreamingHandler) Heartbeat(c *gin.Context) {
 	sessionID := c.Param("sessionId")
 
-	if err := h.service.SendHeartbeat(c.Request.Context(), sessionID); err != nil {
+	// The body is optional; players report measured throughput and their state here
+	var req struct {
+		Bandwidth int64  `json:"bandwidth"` // bps
+		State     string `json:"state" binding:"omitempty,oneof=playing paused stalled ended"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+			return
+		}
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.service.SendHeartbeat(c.Request.Context(), sessionID, userID.(string), req.Bandwidth, req.State); err != nil {
+		if stderrors.Is(err, service.ErrInvalidSession) {
+			c.JSON(http.StatusForbidden, errors.NewForbiddenError("Playback session belongs to another user"))
+			return
+		}
+		if stderrors.Is(err, service.ErrStreamEvicted) {
+			c.JSON(http.StatusConflict, errors.NewConflictError("Playback was started on another device"))
+			return
+		}
+		if stderrors.Is(err, service.ErrSessionEnded) {
+			c.JSON(http.StatusGone, errors.NewAppError(errors.ErrorCodeNotFound, "Playback session has ended", http.StatusGone))
+			return
+		}
+		if stderrors.Is(err, service.ErrInvalidSessionTransition) {
+			c.JSON(http.StatusConflict, errors.NewConflictError(err.Error()))
+			return
+		}
 		h.logger.Error("Failed to send heartbeat", zap.Error(err))
 		c.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
 		return
@@ -199,8 +534,13 @@ func (h *StreamingHandler) Heartbeat(c *gin.Context) {
 // EndSession handles DELETE /api/v1/streaming/sessions/:sessionId
 func (h *StreamingHandler) EndSession(c *gin.Context) {
 	sessionID := c.Param("sessionId")
+	userID, _ := c.Get("user_id")
 
-	if err := h.service.EndSession(c.Request.Context(), sessionID); err != nil {
+	if err := h.service.EndSession(c.Request.Context(), sessionID, userID.(string)); err != nil {
+		if stderrors.Is(err, service.ErrInvalidSession) {
+			c.JSON(http.StatusForbidden, errors.NewForbiddenError("Playback session belongs to another user"))
+			return
+		}
 		h.logger.Error("Failed to end session", zap.Error(err))
 		c.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
 		return
@@ -208,3 +548,29 @@ func (h *StreamingHandler) EndSession(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Session ended"})
 }
+
+// deviceType reads the device class from X-Device-Type, falling back to the User-Agent
+func deviceType(c *gin.Context) string {
+	if deviceType := utils.NormalizeDeviceType(c.GetHeader("X-Device-Type")); deviceType != "" {
+		return deviceType
+	}
+	return utils.DeviceTypeFromUserAgent(c.Request.UserAgent())
+}
+
+// drmSecurity reads the DRM security level the player reports in X-DRM-Security-Level
+// (e.g. Widevine L1, PlayReady SL3000) as hardware or software; empty when unknown
+func drmSecurity(c *gin.Context) string {
+	return utils.NormalizeDRMSecurity(c.GetHeader("X-DRM-Security-Level"))
+}
+
+// hasRole reports whether the authenticated user has role
+func hasRole(c *gin.Context, role string) bool {
+	roles, _ := c.Get("roles")
+	list, _ := roles.([]string)
+	for _, r := range list {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
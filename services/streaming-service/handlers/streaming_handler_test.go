@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/streamverse/common-go/middleware"
+	"github.com/streamverse/streaming-service/internal/playback"
+	"github.com/streamverse/streaming-service/models"
+	"github.com/streamverse/streaming-service/service"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memorySessions stores playback sessions for the handlers under test; the
+// methods they do not use are left to the nil embedded store
+type memorySessions struct {
+	service.SessionStore
+	sessions map[string]*models.PlaybackSession
+}
+
+func (m *memorySessions) GetSession(ctx context.Context, sessionID string) (*models.PlaybackSession, error) {
+	if session, ok := m.sessions[sessionID]; ok {
+		copied := *session
+		return &copied, nil
+	}
+	return nil, errors.New("session not found")
+}
+
+func (m *memorySessions) UpdatePosition(ctx context.Context, sessionID string, position int64) (*models.PlaybackSession, error) {
+	session, ok := m.sessions[sessionID]
+	if !ok {
+		return nil, errors.New("session not found")
+	}
+	session.Position = position
+	copied := *session
+	return &copied, nil
+}
+
+func signUserToken(t *testing.T, userID string) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"exp":     jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestTokenBindingIgnoresForgedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := service.NewStreamingService(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, "",
+		service.TokenBindingConfig{BindIP: true, IPv4Prefix: 32, IPv6Prefix: 64}, "", "secret")
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":    "u1",
+		"content_id": "c1",
+		"ip":         "203.0.113.7",
+		"aud":        "cdn.streamverse.io",
+		"exp":        jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   string
+		want           int
+	}{
+		{"bound client", nil, "203.0.113.7:40000", "", http.StatusOK},
+		{"forged header", nil, "198.51.100.1:40000", "203.0.113.7", http.StatusForbidden},
+		{"forged header through an untrusted proxy", []string{"10.0.0.0/8"}, "198.51.100.1:40000", "203.0.113.7", http.StatusForbidden},
+		{"trusted proxy", []string{"10.0.0.0/8"}, "10.1.2.3:40000", "203.0.113.7", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			if err := middleware.TrustClientIPs(router, tt.trustedProxies, ""); err != nil {
+				t.Fatalf("TrustClientIPs: %v", err)
+			}
+			router.GET("/streaming/steering/:content_id/:token", NewStreamingHandler(svc, nil).GetSteeringManifest)
+
+			req := httptest.NewRequest(http.MethodGet, "/streaming/steering/c1/"+token, nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpdatePositionOnlyForSessionOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	session := &models.PlaybackSession{ID: primitive.NewObjectID(), UserID: "owner", ContentID: "c1", Position: 30}
+	store := &memorySessions{sessions: map[string]*models.PlaybackSession{session.ID.Hex(): session}}
+	svc := service.NewStreamingService(store, nil, nil, nil, nil, nil, nil, nil, nil, playback.NopPublisher{}, nil, nil, nil, "",
+		service.TokenBindingConfig{}, "", "secret")
+
+	router := gin.New()
+	router.PUT("/streaming/sessions/:sessionId/position", middleware.AuthMiddleware("secret"), NewStreamingHandler(svc, nil).UpdatePosition)
+
+	tests := []struct {
+		name     string
+		userID   string
+		want     int
+		position int64
+	}{
+		{"another user", "intruder", http.StatusForbidden, 30},
+		{"owner", "owner", http.StatusOK, 120},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/streaming/sessions/"+session.ID.Hex()+"/position", strings.NewReader(`{"position": 120}`))
+			req.Header.Set("Authorization", "Bearer "+signUserToken(t, tt.userID))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+			if session.Position != tt.position {
+				t.Fatalf("expected position %d, got %d", tt.position, session.Position)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package cdn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CloudFrontSigner issues CloudFront signed URLs with an RSA key pair registered
+// as a trusted key group, using a custom policy with a wildcard resource.
+type CloudFrontSigner struct {
+	keyPairID string
+	key       *rsa.PrivateKey
+	domain    string // e.g. https://d111111abcdef8.cloudfront.net; empty matches any host
+}
+
+// NewCloudFrontSigner creates a signer from a PEM encoded RSA private key (PKCS#1 or PKCS#8)
+func NewCloudFrontSigner(keyPairID string, privateKeyPEM []byte, domain string) (*CloudFrontSigner, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, errors.New("CloudFront private key is not PEM encoded")
+	}
+
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		key = parsed
+	} else {
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CloudFront private key: %w", err)
+		}
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("CloudFront private key must be an RSA key")
+		}
+		key = rsaKey
+	}
+
+	return &CloudFrontSigner{keyPairID: keyPairID, key: key, domain: strings.TrimRight(domain, "/")}, nil
+}
+
+// cloudFrontPolicy is the JSON policy document CloudFront verifies
+type cloudFrontPolicy struct {
+	Statement []cloudFrontStatement `json:"Statement"`
+}
+
+type cloudFrontStatement struct {
+	Resource  string `json:"Resource"`
+	Condition struct {
+		DateLessThan struct {
+			EpochTime int64 `json:"AWS:EpochTime"`
+		} `json:"DateLessThan"`
+	} `json:"Condition"`
+}
+
+// PrefixToken signs a custom policy whose resource covers everything under pathPrefix
+func (s *CloudFrontSigner) PrefixToken(pathPrefix string, expires time.Time) (string, error) {
+	policy, err := s.policy(s.prefixResource(pathPrefix), expires)
+	if err != nil {
+		return "", err
+	}
+	signature, err := s.sign(policy)
+	if err != nil {
+		return "", err
+	}
+
+	return url.Values{
+		"Policy":      {cloudFrontEncode(policy)},
+		"Signature":   {signature},
+		"Key-Pair-Id": {s.keyPairID},
+	}.Encode(), nil
+}
+
+func (s *CloudFrontSigner) prefixResource(pathPrefix string) string {
+	domain := s.domain
+	if domain == "" {
+		domain = "http*://*"
+	}
+	return domain + pathPrefix + "*"
+}
+
+func (s *CloudFrontSigner) policy(resource string, expires time.Time) ([]byte, error) {
+	statement := cloudFrontStatement{Resource: resource}
+	statement.Condition.DateLessThan.EpochTime = expires.Unix()
+	// CloudFront compares resources literally, so & < > must not be escaped
+	var policy bytes.Buffer
+	encoder := json.NewEncoder(&policy)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(cloudFrontPolicy{Statement: []cloudFrontStatement{statement}}); err != nil {
+		return nil, fmt.Errorf("failed to encode CloudFront policy: %w", err)
+	}
+	return bytes.TrimSuffix(policy.Bytes(), []byte("\n")), nil
+}
+
+// sign is RSA-SHA1 over the policy, which is what CloudFront verifies
+func (s *CloudFrontSigner) sign(policy []byte) (string, error) {
+	digest := sha1.Sum(policy)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA1, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign CloudFront policy: %w", err)
+	}
+	return cloudFrontEncode(signature), nil
+}
+
+// cloudFrontEncode is base64 with the characters invalid in query strings replaced
+func cloudFrontEncode(data []byte) string {
+	return strings.NewReplacer("+", "-", "=", "_", "/", "~").Replace(base64.StdEncoding.EncodeToString(data))
+}
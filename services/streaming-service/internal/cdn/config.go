@@ -0,0 +1,204 @@
+package cdn
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Signer types accepted in the signing config
+const (
+	SignerCloudFront = "cloudfront"
+	SignerAkamai     = "akamai"
+	SignerHMAC       = "hmac"
+	SignerNone       = "none"
+)
+
+// Config lists the CDNs content is delivered from and which tenants and content
+// items are pinned to one of them. Content assignments win over tenant
+// assignments; everything else is steered across the providers with a weight, or
+// served from the default when none has one.
+type Config struct {
+	Default   string                    `json:"default"`
+	Providers map[string]ProviderConfig `json:"providers"`
+	Tenants   map[string]string         `json:"tenants"` // tenant ID -> provider name
+	Content   map[string]string         `json:"content"` // content ID -> provider name
+	Steering  SteeringConfig            `json:"steering"`
+}
+
+// ProviderConfig describes one CDN: where content is served from, how URLs are
+// signed and how it is weighed against the other CDNs
+type ProviderConfig struct {
+	Type    string   `json:"type"`
+	BaseURL string   `json:"base_url"`
+	Weight  int      `json:"weight,omitempty"`  // share of sessions; 0 keeps the CDN out of steering
+	Regions []string `json:"regions,omitempty"` // ISO country codes served well; empty serves everywhere
+	Cost    float64  `json:"cost,omitempty"`    // relative delivery cost; cheaper CDNs are tried first on failover
+
+	// CloudFront
+	KeyPairID      string `json:"key_pair_id,omitempty"`
+	PrivateKey     string `json:"private_key,omitempty"` // PEM; PrivateKeyFile is read when empty
+	PrivateKeyFile string `json:"private_key_file,omitempty"`
+	Domain         string `json:"domain,omitempty"`
+
+	// Akamai EdgeAuth and HMAC; SecretEnv names an environment variable holding the secret
+	Secret       string `json:"secret,omitempty"`
+	SecretEnv    string `json:"secret_env,omitempty"`
+	TokenParam   string `json:"token_param,omitempty"`
+	ExpiresParam string `json:"expires_param,omitempty"`
+	ACLParam     string `json:"acl_param,omitempty"`
+}
+
+// Provider is a configured CDN
+type Provider struct {
+	Name    string
+	BaseURL string
+	Signer  URLSigner
+	Weight  int
+	Regions []string
+	Cost    float64
+}
+
+// Providers resolves the CDNs to use for a tenant and content item and tracks
+// their health from player QoE reports
+type Providers struct {
+	providers map[string]*Provider
+	steered   []*Provider // providers with a weight, by name
+	fallback  string
+	tenants   map[string]string
+	content   map[string]string
+	steering  SteeringConfig
+	health    *health
+}
+
+// LoadConfig reads a JSON signing config from path
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CDN config: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse CDN config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// NewProviders builds the signers of every configured provider and checks that all
+// assignments refer to one of them
+func NewProviders(cfg *Config) (*Providers, error) {
+	steering := cfg.Steering.withDefaults()
+	p := &Providers{
+		providers: make(map[string]*Provider, len(cfg.Providers)),
+		fallback:  cfg.Default,
+		tenants:   cfg.Tenants,
+		content:   cfg.Content,
+		steering:  steering,
+		health:    newHealth(steering.Window()),
+	}
+
+	for name, providerCfg := range cfg.Providers {
+		signer, err := newSigner(providerCfg)
+		if err != nil {
+			return nil, fmt.Errorf("CDN provider %s: %w", name, err)
+		}
+		regions := make([]string, 0, len(providerCfg.Regions))
+		for _, region := range providerCfg.Regions {
+			regions = append(regions, strings.ToUpper(strings.TrimSpace(region)))
+		}
+		provider := &Provider{
+			Name:    name,
+			BaseURL: strings.TrimRight(providerCfg.BaseURL, "/"),
+			Signer:  signer,
+			Weight:  providerCfg.Weight,
+			Regions: regions,
+			Cost:    providerCfg.Cost,
+		}
+		p.providers[name] = provider
+		if provider.Weight > 0 {
+			p.steered = append(p.steered, provider)
+		}
+	}
+	sort.Slice(p.steered, func(i, j int) bool { return p.steered[i].Name < p.steered[j].Name })
+
+	if _, ok := p.providers[cfg.Default]; !ok {
+		return nil, fmt.Errorf("default CDN provider %q is not configured", cfg.Default)
+	}
+	for _, assignments := range []map[string]string{cfg.Tenants, cfg.Content} {
+		for key, name := range assignments {
+			if _, ok := p.providers[name]; !ok {
+				return nil, fmt.Errorf("CDN provider %q assigned to %s is not configured", name, key)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// For returns the provider a content item or tenant is pinned to, or the default.
+// A nil Providers returns nil so callers can fall back to unsigned delivery.
+func (p *Providers) For(tenantID, contentID string) *Provider {
+	if p == nil {
+		return nil
+	}
+	if name, ok := p.content[contentID]; ok && contentID != "" {
+		return p.providers[name]
+	}
+	if name, ok := p.tenants[tenantID]; ok && tenantID != "" {
+		return p.providers[name]
+	}
+	return p.providers[p.fallback]
+}
+
+// Get returns a provider by name, or nil
+func (p *Providers) Get(name string) *Provider {
+	if p == nil {
+		return nil
+	}
+	return p.providers[name]
+}
+
+// candidates lists the providers a tenant's content may be served from
+func (p *Providers) candidates(tenantID, contentID string) []*Provider {
+	_, pinnedContent := p.content[contentID]
+	_, pinnedTenant := p.tenants[tenantID]
+	if pinnedContent || pinnedTenant || len(p.steered) == 0 {
+		return []*Provider{p.For(tenantID, contentID)}
+	}
+	return p.steered
+}
+
+func newSigner(cfg ProviderConfig) (URLSigner, error) {
+	switch cfg.Type {
+	case SignerCloudFront:
+		key := []byte(cfg.PrivateKey)
+		if len(key) == 0 && cfg.PrivateKeyFile != "" {
+			data, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CloudFront private key: %w", err)
+			}
+			key = data
+		}
+		return NewCloudFrontSigner(cfg.KeyPairID, key, cfg.Domain)
+	case SignerAkamai:
+		return NewAkamaiSigner(cfg.secret(), cfg.TokenParam)
+	case SignerHMAC:
+		secret := cfg.secret()
+		if secret == "" {
+			return nil, fmt.Errorf("HMAC signer requires a secret")
+		}
+		return NewHMACSigner([]byte(secret), cfg.TokenParam, cfg.ExpiresParam, cfg.ACLParam), nil
+	case SignerNone, "":
+		return NopSigner{}, nil
+	}
+	return nil, fmt.Errorf("unknown signer type %q", cfg.Type)
+}
+
+func (cfg ProviderConfig) secret() string {
+	if cfg.Secret == "" && cfg.SecretEnv != "" {
+		return os.Getenv(cfg.SecretEnv)
+	}
+	return cfg.Secret
+}
@@ -0,0 +1,21 @@
+package cdn
+
+import (
+	"time"
+)
+
+// URLSigner authorizes viewers to fetch content from a CDN
+type URLSigner interface {
+	// PrefixToken authorizes every URL whose path starts with pathPrefix until
+	// expires, so one token covers all segments of a rendition. It returns the
+	// encoded query string to append to those URLs.
+	PrefixToken(pathPrefix string, expires time.Time) (string, error)
+}
+
+// NopSigner leaves URLs unsigned, for origins without token authentication
+type NopSigner struct{}
+
+// PrefixToken returns no token
+func (NopSigner) PrefixToken(pathPrefix string, expires time.Time) (string, error) {
+	return "", nil
+}
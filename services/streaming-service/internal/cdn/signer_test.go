@@ -0,0 +1,165 @@
+package cdn
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/streamverse/streaming-service/utils"
+)
+
+func TestHMACSignerMatchesValidator(t *testing.T) {
+	signer := NewHMACSigner([]byte("secret"), "", "", "")
+	expires := time.Now().Add(time.Hour)
+
+	token, err := signer.PrefixToken("/videos/c1/720p/", expires)
+	if err != nil {
+		t.Fatalf("PrefixToken: %v", err)
+	}
+	q, _ := url.ParseQuery(token)
+	exp, _ := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if exp != expires.Unix() || !utils.ValidateSignedURL(q.Get("token"), q.Get("acl"), exp, "secret") {
+		t.Fatalf("prefix token %s does not validate", token)
+	}
+}
+
+func TestAkamaiSignerTokens(t *testing.T) {
+	signer, err := NewAkamaiSigner("0123456789abcdef", "")
+	if err != nil {
+		t.Fatalf("NewAkamaiSigner: %v", err)
+	}
+	expires := time.Unix(1700000000, 0)
+
+	token, err := signer.PrefixToken("/videos/c1/720p/", expires)
+	if err != nil {
+		t.Fatalf("PrefixToken: %v", err)
+	}
+	fields := "exp=1700000000~acl=/videos/c1/720p/*"
+	key, _ := hex.DecodeString("0123456789abcdef")
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(fields))
+	if want := "__token__=" + fields + "~hmac=" + hex.EncodeToString(mac.Sum(nil)); token != want {
+		t.Fatalf("expected token %s, got %s", want, token)
+	}
+
+	if _, err := NewAkamaiSigner("not-hex", ""); err == nil {
+		t.Fatalf("expected error for non-hex key")
+	}
+}
+
+func TestCloudFrontSignatures(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	signer, err := NewCloudFrontSigner("K2JCJMDEHXQW5F", keyPEM, "https://d111111abcdef8.cloudfront.net")
+	if err != nil {
+		t.Fatalf("NewCloudFrontSigner: %v", err)
+	}
+	expires := time.Unix(1700000000, 0)
+
+	verify := func(policy []byte, signature string) {
+		t.Helper()
+		decoded, err := cloudFrontDecode(signature)
+		if err != nil {
+			t.Fatalf("decode signature: %v", err)
+		}
+		digest := sha1.Sum(policy)
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA1, digest[:], decoded); err != nil {
+			t.Fatalf("signature does not verify: %v", err)
+		}
+	}
+
+	token, err := signer.PrefixToken("/videos/c1/720p/", expires)
+	if err != nil {
+		t.Fatalf("PrefixToken: %v", err)
+	}
+	q, _ := url.ParseQuery(token)
+	policy, err := cloudFrontDecode(q.Get("Policy"))
+	if err != nil {
+		t.Fatalf("decode policy: %v", err)
+	}
+	if !strings.Contains(string(policy), `"Resource":"https://d111111abcdef8.cloudfront.net/videos/c1/720p/*"`) {
+		t.Fatalf("unexpected custom policy %s", policy)
+	}
+	verify(policy, q.Get("Signature"))
+}
+
+func TestCloudFrontPolicyKeepsQueryStrings(t *testing.T) {
+	signer := &CloudFrontSigner{}
+
+	policy, err := signer.policy("https://d111111abcdef8.cloudfront.net/videos/c1/master.m3u8?a=1&b=2", time.Unix(1700000000, 0))
+	if err != nil {
+		t.Fatalf("policy: %v", err)
+	}
+	want := `{"Statement":[{"Resource":"https://d111111abcdef8.cloudfront.net/videos/c1/master.m3u8?a=1&b=2","Condition":{"DateLessThan":{"AWS:EpochTime":1700000000}}}]}`
+	if string(policy) != want {
+		t.Fatalf("expected policy %s, got %s", want, policy)
+	}
+}
+
+func TestProvidersSelection(t *testing.T) {
+	providers, err := NewProviders(&Config{
+		Default: "fastly",
+		Providers: map[string]ProviderConfig{
+			"fastly": {Type: SignerHMAC, BaseURL: "https://fastly.example.com/videos/", Secret: "s1"},
+			"akamai": {Type: SignerAkamai, BaseURL: "https://akamai.example.com/videos", Secret: "abcd"},
+			"origin": {Type: SignerNone, BaseURL: "https://origin.example.com/videos"},
+		},
+		Tenants: map[string]string{"t1": "akamai"},
+		Content: map[string]string{"c1": "origin"},
+	})
+	if err != nil {
+		t.Fatalf("NewProviders: %v", err)
+	}
+
+	tests := []struct {
+		tenantID, contentID, want string
+	}{
+		{"t1", "c1", "origin"},
+		{"t1", "c2", "akamai"},
+		{"t2", "c2", "fastly"},
+		{"", "", "fastly"},
+	}
+	for _, tt := range tests {
+		if got := providers.For(tt.tenantID, tt.contentID); got.Name != tt.want {
+			t.Fatalf("For(%q, %q) = %s, expected %s", tt.tenantID, tt.contentID, got.Name, tt.want)
+		}
+	}
+	if got := providers.For("", "").BaseURL; got != "https://fastly.example.com/videos" {
+		t.Fatalf("expected trailing slash trimmed, got %s", got)
+	}
+
+	var unconfigured *Providers
+	if unconfigured.For("t1", "c1") != nil {
+		t.Fatalf("expected nil provider without config")
+	}
+
+	if _, err := NewProviders(&Config{Default: "missing"}); err == nil {
+		t.Fatalf("expected error for unknown default provider")
+	}
+	if _, err := NewProviders(&Config{
+		Default:   "origin",
+		Providers: map[string]ProviderConfig{"origin": {Type: SignerNone}},
+		Tenants:   map[string]string{"t1": "missing"},
+	}); err == nil {
+		t.Fatalf("expected error for unknown tenant provider")
+	}
+}
+
+func cloudFrontDecode(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.NewReplacer("-", "+", "_", "=", "~", "/").Replace(s))
+}
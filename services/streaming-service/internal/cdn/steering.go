@@ -0,0 +1,314 @@
+package cdn
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Steering modes
+const (
+	// SteeringSingle writes the chosen CDN into the manifest; players stay on it
+	SteeringSingle = "single"
+	// SteeringContent lists every CDN as an HLS pathway or DASH service location and
+	// lets players move between them through the steering server mid-session
+	SteeringContent = "content_steering"
+)
+
+const healthBuckets = 10
+
+// SteeringConfig controls CDN selection and the QoE thresholds that demote a CDN
+type SteeringConfig struct {
+	Mode            string  `json:"mode"`
+	TTLSeconds      int     `json:"ttl_seconds"`       // how often players reload the steering manifest
+	WindowSeconds   int     `json:"window_seconds"`    // QoE history considered for health
+	MinSamples      int     `json:"min_samples"`       // events needed before a CDN can be demoted
+	MaxErrorRate    float64 `json:"max_error_rate"`    // share of error events
+	MaxRebufferRate float64 `json:"max_rebuffer_rate"` // share of buffering events
+}
+
+func (c SteeringConfig) withDefaults() SteeringConfig {
+	if c.Mode == "" {
+		c.Mode = SteeringSingle
+	}
+	if c.TTLSeconds <= 0 {
+		c.TTLSeconds = 300
+	}
+	if c.WindowSeconds <= 0 {
+		c.WindowSeconds = 300
+	}
+	if c.MinSamples <= 0 {
+		c.MinSamples = 50
+	}
+	if c.MaxErrorRate <= 0 {
+		c.MaxErrorRate = 0.05
+	}
+	if c.MaxRebufferRate <= 0 {
+		c.MaxRebufferRate = 0.1
+	}
+	return c
+}
+
+// Window is the QoE history considered for health
+func (c SteeringConfig) Window() time.Duration {
+	return time.Duration(c.WindowSeconds) * time.Second
+}
+
+// ContentSteering reports whether manifests should list every CDN for players to steer between
+func (p *Providers) ContentSteering() bool {
+	return p != nil && p.steering.Mode == SteeringContent
+}
+
+// SteeringTTL is how long players keep a steering manifest before reloading it
+func (p *Providers) SteeringTTL() time.Duration {
+	if p == nil {
+		return time.Duration(SteeringConfig{}.withDefaults().TTLSeconds) * time.Second
+	}
+	return time.Duration(p.steering.TTLSeconds) * time.Second
+}
+
+// Record attributes a player QoE event of a playback session to the CDN it was
+// reported for. Only CDNs the session's content may be served from count, and
+// each session counts at most once per window as a sample, an error and a
+// rebuffer, so no single player can demote a CDN.
+func (p *Providers) Record(tenantID, contentID, sessionKey, provider, event string, at time.Time) {
+	if p == nil || sessionKey == "" {
+		return
+	}
+	for _, candidate := range p.candidates(tenantID, contentID) {
+		if candidate.Name == provider {
+			p.health.record(provider, sessionKey, event, at)
+			return
+		}
+	}
+}
+
+// Healthy reports whether a CDN's recent error and rebuffer rates are within the thresholds
+func (p *Providers) Healthy(provider string) bool {
+	if p == nil {
+		return true
+	}
+	samples, errs, rebuffers := p.health.stats(provider, time.Now())
+	if samples < p.steering.MinSamples {
+		return true
+	}
+	return float64(errs)/float64(samples) <= p.steering.MaxErrorRate &&
+		float64(rebuffers)/float64(samples) <= p.steering.MaxRebufferRate
+}
+
+// Rank orders the CDNs a tenant's content may be served from for one viewer.
+// The first CDN is a weighted pick among healthy CDNs serving the viewer's
+// country, stable for a session key so a session does not hop between CDNs on
+// every manifest request. The rest follow as failover: healthy regional CDNs,
+// then healthy out-of-region CDNs, then demoted ones, cheapest first within each.
+// A nil Providers returns nil.
+func (p *Providers) Rank(tenantID, contentID, country, sessionKey string) []*Provider {
+	if p == nil {
+		return nil
+	}
+	candidates := p.candidates(tenantID, contentID)
+	if len(candidates) == 1 {
+		return candidates
+	}
+
+	country = strings.ToUpper(strings.TrimSpace(country))
+	tiers := make(map[string]int, len(candidates))
+	best := 2
+	for _, provider := range candidates {
+		tier := 0
+		if !p.Healthy(provider.Name) {
+			tier = 2
+		} else if !servesRegion(provider, country) {
+			tier = 1
+		}
+		tiers[provider.Name] = tier
+		if tier < best {
+			best = tier
+		}
+	}
+
+	var pool []*Provider
+	for _, provider := range candidates {
+		if tiers[provider.Name] == best {
+			pool = append(pool, provider)
+		}
+	}
+	primary := weightedPick(pool, sessionKey)
+
+	ranked := []*Provider{primary}
+	for _, provider := range candidates {
+		if provider != primary {
+			ranked = append(ranked, provider)
+		}
+	}
+	rest := ranked[1:]
+	sort.SliceStable(rest, func(i, j int) bool {
+		if tiers[rest[i].Name] != tiers[rest[j].Name] {
+			return tiers[rest[i].Name] < tiers[rest[j].Name]
+		}
+		return rest[i].Cost < rest[j].Cost
+	})
+	return ranked
+}
+
+func servesRegion(provider *Provider, country string) bool {
+	if len(provider.Regions) == 0 || country == "" {
+		return true
+	}
+	for _, region := range provider.Regions {
+		if region == country {
+			return true
+		}
+	}
+	return false
+}
+
+// weightedPick chooses a provider in proportion to its weight, deterministically
+// for a non-empty key
+func weightedPick(pool []*Provider, key string) *Provider {
+	total := 0
+	for _, provider := range pool {
+		total += provider.Weight
+	}
+	if total <= 0 {
+		return pool[0]
+	}
+
+	var n int
+	if key != "" {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		n = int(h.Sum32() % uint32(total))
+	} else {
+		n = rand.Intn(total)
+	}
+	for _, provider := range pool {
+		if n < provider.Weight {
+			return provider
+		}
+		n -= provider.Weight
+	}
+	return pool[len(pool)-1]
+}
+
+// health counts QoE events per CDN over a sliding window of buckets. Counts are
+// per process: each replica judges CDNs from the players it serves.
+type health struct {
+	mu        sync.Mutex
+	bucketLen time.Duration
+	providers map[string]*[healthBuckets]healthBucket
+	reports   map[healthReporter]healthReport
+}
+
+// healthReporter is a session reporting on a CDN
+type healthReporter struct {
+	provider string
+	session  string
+}
+
+// healthReport is what a session has counted towards a CDN's health in the
+// window starting at bucket start
+type healthReport struct {
+	start int64
+	kinds uint8
+}
+
+// Kinds of counts a session contributes to a CDN's health
+const (
+	reportSample uint8 = 1 << iota
+	reportError
+	reportRebuffer
+)
+
+type healthBucket struct {
+	start     int64 // bucket index since the epoch
+	samples   int
+	errors    int
+	rebuffers int
+}
+
+func newHealth(window time.Duration) *health {
+	bucketLen := window / healthBuckets
+	if bucketLen <= 0 {
+		bucketLen = time.Second
+	}
+	return &health{
+		bucketLen: bucketLen,
+		providers: make(map[string]*[healthBuckets]healthBucket),
+		reports:   make(map[healthReporter]healthReport),
+	}
+}
+
+func (h *health) record(provider, session, event string, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buckets, ok := h.providers[provider]
+	if !ok {
+		buckets = &[healthBuckets]healthBucket{}
+		h.providers[provider] = buckets
+	}
+	index := at.UnixNano() / int64(h.bucketLen)
+	bucket := &buckets[index%healthBuckets]
+	if bucket.start != index {
+		*bucket = healthBucket{start: index}
+		h.expireReports(index)
+	}
+
+	reporter := healthReporter{provider: provider, session: session}
+	report, ok := h.reports[reporter]
+	if !ok || index-report.start >= healthBuckets {
+		report = healthReport{start: index}
+	}
+	kind := reportSample
+	switch event {
+	case "error":
+		kind |= reportError
+	case "buffering":
+		kind |= reportRebuffer
+	}
+	counted := kind &^ report.kinds
+	report.kinds |= kind
+	h.reports[reporter] = report
+
+	if counted&reportSample != 0 {
+		bucket.samples++
+	}
+	if counted&reportError != 0 {
+		bucket.errors++
+	}
+	if counted&reportRebuffer != 0 {
+		bucket.rebuffers++
+	}
+}
+
+// expireReports forgets sessions whose window ended before bucket index
+func (h *health) expireReports(index int64) {
+	for reporter, report := range h.reports {
+		if index-report.start >= healthBuckets {
+			delete(h.reports, reporter)
+		}
+	}
+}
+
+func (h *health) stats(provider string, now time.Time) (samples, errs, rebuffers int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buckets, ok := h.providers[provider]
+	if !ok {
+		return 0, 0, 0
+	}
+	current := now.UnixNano() / int64(h.bucketLen)
+	for _, bucket := range buckets {
+		if current-bucket.start < healthBuckets {
+			samples += bucket.samples
+			errs += bucket.errors
+			rebuffers += bucket.rebuffers
+		}
+	}
+	return samples, errs, rebuffers
+}
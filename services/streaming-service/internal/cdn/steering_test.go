@@ -0,0 +1,132 @@
+package cdn
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func testSteeringProviders(t *testing.T) *Providers {
+	t.Helper()
+	providers, err := NewProviders(&Config{
+		Default: "origin",
+		Providers: map[string]ProviderConfig{
+			"origin":     {Type: SignerNone, BaseURL: "https://origin.example.com/videos"},
+			"akamai":     {Type: SignerNone, BaseURL: "https://akamai.example.com/videos", Weight: 3, Cost: 2},
+			"cloudfront": {Type: SignerNone, BaseURL: "https://cloudfront.example.com/videos", Weight: 1, Cost: 1, Regions: []string{"us", "CA"}},
+			"fastly":     {Type: SignerNone, BaseURL: "https://fastly.example.com/videos", Weight: 1, Cost: 3, Regions: []string{"DE"}},
+		},
+		Tenants:  map[string]string{"pinned": "origin"},
+		Steering: SteeringConfig{Mode: SteeringContent, MinSamples: 10},
+	})
+	if err != nil {
+		t.Fatalf("NewProviders: %v", err)
+	}
+	return providers
+}
+
+func rankNames(ranked []*Provider) []string {
+	names := make([]string, len(ranked))
+	for i, provider := range ranked {
+		names[i] = provider.Name
+	}
+	return names
+}
+
+func TestRankWeightsAndStickiness(t *testing.T) {
+	providers := testSteeringProviders(t)
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("session-%d", i)
+		first := providers.Rank("", "c1", "", key)[0].Name
+		if again := providers.Rank("", "c1", "", key)[0].Name; again != first {
+			t.Fatalf("session %s moved from %s to %s", key, first, again)
+		}
+		counts[first]++
+	}
+	if counts["origin"] != 0 {
+		t.Fatalf("unweighted provider was picked %d times", counts["origin"])
+	}
+	if counts["akamai"] < 450 || counts["akamai"] > 750 {
+		t.Fatalf("expected akamai on about 60%% of sessions, got %v", counts)
+	}
+
+	if got := rankNames(providers.Rank("pinned", "c1", "US", "s")); len(got) != 1 || got[0] != "origin" {
+		t.Fatalf("expected pinned tenant on origin only, got %v", got)
+	}
+}
+
+func TestRankPrefersRegionalThenCheapest(t *testing.T) {
+	providers := testSteeringProviders(t)
+
+	// akamai serves everywhere and cloudfront serves US; fastly only serves DE
+	for i := 0; i < 50; i++ {
+		ranked := rankNames(providers.Rank("", "c1", "US", fmt.Sprintf("s%d", i)))
+		if ranked[len(ranked)-1] != "fastly" {
+			t.Fatalf("expected out-of-region fastly last for US viewers, got %v", ranked)
+		}
+	}
+
+	ranked := rankNames(providers.Rank("", "c1", "DE", "s1"))
+	if ranked[len(ranked)-1] != "cloudfront" {
+		t.Fatalf("expected out-of-region cloudfront last for DE viewers, got %v", ranked)
+	}
+}
+
+func TestRankDemotesUnhealthyProviders(t *testing.T) {
+	providers := testSteeringProviders(t)
+	now := time.Now()
+
+	for i := 0; i < 20; i++ {
+		providers.Record("", "c1", fmt.Sprintf("s%d", i), "akamai", "play", now)
+	}
+	if !providers.Healthy("akamai") {
+		t.Fatalf("expected akamai healthy")
+	}
+	// A session counts once per window, however often it reports
+	for i := 0; i < 20; i++ {
+		providers.Record("", "c1", "s0", "akamai", "error", now)
+	}
+	if !providers.Healthy("akamai") {
+		t.Fatalf("expected one session's errors not to demote akamai")
+	}
+	for i := 1; i < 5; i++ {
+		providers.Record("", "c1", fmt.Sprintf("s%d", i), "akamai", "error", now)
+	}
+	if providers.Healthy("akamai") {
+		t.Fatalf("expected akamai demoted at a 25%% error rate")
+	}
+
+	for i := 0; i < 100; i++ {
+		ranked := rankNames(providers.Rank("", "c1", "", fmt.Sprintf("s%d", i)))
+		if ranked[len(ranked)-1] != "akamai" {
+			t.Fatalf("expected demoted akamai last, got %v", ranked)
+		}
+	}
+
+	// Events age out of the window
+	providers.health = newHealth(time.Minute)
+	providers.health.record("fastly", "s1", "buffering", now.Add(-2*time.Minute))
+	if samples, _, _ := providers.health.stats("fastly", now); samples != 0 {
+		t.Fatalf("expected expired events ignored, got %d samples", samples)
+	}
+	providers.health.record("fastly", "s1", "buffering", now)
+	if samples, _, rebuffers := providers.health.stats("fastly", now); samples != 1 || rebuffers != 1 {
+		t.Fatalf("expected a session to count again in a new window, got %d samples and %d rebuffers", samples, rebuffers)
+	}
+
+	providers.Record("pinned", "c1", "s2", "fastly", "error", now)
+	if samples, _, _ := providers.health.stats("fastly", now); samples != 1 {
+		t.Fatalf("expected events for CDNs the content is not served from ignored, got %d samples", samples)
+	}
+
+	providers.Record("", "c1", "s1", "unknown", "error", now)
+	if _, ok := providers.health.providers["unknown"]; ok {
+		t.Fatalf("expected events for unknown providers ignored")
+	}
+	providers.Record("", "c1", "", "akamai", "error", now)
+	if _, ok := providers.health.providers["akamai"]; ok {
+		t.Fatalf("expected events without a session ignored")
+	}
+}
@@ -0,0 +1,70 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// EntitlementsClient fetches a user's subscription and purchase records from the
+// payment service HTTP API, in the form policy-service evaluates.
+type EntitlementsClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewEntitlementsClient creates a payment-service entitlements client.
+func NewEntitlementsClient(baseURL string) *EntitlementsClient {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	return &EntitlementsClient{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+// GetUserEntitlements retrieves entitlement records for a user, on behalf of the
+// caller identified by authHeader.
+func (c *EntitlementsClient) GetUserEntitlements(ctx context.Context, userID, authHeader string) ([]map[string]interface{}, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("user id is required")
+	}
+	if authHeader == "" {
+		return nil, fmt.Errorf("authorization header is required")
+	}
+
+	endpoint := fmt.Sprintf("%s/payments/entitlements/%s", c.baseURL, url.PathEscape(userID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authHeader)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("payment service returned status %d", res.StatusCode)
+	}
+
+	var payload struct {
+		Entitlements []map[string]interface{} `json:"entitlements"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	return payload.Entitlements, nil
+}
@@ -0,0 +1,110 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const contractVersionV1 = "v1"
+
+// Input is the data policy-service evaluates an entitlement from
+type Input struct {
+	ContentID       string
+	UserID          string
+	CountryCode     string
+	ContentCategory string
+	IsDRMProtected  bool
+	Entitlements    []map[string]interface{}
+}
+
+// Decision is policy-service's entitlement decision
+type Decision struct {
+	HasAccess  bool       `json:"has_access"`
+	Reason     string     `json:"reason"` // purchased, subscription, free, subscription_required, geo_blocked
+	ExpiresAt  *time.Time `json:"expires_at"`
+	DRMLevel   string     `json:"drm_level"`
+	LicenseURL string     `json:"license_url"`
+}
+
+// Client calls the policy-service entitlement evaluation API
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a policy-service client.
+func NewClient(baseURL string) *Client {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8090"
+	}
+
+	return &Client{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+// EvaluateEntitlement asks policy-service whether the user may play the content,
+// on behalf of the caller identified by authHeader.
+func (c *Client) EvaluateEntitlement(ctx context.Context, input Input, authHeader string) (*Decision, error) {
+	if strings.TrimSpace(input.ContentID) == "" {
+		return nil, fmt.Errorf("content id is required")
+	}
+	if strings.TrimSpace(input.UserID) == "" {
+		return nil, fmt.Errorf("user id is required")
+	}
+	if strings.TrimSpace(authHeader) == "" {
+		return nil, fmt.Errorf("authorization header is required")
+	}
+
+	bodyBytes, err := json.Marshal(map[string]interface{}{
+		"contract_version": contractVersionV1,
+		"content_id":       input.ContentID,
+		"user_id":          input.UserID,
+		"country_code":     input.CountryCode,
+		"content_category": input.ContentCategory,
+		"is_drm_protected": input.IsDRMProtected,
+		"entitlements":     input.Entitlements,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/policy/v1/entitlements/evaluate", bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authHeader)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("policy service returned status %d", res.StatusCode)
+	}
+
+	var payload struct {
+		ContractVersion string   `json:"contract_version"`
+		Decision        Decision `json:"decision"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+	if payload.ContractVersion != contractVersionV1 {
+		return nil, fmt.Errorf("unsupported policy contract version: %s", payload.ContractVersion)
+	}
+
+	return &payload.Decision, nil
+}
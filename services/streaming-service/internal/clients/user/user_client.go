@@ -0,0 +1,69 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Preferences are the playback language and accessibility preferences of a user
+type Preferences struct {
+	Language         string `json:"language"`
+	SubtitleLanguage string `json:"subtitleLanguage"`
+	AudioLanguage    string `json:"audioLanguage"`
+	ClosedCaptions   bool   `json:"closedCaptions"`
+	AudioDescription bool   `json:"audioDescription"`
+}
+
+// Client calls the user-service HTTP API on behalf of a user
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a user-service client.
+func NewClient(baseURL string) *Client {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	return &Client{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+// GetPreferences returns the preferences of the caller identified by authHeader.
+func (c *Client) GetPreferences(ctx context.Context, authHeader string) (*Preferences, error) {
+	if strings.TrimSpace(authHeader) == "" {
+		return nil, fmt.Errorf("authorization header is required")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v1/users/me/preferences", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authHeader)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("user service returned status %d", res.StatusCode)
+	}
+
+	var prefs Preferences
+	if err := json.NewDecoder(res.Body).Decode(&prefs); err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
@@ -0,0 +1,138 @@
+package drm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+// Largest pixel count of each video track type: 1024x576 for SD, 1280x720 for
+// HD and 1920x1080 for FHD
+const (
+	sdMaxPixels  = 1024 * 576
+	hdMaxPixels  = 1280 * 720
+	fhdMaxPixels = 1920 * 1080
+)
+
+// CPIX is a DASH-IF Content Protection Information Exchange (CPIX 2.3) document
+// telling a packager which key encrypts which track and how to signal it
+type CPIX struct {
+	XMLName         xml.Name         `xml:"cpix:CPIX"`
+	XMLNSCPIX       string           `xml:"xmlns:cpix,attr"`
+	XMLNSPSKC       string           `xml:"xmlns:pskc,attr"`
+	ContentID       string           `xml:"contentId,attr"`
+	Version         string           `xml:"version,attr"`
+	ContentKeys     []CPIXContentKey `xml:"cpix:ContentKeyList>cpix:ContentKey"`
+	DRMSystems      []CPIXDRMSystem  `xml:"cpix:DRMSystemList>cpix:DRMSystem"`
+	ContentKeyUsage []CPIXUsageRule  `xml:"cpix:ContentKeyUsageRuleList>cpix:ContentKeyUsageRule"`
+}
+
+// CPIXContentKey is a key in clear, wrapped in a PSKC secret
+type CPIXContentKey struct {
+	KID                    string `xml:"kid,attr"`
+	CommonEncryptionScheme string `xml:"commonEncryptionScheme,attr"`
+	PlainValue             string `xml:"cpix:Data>pskc:Secret>pskc:PlainValue"`
+}
+
+// CPIXDRMSystem is the signalling of one key for one DRM system
+type CPIXDRMSystem struct {
+	KID        string `xml:"kid,attr"`
+	SystemID   string `xml:"systemId,attr"`
+	PSSH       string `xml:"cpix:PSSH,omitempty"`
+	URIExtXKey string `xml:"cpix:URIExtXKey,omitempty"` // base64 EXT-X-KEY URI, for FairPlay
+}
+
+// CPIXUsageRule maps a key to the tracks it encrypts
+type CPIXUsageRule struct {
+	KID               string           `xml:"kid,attr"`
+	IntendedTrackType string           `xml:"intendedTrackType,attr"`
+	VideoFilter       *CPIXVideoFilter `xml:"cpix:VideoFilter,omitempty"`
+	AudioFilter       *struct{}        `xml:"cpix:AudioFilter,omitempty"`
+}
+
+// CPIXVideoFilter selects video tracks by pixel count
+type CPIXVideoFilter struct {
+	MinPixels int `xml:"minPixels,attr,omitempty"`
+	MaxPixels int `xml:"maxPixels,attr,omitempty"`
+}
+
+// CPIX exports the keys of a title, generating them if needed, as a CPIX
+// document. Keys are in clear, so the document must only reach packagers.
+func (m *Manager) CPIX(ctx context.Context, contentID string) ([]byte, error) {
+	keys, err := m.Keys(ctx, contentID)
+	if err != nil {
+		return nil, err
+	}
+	document, err := BuildCPIX(contentID, m.scheme, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal CPIX: %w", err)
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
+
+// BuildCPIX assembles the CPIX document for a title's keys
+func BuildCPIX(contentID, scheme string, keys []Key) (*CPIX, error) {
+	document := &CPIX{
+		XMLNSCPIX: "urn:dashif:org:cpix",
+		XMLNSPSKC: "urn:ietf:params:xml:ns:keyprov:pskc",
+		ContentID: contentID,
+		Version:   "2.3",
+	}
+
+	for _, key := range keys {
+		kid, err := ParseKeyID(key.KeyID)
+		if err != nil {
+			return nil, err
+		}
+
+		document.ContentKeys = append(document.ContentKeys, CPIXContentKey{
+			KID:                    key.KeyID,
+			CommonEncryptionScheme: scheme,
+			PlainValue:             base64.StdEncoding.EncodeToString(key.Value),
+		})
+
+		document.DRMSystems = append(document.DRMSystems,
+			CPIXDRMSystem{KID: key.KeyID, SystemID: WidevineSystemID, PSSH: base64.StdEncoding.EncodeToString(WidevinePSSH(contentID, scheme, kid))},
+			CPIXDRMSystem{KID: key.KeyID, SystemID: PlayReadySystemID, PSSH: base64.StdEncoding.EncodeToString(PlayReadyPSSH(scheme, kid))},
+		)
+		// FairPlay only works with cbcs
+		if scheme == SchemeCBCS {
+			document.DRMSystems = append(document.DRMSystems, CPIXDRMSystem{
+				KID:        key.KeyID,
+				SystemID:   FairPlaySystemID,
+				URIExtXKey: base64.StdEncoding.EncodeToString([]byte(FairPlayKeyURI(key.KeyID))),
+			})
+		}
+
+		rule := CPIXUsageRule{KID: key.KeyID, IntendedTrackType: key.TrackType}
+		switch key.TrackType {
+		case models.TrackTypeSD:
+			rule.VideoFilter = &CPIXVideoFilter{MaxPixels: sdMaxPixels}
+		case models.TrackTypeHD:
+			rule.VideoFilter = &CPIXVideoFilter{MinPixels: sdMaxPixels + 1, MaxPixels: hdMaxPixels}
+		case models.TrackTypeFHD:
+			rule.VideoFilter = &CPIXVideoFilter{MinPixels: hdMaxPixels + 1, MaxPixels: fhdMaxPixels}
+		case models.TrackTypeUHD:
+			rule.VideoFilter = &CPIXVideoFilter{MinPixels: fhdMaxPixels + 1}
+		case models.TrackTypeAudio:
+			rule.AudioFilter = &struct{}{}
+		}
+		document.ContentKeyUsage = append(document.ContentKeyUsage, rule)
+	}
+
+	return document, nil
+}
+
+// FairPlayKeyURI is the skd:// key URI FairPlay players send to the license server
+func FairPlayKeyURI(keyID string) string {
+	return "skd://" + strings.ReplaceAll(keyID, "-", "")
+}
@@ -0,0 +1,235 @@
+package drm
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+// Key is a content key in clear. Keys are only decrypted while building
+// manifests and CPIX documents and are never stored this way.
+type Key struct {
+	TrackType string
+	KeyID     string // UUID form, lower case
+	Value     []byte // 16-byte AES key
+}
+
+// KeyStore persists encrypted content keys
+type KeyStore interface {
+	GetContentKeys(ctx context.Context, contentID string) ([]models.ContentKey, error)
+	// InsertContentKeys stores keys, keeping the existing key for any content and
+	// track type that already has one
+	InsertContentKeys(ctx context.Context, keys []models.ContentKey) error
+}
+
+// Manager generates, stores and decrypts the content keys of each title: one key
+// per track type, created on first use and stable afterwards
+type Manager struct {
+	store  KeyStore
+	aead   cipher.AEAD
+	scheme string
+}
+
+// NewManager creates a manager storing keys in store, encrypted with kek, a
+// 32-byte key encryption key
+func NewManager(store KeyStore, kek []byte) (*Manager, error) {
+	if len(kek) != 32 {
+		return nil, fmt.Errorf("key encryption key must be 32 bytes, got %d", len(kek))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Manager{store: store, aead: aead, scheme: SchemeCBCS}, nil
+}
+
+// NewLocalManager creates a manager that keeps keys in memory under a random key
+// encryption key, for development without a database or KMS. Keys do not survive
+// a restart, so content must be repackaged after one.
+func NewLocalManager() *Manager {
+	kek := make([]byte, 32)
+	if _, err := rand.Read(kek); err != nil {
+		panic(fmt.Sprintf("failed to generate key encryption key: %v", err))
+	}
+	manager, err := NewManager(NewMemoryStore(), kek)
+	if err != nil {
+		panic(err)
+	}
+	return manager
+}
+
+// DecodeKEK decodes a base64 key encryption key, e.g. from DRM_KEY_ENCRYPTION_KEY
+func DecodeKEK(encoded string) ([]byte, error) {
+	kek, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key encryption key: %w", err)
+	}
+	return kek, nil
+}
+
+// Scheme is the common encryption scheme content is packaged with
+func (m *Manager) Scheme() string {
+	return m.scheme
+}
+
+// Keys returns the content keys of a title, one per track type, generating any
+// that do not exist yet. Concurrent callers always end up with the same keys.
+func (m *Manager) Keys(ctx context.Context, contentID string) ([]Key, error) {
+	stored, err := m.store.GetContentKeys(ctx, contentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load content keys: %w", err)
+	}
+
+	if missing := missingTrackTypes(stored); len(missing) > 0 {
+		var created []models.ContentKey
+		for _, trackType := range missing {
+			key, err := m.newKey(contentID, trackType)
+			if err != nil {
+				return nil, err
+			}
+			created = append(created, key)
+		}
+		if err := m.store.InsertContentKeys(ctx, created); err != nil {
+			return nil, fmt.Errorf("failed to store content keys: %w", err)
+		}
+		// Reload so a key another replica stored first wins over ours
+		if stored, err = m.store.GetContentKeys(ctx, contentID); err != nil {
+			return nil, fmt.Errorf("failed to load content keys: %w", err)
+		}
+	}
+
+	keys := make([]Key, 0, len(stored))
+	for _, trackType := range models.TrackTypes {
+		for _, record := range stored {
+			if record.TrackType != trackType {
+				continue
+			}
+			value, err := m.open(&record)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt %s key of %s: %w", trackType, contentID, err)
+			}
+			keys = append(keys, Key{TrackType: trackType, KeyID: record.KeyID, Value: value})
+			break
+		}
+	}
+	return keys, nil
+}
+
+// newKey generates a random key and key ID and seals the key for storage
+func (m *Manager) newKey(contentID, trackType string) (models.ContentKey, error) {
+	random := make([]byte, 32+m.aead.NonceSize())
+	if _, err := rand.Read(random); err != nil {
+		return models.ContentKey{}, fmt.Errorf("failed to generate content key: %w", err)
+	}
+	kid, value, nonce := random[:16], random[16:32], random[32:]
+
+	// Version 4 UUID, as packagers and license servers expect
+	kid[6] = kid[6]&0x0f | 0x40
+	kid[8] = kid[8]&0x3f | 0x80
+
+	record := models.ContentKey{
+		ContentID: contentID,
+		TrackType: trackType,
+		KeyID:     FormatKeyID(kid),
+		CreatedAt: time.Now(),
+	}
+	record.EncryptedKey = m.aead.Seal(nonce, nonce, value, keyAAD(&record))
+	return record, nil
+}
+
+// open decrypts a stored key, sealed as nonce followed by ciphertext
+func (m *Manager) open(record *models.ContentKey) ([]byte, error) {
+	nonceSize := m.aead.NonceSize()
+	if len(record.EncryptedKey) < nonceSize {
+		return nil, errors.New("sealed key is truncated")
+	}
+	return m.aead.Open(nil, record.EncryptedKey[:nonceSize], record.EncryptedKey[nonceSize:], keyAAD(record))
+}
+
+// keyAAD binds a sealed key to its content, track type and key ID, so a stored
+// key cannot be swapped onto another title
+func keyAAD(record *models.ContentKey) []byte {
+	return []byte(record.ContentID + "/" + record.TrackType + "/" + record.KeyID)
+}
+
+func missingTrackTypes(stored []models.ContentKey) []string {
+	have := make(map[string]bool, len(stored))
+	for _, record := range stored {
+		have[record.TrackType] = true
+	}
+	var missing []string
+	for _, trackType := range models.TrackTypes {
+		if !have[trackType] {
+			missing = append(missing, trackType)
+		}
+	}
+	return missing
+}
+
+// FormatKeyID renders a 16-byte key ID in UUID form
+func FormatKeyID(kid []byte) string {
+	h := hex.EncodeToString(kid)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// ParseKeyID parses a key ID in UUID or plain hex form
+func ParseKeyID(kid string) ([]byte, error) {
+	raw, err := hex.DecodeString(strings.ReplaceAll(kid, "-", ""))
+	if err != nil || len(raw) != 16 {
+		return nil, errors.New("invalid key ID: " + kid)
+	}
+	return raw, nil
+}
+
+// MemoryStore keeps encrypted content keys in memory
+type MemoryStore struct {
+	mu   sync.Mutex
+	keys map[string][]models.ContentKey
+}
+
+// NewMemoryStore creates an empty in-memory key store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string][]models.ContentKey)}
+}
+
+// GetContentKeys returns the stored keys of a title
+func (s *MemoryStore) GetContentKeys(ctx context.Context, contentID string) ([]models.ContentKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.ContentKey(nil), s.keys[contentID]...), nil
+}
+
+// InsertContentKeys stores keys for track types that have none yet
+func (s *MemoryStore) InsertContentKeys(ctx context.Context, keys []models.ContentKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		if !hasTrackType(s.keys[key.ContentID], key.TrackType) {
+			s.keys[key.ContentID] = append(s.keys[key.ContentID], key)
+		}
+	}
+	return nil
+}
+
+func hasTrackType(keys []models.ContentKey, trackType string) bool {
+	for _, key := range keys {
+		if key.TrackType == trackType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package drm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"testing"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+func TestKeysAreCreatedOncePerTrackType(t *testing.T) {
+	manager := NewLocalManager()
+	ctx := context.Background()
+
+	keys, err := manager.Keys(ctx, "content-1")
+	if err != nil {
+		t.Fatalf("Keys: %v", err)
+	}
+	if len(keys) != len(models.TrackTypes) {
+		t.Fatalf("expected one key per track type, got %d", len(keys))
+	}
+	seen := map[string]bool{}
+	for i, key := range keys {
+		if key.TrackType != models.TrackTypes[i] || len(key.Value) != 16 {
+			t.Fatalf("unexpected key %d: %+v", i, key)
+		}
+		if seen[key.KeyID] {
+			t.Fatalf("key ID %s reused across track types", key.KeyID)
+		}
+		seen[key.KeyID] = true
+	}
+
+	again, err := manager.Keys(ctx, "content-1")
+	if err != nil {
+		t.Fatalf("Keys: %v", err)
+	}
+	for i := range keys {
+		if again[i].KeyID != keys[i].KeyID || !bytes.Equal(again[i].Value, keys[i].Value) {
+			t.Fatalf("expected stable keys, %s changed", keys[i].TrackType)
+		}
+	}
+
+	other, _ := manager.Keys(ctx, "content-2")
+	if other[0].KeyID == keys[0].KeyID {
+		t.Fatalf("expected separate keys per content")
+	}
+}
+
+func TestKeysAreEncryptedAtRest(t *testing.T) {
+	store := NewMemoryStore()
+	manager, err := NewManager(store, bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	ctx := context.Background()
+
+	keys, _ := manager.Keys(ctx, "content-1")
+	stored, _ := store.GetContentKeys(ctx, "content-1")
+	for _, record := range stored {
+		for _, key := range keys {
+			if bytes.Contains(record.EncryptedKey, key.Value) {
+				t.Fatalf("stored %s key is in clear", record.TrackType)
+			}
+		}
+	}
+
+	// A sealed key moved onto another title must not decrypt
+	moved := stored[0]
+	moved.ContentID = "content-2"
+	store.InsertContentKeys(ctx, []models.ContentKey{moved})
+	if _, err := manager.Keys(ctx, "content-2"); err == nil {
+		t.Fatalf("expected a key bound to another title to be rejected")
+	}
+
+	if _, err := NewManager(store, []byte("short")); err == nil {
+		t.Fatalf("expected short key encryption key to be rejected")
+	}
+}
+
+func TestPSSHBoxes(t *testing.T) {
+	kid, _ := ParseKeyID("9eb4050d-e44b-4802-932e-27d75083e266")
+
+	for name, box := range map[string][]byte{
+		"widevine":  WidevinePSSH("content-1", SchemeCBCS, kid),
+		"playready": PlayReadyPSSH(SchemeCBCS, kid),
+	} {
+		if int(binary.BigEndian.Uint32(box)) != len(box) || string(box[4:8]) != "pssh" {
+			t.Fatalf("%s: malformed box header", name)
+		}
+		if int(binary.BigEndian.Uint32(box[28:32])) != len(box)-32 {
+			t.Fatalf("%s: data size does not match the box", name)
+		}
+	}
+
+	widevine := WidevinePSSH("content-1", SchemeCBCS, kid)
+	if !bytes.Contains(widevine, kid) || !bytes.Contains(widevine, []byte("content-1")) {
+		t.Fatalf("expected Widevine data to carry the key and content IDs")
+	}
+	if got := FormatKeyID(playReadyGUID(kid)); got != "0d05b49e-4be4-0248-932e-27d75083e266" {
+		t.Fatalf("unexpected PlayReady GUID order %s", got)
+	}
+}
+
+func TestCPIXDocument(t *testing.T) {
+	manager := NewLocalManager()
+	ctx := context.Background()
+
+	out, err := manager.CPIX(ctx, "content-1")
+	if err != nil {
+		t.Fatalf("CPIX: %v", err)
+	}
+
+	var document struct {
+		ContentID string `xml:"contentId,attr"`
+		Keys      []struct {
+			KID   string `xml:"kid,attr"`
+			Value string `xml:"Data>Secret>PlainValue"`
+		} `xml:"ContentKeyList>ContentKey"`
+		Systems []struct {
+			SystemID string `xml:"systemId,attr"`
+		} `xml:"DRMSystemList>DRMSystem"`
+		Rules []struct {
+			TrackType string `xml:"intendedTrackType,attr"`
+		} `xml:"ContentKeyUsageRuleList>ContentKeyUsageRule"`
+	}
+	if err := xml.Unmarshal(out, &document); err != nil {
+		t.Fatalf("CPIX is not valid XML: %v", err)
+	}
+
+	keys, _ := manager.Keys(ctx, "content-1")
+	if document.ContentID != "content-1" || len(document.Keys) != len(keys) || len(document.Rules) != len(keys) {
+		t.Fatalf("unexpected CPIX document %+v", document)
+	}
+	if document.Keys[0].KID != keys[0].KeyID || document.Keys[0].Value != base64.StdEncoding.EncodeToString(keys[0].Value) {
+		t.Fatalf("expected the stored SD key in the CPIX document")
+	}
+	// Widevine, PlayReady and FairPlay for every key under cbcs
+	if len(document.Systems) != 3*len(keys) {
+		t.Fatalf("expected three DRM systems per key, got %d", len(document.Systems))
+	}
+	if last := document.Rules[len(document.Rules)-1]; last.TrackType != models.TrackTypeAudio {
+		t.Fatalf("expected audio usage rule last, got %s", last.TrackType)
+	}
+}
@@ -0,0 +1,126 @@
+package drm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+// Common encryption schemes. cbcs is understood by FairPlay, Widevine and
+// PlayReady 4, so one packaging serves every DRM system.
+const (
+	SchemeCENC = "cenc"
+	SchemeCBCS = "cbcs"
+)
+
+// DRM system IDs, in UUID form
+const (
+	WidevineSystemID  = "edef8ba9-79d6-4ace-a3c8-27dcd51d21ed"
+	PlayReadySystemID = "9a04f079-9840-4286-ab92-e65be0885f95"
+	FairPlaySystemID  = "94ce86fb-07ff-4f43-adb8-93d2fa968ca2"
+)
+
+// TrackKeys describes the keys for manifests: key IDs and the Widevine and
+// PlayReady PSSH boxes players use to request licenses. Key values are left out.
+func TrackKeys(contentID, scheme string, keys []Key) ([]models.TrackKey, error) {
+	trackKeys := make([]models.TrackKey, 0, len(keys))
+	for _, key := range keys {
+		kid, err := ParseKeyID(key.KeyID)
+		if err != nil {
+			return nil, err
+		}
+		trackKeys = append(trackKeys, models.TrackKey{
+			TrackType: key.TrackType,
+			KeyID:     key.KeyID,
+			PSSH: map[string]string{
+				"widevine":  base64.StdEncoding.EncodeToString(WidevinePSSH(contentID, scheme, kid)),
+				"playready": base64.StdEncoding.EncodeToString(PlayReadyPSSH(scheme, kid)),
+			},
+		})
+	}
+	return trackKeys, nil
+}
+
+// WidevinePSSH builds a version 0 pssh box carrying WidevinePsshData with the key
+// ID, content ID and protection scheme
+func WidevinePSSH(contentID, scheme string, kid []byte) []byte {
+	var data bytes.Buffer
+	writeProtoBytes(&data, 2, kid)               // key_ids
+	writeProtoBytes(&data, 4, []byte(contentID)) // content_id
+	data.WriteByte(9 << 3)                       // protection_scheme, varint
+	writeVarint(&data, uint64(binary.BigEndian.Uint32([]byte(scheme))))
+	return psshBox(mustParseKeyID(WidevineSystemID), data.Bytes())
+}
+
+// PlayReadyPSSH builds a version 0 pssh box carrying a PlayReady Object with a
+// version 4.3 header for the key ID
+func PlayReadyPSSH(scheme string, kid []byte) []byte {
+	algorithm := "AESCTR"
+	if scheme == SchemeCBCS {
+		algorithm = "AESCBC"
+	}
+	header := fmt.Sprintf(`<WRMHEADER xmlns="http://schemas.microsoft.com/DRM/2007/03/PlayReadyHeader" version="4.3.0.0">`+
+		`<DATA><PROTECTINFO><KIDS><KID ALGID="%s" VALUE="%s"></KID></KIDS></PROTECTINFO></DATA></WRMHEADER>`,
+		algorithm, base64.StdEncoding.EncodeToString(playReadyGUID(kid)))
+
+	var record bytes.Buffer
+	for _, unit := range utf16.Encode([]rune(header)) {
+		binary.Write(&record, binary.LittleEndian, unit)
+	}
+
+	// PlayReady Object: length, record count, then one rights management header record
+	var object bytes.Buffer
+	binary.Write(&object, binary.LittleEndian, uint32(10+record.Len()))
+	binary.Write(&object, binary.LittleEndian, uint16(1))
+	binary.Write(&object, binary.LittleEndian, uint16(1))
+	binary.Write(&object, binary.LittleEndian, uint16(record.Len()))
+	object.Write(record.Bytes())
+	return psshBox(mustParseKeyID(PlayReadySystemID), object.Bytes())
+}
+
+// playReadyGUID converts a key ID to the little-endian GUID byte order PlayReady uses
+func playReadyGUID(kid []byte) []byte {
+	guid := append([]byte(nil), kid...)
+	guid[0], guid[1], guid[2], guid[3] = kid[3], kid[2], kid[1], kid[0]
+	guid[4], guid[5] = kid[5], kid[4]
+	guid[6], guid[7] = kid[7], kid[6]
+	return guid
+}
+
+// psshBox wraps system-specific data in an ISO/IEC 23001-7 version 0 pssh box
+func psshBox(systemID, data []byte) []byte {
+	var box bytes.Buffer
+	binary.Write(&box, binary.BigEndian, uint32(32+len(data)))
+	box.WriteString("pssh")
+	box.Write([]byte{0, 0, 0, 0}) // version 0, no flags
+	box.Write(systemID)
+	binary.Write(&box, binary.BigEndian, uint32(len(data)))
+	box.Write(data)
+	return box.Bytes()
+}
+
+func writeProtoBytes(b *bytes.Buffer, field int, value []byte) {
+	b.WriteByte(byte(field<<3 | 2))
+	writeVarint(b, uint64(len(value)))
+	b.Write(value)
+}
+
+func writeVarint(b *bytes.Buffer, v uint64) {
+	for v >= 0x80 {
+		b.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	b.WriteByte(byte(v))
+}
+
+func mustParseKeyID(id string) []byte {
+	raw, err := ParseKeyID(id)
+	if err != nil {
+		panic(err)
+	}
+	return raw
+}
@@ -0,0 +1,62 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Locator resolves client IP addresses to ISO 3166-1 alpha-2 country codes
+type Locator interface {
+	// Country returns the country of ip, or "" when the database has no answer
+	Country(ip string) (string, error)
+}
+
+// countryRecord is the subset of a GeoIP2/GeoLite2 Country or City record we read
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// MMDBLocator looks countries up in a local MaxMind-format database
+type MMDBLocator struct {
+	reader *maxminddb.Reader
+}
+
+// OpenMMDB opens a MaxMind-format (.mmdb) country or city database
+func OpenMMDB(path string) (*MMDBLocator, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	return &MMDBLocator{reader: reader}, nil
+}
+
+// Country returns the country of ip, falling back to the country the network is
+// registered in for anycast and satellite ranges without a located country
+func (l *MMDBLocator) Country(ip string) (string, error) {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return "", fmt.Errorf("invalid IP address %q", ip)
+	}
+
+	var record countryRecord
+	if err := l.reader.Lookup(parsed, &record); err != nil {
+		return "", fmt.Errorf("GeoIP lookup failed: %w", err)
+	}
+	if record.Country.ISOCode != "" {
+		return record.Country.ISOCode, nil
+	}
+	return record.RegisteredCountry.ISOCode, nil
+}
+
+// Close releases the database
+func (l *MMDBLocator) Close() error {
+	return l.reader.Close()
+}
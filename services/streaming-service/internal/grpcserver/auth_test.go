@@ -0,0 +1,43 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestServiceTokenInterceptor(t *testing.T) {
+	interceptor := ServiceTokenInterceptor("service-secret")
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		auth   string
+		want   codes.Code
+	}{
+		{"service token", "/streaming.StreamingService/GetManifest", "Bearer service-secret", codes.OK},
+		{"no token", "/streaming.StreamingService/GetManifest", "", codes.Unauthenticated},
+		{"wrong token", "/streaming.StreamingService/CreateSession", "Bearer guess", codes.Unauthenticated},
+		{"token without scheme", "/streaming.StreamingService/EndSession", "service-secret", codes.Unauthenticated},
+		{"health check", "/grpc.health.v1.Health/Check", "", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.auth != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tt.auth))
+			}
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
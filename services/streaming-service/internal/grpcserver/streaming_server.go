@@ -0,0 +1,188 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/streamverse/proto/gen/go/streaming"
+	"github.com/streamverse/streaming-service/models"
+	"github.com/streamverse/streaming-service/service"
+	"github.com/streamverse/streaming-service/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// PlaybackService is the part of the streaming service exposed over gRPC
+type PlaybackService interface {
+	GetManifest(ctx context.Context, contentID, format, userID, clientIP, drmSecurity string) (*models.StreamManifest, error)
+	CreateSession(ctx context.Context, userID, profileID, contentID, deviceID, deviceType string) (*models.PlaybackSession, error)
+	GetSession(ctx context.Context, sessionID string) (*models.PlaybackSession, error)
+	SendHeartbeat(ctx context.Context, sessionID, userID string, bandwidth int64, state string) error
+	EndSession(ctx context.Context, sessionID, userID string) error
+}
+
+// StreamingServer implements the streaming gRPC API used by backend-for-frontend
+// services. It shares the service layer with the HTTP API; callers are trusted
+// internal services acting for the user named in each request, so session RPCs
+// only check that the session belongs to that user.
+type StreamingServer struct {
+	streaming.UnimplementedStreamingServiceServer
+	service PlaybackService
+}
+
+// NewStreamingServer creates a new streaming gRPC server
+func NewStreamingServer(service PlaybackService) *StreamingServer {
+	return &StreamingServer{service: service}
+}
+
+// GetManifest returns the manifest URL, the qualities the viewer is entitled to,
+// the subtitle tracks and the DRM details of a title
+func (s *StreamingServer) GetManifest(ctx context.Context, req *streaming.GetManifestRequest) (*streaming.GetManifestResponse, error) {
+	if req.GetContentId() == "" || req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "content_id and user_id are required")
+	}
+	format := req.GetFormat()
+	if format == "" {
+		format = "hls"
+	}
+	if format != "hls" && format != "dash" {
+		return nil, status.Error(codes.InvalidArgument, "format must be hls or dash")
+	}
+
+	manifest, err := s.service.GetManifest(ctx, req.GetContentId(), format, req.GetUserId(), req.GetClientIp(), utils.NormalizeDRMSecurity(req.GetDrmSecurity()))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrGeoBlocked), errors.Is(err, service.ErrNoActiveSubscription):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	resp := &streaming.GetManifestResponse{
+		ManifestUrl: manifest.ManifestURL,
+		Format:      manifest.Type,
+	}
+	for _, q := range manifest.Qualities {
+		resp.QualityLevels = append(resp.QualityLevels, &streaming.QualityLevel{
+			Id:         q.ID,
+			Resolution: q.Resolution,
+			Bitrate:    int32(q.Bitrate),
+			Codec:      q.Codec,
+			Url:        q.URL,
+		})
+	}
+	for _, t := range manifest.Subtitles {
+		resp.SubtitleTracks = append(resp.SubtitleTracks, &streaming.SubtitleTrack{
+			Language: t.Language,
+			Label:    t.Label,
+			Url:      t.URL,
+			Format:   t.Format,
+			Kind:     t.Kind,
+			Default:  t.Default,
+		})
+	}
+	if manifest.DRMInfo != nil {
+		resp.DrmInfo = &streaming.DRMInfo{
+			Type:           manifest.DRMInfo.Type,
+			LicenseUrl:     manifest.DRMInfo.LicenseURL,
+			CertificateUrl: manifest.DRMInfo.CertificateURL,
+		}
+	}
+	return resp, nil
+}
+
+// CreateSession starts a playback session
+func (s *StreamingServer) CreateSession(ctx context.Context, req *streaming.CreateSessionRequest) (*streaming.CreateSessionResponse, error) {
+	if req.GetContentId() == "" || req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "content_id and user_id are required")
+	}
+
+	session, err := s.service.CreateSession(ctx, req.GetUserId(), req.GetProfileId(), req.GetContentId(), req.GetDeviceId(), utils.NormalizeDeviceType(req.GetDeviceType()))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrStreamLimitReached):
+			return nil, status.Error(codes.ResourceExhausted, "concurrent stream limit reached for the plan")
+		case errors.Is(err, service.ErrNoActiveSubscription):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to create session: %v", err)
+	}
+
+	return &streaming.CreateSessionResponse{Session: toProtoSession(session)}, nil
+}
+
+// Heartbeat keeps a playback session alive
+func (s *StreamingServer) Heartbeat(ctx context.Context, req *streaming.HeartbeatRequest) (*streaming.HeartbeatResponse, error) {
+	switch req.GetState() {
+	case "", models.SessionStatePlaying, models.SessionStatePaused, models.SessionStateStalled, models.SessionStateEnded:
+	default:
+		return nil, status.Error(codes.InvalidArgument, "state must be playing, paused, stalled or ended")
+	}
+	if err := s.checkOwner(ctx, req.GetSessionId(), req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.service.SendHeartbeat(ctx, req.GetSessionId(), req.GetUserId(), req.GetBandwidth(), req.GetState()); err != nil {
+		switch {
+		case errors.Is(err, service.ErrStreamEvicted):
+			return nil, status.Error(codes.FailedPrecondition, "playback was started on another device")
+		case errors.Is(err, service.ErrSessionEnded):
+			return nil, status.Error(codes.NotFound, "playback session has ended")
+		case errors.Is(err, service.ErrInvalidSessionTransition):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to record heartbeat: %v", err)
+	}
+
+	return &streaming.HeartbeatResponse{}, nil
+}
+
+// EndSession ends a playback session
+func (s *StreamingServer) EndSession(ctx context.Context, req *streaming.EndSessionRequest) (*streaming.EndSessionResponse, error) {
+	if err := s.checkOwner(ctx, req.GetSessionId(), req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.service.EndSession(ctx, req.GetSessionId(), req.GetUserId()); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to end session: %v", err)
+	}
+
+	return &streaming.EndSessionResponse{}, nil
+}
+
+// checkOwner verifies that a session exists and belongs to the user
+func (s *StreamingServer) checkOwner(ctx context.Context, sessionID, userID string) error {
+	if sessionID == "" || userID == "" {
+		return status.Error(codes.InvalidArgument, "session_id and user_id are required")
+	}
+
+	session, err := s.service.GetSession(ctx, sessionID)
+	if err != nil {
+		return status.Error(codes.NotFound, "session not found")
+	}
+	if session.UserID != userID {
+		return status.Error(codes.PermissionDenied, "session belongs to another user")
+	}
+	return nil
+}
+
+// toProtoSession converts a playback session to its gRPC message
+func toProtoSession(session *models.PlaybackSession) *streaming.PlaybackSession {
+	msg := &streaming.PlaybackSession{
+		Id:         session.ID.Hex(),
+		UserId:     session.UserID,
+		ProfileId:  session.ProfileID,
+		ContentId:  session.ContentID,
+		DeviceId:   session.DeviceID,
+		DeviceType: session.DeviceType,
+		State:      session.State,
+		Position:   session.Position,
+		StreamUrl:  session.StreamURL,
+		CreatedAt:  timestamppb.New(session.CreatedAt),
+	}
+	if !session.LastHeartbeat.IsZero() {
+		msg.LastHeartbeat = timestamppb.New(session.LastHeartbeat)
+	}
+	return msg
+}
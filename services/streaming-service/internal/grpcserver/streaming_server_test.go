@@ -0,0 +1,140 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streamverse/proto/gen/go/streaming"
+	"github.com/streamverse/streaming-service/models"
+	"github.com/streamverse/streaming-service/service"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakePlayback struct {
+	sessions     map[string]*models.PlaybackSession
+	manifestErr  error
+	createErr    error
+	heartbeatErr error
+	drmSecurity  string
+	ended        []string
+}
+
+func (f *fakePlayback) GetManifest(ctx context.Context, contentID, format, userID, clientIP, drmSecurity string) (*models.StreamManifest, error) {
+	if f.manifestErr != nil {
+		return nil, f.manifestErr
+	}
+	f.drmSecurity = drmSecurity
+	return &models.StreamManifest{
+		ContentID:   contentID,
+		ManifestURL: "https://cdn/" + contentID + "/master.m3u8",
+		Type:        format,
+		Qualities:   []models.QualityLevel{{ID: "720p", Resolution: "1280x720", Bitrate: 3000000}},
+		Subtitles:   []models.SubtitleTrack{{Language: "en", Kind: models.KindCaptions, Format: "vtt", Default: true}},
+		DRMInfo:     &models.DRMInfo{Type: "widevine", LicenseURL: "https://drm/license"},
+	}, nil
+}
+
+func (f *fakePlayback) CreateSession(ctx context.Context, userID, profileID, contentID, deviceID, deviceType string) (*models.PlaybackSession, error) {
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	session := &models.PlaybackSession{
+		ID:         primitive.NewObjectID(),
+		UserID:     userID,
+		ContentID:  contentID,
+		DeviceID:   deviceID,
+		DeviceType: deviceType,
+		State:      models.SessionStateStarting,
+		CreatedAt:  time.Now(),
+	}
+	f.sessions[session.ID.Hex()] = session
+	return session, nil
+}
+
+func (f *fakePlayback) GetSession(ctx context.Context, sessionID string) (*models.PlaybackSession, error) {
+	if session, ok := f.sessions[sessionID]; ok {
+		return session, nil
+	}
+	return nil, errors.New("session not found")
+}
+
+func (f *fakePlayback) SendHeartbeat(ctx context.Context, sessionID, userID string, bandwidth int64, state string) error {
+	return f.heartbeatErr
+}
+
+func (f *fakePlayback) EndSession(ctx context.Context, sessionID, userID string) error {
+	f.ended = append(f.ended, sessionID)
+	return nil
+}
+
+func TestGetManifest(t *testing.T) {
+	fake := &fakePlayback{}
+	server := NewStreamingServer(fake)
+
+	resp, err := server.GetManifest(context.Background(), &streaming.GetManifestRequest{ContentId: "c1", UserId: "u1", DrmSecurity: "L1"})
+	if err != nil {
+		t.Fatalf("GetManifest: %v", err)
+	}
+	if resp.GetFormat() != "hls" || len(resp.GetQualityLevels()) != 1 || resp.GetDrmInfo().GetType() != "widevine" {
+		t.Fatalf("unexpected response %v", resp)
+	}
+	if track := resp.GetSubtitleTracks()[0]; track.GetKind() != models.KindCaptions || !track.GetDefault() {
+		t.Fatalf("expected the subtitle kind and default flag, got %v", track)
+	}
+	if fake.drmSecurity != "hardware" {
+		t.Fatalf("expected the DRM security level to be normalized, got %q", fake.drmSecurity)
+	}
+
+	fake.manifestErr = service.ErrGeoBlocked
+	if _, err := server.GetManifest(context.Background(), &streaming.GetManifestRequest{ContentId: "c1", UserId: "u1"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for geo-blocked content, got %v", err)
+	}
+	if _, err := server.GetManifest(context.Background(), &streaming.GetManifestRequest{ContentId: "c1"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument without a user, got %v", err)
+	}
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	fake := &fakePlayback{sessions: map[string]*models.PlaybackSession{}}
+	server := NewStreamingServer(fake)
+	ctx := context.Background()
+
+	created, err := server.CreateSession(ctx, &streaming.CreateSessionRequest{UserId: "u1", ContentId: "c1", DeviceId: "d1", DeviceType: "smarttv"})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	session := created.GetSession()
+	if session.GetId() == "" || session.GetDeviceType() != "tv" || session.GetCreatedAt() == nil {
+		t.Fatalf("unexpected session %v", session)
+	}
+
+	if _, err := server.Heartbeat(ctx, &streaming.HeartbeatRequest{SessionId: session.GetId(), UserId: "u1", State: "playing"}); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	if _, err := server.Heartbeat(ctx, &streaming.HeartbeatRequest{SessionId: session.GetId(), UserId: "u2"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for another user's session, got %v", err)
+	}
+	if _, err := server.Heartbeat(ctx, &streaming.HeartbeatRequest{SessionId: session.GetId(), UserId: "u1", State: "rewinding"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for an unknown state, got %v", err)
+	}
+	fake.heartbeatErr = service.ErrStreamEvicted
+	if _, err := server.Heartbeat(ctx, &streaming.HeartbeatRequest{SessionId: session.GetId(), UserId: "u1"}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition for an evicted stream, got %v", err)
+	}
+
+	if _, err := server.EndSession(ctx, &streaming.EndSessionRequest{SessionId: "missing", UserId: "u1"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for an unknown session, got %v", err)
+	}
+	if _, err := server.EndSession(ctx, &streaming.EndSessionRequest{SessionId: session.GetId(), UserId: "u1"}); err != nil || len(fake.ended) != 1 {
+		t.Fatalf("EndSession: %v", err)
+	}
+
+	fake.createErr = service.ErrStreamLimitReached
+	if _, err := server.CreateSession(ctx, &streaming.CreateSessionRequest{UserId: "u1", ContentId: "c1"}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted at the stream limit, got %v", err)
+	}
+}
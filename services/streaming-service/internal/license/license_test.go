@@ -0,0 +1,158 @@
+package license
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestDecideSubscriptionPolicy(t *testing.T) {
+	cfg := Config{LicenseDuration: 24 * time.Hour, RentalPlaybackWindow: 48 * time.Hour}
+
+	policy, err := cfg.Decide(Grant{Reason: "subscription", DRMLevel: "2", MaxHeight: 1080}, time.Now())
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if policy.Rental || policy.LicenseDuration != 86400 || policy.PlaybackDuration != 0 {
+		t.Fatalf("expected a 24h streaming license, got %+v", policy)
+	}
+	if got := trackLevels(policy); got != "SD:3,AUDIO:3,HD:3,FHD:1" || policy.HDCP != HDCPV1 {
+		t.Fatalf("expected 1080p at level 1 with HDCP 1.4, got %s %+v", got, policy)
+	}
+
+	if policy, _ := cfg.Decide(Grant{Reason: "subscription", MaxHeight: 480}, time.Now()); policy.HDCP != HDCPNone || trackLevels(policy) != "SD:3,AUDIO:3" {
+		t.Fatalf("expected SD without output protection at level 3, got %+v", policy)
+	}
+	if policy, _ := cfg.Decide(Grant{Reason: "subscription"}, time.Now()); policy.HDCP != HDCPV2 {
+		t.Fatalf("expected uncapped viewers to require HDCP 2.2, got %+v", policy)
+	}
+}
+
+func TestDecideTrackSecurityLevels(t *testing.T) {
+	cfg := Config{LicenseDuration: 24 * time.Hour}
+	tests := []struct {
+		name  string
+		grant Grant
+		want  string
+	}{
+		// A premium viewer on a software CDM is entitled to 720p: SD and HD keys at
+		// level 3, and no key that unlocks 1080p
+		{"premium on software DRM", Grant{Reason: "subscription", DRMLevel: "1", MaxHeight: 720}, "SD:3,AUDIO:3,HD:3"},
+		{"premium on hardware DRM", Grant{Reason: "subscription", DRMLevel: "1", MaxHeight: 2160}, "SD:3,AUDIO:3,HD:3,FHD:1,UHD:1"},
+		// The plan's level caps the tracks whatever height the token claims
+		{"standard plan", Grant{Reason: "subscription", DRMLevel: "2", MaxHeight: 2160}, "SD:3,AUDIO:3,HD:3,FHD:1"},
+		{"basic plan", Grant{Reason: "subscription", DRMLevel: "3"}, "SD:3,AUDIO:3"},
+		{"uncapped", Grant{Reason: "purchased"}, "SD:3,AUDIO:3,HD:3,FHD:1,UHD:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := cfg.Decide(tt.grant, time.Now())
+			if err != nil {
+				t.Fatalf("Decide: %v", err)
+			}
+			if got := trackLevels(policy); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+// trackLevels lists a policy's tracks as TYPE:level, in order
+func trackLevels(policy *Policy) string {
+	var tracks []string
+	for _, track := range policy.Tracks {
+		tracks = append(tracks, track.TrackType+":"+track.SecurityLevel)
+	}
+	return strings.Join(tracks, ",")
+}
+
+func TestDecideRentalWindow(t *testing.T) {
+	cfg := Config{LicenseDuration: 24 * time.Hour, RentalPlaybackWindow: 48 * time.Hour}
+	now := time.Now()
+
+	expires := now.Add(72 * time.Hour)
+	policy, err := cfg.Decide(Grant{Reason: "purchased", ExpiresAt: &expires}, now)
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if !policy.Rental || policy.LicenseDuration != 86400 || policy.PlaybackDuration != 48*3600 {
+		t.Fatalf("expected the full rental window, got %+v", policy)
+	}
+
+	// Both durations stop at the rental's expiry
+	expires = now.Add(2 * time.Hour)
+	policy, _ = cfg.Decide(Grant{Reason: "purchased", ExpiresAt: &expires}, now)
+	if policy.LicenseDuration != 7200 || policy.PlaybackDuration != 7200 {
+		t.Fatalf("expected durations capped at expiry, got %+v", policy)
+	}
+
+	expires = now.Add(-time.Minute)
+	if _, err := cfg.Decide(Grant{Reason: "purchased", ExpiresAt: &expires}, now); !errors.Is(err, ErrRentalExpired) {
+		t.Fatalf("expected expired rental, got %v", err)
+	}
+
+	// Owned titles have no expiry and license like subscriptions
+	if policy, _ := cfg.Decide(Grant{Reason: "purchased"}, now); policy.Rental {
+		t.Fatalf("expected an owned title not to be a rental")
+	}
+}
+
+func TestDecideOfflineIsPersistent(t *testing.T) {
+	cfg := Config{LicenseDuration: 24 * time.Hour, RentalPlaybackWindow: 48 * time.Hour, OfflineLicenseDuration: 30 * 24 * time.Hour}
+	now := time.Now()
+
+	policy, err := cfg.DecideOffline(Grant{Reason: "subscription", MaxHeight: 720}, now)
+	if err != nil {
+		t.Fatalf("DecideOffline: %v", err)
+	}
+	if !policy.Persistent || policy.LicenseDuration != 30*24*3600 {
+		t.Fatalf("expected a 30-day persistent license, got %+v", policy)
+	}
+
+	// A downloaded rental still ends when the rental does
+	expires := now.Add(72 * time.Hour)
+	policy, _ = cfg.DecideOffline(Grant{Reason: "purchased", ExpiresAt: &expires}, now)
+	if !policy.Persistent || policy.LicenseDuration != 72*3600 || policy.PlaybackDuration != 48*3600 {
+		t.Fatalf("expected an offline rental capped at expiry, got %+v", policy)
+	}
+}
+
+func TestHTTPUpstreamForwardsChallengeWithSignedPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "challenge" {
+			t.Fatalf("expected the raw challenge, got %q", body)
+		}
+		claims := jwt.MapClaims{}
+		if _, err := jwt.ParseWithClaims(r.Header.Get("X-DRM-License-Token"), claims, func(*jwt.Token) (interface{}, error) {
+			return []byte("vendor-secret"), nil
+		}); err != nil {
+			t.Fatalf("invalid license token: %v", err)
+		}
+		policy, _ := claims["policy"].(map[string]interface{})
+		if claims["content_id"] != "c1" || policy["hdcp"] != HDCPV2 {
+			t.Fatalf("unexpected license token claims %v", claims)
+		}
+		_, _ = w.Write([]byte("license"))
+	}))
+	defer server.Close()
+
+	upstream := NewHTTPUpstream(map[string]string{SystemWidevine: server.URL}, "vendor-secret", "")
+	req := &Request{System: SystemWidevine, Challenge: []byte("challenge"), ContentID: "c1", Policy: &Policy{HDCP: HDCPV2}}
+	got, err := upstream.Acquire(context.Background(), req)
+	if err != nil || string(got) != "license" {
+		t.Fatalf("expected license, got %q, %v", got, err)
+	}
+
+	req.System = SystemFairPlay
+	if _, err := upstream.Acquire(context.Background(), req); !errors.Is(err, ErrUnsupportedSystem) {
+		t.Fatalf("expected unconfigured system to be unsupported, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package license
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+// Output protection levels, as the minimum HDCP version required on outputs
+const (
+	HDCPNone = ""
+	HDCPV1   = "1.4"
+	HDCPV2   = "2.2"
+)
+
+// DRM security levels, from hardware-backed to software-only CDMs
+const (
+	SecurityLevelHardware = "1"
+	SecurityLevelTEE      = "2"
+	SecurityLevelSoftware = "3"
+)
+
+// SoftwareMaxHeight is the tallest video software-only CDMs are licensed for
+const SoftwareMaxHeight = 720
+
+// ErrRentalExpired is returned when a rental's viewing window has closed
+var ErrRentalExpired = errors.New("rental has expired")
+
+// Policy is what a license permits. Upstream adapters translate it into the
+// vendor's license policy.
+type Policy struct {
+	LicenseDuration  int64         `json:"license_duration"`  // seconds the license may be used to start playback
+	PlaybackDuration int64         `json:"playback_duration"` // seconds of playback after first use, 0 for the license duration
+	Rental           bool          `json:"rental"`
+	Persistent       bool          `json:"persistent"`     // may be stored for offline playback
+	Tracks           []TrackPolicy `json:"tracks"`         // key track types the license carries
+	HDCP             string        `json:"hdcp,omitempty"` // minimum HDCP version, empty when not required
+}
+
+// TrackPolicy is the minimum DRM security level a device needs for the keys of
+// a track type. Track types left out of a policy are not licensed.
+type TrackPolicy struct {
+	TrackType     string `json:"track_type"`
+	SecurityLevel string `json:"security_level"` // "1" hardware, "2", "3" software
+}
+
+// Config holds license durations
+type Config struct {
+	// LicenseDuration bounds streaming licenses; players renew them with a new request
+	LicenseDuration time.Duration
+	// RentalPlaybackWindow is how long a rental plays once started, within its expiry
+	RentalPlaybackWindow time.Duration
+	// OfflineLicenseDuration bounds persistent licenses of downloads; devices renew
+	// them online before they lapse
+	OfflineLicenseDuration time.Duration
+}
+
+// ConfigFromEnv builds license config from environment variables.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		LicenseDuration:        24 * time.Hour,
+		RentalPlaybackWindow:   48 * time.Hour,
+		OfflineLicenseDuration: 30 * 24 * time.Hour,
+	}
+
+	duration := func(name string, target *time.Duration) {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+				*target = parsed
+			}
+		}
+	}
+	duration("LICENSE_DURATION", &cfg.LicenseDuration)
+	duration("RENTAL_PLAYBACK_WINDOW", &cfg.RentalPlaybackWindow)
+	duration("OFFLINE_LICENSE_DURATION", &cfg.OfflineLicenseDuration)
+
+	return cfg
+}
+
+// Grant is the entitlement a license is issued under
+type Grant struct {
+	Reason    string     // policy-service decision reason: purchased, subscription, free
+	ExpiresAt *time.Time // end of a rental, nil for owned titles and subscriptions
+	DRMLevel  string     // DRM level policy-service grants the plan, empty when unknown
+	MaxHeight int        // tallest video the viewer is entitled to, 0 when uncapped
+}
+
+// Decide works out the license policy for a grant. Rentals are licensed until
+// they expire and play for the rental window once started, never past expiry.
+// Keys and output protection follow the resolution the viewer may receive.
+func (c Config) Decide(grant Grant, now time.Time) (*Policy, error) {
+	return c.decide(grant, now, c.LicenseDuration)
+}
+
+// DecideOffline works out the persistent license policy of a download. It follows
+// Decide, but the license may be stored and lasts the offline license duration.
+func (c Config) DecideOffline(grant Grant, now time.Time) (*Policy, error) {
+	policy, err := c.decide(grant, now, c.OfflineLicenseDuration)
+	if err != nil {
+		return nil, err
+	}
+	policy.Persistent = true
+	return policy, nil
+}
+
+func (c Config) decide(grant Grant, now time.Time, licenseDuration time.Duration) (*Policy, error) {
+	maxHeight := grant.MaxHeight
+	if limit := levelMaxHeight(grant.DRMLevel); limit > 0 && (maxHeight <= 0 || limit < maxHeight) {
+		maxHeight = limit
+	}
+	policy := &Policy{
+		LicenseDuration: int64(licenseDuration / time.Second),
+		Tracks:          tracksFor(maxHeight),
+		HDCP:            hdcpFor(maxHeight),
+	}
+
+	if grant.Reason == "purchased" && grant.ExpiresAt != nil {
+		remaining := grant.ExpiresAt.Sub(now)
+		if remaining <= 0 {
+			return nil, ErrRentalExpired
+		}
+		policy.Rental = true
+		policy.LicenseDuration = int64(minDuration(licenseDuration, remaining) / time.Second)
+		policy.PlaybackDuration = int64(minDuration(c.RentalPlaybackWindow, remaining) / time.Second)
+	}
+
+	return policy, nil
+}
+
+// tracksFor licenses the track types of video up to maxHeight, 0 for uncapped.
+// Software CDMs get the keys of video up to SoftwareMaxHeight; the keys of
+// anything taller need hardware-backed DRM.
+func tracksFor(maxHeight int) []TrackPolicy {
+	tracks := []TrackPolicy{
+		{TrackType: models.TrackTypeSD, SecurityLevel: SecurityLevelSoftware},
+		{TrackType: models.TrackTypeAudio, SecurityLevel: SecurityLevelSoftware},
+	}
+	if maxHeight <= 0 || maxHeight > 576 {
+		tracks = append(tracks, TrackPolicy{TrackType: models.TrackTypeHD, SecurityLevel: SecurityLevelSoftware})
+	}
+	if maxHeight <= 0 || maxHeight > SoftwareMaxHeight {
+		tracks = append(tracks, TrackPolicy{TrackType: models.TrackTypeFHD, SecurityLevel: SecurityLevelHardware})
+	}
+	if maxHeight <= 0 || maxHeight > 1080 {
+		tracks = append(tracks, TrackPolicy{TrackType: models.TrackTypeUHD, SecurityLevel: SecurityLevelHardware})
+	}
+	return tracks
+}
+
+// levelMaxHeight is the tallest video a plan's DRM level licenses, 0 when the
+// level is unknown
+func levelMaxHeight(level string) int {
+	switch level {
+	case SecurityLevelHardware:
+		return 2160
+	case SecurityLevelTEE:
+		return 1080
+	case SecurityLevelSoftware:
+		return 480
+	default:
+		return 0
+	}
+}
+
+// hdcpFor requires HDCP 2.2 above 1080p and HDCP 1.4 for HD. Uncapped viewers may
+// receive every rendition, so they get the strictest protection.
+func hdcpFor(maxHeight int) string {
+	switch {
+	case maxHeight <= 0 || maxHeight > 1080:
+		return HDCPV2
+	case maxHeight > 576:
+		return HDCPV1
+	default:
+		return HDCPNone
+	}
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,146 @@
+package license
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DRM systems licenses are proxied for
+const (
+	SystemWidevine  = "widevine"
+	SystemPlayReady = "playready"
+	SystemFairPlay  = "fairplay"
+)
+
+// ErrUnsupportedSystem is returned for a DRM system the upstream does not serve
+var ErrUnsupportedSystem = errors.New("unsupported DRM system")
+
+// maxLicenseSize bounds upstream license responses
+const maxLicenseSize = 1 << 20
+
+// Request is an authorized license request: the player's challenge (a FairPlay
+// SPC for FairPlay) and the policy the license must carry
+type Request struct {
+	System    string
+	Challenge []byte
+	ContentID string
+	UserID    string
+	SessionID string
+	Policy    *Policy
+}
+
+// Upstream issues licenses from a DRM vendor's license server
+type Upstream interface {
+	Acquire(ctx context.Context, req *Request) ([]byte, error)
+}
+
+// HTTPUpstream forwards challenges to a vendor license server that takes the
+// challenge as the request body and the entitlement as a JWT, signed with the
+// secret shared with the vendor, in a request header.
+type HTTPUpstream struct {
+	urls        map[string]string // license server URL per DRM system
+	secret      []byte
+	tokenHeader string
+	httpClient  *http.Client
+}
+
+// NewHTTPUpstream creates a vendor adapter. tokenHeader defaults to X-DRM-License-Token.
+func NewHTTPUpstream(urls map[string]string, secret, tokenHeader string) *HTTPUpstream {
+	if tokenHeader == "" {
+		tokenHeader = "X-DRM-License-Token"
+	}
+	return &HTTPUpstream{
+		urls:        urls,
+		secret:      []byte(secret),
+		tokenHeader: tokenHeader,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// HTTPUpstreamFromEnv configures a vendor adapter from DRM_LICENSE_<SYSTEM>_URL,
+// DRM_LICENSE_SECRET and DRM_LICENSE_TOKEN_HEADER
+func HTTPUpstreamFromEnv() *HTTPUpstream {
+	urls := make(map[string]string)
+	for _, system := range []string{SystemWidevine, SystemPlayReady, SystemFairPlay} {
+		if url := strings.TrimSpace(os.Getenv("DRM_LICENSE_" + strings.ToUpper(system) + "_URL")); url != "" {
+			urls[system] = url
+		}
+	}
+	return NewHTTPUpstream(urls, os.Getenv("DRM_LICENSE_SECRET"), os.Getenv("DRM_LICENSE_TOKEN_HEADER"))
+}
+
+// Acquire posts the challenge with a short-lived license token
+func (u *HTTPUpstream) Acquire(ctx context.Context, req *Request) ([]byte, error) {
+	url, ok := u.urls[req.System]
+	if !ok {
+		return nil, ErrUnsupportedSystem
+	}
+
+	now := time.Now()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"content_id": req.ContentID,
+		"user_id":    req.UserID,
+		"session_id": req.SessionID,
+		"drm_system": req.System,
+		"policy":     req.Policy,
+		"iat":        now.Unix(),
+		"exp":        now.Add(time.Minute).Unix(),
+	}).SignedString(u.secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign license token: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(req.Challenge))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/octet-stream")
+	httpReq.Header.Set(u.tokenHeader, token)
+
+	res, err := u.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("license server unavailable: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxLicenseSize))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("license server returned status %d", res.StatusCode)
+	}
+	return body, nil
+}
+
+// FakeUpstream issues placeholder licenses without a vendor, for tests and local
+// development. Licenses are "license:<system>:<content>" and requests are kept.
+type FakeUpstream struct {
+	mu       sync.Mutex
+	Requests []*Request
+	Err      error // returned instead of a license when set
+}
+
+// Acquire records the request and returns a placeholder license
+func (u *FakeUpstream) Acquire(ctx context.Context, req *Request) ([]byte, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.Requests = append(u.Requests, req)
+	if u.Err != nil {
+		return nil, u.Err
+	}
+	return []byte("license:" + req.System + ":" + req.ContentID), nil
+}
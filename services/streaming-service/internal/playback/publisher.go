@@ -0,0 +1,82 @@
+package playback
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Playback event types
+const (
+	EventPosition = "position" // the player reported its position
+	EventEnded    = "ended"    // the session ended; Position is the final position
+)
+
+// Event is a playback progress update consumed by the user service to keep
+// watch history and continue watching in sync
+type Event struct {
+	Type      string    `json:"type"`
+	SessionID string    `json:"sessionId"`
+	UserID    string    `json:"userId"`
+	ProfileID string    `json:"profileId,omitempty"`
+	ContentID string    `json:"contentId"`
+	Position  int64     `json:"position"` // milliseconds
+	Duration  int64     `json:"duration"` // milliseconds
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Publisher delivers playback events to their consumers
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+	Close() error
+}
+
+// KafkaPublisher produces playback events to a Kafka topic, keyed by viewer and
+// content so updates to one watch history entry stay ordered within a partition
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaPublisher creates a publisher producing to topic on the given brokers
+func NewKafkaPublisher(brokers []string, topic string) *KafkaPublisher {
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireOne,
+			BatchTimeout: 10 * time.Millisecond, // events are published from request handlers
+		},
+	}
+}
+
+// Publish produces one message for the event
+func (p *KafkaPublisher) Publish(ctx context.Context, event *Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode playback event: %w", err)
+	}
+	key := event.UserID + ":" + event.ProfileID + ":" + event.ContentID
+	return p.writer.WriteMessages(ctx, kafka.Message{Key: []byte(key), Value: value, Time: event.Timestamp})
+}
+
+// Close flushes and closes the producer
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
+
+// NopPublisher drops playback events; used when no broker is configured
+type NopPublisher struct{}
+
+// Publish discards the event
+func (NopPublisher) Publish(ctx context.Context, event *Event) error {
+	return nil
+}
+
+// Close does nothing
+func (NopPublisher) Close() error {
+	return nil
+}
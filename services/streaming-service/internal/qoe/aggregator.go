@@ -0,0 +1,151 @@
+package qoe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MetricsStore persists aggregated session metrics
+type MetricsStore interface {
+	GetSessionQoE(ctx context.Context, sessionID string) (*models.SessionQoE, error)
+	SaveSessionQoE(ctx context.Context, metrics *models.SessionQoE) error
+}
+
+// Aggregator folds QoE events into per-session metrics
+type Aggregator struct {
+	store MetricsStore
+}
+
+// NewAggregator creates a new aggregator
+func NewAggregator(store MetricsStore) *Aggregator {
+	return &Aggregator{store: store}
+}
+
+// Apply updates the metrics of every session in the batch. Events without a
+// session ID cannot be attributed and are skipped.
+func (a *Aggregator) Apply(ctx context.Context, events []models.QoEEvent) error {
+	bySession := make(map[string][]models.QoEEvent)
+	var order []string
+	for _, event := range events {
+		if event.SessionID == "" {
+			continue
+		}
+		if _, ok := bySession[event.SessionID]; !ok {
+			order = append(order, event.SessionID)
+		}
+		bySession[event.SessionID] = append(bySession[event.SessionID], event)
+	}
+
+	var errs []error
+	for _, sessionID := range order {
+		sessionEvents := bySession[sessionID]
+		sort.SliceStable(sessionEvents, func(i, j int) bool {
+			return sessionEvents[i].Timestamp.Before(sessionEvents[j].Timestamp)
+		})
+
+		metrics, err := a.store.GetSessionQoE(ctx, sessionID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			metrics = &models.SessionQoE{SessionID: sessionID}
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load QoE for session %s: %w", sessionID, err))
+			continue
+		}
+
+		for i := range sessionEvents {
+			ApplyEvent(metrics, &sessionEvents[i])
+		}
+		metrics.UpdatedAt = time.Now()
+
+		if err := a.store.SaveSessionQoE(ctx, metrics); err != nil {
+			errs = append(errs, fmt.Errorf("failed to save QoE for session %s: %w", sessionID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyEvent folds a single event into session metrics. The time since the
+// previous event is attributed to the state the player was in, so playing time,
+// rebuffer time and the time-weighted bitrate follow from the event sequence.
+func ApplyEvent(m *models.SessionQoE, event *models.QoEEvent) {
+	if m.State == "" {
+		m.State = models.QoEStateIdle
+	}
+	if m.StartedAt.IsZero() {
+		m.StartedAt = event.Timestamp
+		m.UserID = event.UserID
+		m.ContentID = event.ContentID
+	}
+
+	if !m.LastEventAt.IsZero() {
+		if elapsed := event.Timestamp.Sub(m.LastEventAt).Milliseconds(); elapsed > 0 {
+			switch m.State {
+			case models.QoEStatePlaying:
+				m.PlayingTime += elapsed
+				m.BitrateTime += int64(m.LastBitrate) * elapsed
+			case models.QoEStateBuffering:
+				m.RebufferTime += elapsed
+			}
+		}
+	}
+	if event.Timestamp.After(m.LastEventAt) {
+		m.LastEventAt = event.Timestamp
+	}
+
+	if event.Bitrate > 0 {
+		if m.LastBitrate > 0 && event.Bitrate != m.LastBitrate {
+			m.BitrateSwitches++
+		}
+		m.LastBitrate = event.Bitrate
+	}
+
+	switch event.Event {
+	case "start":
+		if !m.VideoStarted {
+			m.State = models.QoEStateStarting
+		}
+	case "play":
+		if !m.VideoStarted {
+			m.VideoStarted = true
+			m.StartupTime = event.Timestamp.Sub(m.StartedAt).Milliseconds()
+		}
+		m.State = models.QoEStatePlaying
+	case "pause":
+		if m.VideoStarted {
+			m.State = models.QoEStatePaused
+		}
+	case "seek":
+		if m.VideoStarted {
+			m.State = models.QoEStateSeeking
+		}
+	case "buffering":
+		// Buffering while starting up or seeking is not a rebuffer
+		if m.VideoStarted && m.State != models.QoEStateSeeking && m.State != models.QoEStateBuffering {
+			m.RebufferCount++
+			m.State = models.QoEStateBuffering
+		}
+	case "error":
+		m.Errors++
+		if !m.VideoStarted {
+			m.ExitedBeforeStart = true
+			m.State = models.QoEStateEnded
+		}
+	case "ended":
+		if !m.VideoStarted {
+			m.ExitedBeforeStart = true
+		}
+		m.State = models.QoEStateEnded
+	}
+
+	if total := m.PlayingTime + m.RebufferTime; total > 0 {
+		m.RebufferRatio = float64(m.RebufferTime) / float64(total)
+	}
+	if m.PlayingTime > 0 {
+		m.AverageBitrate = int(m.BitrateTime / m.PlayingTime)
+	}
+}
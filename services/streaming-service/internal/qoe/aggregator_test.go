@@ -0,0 +1,110 @@
+package qoe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type memoryMetricsStore struct {
+	sessions map[string]*models.SessionQoE
+}
+
+func (s *memoryMetricsStore) GetSessionQoE(ctx context.Context, sessionID string) (*models.SessionQoE, error) {
+	metrics, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	copied := *metrics
+	return &copied, nil
+}
+
+func (s *memoryMetricsStore) SaveSessionQoE(ctx context.Context, metrics *models.SessionQoE) error {
+	copied := *metrics
+	s.sessions[metrics.SessionID] = &copied
+	return nil
+}
+
+func qoeEvent(session, event string, at time.Time, bitrate int) models.QoEEvent {
+	return models.QoEEvent{UserID: "u1", SessionID: session, ContentID: "c1", Event: event, Bitrate: bitrate, Timestamp: at}
+}
+
+func TestAggregatorComputesSessionMetrics(t *testing.T) {
+	store := &memoryMetricsStore{sessions: map[string]*models.SessionQoE{}}
+	aggregator := NewAggregator(store)
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Split across two batches, the second out of order, to exercise persistence and sorting
+	first := []models.QoEEvent{
+		qoeEvent("s1", "start", t0, 0),
+		qoeEvent("s1", "play", t0.Add(1500*time.Millisecond), 2000000),
+		qoeEvent("s1", "buffering", t0.Add(11500*time.Millisecond), 2000000),
+	}
+	second := []models.QoEEvent{
+		qoeEvent("s1", "ended", t0.Add(23500*time.Millisecond), 0),
+		qoeEvent("s1", "play", t0.Add(13500*time.Millisecond), 4000000),
+	}
+	if err := aggregator.Apply(context.Background(), first); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if err := aggregator.Apply(context.Background(), second); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+
+	m := store.sessions["s1"]
+	if m.StartupTime != 1500 {
+		t.Fatalf("expected startup time 1500ms, got %d", m.StartupTime)
+	}
+	if m.PlayingTime != 20000 || m.RebufferTime != 2000 || m.RebufferCount != 1 {
+		t.Fatalf("unexpected time accounting: playing %d, rebuffer %d, count %d", m.PlayingTime, m.RebufferTime, m.RebufferCount)
+	}
+	if m.RebufferRatio < 0.0909 || m.RebufferRatio > 0.0910 {
+		t.Fatalf("expected rebuffer ratio 2/22, got %v", m.RebufferRatio)
+	}
+	if m.AverageBitrate != 3000000 || m.BitrateSwitches != 1 {
+		t.Fatalf("expected average bitrate 3M with 1 switch, got %d with %d", m.AverageBitrate, m.BitrateSwitches)
+	}
+	if m.ExitedBeforeStart || m.State != models.QoEStateEnded {
+		t.Fatalf("unexpected final state %+v", m)
+	}
+}
+
+func TestApplyEventDetectsExitBeforeVideoStart(t *testing.T) {
+	t0 := time.Now()
+	m := &models.SessionQoE{SessionID: "s2"}
+
+	start := qoeEvent("s2", "start", t0, 0)
+	buffering := qoeEvent("s2", "buffering", t0.Add(time.Second), 0)
+	ended := qoeEvent("s2", "ended", t0.Add(8*time.Second), 0)
+	ApplyEvent(m, &start)
+	ApplyEvent(m, &buffering)
+	ApplyEvent(m, &ended)
+
+	if !m.ExitedBeforeStart || m.VideoStarted {
+		t.Fatalf("expected exit before video start, got %+v", m)
+	}
+	if m.RebufferCount != 0 || m.RebufferTime != 0 {
+		t.Fatalf("startup buffering must not count as rebuffering, got %+v", m)
+	}
+}
+
+func TestApplyEventIgnoresSeekBuffering(t *testing.T) {
+	t0 := time.Now()
+	m := &models.SessionQoE{SessionID: "s3"}
+
+	for _, e := range []models.QoEEvent{
+		qoeEvent("s3", "play", t0, 1000000),
+		qoeEvent("s3", "seek", t0.Add(time.Second), 0),
+		qoeEvent("s3", "buffering", t0.Add(1100*time.Millisecond), 0),
+		qoeEvent("s3", "play", t0.Add(3*time.Second), 0),
+	} {
+		ApplyEvent(m, &e)
+	}
+
+	if m.RebufferCount != 0 || m.RebufferTime != 0 {
+		t.Fatalf("seek buffering must not count as rebuffering, got %+v", m)
+	}
+}
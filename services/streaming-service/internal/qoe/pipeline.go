@@ -0,0 +1,188 @@
+package qoe
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/streamverse/common-go/logger"
+	"github.com/streamverse/streaming-service/models"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrBackpressure is returned when the pipeline buffer stays full; clients should retry later
+	ErrBackpressure = errors.New("QoE pipeline is saturated")
+	// ErrPipelineClosed is returned for events submitted after shutdown
+	ErrPipelineClosed = errors.New("QoE pipeline is closed")
+)
+
+const (
+	sinkRetries  = 3
+	sinkBackoff  = 100 * time.Millisecond
+	flushTimeout = 10 * time.Second
+)
+
+// Config controls batching and backpressure of the QoE pipeline
+type Config struct {
+	BufferSize     int           // events held in memory before submitters are pushed back
+	BatchSize      int           // events written to the sink at once
+	FlushInterval  time.Duration // longest time an event waits for a batch to fill
+	EnqueueTimeout time.Duration // how long Submit waits for buffer space
+}
+
+// DefaultConfig returns the production pipeline settings
+func DefaultConfig() Config {
+	return Config{
+		BufferSize:     10000,
+		BatchSize:      500,
+		FlushInterval:  2 * time.Second,
+		EnqueueTimeout: 100 * time.Millisecond,
+	}
+}
+
+// Pipeline buffers QoE events, writes them to a sink in batches and feeds the
+// aggregator. A full buffer is reported to submitters as ErrBackpressure rather
+// than growing without bound.
+type Pipeline struct {
+	sink       Sink
+	aggregator *Aggregator
+	config     Config
+	logger     *logger.Logger
+
+	events    chan models.QoEEvent
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// NewPipeline creates a new QoE pipeline; aggregator may be nil
+func NewPipeline(sink Sink, aggregator *Aggregator, config Config, logger *logger.Logger) *Pipeline {
+	return &Pipeline{
+		sink:       sink,
+		aggregator: aggregator,
+		config:     config,
+		logger:     logger,
+		events:     make(chan models.QoEEvent, config.BufferSize),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start runs the batching loop in the background
+func (p *Pipeline) Start() {
+	p.startOnce.Do(func() {
+		go p.run()
+	})
+}
+
+// Submit enqueues an event, waiting up to EnqueueTimeout for buffer space
+func (p *Pipeline) Submit(ctx context.Context, event *models.QoEEvent) error {
+	select {
+	case <-p.stop:
+		return ErrPipelineClosed
+	default:
+	}
+
+	select {
+	case p.events <- *event:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(p.config.EnqueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case p.events <- *event:
+		return nil
+	case <-timer.C:
+		return ErrBackpressure
+	case <-p.stop:
+		return ErrPipelineClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting events, flushes what is buffered and closes the sink
+func (p *Pipeline) Close(ctx context.Context) error {
+	p.Start()
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return p.sink.Close()
+}
+
+func (p *Pipeline) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.QoEEvent, 0, p.config.BatchSize)
+	for {
+		select {
+		case event := <-p.events:
+			batch = append(batch, event)
+			if len(batch) >= p.config.BatchSize {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-p.stop:
+			// Drain whatever was accepted before shutdown
+			for {
+				select {
+				case event := <-p.events:
+					batch = append(batch, event)
+					if len(batch) >= p.config.BatchSize {
+						p.flush(batch)
+						batch = batch[:0]
+					}
+				default:
+					if len(batch) > 0 {
+						p.flush(batch)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes a batch to the sink, retrying with backoff, then aggregates it.
+// While a flush is in progress the buffer fills up, which is what pushes back on Submit.
+func (p *Pipeline) flush(batch []models.QoEEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	var err error
+	for attempt := 0; attempt < sinkRetries; attempt++ {
+		if err = p.sink.Write(ctx, batch); err == nil {
+			break
+		}
+		time.Sleep(sinkBackoff << attempt)
+	}
+	if err != nil {
+		p.logger.Error("Failed to write QoE batch, dropping events", zap.Int("events", len(batch)), zap.Error(err))
+	}
+
+	if p.aggregator != nil {
+		if err := p.aggregator.Apply(ctx, batch); err != nil {
+			p.logger.Error("Failed to aggregate QoE batch", zap.Error(err))
+		}
+	}
+}
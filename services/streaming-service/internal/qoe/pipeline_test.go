@@ -0,0 +1,81 @@
+package qoe
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streamverse/common-go/logger"
+	"github.com/streamverse/streaming-service/models"
+)
+
+type recordingSink struct {
+	mu      sync.Mutex
+	batches [][]models.QoEEvent
+	block   chan struct{}
+}
+
+func (s *recordingSink) Write(ctx context.Context, events []models.QoEEvent) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]models.QoEEvent(nil), events...))
+	return nil
+}
+
+func (s *recordingSink) Close() error { return nil }
+
+func (s *recordingSink) count() (batches, events int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.batches {
+		events += len(b)
+	}
+	return len(s.batches), events
+}
+
+func TestPipelineBatchesAndFlushesOnClose(t *testing.T) {
+	sink := &recordingSink{}
+	pipeline := NewPipeline(sink, nil, Config{BufferSize: 100, BatchSize: 4, FlushInterval: time.Hour, EnqueueTimeout: time.Second}, logger.NewDefault())
+	pipeline.Start()
+
+	for i := 0; i < 10; i++ {
+		if err := pipeline.Submit(context.Background(), &models.QoEEvent{SessionID: "s1", Event: "play"}); err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+	}
+	if err := pipeline.Close(context.Background()); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	batches, events := sink.count()
+	if events != 10 || batches != 3 {
+		t.Fatalf("expected 10 events in 3 batches, got %d in %d", events, batches)
+	}
+	if err := pipeline.Submit(context.Background(), &models.QoEEvent{}); !errors.Is(err, ErrPipelineClosed) {
+		t.Fatalf("expected ErrPipelineClosed after close, got %v", err)
+	}
+}
+
+func TestPipelineAppliesBackpressureWhenSinkStalls(t *testing.T) {
+	sink := &recordingSink{block: make(chan struct{})}
+	pipeline := NewPipeline(sink, nil, Config{BufferSize: 2, BatchSize: 1, FlushInterval: time.Hour, EnqueueTimeout: 10 * time.Millisecond}, logger.NewDefault())
+	pipeline.Start()
+
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = pipeline.Submit(context.Background(), &models.QoEEvent{SessionID: "s1", Event: "play"})
+	}
+	if !errors.Is(err, ErrBackpressure) {
+		t.Fatalf("expected ErrBackpressure while the sink is stalled, got %v", err)
+	}
+
+	close(sink.block)
+	if err := pipeline.Close(context.Background()); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+}
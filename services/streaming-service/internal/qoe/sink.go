@@ -0,0 +1,119 @@
+package qoe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/streamverse/streaming-service/models"
+)
+
+// Sink is a durable destination for batches of QoE events
+type Sink interface {
+	Write(ctx context.Context, events []models.QoEEvent) error
+	Close() error
+}
+
+// KafkaSink publishes QoE events to a Kafka topic, keyed by session so a
+// session's events stay ordered within a partition
+type KafkaSink struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaSink creates a sink producing to topic on the given brokers
+func NewKafkaSink(brokers []string, topic string) *KafkaSink {
+	return &KafkaSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireOne,
+			BatchSize:    1000,
+			Compression:  kafka.Snappy,
+		},
+	}
+}
+
+// Write produces one message per event
+func (s *KafkaSink) Write(ctx context.Context, events []models.QoEEvent) error {
+	messages := make([]kafka.Message, 0, len(events))
+	for _, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode QoE event: %w", err)
+		}
+		key := event.SessionID
+		if key == "" {
+			key = event.UserID
+		}
+		messages = append(messages, kafka.Message{Key: []byte(key), Value: value, Time: event.Timestamp})
+	}
+	return s.writer.WriteMessages(ctx, messages...)
+}
+
+// Close flushes and closes the producer
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
+
+// FileSink appends QoE events as JSON lines to a local file, for development
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink opens (or creates) the file at path for appending
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open QoE file: %w", err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+// Write appends one JSON line per event
+func (s *FileSink) Write(ctx context.Context, events []models.QoEEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	encoder := json.NewEncoder(s.file)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to write QoE event: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// EventStore persists raw QoE events
+type EventStore interface {
+	InsertQoEEvents(ctx context.Context, events []models.QoEEvent) error
+}
+
+// MongoSink stores QoE events in MongoDB, for development
+type MongoSink struct {
+	store EventStore
+}
+
+// NewMongoSink creates a sink writing to store
+func NewMongoSink(store EventStore) *MongoSink {
+	return &MongoSink{store: store}
+}
+
+// Write inserts the batch
+func (s *MongoSink) Write(ctx context.Context, events []models.QoEEvent) error {
+	return s.store.InsertQoEEvents(ctx, events)
+}
+
+// Close is a no-op; the database connection is owned by main
+func (s *MongoSink) Close() error {
+	return nil
+}
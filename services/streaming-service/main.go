@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -14,11 +16,25 @@ import (
 	"github.com/streamverse/common-go/database"
 	"github.com/streamverse/common-go/logger"
 	"github.com/streamverse/common-go/middleware"
+	streamingpb "github.com/streamverse/proto/gen/go/streaming"
 	streamingHandler "github.com/streamverse/streaming-service/handlers"
+	"github.com/streamverse/streaming-service/internal/cdn"
 	"github.com/streamverse/streaming-service/internal/clients/content"
 	"github.com/streamverse/streaming-service/internal/clients/payment"
+	"github.com/streamverse/streaming-service/internal/clients/policy"
+	"github.com/streamverse/streaming-service/internal/clients/user"
+	"github.com/streamverse/streaming-service/internal/drm"
+	"github.com/streamverse/streaming-service/internal/geoip"
+	"github.com/streamverse/streaming-service/internal/grpcserver"
+	"github.com/streamverse/streaming-service/internal/license"
+	"github.com/streamverse/streaming-service/internal/playback"
+	"github.com/streamverse/streaming-service/internal/qoe"
 	"github.com/streamverse/streaming-service/repository"
 	"github.com/streamverse/streaming-service/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 func main() {
@@ -43,8 +59,12 @@ func main() {
 	}
 	defer db.Disconnect(context.Background())
 
-	// Initialize repository
+	// Initialize repositories
 	streamingRepo := repository.NewStreamingRepository(db)
+	renditionRepo := repository.NewRenditionRepository(db)
+	qoeRepo := repository.NewQoERepository(db)
+	leaseRepo := repository.NewStreamLeaseRepository(db)
+	downloadRepo := repository.NewDownloadRepository(db)
 
 	// Initialize gRPC clients
 	contentClient, err := content.NewClient(cfg.ContentServiceAddr)
@@ -68,43 +88,197 @@ func main() {
 	)
 	defer redisClient.Close()
 
+	// Initialize QoE pipeline (QOE_SINK is kafka, file or mongo)
+	var qoeSink qoe.Sink
+	switch os.Getenv("QOE_SINK") {
+	case "kafka":
+		topic := os.Getenv("QOE_KAFKA_TOPIC")
+		if topic == "" {
+			topic = "qoe-events"
+		}
+		qoeSink = qoe.NewKafkaSink(strings.Split(os.Getenv("KAFKA_BROKERS"), ","), topic)
+	case "file":
+		path := os.Getenv("QOE_FILE_PATH")
+		if path == "" {
+			path = "qoe-events.jsonl"
+		}
+		fileSink, err := qoe.NewFileSink(path)
+		if err != nil {
+			log.Fatal("Failed to open QoE file sink", logger.Error(err))
+		}
+		qoeSink = fileSink
+	default:
+		qoeSink = qoe.NewMongoSink(qoeRepo)
+	}
+	qoePipeline := qoe.NewPipeline(qoeSink, qoe.NewAggregator(qoeRepo), qoe.DefaultConfig(), log)
+	qoePipeline.Start()
+
+	// Playback events keep watch history in the user service in sync
+	var playbackPublisher playback.Publisher = playback.NopPublisher{}
+	if brokers := os.Getenv("KAFKA_BROKERS"); brokers != "" {
+		topic := os.Getenv("PLAYBACK_EVENTS_TOPIC")
+		if topic == "" {
+			topic = "playback-events"
+		}
+		playbackPublisher = playback.NewKafkaPublisher(strings.Split(brokers, ","), topic)
+	}
+
+	// GeoIP database for territory restrictions (GEOIP_DB_PATH, MaxMind .mmdb format)
+	var geoLocator geoip.Locator
+	if path := os.Getenv("GEOIP_DB_PATH"); path != "" {
+		mmdb, err := geoip.OpenMMDB(path)
+		if err != nil {
+			log.Fatal("Failed to open GeoIP database", logger.Error(err))
+		}
+		defer mmdb.Close()
+		geoLocator = mmdb
+	} else {
+		log.Warn("GEOIP_DB_PATH not set, territory restrictions are not enforced")
+	}
+
+	// CDN providers, URL signing and steering, per tenant or content (CDN_SIGNING_CONFIG, JSON)
+	var cdnProviders *cdn.Providers
+	if path := os.Getenv("CDN_SIGNING_CONFIG"); path != "" {
+		cdnConfig, err := cdn.LoadConfig(path)
+		if err != nil {
+			log.Fatal("Failed to load CDN signing config", logger.Error(err))
+		}
+		cdnProviders, err = cdn.NewProviders(cdnConfig)
+		if err != nil {
+			log.Fatal("Invalid CDN signing config", logger.Error(err))
+		}
+	} else {
+		log.Warn("CDN_SIGNING_CONFIG not set, segment URLs are not signed")
+	}
+
+	// DRM content keys, encrypted at rest (DRM_KEY_ENCRYPTION_KEY, base64 32-byte key)
+	var drmKeys *drm.Manager
+	if encoded := os.Getenv("DRM_KEY_ENCRYPTION_KEY"); encoded != "" {
+		kek, err := drm.DecodeKEK(encoded)
+		if err != nil {
+			log.Fatal("Invalid DRM key encryption key", logger.Error(err))
+		}
+		drmKeys, err = drm.NewManager(repository.NewContentKeyRepository(db), kek)
+		if err != nil {
+			log.Fatal("Invalid DRM key encryption key", logger.Error(err))
+		}
+	} else {
+		log.Warn("DRM_KEY_ENCRYPTION_KEY not set, content keys are kept in memory and lost on restart")
+		drmKeys = drm.NewLocalManager()
+	}
+
 	// Initialize service
 	streamingService := service.NewStreamingService(
 		streamingRepo,
+		renditionRepo,
+		qoeRepo,
+		leaseRepo,
 		contentClient,
 		paymentClient,
+		user.NewClient(os.Getenv("USER_SERVICE_URL")),
 		redisClient,
+		qoePipeline,
+		playbackPublisher,
+		geoLocator,
+		cdnProviders,
+		drmKeys,
+		os.Getenv("STREAM_LIMIT_POLICY"), // "reject" (default) or "kick_oldest"
+		service.TokenBindingConfigFromEnv(),
+		os.Getenv("API_BASE_URL"),
 		cfg.JWT.SecretKey,
 	)
 
+	// End sessions whose players stopped sending heartbeats
+	reaperCtx, stopReaper := context.WithCancel(context.Background())
+	defer stopReaper()
+	sessionReaper := service.NewSessionReaper(streamingService, log, service.SessionReaperConfigFromEnv())
+	go sessionReaper.Start(reaperCtx)
+
+	// Offline downloads get persistent licenses through the license proxy
+	licenseConfig := license.ConfigFromEnv()
+	downloadManager := service.NewDownloadManager(streamingService, downloadRepo, licenseConfig)
+
+	// DRM license proxy (DRM_LICENSE_UPSTREAM is "http" for the vendor license server or "fake")
+	var licenseUpstream license.Upstream
+	if os.Getenv("DRM_LICENSE_UPSTREAM") == "fake" {
+		log.Warn("DRM_LICENSE_UPSTREAM is fake, licenses are placeholders")
+		licenseUpstream = &license.FakeUpstream{}
+	} else {
+		licenseUpstream = license.HTTPUpstreamFromEnv()
+	}
+	licenseProxy := service.NewLicenseProxy(
+		streamingService,
+		payment.NewEntitlementsClient(os.Getenv("PAYMENT_SERVICE_URL")),
+		policy.NewClient(os.Getenv("POLICY_SERVICE_URL")),
+		licenseUpstream,
+		licenseConfig,
+		downloadManager,
+	)
+
 	// Initialize handlers
+	licenseHandler := streamingHandler.NewLicenseHandler(licenseProxy, log)
+	downloadHandler := streamingHandler.NewDownloadHandler(downloadManager, log)
 	streamingHandler := streamingHandler.NewStreamingHandler(streamingService, log)
 
 	// Setup router
 	router := gin.Default()
+	// Geo restrictions and token binding rely on the client IP
+	if err := middleware.TrustClientIPs(router, cfg.Server.TrustedProxies, cfg.Server.TrustedPlatform); err != nil {
+		log.Fatal("Invalid trusted proxies", logger.Error(err))
+	}
 	router.Use(middleware.CORS())
-	router.Use(middleware.AuthMiddleware(cfg.JWT.SecretKey))
+
+	// Playback routes are fetched by native HLS/DASH players and CDNs, which cannot
+	// send the viewer's bearer token; the manifest token in the path authorizes them
+	playback := router.Group("/streaming")
+	{
+		// Manifest endpoints with token (:token is "<token>.m3u8" or "<token>.mpd")
+		playback.GET("/manifest/:content_id/:token", streamingHandler.GetManifestFile)
+		playback.GET("/manifest/:content_id/:token/:rendition", streamingHandler.GetHLSMediaPlaylist)
+		// Playback proxy: the token is a path segment so relative child URIs keep it
+		playback.GET("/play/:token/:file", streamingHandler.GetPlaybackFile)
+		// Content steering server for players switching CDNs mid-session
+		playback.GET("/steering/:content_id/:token", streamingHandler.GetSteeringManifest)
+		// Live channels: LL-HLS / LL-DASH output (:file is master.m3u8, manifest.mpd or <rendition>.m3u8)
+		playback.GET("/live/:channel_id/:token/:file", streamingHandler.GetLiveManifest)
+	}
+
+	authenticated := router.Group("/", middleware.AuthMiddleware(cfg.JWT.SecretKey))
 
 	// Health check
-	router.GET("/health", func(c *gin.Context) {
+	authenticated.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
 
 	// Streaming routes
-	api := router.Group("/streaming")
+	api := authenticated.Group("/streaming")
 	{
-		// Manifest endpoints with token
-		api.GET("/manifest/:content_id/:token.m3u8", streamingHandler.GetHLSManifest)
-		api.GET("/manifest/:content_id/:token.mpd", streamingHandler.GetDASHManifest)
 		// Token generation
 		api.POST("/token", middleware.AuthMiddleware(cfg.JWT.SecretKey), streamingHandler.GenerateToken)
+		api.POST("/tokens/revoke", middleware.RequireRole("support"), streamingHandler.RevokeToken)
 		// QoE metrics
 		api.POST("/qoe", middleware.AuthMiddleware(cfg.JWT.SecretKey), streamingHandler.SubmitQoE)
+		api.GET("/qoe/content/:content_id", middleware.RequireRole("admin"), streamingHandler.GetContentQoE)
+		// DRM licenses (:system is widevine, playready or fairplay)
+		api.POST("/license/:system", licenseHandler.AcquireLicense)
+		// Offline downloads
+		api.POST("/downloads", downloadHandler.RequestDownload)
+		api.GET("/downloads", downloadHandler.ListDownloads)
+		api.DELETE("/downloads/:download_id", downloadHandler.DeleteDownload)
+		api.DELETE("/devices/:device_id/downloads", downloadHandler.RevokeDevice)
+		// Content keys for packagers
+		api.GET("/drm/:content_id/cpix", middleware.RequireRole("packager"), streamingHandler.GetCPIX)
 		// Session management
 		api.POST("/sessions", middleware.AuthMiddleware(cfg.JWT.SecretKey), streamingHandler.CreateSession)
 		api.PUT("/sessions/:sessionId/position", middleware.AuthMiddleware(cfg.JWT.SecretKey), streamingHandler.UpdatePosition)
 		api.POST("/sessions/:sessionId/heartbeat", middleware.AuthMiddleware(cfg.JWT.SecretKey), streamingHandler.Heartbeat)
 		api.DELETE("/sessions/:sessionId", middleware.AuthMiddleware(cfg.JWT.SecretKey), streamingHandler.EndSession)
+		api.GET("/sessions/:sessionId/qoe", streamingHandler.GetSessionQoE)
+		// Live packager updates
+		api.PUT("/live/:channel_id", middleware.RequireRole("packager"), streamingHandler.StartLiveStream)
+		api.DELETE("/live/:channel_id", middleware.RequireRole("packager"), streamingHandler.StopLiveStream)
+		api.POST("/live/:channel_id/:rendition/parts", middleware.RequireRole("packager"), streamingHandler.AppendLivePart)
+		api.POST("/live/:channel_id/:rendition/segments", middleware.RequireRole("packager"), streamingHandler.CompleteLiveSegment)
 	}
 
 	// Start server
@@ -123,6 +297,46 @@ func main() {
 
 	log.Info("Streaming service started", logger.String("address", srv.Addr))
 
+	// gRPC API for backend-for-frontend services, sharing the service layer with HTTP
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "50054"
+	}
+	// Callers name the user they act for, so the API must only be reachable by
+	// internal services: on loopback by default, and behind the service token
+	// when bound to another interface
+	grpcHost := os.Getenv("GRPC_HOST")
+	if grpcHost == "" {
+		grpcHost = "127.0.0.1"
+	}
+	var grpcOptions []grpc.ServerOption
+	if serviceToken := os.Getenv("GRPC_SERVICE_TOKEN"); serviceToken != "" {
+		grpcOptions = append(grpcOptions, grpc.UnaryInterceptor(grpcserver.ServiceTokenInterceptor(serviceToken)))
+	} else if ip := net.ParseIP(grpcHost); grpcHost != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		log.Fatal("GRPC_SERVICE_TOKEN is required when gRPC listens beyond loopback", logger.String("host", grpcHost))
+	}
+	grpcAddr := net.JoinHostPort(grpcHost, grpcPort)
+	grpcListener, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		log.Fatal("Failed to listen for gRPC", logger.Error(err))
+	}
+	grpcServer := grpc.NewServer(grpcOptions...)
+	streamingpb.RegisterStreamingServiceServer(grpcServer, grpcserver.NewStreamingServer(streamingService))
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(streamingpb.StreamingService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	if cfg.IsDevelopment() {
+		reflection.Register(grpcServer)
+	}
+
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatal("Failed to start gRPC server", logger.Error(err))
+		}
+	}()
+
+	log.Info("Streaming gRPC server started", logger.String("address", grpcAddr))
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -132,9 +346,22 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// Health checks report NOT_SERVING while in-flight RPCs drain
+	healthServer.Shutdown()
+	grpcServer.GracefulStop()
+
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatal("Server forced to shutdown", logger.Error(err))
 	}
 
+	// Flush buffered QoE events once neither requests nor the reaper can submit them
+	stopReaper()
+	if err := qoePipeline.Close(ctx); err != nil {
+		log.Error("Failed to flush QoE pipeline", logger.Error(err))
+	}
+	if err := playbackPublisher.Close(); err != nil {
+		log.Error("Failed to close playback event publisher", logger.Error(err))
+	}
+
 	log.Info("Server exited")
 }
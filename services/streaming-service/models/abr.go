@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ThroughputEstimate is the bandwidth history kept per user and device
+type ThroughputEstimate struct {
+	Bandwidth   float64   `json:"bandwidth"` // smoothed throughput, bps
+	Samples     int       `json:"samples"`   // throughput samples folded into Bandwidth
+	Rebuffers   float64   `json:"rebuffers"` // decayed count of buffering/error events
+	LastUpdated time.Time `json:"lastUpdated"`
+}
+
+// ABRProfile describes how the bitrate ladder is capped and which variant playback starts on
+type ABRProfile struct {
+	Profile        string `json:"profile"`        // initial quality for the device class, e.g. "1080p"
+	MaxHeight      int    `json:"maxHeight"`      // tallest variant worth advertising to the device
+	MaxBandwidth   int    `json:"maxBandwidth"`   // highest variant bandwidth to advertise, 0 when unknown
+	StartBandwidth int    `json:"startBandwidth"` // bandwidth budget of the initial variant, 0 when unknown
+}
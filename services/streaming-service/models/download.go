@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Download is a title stored for offline playback on one device. It counts against
+// the account's download quotas while active.
+type Download struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID           string             `bson:"user_id" json:"userId"`
+	ContentID        string             `bson:"content_id" json:"contentId"`
+	DeviceID         string             `bson:"device_id" json:"deviceId"`
+	Quality          string             `bson:"quality" json:"quality"` // e.g. "720p"
+	MaxHeight        int                `bson:"max_height" json:"maxHeight"`
+	State            string             `bson:"state" json:"state"` // see DownloadState* constants
+	LicenseExpiresAt time.Time          `bson:"license_expires_at" json:"licenseExpiresAt"`
+	RevokeReason     string             `bson:"revoke_reason,omitempty" json:"revokeReason,omitempty"`
+	RevokedAt        *time.Time         `bson:"revoked_at,omitempty" json:"revokedAt,omitempty"`
+	CreatedAt        time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt        time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// Download lifecycle states. Devices delete revoked downloads on their next sync.
+const (
+	DownloadStateActive  = "active"
+	DownloadStateRevoked = "revoked"
+)
+
+// Reasons a download was revoked
+const (
+	DownloadRevokedDeviceRemoved = "device_removed"
+)
+
+// DownloadTrack is one rendition a device fetches for a download
+type DownloadTrack struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"` // "video", "audio" or "subtitle"
+	Language      string `json:"language,omitempty"`
+	Resolution    string `json:"resolution,omitempty"`
+	Bandwidth     int    `json:"bandwidth,omitempty"`
+	EstimatedSize int64  `json:"estimatedSize,omitempty"` // bytes
+}
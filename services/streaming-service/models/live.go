@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// LiveStream is the configuration a live packager reports when a channel goes on air
+type LiveStream struct {
+	ChannelID             string      `json:"channelId"`
+	TargetDuration        float64     `json:"targetDuration" binding:"required"` // segment target, seconds
+	PartTarget            float64     `json:"partTarget" binding:"required"`     // LL-HLS part / CMAF chunk target, seconds
+	AvailabilityStartTime time.Time   `json:"availabilityStartTime"`             // wall clock time of segment StartNumber
+	WindowSegments        int         `json:"windowSegments"`                    // segments kept in the sliding window
+	PartTemplate          string      `json:"partTemplate"`                      // next-part URI for preload hints, e.g. "segment_$Number$.$Part$.m4s"
+	Renditions            []Rendition `json:"renditions" binding:"required,min=1"`
+}
+
+// LivePart is a partial segment (CMAF chunk) of a live rendition
+type LivePart struct {
+	MSN         int64   `json:"msn"` // media sequence number of the parent segment
+	URI         string  `json:"uri" binding:"required"`
+	Duration    float64 `json:"duration" binding:"required"`
+	Independent bool    `json:"independent"`
+}
+
+// LiveSegment is a completed segment of a live rendition
+type LiveSegment struct {
+	MSN             int64      `json:"msn"`
+	URI             string     `json:"uri" binding:"required"`
+	Duration        float64    `json:"duration" binding:"required"`
+	ProgramDateTime time.Time  `json:"programDateTime"`
+	Parts           []LivePart `json:"parts,omitempty"`
+}
+
+// RenditionReport carries the last known position of a sibling rendition
+type RenditionReport struct {
+	Name     string
+	LastMSN  int64
+	LastPart int
+}
+
+// LivePlaylist is a snapshot of one rendition's live window
+type LivePlaylist struct {
+	ChannelID      string
+	Rendition      Rendition
+	TargetDuration float64
+	PartTarget     float64
+	Segments       []LiveSegment // completed segments, oldest first
+	PendingParts   []LivePart    // parts of the segment currently being produced
+	NextMSN        int64         // media sequence number of the segment in progress
+	PreloadHintURI string        // URI of the next part the packager will publish
+	Reports        []RenditionReport
+}
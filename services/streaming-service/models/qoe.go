@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// QoE player states tracked between events
+const (
+	QoEStateIdle      = "idle"     // before the first event
+	QoEStateStarting  = "starting" // playback requested, first frame not shown yet
+	QoEStatePlaying   = "playing"
+	QoEStatePaused    = "paused"
+	QoEStateSeeking   = "seeking"
+	QoEStateBuffering = "buffering" // rebuffering after playback started
+	QoEStateEnded     = "ended"
+)
+
+// SessionQoE holds the aggregated quality of experience metrics of one playback session
+type SessionQoE struct {
+	SessionID         string    `bson:"_id" json:"sessionId"`
+	UserID            string    `bson:"user_id" json:"userId"`
+	ContentID         string    `bson:"content_id" json:"contentId"`
+	StartedAt         time.Time `bson:"started_at" json:"startedAt"`
+	StartupTime       int64     `bson:"startup_time" json:"startupTime"`       // milliseconds until first play
+	PlayingTime       int64     `bson:"playing_time" json:"playingTime"`       // milliseconds
+	RebufferTime      int64     `bson:"rebuffer_time" json:"rebufferTime"`     // milliseconds
+	RebufferCount     int       `bson:"rebuffer_count" json:"rebufferCount"`   // buffering events after startup
+	RebufferRatio     float64   `bson:"rebuffer_ratio" json:"rebufferRatio"`   // rebuffer time / (playing + rebuffer time)
+	AverageBitrate    int       `bson:"average_bitrate" json:"averageBitrate"` // bps, weighted by playing time
+	BitrateSwitches   int       `bson:"bitrate_switches" json:"bitrateSwitches"`
+	Errors            int       `bson:"errors" json:"errors"`
+	VideoStarted      bool      `bson:"video_started" json:"videoStarted"`
+	ExitedBeforeStart bool      `bson:"exited_before_start" json:"exitedBeforeStart"` // ended or failed before the first frame
+	State             string    `bson:"state" json:"state"`
+	LastBitrate       int       `bson:"last_bitrate" json:"lastBitrate"`
+	BitrateTime       int64     `bson:"bitrate_time" json:"-"` // bitrate x playing milliseconds, for the weighted average
+	LastEventAt       time.Time `bson:"last_event_at" json:"lastEventAt"`
+	UpdatedAt         time.Time `bson:"updated_at" json:"updatedAt"`
+}
+
+// ContentQoE is a rollup of session QoE metrics for one content item
+type ContentQoE struct {
+	ContentID              string  `bson:"_id" json:"contentId"`
+	Sessions               int     `bson:"sessions" json:"sessions"`
+	AverageStartupTime     float64 `bson:"average_startup_time" json:"averageStartupTime"` // milliseconds, started sessions only
+	AverageRebufferRatio   float64 `bson:"average_rebuffer_ratio" json:"averageRebufferRatio"`
+	AverageBitrate         float64 `bson:"average_bitrate" json:"averageBitrate"`
+	AverageBitrateSwitches float64 `bson:"average_bitrate_switches" json:"averageBitrateSwitches"`
+	ExitBeforeStartRate    float64 `bson:"exit_before_start_rate" json:"exitBeforeStartRate"`
+	ErrorRate              float64 `bson:"error_rate" json:"errorRate"` // share of sessions with at least one error
+}
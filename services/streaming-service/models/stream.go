@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -26,36 +29,246 @@ type StreamSession struct {
 
 // Manifest represents HLS/DASH manifest
 type Manifest struct {
-	ContentID    string    `json:"contentId"`
-	Protocol     string    `json:"protocol"` // hls, dash
-	BaseURL      string    `json:"baseUrl"`
-	Variants     []Variant `json:"variants"`
-	Subtitles    []Subtitle `json:"subtitles"`
-	DRMConfig    *DRMConfig `json:"drmConfig,omitempty"`
+	ContentID   string           `json:"contentId"`
+	Protocol    string           `json:"protocol"` // hls, dash
+	BaseURL     string           `json:"baseUrl"`
+	Variants    []Variant        `json:"variants"`
+	AudioTracks []AudioTrack     `json:"audioTracks,omitempty"`
+	Subtitles   []Subtitle       `json:"subtitles"`
+	DRMConfig   *DRMConfig       `json:"drmConfig,omitempty"`
+	Steering    *ContentSteering `json:"steering,omitempty"`
+	// Trick play: I-frame-only variants for fast forward and thumbnail sprite
+	// streams for scrubbing previews, resolution being the thumbnail tile size
+	IFrameVariants []Variant `json:"iframeVariants,omitempty"`
+	ImageStreams   []Variant `json:"imageStreams,omitempty"`
+	// CEA-608/708 captions carried in the video stream
+	ClosedCaptions []ClosedCaption `json:"closedCaptions,omitempty"`
+}
+
+// ContentSteering lists the CDNs a manifest may be played from and the server
+// players ask which one to use
+type ContentSteering struct {
+	ServerURI string   `json:"serverUri"`
+	Pathways  []string `json:"pathways"` // CDN names, preferred first
+}
+
+// SteeringManifest is the steering server response shared by HLS content steering
+// and DASH content steering
+type SteeringManifest struct {
+	Version                 int      `json:"VERSION"`
+	TTL                     int      `json:"TTL"`
+	ReloadURI               string   `json:"RELOAD-URI,omitempty"`
+	PathwayPriority         []string `json:"PATHWAY-PRIORITY,omitempty"`
+	ServiceLocationPriority []string `json:"SERVICE-LOCATION-PRIORITY,omitempty"`
 }
 
 // Variant represents a quality variant
 type Variant struct {
-	Bandwidth int    `json:"bandwidth"`
-	Resolution string `json:"resolution"`
-	Codec     string `json:"codec"`
-	URL       string `json:"url"`
+	Name             string  `json:"name"`
+	Bandwidth        int     `json:"bandwidth"`        // peak bps
+	AverageBandwidth int     `json:"averageBandwidth"` // bps
+	Resolution       string  `json:"resolution"`       // "1920x1080"
+	Codec            string  `json:"codec"`            // RFC 6381 codecs, e.g. "avc1.640028,mp4a.40.2"
+	FrameRate        float64 `json:"frameRate,omitempty"`
+	URL              string  `json:"url"`
+}
+
+// AudioTrack represents an alternate audio rendition
+type AudioTrack struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Label    string `json:"label"`
+	Kind     string `json:"kind,omitempty"` // main, dub or description
+	Channels int    `json:"channels,omitempty"`
+	URL      string `json:"url"`
+	Default  bool   `json:"default"`
 }
 
 // Subtitle represents subtitle track
 type Subtitle struct {
 	Language string `json:"language"`
 	Label    string `json:"label"`
+	Kind     string `json:"kind,omitempty"` // subtitles, captions or forced
 	URL      string `json:"url"`
 	Default  bool   `json:"default"`
 }
 
+// ClosedCaption is a CEA-608/708 caption service carried in the video stream
+type ClosedCaption struct {
+	Name       string `json:"name"`
+	Language   string `json:"language"`
+	Label      string `json:"label"`
+	InstreamID string `json:"instreamId"` // "CC1"-"CC4" or "SERVICE1"-"SERVICE63"
+	Default    bool   `json:"default"`
+}
+
+// Track kinds, as the track catalog of transcoding-service stores them. Audio
+// is the original mix, a dub or an audio description; text tracks are subtitles,
+// captions for the deaf and hard of hearing (SDH) or forced narratives; captions
+// carried in the video are CEA-608 or CEA-708.
+const (
+	KindMain        = "main"
+	KindDub         = "dub"
+	KindDescription = "description"
+
+	KindSubtitles = "subtitles"
+	KindCaptions  = "captions"
+	KindForced    = "forced"
+
+	KindCEA608 = "cea-608"
+	KindCEA708 = "cea-708"
+)
+
+// Rendition is one packaged output of a transcoding job. Documents live in the
+// shared "renditions" collection and are written by transcoding-service when a
+// job completes.
+type Rendition struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ContentID        string             `bson:"content_id" json:"contentId"`
+	Name             string             `bson:"name" json:"name"`                     // unique per content: "1080p", "audio-en", "subs-en"
+	Type             string             `bson:"type" json:"type"`                     // video, audio, subtitle, caption, iframe, thumbnail
+	Kind             string             `bson:"kind,omitempty" json:"kind,omitempty"` // audio, subtitle and caption kinds above
+	Bandwidth        int                `bson:"bandwidth" json:"bandwidth"`           // peak bps
+	AverageBandwidth int                `bson:"average_bandwidth" json:"averageBandwidth"`
+	Width            int                `bson:"width,omitempty" json:"width,omitempty"`            // tile width for thumbnails
+	Height           int                `bson:"height,omitempty" json:"height,omitempty"`          // tile height for thumbnails
+	TileLayout       string             `bson:"tile_layout,omitempty" json:"tileLayout,omitempty"` // thumbnails: tiles per sprite, "<columns>x<rows>"
+	Codecs           string             `bson:"codecs" json:"codecs"`                              // RFC 6381
+	FrameRate        float64            `bson:"frame_rate,omitempty" json:"frameRate,omitempty"`
+	Language         string             `bson:"language,omitempty" json:"language,omitempty"`
+	Label            string             `bson:"label,omitempty" json:"label,omitempty"`
+	Channels         int                `bson:"channels,omitempty" json:"channels,omitempty"`
+	Default          bool               `bson:"default" json:"default"`
+	InstreamID       string             `bson:"instream_id,omitempty" json:"instreamId,omitempty"`           // captions: "CC1"-"CC4" or "SERVICE1"-"SERVICE63"
+	SidecarURI       string             `bson:"sidecar_uri,omitempty" json:"sidecarUri,omitempty"`           // subtitles: the whole track as one WebVTT file
+	InitSegment      string             `bson:"init_segment,omitempty" json:"initSegment,omitempty"`         // fMP4 init, relative to the rendition path
+	SegmentTemplate  string             `bson:"segment_template,omitempty" json:"segmentTemplate,omitempty"` // DASH media template, e.g. "segment_$Number$.m4s"
+	StartNumber      int                `bson:"start_number" json:"startNumber"`                             // $Number$ of the first segment
+	Timescale        int                `bson:"timescale,omitempty" json:"timescale,omitempty"`              // ticks per second, defaults to 1000
+	Segments         []Segment          `bson:"segments" json:"segments"`
+	CreatedAt        time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+// Segment is a single media segment of a rendition
+type Segment struct {
+	URI      string  `bson:"uri" json:"uri"`           // relative to the rendition path
+	Duration float64 `bson:"duration" json:"duration"` // seconds; for thumbnails, the time covered by the sprite
+}
+
+// Resolution returns the rendition resolution in "WxH" form
+func (r *Rendition) Resolution() string {
+	if r.Width == 0 || r.Height == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", r.Width, r.Height)
+}
+
+// Tiles returns the columns and rows of a thumbnail sprite, 1x1 when unset
+func (r *Rendition) Tiles() (columns, rows int) {
+	c, rw, ok := strings.Cut(r.TileLayout, "x")
+	columns, _ = strconv.Atoi(c)
+	rows, _ = strconv.Atoi(rw)
+	if !ok || columns <= 0 || rows <= 0 {
+		return 1, 1
+	}
+	return columns, rows
+}
+
+// TileDuration returns the time each thumbnail tile covers. Every sprite but the
+// last is full, so the first one gives the interval.
+func (r *Rendition) TileDuration() float64 {
+	if len(r.Segments) == 0 {
+		return 0
+	}
+	columns, rows := r.Tiles()
+	return r.Segments[0].Duration / float64(columns*rows)
+}
+
+// Key track types. Video is split by resolution so a key leaked from a
+// low-security device never unlocks higher qualities. HD ends at 720p, the
+// tallest video software DRM may play, so its key never unlocks 1080p.
+const (
+	TrackTypeSD    = "SD"  // up to 576p
+	TrackTypeHD    = "HD"  // up to 720p
+	TrackTypeFHD   = "FHD" // up to 1080p
+	TrackTypeUHD   = "UHD"
+	TrackTypeAudio = "AUDIO"
+)
+
+// TrackTypes lists every key track type, in CPIX order
+var TrackTypes = []string{TrackTypeSD, TrackTypeHD, TrackTypeFHD, TrackTypeUHD, TrackTypeAudio}
+
+// TrackType returns the key track type protecting the rendition, or "" for
+// subtitles, captions and thumbnails, which are not encrypted. I-frame
+// renditions are cut from the video encode and share its key.
+func (r *Rendition) TrackType() string {
+	switch r.Type {
+	case "audio":
+		return TrackTypeAudio
+	case "video", "iframe":
+		switch {
+		case r.Height <= 576:
+			return TrackTypeSD
+		case r.Height <= 720:
+			return TrackTypeHD
+		case r.Height <= 1080:
+			return TrackTypeFHD
+		default:
+			return TrackTypeUHD
+		}
+	default:
+		return ""
+	}
+}
+
+// TotalDuration returns the sum of all segment durations in seconds
+func (r *Rendition) TotalDuration() float64 {
+	var total float64
+	for _, segment := range r.Segments {
+		total += segment.Duration
+	}
+	return total
+}
+
 // DRMConfig represents DRM configuration
 type DRMConfig struct {
-	Type        string   `json:"type"` // widevine, fairplay, playready
-	LicenseURL  string   `json:"licenseUrl"`
-	Certificate string   `json:"certificate,omitempty"`
-	KeyIDs      []string `json:"keyIds,omitempty"`
+	Type        string            `json:"type"` // widevine, fairplay, playready
+	LicenseURL  string            `json:"licenseUrl"`
+	Certificate string            `json:"certificate,omitempty"`
+	Scheme      string            `json:"scheme,omitempty"` // common encryption scheme, "cenc" (default) or "cbcs"
+	KeyIDs      []string          `json:"keyIds,omitempty"` // UUID form, first entry is the default KID
+	PSSH        map[string]string `json:"pssh,omitempty"`   // base64 pssh box per DRM system
+	TrackKeys   []TrackKey        `json:"trackKeys,omitempty"`
+}
+
+// TrackKey is the key protecting one track type. When a DRMConfig lists track
+// keys they replace KeyIDs and PSSH for the renditions of that type.
+type TrackKey struct {
+	TrackType string            `json:"trackType"`
+	KeyID     string            `json:"keyId"` // UUID form
+	PSSH      map[string]string `json:"pssh,omitempty"`
+}
+
+// KeyFor returns the key of a track type, or nil when the config has none
+func (d *DRMConfig) KeyFor(trackType string) *TrackKey {
+	for i := range d.TrackKeys {
+		if d.TrackKeys[i].TrackType == trackType {
+			return &d.TrackKeys[i]
+		}
+	}
+	return nil
+}
+
+// ContentKey is a stored content key. The key itself is encrypted with the
+// service's key encryption key and never leaves the service in clear except in
+// CPIX documents for packagers.
+type ContentKey struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ContentID    string             `bson:"content_id" json:"contentId"`
+	TrackType    string             `bson:"track_type" json:"trackType"`
+	KeyID        string             `bson:"key_id" json:"keyId"` // UUID form
+	EncryptedKey []byte             `bson:"encrypted_key" json:"-"`
+	CreatedAt    time.Time          `bson:"created_at" json:"createdAt"`
 }
 
 // PlaybackEvent represents an analytics event
@@ -67,4 +280,3 @@ type PlaybackEvent struct {
 	Quality     string    `json:"quality,omitempty"`
 	Error       string    `json:"error,omitempty"`
 }
-
@@ -8,77 +8,159 @@ import (
 
 // PlaybackSession represents a video playback session
 type PlaybackSession struct {
-	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID          string             `bson:"user_id" json:"userId"`
-	ContentID       string             `bson:"content_id" json:"contentId"`
-	Position        int64              `bson:"position" json:"position"` // milliseconds
-	Duration        int64              `bson:"duration" json:"duration"` // milliseconds
-	Quality         string             `bson:"quality" json:"quality"`
-	Bandwidth       int64              `bson:"bandwidth" json:"bandwidth"` // bps
-	LastHeartbeat   time.Time          `bson:"last_heartbeat" json:"lastHeartbeat"`
-	DeviceID        string             `bson:"device_id" json:"deviceId"`
-	StreamURL       string             `bson:"stream_url" json:"streamUrl"`
-	DRMType         string             `bson:"drm_type,omitempty" json:"drmType,omitempty"`
-	DRMLicenseURL   string             `bson:"drm_license_url,omitempty" json:"drmLicenseUrl,omitempty"`
-	CreatedAt       time.Time          `bson:"created_at" json:"createdAt"`
-	UpdatedAt       time.Time          `bson:"updated_at" json:"updatedAt"`
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        string             `bson:"user_id" json:"userId"`
+	ProfileID     string             `bson:"profile_id,omitempty" json:"profileId,omitempty"`
+	ContentID     string             `bson:"content_id" json:"contentId"`
+	Position      int64              `bson:"position" json:"position"` // milliseconds
+	Duration      int64              `bson:"duration" json:"duration"` // milliseconds
+	Quality       string             `bson:"quality" json:"quality"`
+	Bandwidth     int64              `bson:"bandwidth" json:"bandwidth"` // bps
+	LastHeartbeat time.Time          `bson:"last_heartbeat" json:"lastHeartbeat"`
+	DeviceID      string             `bson:"device_id" json:"deviceId"`
+	DeviceType    string             `bson:"device_type,omitempty" json:"deviceType,omitempty"` // "mobile", "tablet", "desktop", "tv"
+	StreamURL     string             `bson:"stream_url" json:"streamUrl"`
+	DRMType       string             `bson:"drm_type,omitempty" json:"drmType,omitempty"`
+	DRMLicenseURL string             `bson:"drm_license_url,omitempty" json:"drmLicenseUrl,omitempty"`
+	State         string             `bson:"state" json:"state"` // see SessionState* constants
+	EndReason     string             `bson:"end_reason,omitempty" json:"endReason,omitempty"`
+	EndedAt       *time.Time         `bson:"ended_at,omitempty" json:"endedAt,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updatedAt"`
 }
 
+// Playback session lifecycle states
+const (
+	SessionStateStarting = "starting"
+	SessionStatePlaying  = "playing"
+	SessionStatePaused   = "paused"
+	SessionStateStalled  = "stalled" // rebuffering, or heartbeats overdue
+	SessionStateEnded    = "ended"
+)
+
+// Reasons a playback session ended
+const (
+	SessionEndUser    = "user"    // the player ended the session
+	SessionEndTimeout = "timeout" // heartbeats stopped and the reaper ended it
+	SessionEndEvicted = "evicted" // another device took its stream slot
+)
+
 // StreamManifest represents HLS or DASH manifest
 type StreamManifest struct {
-	ContentID       string             `json:"contentId"`
-	ManifestURL     string             `json:"manifestUrl"`
-	Type            string             `json:"type"` // "hls" or "dash"
-	Qualities       []QualityLevel     `json:"qualities"`
-	Subtitles       []SubtitleTrack    `json:"subtitles"`
-	DRMInfo         *DRMInfo           `json:"drmInfo,omitempty"`
+	ContentID   string           `json:"contentId"`
+	ManifestURL string           `json:"manifestUrl"`
+	Type        string           `json:"type"` // "hls" or "dash"
+	Qualities   []QualityLevel   `json:"qualities"`
+	Subtitles   []SubtitleTrack  `json:"subtitles"`
+	DRMInfo     *DRMInfo         `json:"drmInfo,omitempty"`
+	Entitlement *QualityDecision `json:"entitlement,omitempty"`
 }
 
 // QualityLevel represents a quality level
 type QualityLevel struct {
-	ID          string `json:"id"`
-	Resolution  string `json:"resolution"`
-	Bitrate     int    `json:"bitrate"`
-	Codec       string `json:"codec"`
-	URL         string `json:"url"`
+	ID         string `json:"id"`
+	Resolution string `json:"resolution"`
+	Bitrate    int    `json:"bitrate"`
+	Codec      string `json:"codec"`
+	URL        string `json:"url"`
 }
 
 // SubtitleTrack represents a subtitle track
 type SubtitleTrack struct {
 	Language string `json:"language"`
 	Label    string `json:"label"`
+	Kind     string `json:"kind,omitempty"` // subtitles, captions or forced
 	URL      string `json:"url"`
-	Format   string `json:"format"` // "vtt", "srt"
+	Format   string `json:"format"` // "vtt"; uploads are converted to WebVTT
+	Default  bool   `json:"default"`
 }
 
 // DRMInfo represents DRM information
 type DRMInfo struct {
-	Type         string `json:"type"` // "widevine", "fairplay", "playready"
-	LicenseURL   string `json:"licenseUrl"`
+	Type           string `json:"type"` // "widevine", "fairplay", "playready"
+	LicenseURL     string `json:"licenseUrl"`
 	CertificateURL string `json:"certificateUrl,omitempty"`
 }
 
 // StreamingRequest represents a streaming request
 type StreamingRequest struct {
 	ContentID string `json:"contentId" binding:"required"`
-	Quality   string `json:"quality,omitempty"` // "auto", "1080p", "720p", etc.
+	ProfileID string `json:"profileId,omitempty"` // sub-profile whose watch history the session updates
+	Quality   string `json:"quality,omitempty"`   // "auto", "1080p", "720p", etc.
 }
 
 // StreamingToken represents a token for accessing manifests - Issue #14
 type StreamingToken struct {
-	Token      string `json:"token"`
-	ExpiresIn  int    `json:"expiresIn"` // seconds
-	ContentID  string `json:"contentId"`
+	Token       string           `json:"token"`
+	ExpiresIn   int              `json:"expiresIn"` // seconds
+	ContentID   string           `json:"contentId"`
+	SessionID   string           `json:"sessionId,omitempty"`
+	Quality     string           `json:"quality,omitempty"` // highest quality the plan allows
+	Entitlement *QualityDecision `json:"entitlement,omitempty"`
+	HLSURL      string           `json:"hlsUrl,omitempty"` // playback proxy URLs carrying the token in the path
+	DASHURL     string           `json:"dashUrl,omitempty"`
+	// WebVTT thumbnail track for scrubbing previews on web players, when the title has one
+	ThumbnailsURL string `json:"thumbnailsUrl,omitempty"`
+}
+
+// QualityDecision explains the highest quality offered to a viewer, so clients can
+// tell a plan limit ("upgrade for 4K") from a device limit
+type QualityDecision struct {
+	MaxQuality       string `json:"maxQuality"` // e.g. "720p"
+	MaxHeight        int    `json:"maxHeight"`
+	ContentQuality   string `json:"contentQuality,omitempty"` // best rendition of the title
+	PlanQuality      string `json:"planQuality,omitempty"`
+	DeviceSecurity   string `json:"deviceSecurity,omitempty"` // "hardware" or "software" DRM
+	LimitedBy        string `json:"limitedBy,omitempty"`      // "plan" or "device_security"
+	UpgradeAvailable bool   `json:"upgradeAvailable"`
+}
+
+// StreamingClaims are the viewer details bound into a manifest token
+type StreamingClaims struct {
+	TokenID    string // jti, the handle used to revoke the token
+	UserID     string
+	ContentID  string
+	SessionID  string // playback session the token was issued to, if any
+	DownloadID string // offline download the token fetches, if any
+	TenantID   string // selects the CDN segments are signed for
+	Quality    string // highest quality of the viewer's plan, e.g. "720p"
+	MaxHeight  int    // tallest video the viewer is entitled to; 0 is uncapped
+	IP         string
+	DeviceID   string
+	DeviceType string
+	Tracks     TrackPreferences // default audio and text tracks of the manifests
+	ExpiresAt  time.Time
+}
+
+// TrackPreferences pick the default audio and text tracks of a manifest
+type TrackPreferences struct {
+	SubtitleLanguage string `json:"subtitleLanguage,omitempty"` // no subtitles by default when empty
+	AudioLanguage    string `json:"audioLanguage,omitempty"`    // the original audio when empty
+	ClosedCaptions   bool   `json:"closedCaptions,omitempty"`   // prefer SDH captions, and show them without a subtitle language
+	AudioDescription bool   `json:"audioDescription,omitempty"` // prefer audio description tracks
 }
 
 // QoEEvent represents a Quality of Experience event - Issue #14
 type QoEEvent struct {
-	UserID         string    `json:"userId" binding:"required"`
-	SessionID      string    `json:"sessionId"`
-	ContentID      string    `json:"contentId" binding:"required"`
-	Event          string    `json:"event" binding:"required"` // "play|pause|seek|buffering|error|ended"
-	Bitrate        int       `json:"bitrate"`
-	BufferDuration float64   `json:"bufferDuration"`
-	Timestamp      time.Time `json:"timestamp"`
+	UserID         string    `bson:"user_id" json:"userId" binding:"required"`
+	SessionID      string    `bson:"session_id" json:"sessionId"`
+	DeviceID       string    `bson:"device_id" json:"deviceId"`
+	ContentID      string    `bson:"content_id" json:"contentId" binding:"required"`
+	Event          string    `bson:"event" json:"event" binding:"required"` // "start|play|pause|seek|buffering|error|ended"
+	Bitrate        int       `bson:"bitrate" json:"bitrate"`
+	BufferDuration float64   `bson:"buffer_duration" json:"bufferDuration"`
+	Position       int64     `bson:"position,omitempty" json:"position,omitempty"` // milliseconds
+	CDN            string    `bson:"cdn,omitempty" json:"cdn,omitempty"`           // pathway / service location the player was on
+	Timestamp      time.Time `bson:"timestamp" json:"timestamp"`
 }
 
+// StreamLease reserves one of a user's concurrent stream slots for a playback session
+type StreamLease struct {
+	ID            string    `bson:"_id" json:"id"` // "<user_id>:<slot>", unique per slot
+	UserID        string    `bson:"user_id" json:"userId"`
+	Slot          int       `bson:"slot" json:"slot"`
+	SessionID     string    `bson:"session_id" json:"sessionId"`
+	DeviceID      string    `bson:"device_id" json:"deviceId"`
+	StartedAt     time.Time `bson:"started_at" json:"startedAt"`
+	LastHeartbeat time.Time `bson:"last_heartbeat" json:"lastHeartbeat"`
+}
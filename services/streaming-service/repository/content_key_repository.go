@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/streamverse/common-go/database"
+	"github.com/streamverse/streaming-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ContentKeyRepository stores encrypted DRM content keys, one per content and
+// track type
+type ContentKeyRepository struct {
+	collection *mongo.Collection
+}
+
+// NewContentKeyRepository creates a new content key repository
+func NewContentKeyRepository(db *database.MongoDB) *ContentKeyRepository {
+	collection := db.Collection("content_keys")
+
+	_, _ = collection.Indexes().CreateMany(
+		context.Background(),
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "content_id", Value: 1}, {Key: "track_type", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "key_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+	)
+
+	return &ContentKeyRepository{
+		collection: collection,
+	}
+}
+
+// GetContentKeys retrieves the keys of a content item
+func (r *ContentKeyRepository) GetContentKeys(ctx context.Context, contentID string) ([]models.ContentKey, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"content_id": contentID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var keys []models.ContentKey
+	if err = cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// InsertContentKeys stores new keys. Keys for a track type that already has one,
+// e.g. created concurrently by another replica, are dropped by the unique index.
+func (r *ContentKeyRepository) InsertContentKeys(ctx context.Context, keys []models.ContentKey) error {
+	docs := make([]interface{}, len(keys))
+	for i := range keys {
+		docs[i] = keys[i]
+	}
+
+	_, err := r.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	return nil
+}
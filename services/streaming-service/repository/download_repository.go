@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/streamverse/common-go/database"
+	"github.com/streamverse/streaming-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DownloadRepository stores offline downloads. A device holds at most one active
+// download of a title, enforced by a partial unique index.
+type DownloadRepository struct {
+	collection *mongo.Collection
+}
+
+// NewDownloadRepository creates a new download repository
+func NewDownloadRepository(db *database.MongoDB) *DownloadRepository {
+	collection := db.Collection("downloads")
+
+	_, _ = collection.Indexes().CreateMany(
+		context.Background(),
+		[]mongo.IndexModel{
+			{
+				Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "content_id", Value: 1}, {Key: "device_id", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"state": models.DownloadStateActive}),
+			},
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "state", Value: 1}, {Key: "created_at", Value: 1}}},
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "device_id", Value: 1}}},
+		},
+	)
+
+	return &DownloadRepository{
+		collection: collection,
+	}
+}
+
+// CreateDownload stores a new active download, reporting false if the device
+// already holds an active download of the title
+func (r *DownloadRepository) CreateDownload(ctx context.Context, download *models.Download) (bool, error) {
+	if download.ID.IsZero() {
+		download.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, download)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetDownload retrieves a download by ID, or nil if there is none
+func (r *DownloadRepository) GetDownload(ctx context.Context, id string) (*models.Download, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+	return r.findOne(ctx, bson.M{"_id": objectID})
+}
+
+// FindActiveDownload retrieves a device's active download of a title, or nil if there is none
+func (r *DownloadRepository) FindActiveDownload(ctx context.Context, userID, contentID, deviceID string) (*models.Download, error) {
+	return r.findOne(ctx, bson.M{
+		"user_id":    userID,
+		"content_id": contentID,
+		"device_id":  deviceID,
+		"state":      models.DownloadStateActive,
+	})
+}
+
+func (r *DownloadRepository) findOne(ctx context.Context, filter bson.M) (*models.Download, error) {
+	var download models.Download
+	err := r.collection.FindOne(ctx, filter).Decode(&download)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &download, nil
+}
+
+// ListActiveDownloads retrieves a user's active downloads, oldest first
+func (r *DownloadRepository) ListActiveDownloads(ctx context.Context, userID string) ([]models.Download, error) {
+	return r.find(ctx, bson.M{"user_id": userID, "state": models.DownloadStateActive})
+}
+
+// ListDownloads retrieves a user's downloads, including revoked ones, optionally
+// only those of one device
+func (r *DownloadRepository) ListDownloads(ctx context.Context, userID, deviceID string) ([]models.Download, error) {
+	filter := bson.M{"user_id": userID}
+	if deviceID != "" {
+		filter["device_id"] = deviceID
+	}
+	return r.find(ctx, filter)
+}
+
+func (r *DownloadRepository) find(ctx context.Context, filter bson.M) ([]models.Download, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var downloads []models.Download
+	if err = cursor.All(ctx, &downloads); err != nil {
+		return nil, err
+	}
+	return downloads, nil
+}
+
+// RenewDownload updates the quality and license expiry of an active download,
+// reporting false if it is no longer active
+func (r *DownloadRepository) RenewDownload(ctx context.Context, download *models.Download) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": download.ID, "state": models.DownloadStateActive},
+		bson.M{"$set": bson.M{
+			"quality":            download.Quality,
+			"max_height":         download.MaxHeight,
+			"license_expires_at": download.LicenseExpiresAt,
+			"updated_at":         download.UpdatedAt,
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// DeleteDownload removes a user's download, reporting false if there was none
+func (r *DownloadRepository) DeleteDownload(ctx context.Context, userID string, id primitive.ObjectID) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "user_id": userID})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// RevokeDeviceDownloads revokes every active download of a device and returns how many there were
+func (r *DownloadRepository) RevokeDeviceDownloads(ctx context.Context, userID, deviceID, reason string) (int64, error) {
+	now := time.Now()
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"user_id": userID, "device_id": deviceID, "state": models.DownloadStateActive},
+		bson.M{"$set": bson.M{
+			"state":         models.DownloadStateRevoked,
+			"revoke_reason": reason,
+			"revoked_at":    now,
+			"updated_at":    now,
+		}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/streamverse/common-go/database"
+	"github.com/streamverse/streaming-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// QoERepository stores raw QoE events and aggregated session metrics
+type QoERepository struct {
+	events   *mongo.Collection
+	sessions *mongo.Collection
+}
+
+// NewQoERepository creates a new QoE repository
+func NewQoERepository(db *database.MongoDB) *QoERepository {
+	return &QoERepository{
+		events:   db.Collection("qoe_events"),
+		sessions: db.Collection("qoe_sessions"),
+	}
+}
+
+// InsertQoEEvents stores a batch of raw QoE events
+func (r *QoERepository) InsertQoEEvents(ctx context.Context, events []models.QoEEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(events))
+	for i := range events {
+		docs[i] = events[i]
+	}
+	_, err := r.events.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	return err
+}
+
+// GetSessionQoE retrieves the aggregated metrics of a session
+func (r *QoERepository) GetSessionQoE(ctx context.Context, sessionID string) (*models.SessionQoE, error) {
+	var metrics models.SessionQoE
+	if err := r.sessions.FindOne(ctx, bson.M{"_id": sessionID}).Decode(&metrics); err != nil {
+		return nil, err
+	}
+	return &metrics, nil
+}
+
+// SaveSessionQoE creates or replaces the aggregated metrics of a session
+func (r *QoERepository) SaveSessionQoE(ctx context.Context, metrics *models.SessionQoE) error {
+	_, err := r.sessions.ReplaceOne(ctx, bson.M{"_id": metrics.SessionID}, metrics, options.Replace().SetUpsert(true))
+	return err
+}
+
+// GetContentQoE rolls up session metrics for a content item. Startup time, rebuffer
+// ratio, bitrate and switches are averaged over sessions that started playing.
+func (r *QoERepository) GetContentQoE(ctx context.Context, contentID string) (*models.ContentQoE, error) {
+	startedOnly := func(field string) bson.M {
+		return bson.M{"$cond": bson.A{"$video_started", field, nil}}
+	}
+	flag := func(cond interface{}) bson.M {
+		return bson.M{"$cond": bson.A{cond, 1, 0}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"content_id": contentID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":                      "$content_id",
+			"sessions":                 bson.M{"$sum": 1},
+			"average_startup_time":     bson.M{"$avg": startedOnly("$startup_time")},
+			"average_rebuffer_ratio":   bson.M{"$avg": startedOnly("$rebuffer_ratio")},
+			"average_bitrate":          bson.M{"$avg": startedOnly("$average_bitrate")},
+			"average_bitrate_switches": bson.M{"$avg": startedOnly("$bitrate_switches")},
+			"exit_before_start_rate":   bson.M{"$avg": flag("$exited_before_start")},
+			"error_rate":               bson.M{"$avg": flag(bson.M{"$gt": bson.A{"$errors", 0}})},
+		}}},
+	}
+
+	cursor, err := r.sessions.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if !cursor.Next(ctx) {
+		if err := cursor.Err(); err != nil {
+			return nil, err
+		}
+		return nil, mongo.ErrNoDocuments
+	}
+
+	var rollup models.ContentQoE
+	if err := cursor.Decode(&rollup); err != nil {
+		return nil, err
+	}
+	return &rollup, nil
+}
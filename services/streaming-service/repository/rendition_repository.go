@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/streamverse/common-go/database"
+	"github.com/streamverse/streaming-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RenditionRepository reads packaged renditions produced by transcoding-service
+type RenditionRepository struct {
+	collection *mongo.Collection
+}
+
+// NewRenditionRepository creates a new rendition repository
+func NewRenditionRepository(db *database.MongoDB) *RenditionRepository {
+	return &RenditionRepository{
+		collection: db.Collection("renditions"),
+	}
+}
+
+// GetRenditionsByContent retrieves all renditions for a content item, highest bandwidth first
+func (r *RenditionRepository) GetRenditionsByContent(ctx context.Context, contentID string) ([]models.Rendition, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "bandwidth", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, bson.M{"content_id": contentID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var renditions []models.Rendition
+	if err = cursor.All(ctx, &renditions); err != nil {
+		return nil, err
+	}
+
+	return renditions, nil
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/streamverse/common-go/database"
+	"github.com/streamverse/streaming-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StreamLeaseRepository stores concurrent stream slot leases. Each slot is a
+// document keyed by user and slot number, so claiming a slot is a single atomic
+// insert or compare-and-swap update.
+type StreamLeaseRepository struct {
+	collection *mongo.Collection
+}
+
+// NewStreamLeaseRepository creates a new stream lease repository
+func NewStreamLeaseRepository(db *database.MongoDB) *StreamLeaseRepository {
+	collection := db.Collection("stream_leases")
+
+	_, _ = collection.Indexes().CreateMany(
+		context.Background(),
+		[]mongo.IndexModel{
+			{Keys: bson.D{{Key: "session_id", Value: 1}}},
+			{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		},
+	)
+
+	return &StreamLeaseRepository{
+		collection: collection,
+	}
+}
+
+// AcquireLease claims a free slot, reporting false if the slot is already leased
+func (r *StreamLeaseRepository) AcquireLease(ctx context.Context, lease *models.StreamLease) (bool, error) {
+	_, err := r.collection.InsertOne(ctx, lease)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// TakeOverStaleLease claims a slot whose holder has not sent a heartbeat since cutoff
+func (r *StreamLeaseRepository) TakeOverStaleLease(ctx context.Context, lease *models.StreamLease, cutoff time.Time) (*models.StreamLease, error) {
+	filter := bson.M{"_id": lease.ID, "last_heartbeat": bson.M{"$lt": cutoff}}
+	return r.swap(ctx, filter, lease)
+}
+
+// ReplaceLease hands a slot to a new session if it is still held by expectedSessionID
+func (r *StreamLeaseRepository) ReplaceLease(ctx context.Context, lease *models.StreamLease, expectedSessionID string) (*models.StreamLease, error) {
+	filter := bson.M{"_id": lease.ID, "session_id": expectedSessionID}
+	return r.swap(ctx, filter, lease)
+}
+
+// swap replaces the lease matching filter and returns the previous holder, or nil if nothing matched
+func (r *StreamLeaseRepository) swap(ctx context.Context, filter bson.M, lease *models.StreamLease) (*models.StreamLease, error) {
+	opts := options.FindOneAndReplace().SetReturnDocument(options.Before)
+
+	var previous models.StreamLease
+	err := r.collection.FindOneAndReplace(ctx, filter, lease, opts).Decode(&previous)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &previous, nil
+}
+
+// ListLeases retrieves all slot leases of a user
+func (r *StreamLeaseRepository) ListLeases(ctx context.Context, userID string) ([]models.StreamLease, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var leases []models.StreamLease
+	if err = cursor.All(ctx, &leases); err != nil {
+		return nil, err
+	}
+	return leases, nil
+}
+
+// RefreshLease records a heartbeat, reporting false if the session no longer holds
+// a slot below maxSlots (any slot when maxSlots is 0)
+func (r *StreamLeaseRepository) RefreshLease(ctx context.Context, sessionID string, maxSlots int) (bool, error) {
+	filter := bson.M{"session_id": sessionID}
+	if maxSlots > 0 {
+		filter["slot"] = bson.M{"$lt": maxSlots}
+	}
+	result, err := r.collection.UpdateOne(ctx, filter,
+		bson.M{"$set": bson.M{"last_heartbeat": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// ReleaseLease frees the slot held by a session
+func (r *StreamLeaseRepository) ReleaseLease(ctx context.Context, sessionID string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"session_id": sessionID})
+	return err
+}
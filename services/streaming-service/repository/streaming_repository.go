@@ -5,11 +5,12 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/streamverse/common-go/database"
+	"github.com/streamverse/streaming-service/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"github.com/streamverse/common-go/database"
-	"github.com/streamverse/streaming-service/models"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // StreamingRepository handles playback session operations
@@ -48,13 +49,15 @@ func (r *StreamingRepository) GetSession(ctx context.Context, sessionID string)
 	return &session, nil
 }
 
-// UpdatePosition updates playback position
-func (r *StreamingRepository) UpdatePosition(ctx context.Context, sessionID string, position int64) error {
+// UpdatePosition updates playback position of a session that has not ended and
+// returns the updated session
+func (r *StreamingRepository) UpdatePosition(ctx context.Context, sessionID string, position int64) (*models.PlaybackSession, error) {
 	objectID, err := primitive.ObjectIDFromHex(sessionID)
 	if err != nil {
-		return fmt.Errorf("invalid session ID: %w", err)
+		return nil, fmt.Errorf("invalid session ID: %w", err)
 	}
 
+	filter := bson.M{"_id": objectID, "state": bson.M{"$ne": models.SessionStateEnded}}
 	update := bson.M{
 		"$set": bson.M{
 			"position":   position,
@@ -62,43 +65,143 @@ func (r *StreamingRepository) UpdatePosition(ctx context.Context, sessionID stri
 		},
 	}
 
-	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
-	return err
+	var session models.PlaybackSession
+	err = r.collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&session)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
 }
 
-// UpdateHeartbeat updates session heartbeat
-func (r *StreamingRepository) UpdateHeartbeat(ctx context.Context, sessionID string) error {
+// UpdateHeartbeat updates session heartbeat and, when positive, the measured bandwidth.
+// It reports false when the session does not exist or has already ended.
+func (r *StreamingRepository) UpdateHeartbeat(ctx context.Context, sessionID string, bandwidth int64) (bool, error) {
 	objectID, err := primitive.ObjectIDFromHex(sessionID)
 	if err != nil {
-		return fmt.Errorf("invalid session ID: %w", err)
+		return false, fmt.Errorf("invalid session ID: %w", err)
 	}
 
+	set := bson.M{
+		"last_heartbeat": time.Now(),
+		"updated_at":     time.Now(),
+	}
+	if bandwidth > 0 {
+		set["bandwidth"] = bandwidth
+	}
+	update := bson.M{"$set": set}
+
+	filter := bson.M{"_id": objectID, "state": bson.M{"$ne": models.SessionStateEnded}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// TransitionState moves a session to a new state if it is currently in one of the
+// given states. It returns nil when the session is missing or in another state.
+func (r *StreamingRepository) TransitionState(ctx context.Context, sessionID, state string, from []string) (*models.PlaybackSession, error) {
+	objectID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session ID: %w", err)
+	}
+
+	filter := bson.M{"_id": objectID, "state": bson.M{"$in": from}}
 	update := bson.M{
 		"$set": bson.M{
-			"last_heartbeat": time.Now(),
-			"updated_at":     time.Now(),
+			"state":      state,
+			"updated_at": time.Now(),
 		},
 	}
 
-	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
-	return err
+	var session models.PlaybackSession
+	err = r.collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
 }
 
-// DeleteSession deletes a session
-func (r *StreamingRepository) DeleteSession(ctx context.Context, sessionID string) error {
+// EndSession marks a session as ended. It returns nil when the session is missing
+// or was already ended, so only one caller ever sees the transition.
+func (r *StreamingRepository) EndSession(ctx context.Context, sessionID, reason string, endedAt time.Time) (*models.PlaybackSession, error) {
 	objectID, err := primitive.ObjectIDFromHex(sessionID)
 	if err != nil {
-		return fmt.Errorf("invalid session ID: %w", err)
+		return nil, fmt.Errorf("invalid session ID: %w", err)
+	}
+
+	filter := bson.M{"_id": objectID, "state": bson.M{"$ne": models.SessionStateEnded}}
+	update := bson.M{
+		"$set": bson.M{
+			"state":      models.SessionStateEnded,
+			"end_reason": reason,
+			"ended_at":   endedAt,
+			"updated_at": time.Now(),
+		},
+	}
+
+	var session models.PlaybackSession
+	err = r.collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// MarkStalledSessions moves starting and playing sessions whose last heartbeat is
+// older than the cutoff to the stalled state
+func (r *StreamingRepository) MarkStalledSessions(ctx context.Context, cutoff time.Time) (int64, error) {
+	filter := bson.M{
+		"state":          bson.M{"$in": bson.A{models.SessionStateStarting, models.SessionStatePlaying}},
+		"last_heartbeat": bson.M{"$lt": cutoff},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"state":      models.SessionStateStalled,
+			"updated_at": time.Now(),
+		},
 	}
 
-	_, err = r.collection.DeleteOne(ctx, bson.M{"_id": objectID})
-	return err
+	result, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// GetExpiredSessions retrieves sessions that have not ended but whose last
+// heartbeat is older than the cutoff, oldest first
+func (r *StreamingRepository) GetExpiredSessions(ctx context.Context, cutoff time.Time, limit int64) ([]models.PlaybackSession, error) {
+	filter := bson.M{
+		"state":          bson.M{"$ne": models.SessionStateEnded},
+		"last_heartbeat": bson.M{"$lt": cutoff},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "last_heartbeat", Value: 1}}).SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var sessions []models.PlaybackSession
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
 }
 
 // GetActiveSessions retrieves active sessions for a user
 func (r *StreamingRepository) GetActiveSessions(ctx context.Context, userID string) ([]models.PlaybackSession, error) {
 	filter := bson.M{
 		"user_id":        userID,
+		"state":          bson.M{"$ne": models.SessionStateEnded},
 		"last_heartbeat": bson.M{"$gte": time.Now().Add(-5 * time.Minute)},
 	}
 
@@ -115,4 +218,3 @@ func (r *StreamingRepository) GetActiveSessions(ctx context.Context, userID stri
 
 	return sessions, nil
 }
-
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/streamverse/common-go/cache"
+	"github.com/streamverse/streaming-service/models"
+	"github.com/streamverse/streaming-service/utils"
+)
+
+const (
+	throughputTTL       = 7 * 24 * time.Hour
+	throughputSmoothing = 0.3              // EWMA weight of the newest throughput sample
+	rebufferHalfLife    = 10 * time.Minute // how quickly past rebuffers stop counting
+	healthyBuffer       = 10.0             // seconds of buffer that prove a bitrate is sustainable
+	lowBuffer           = 2.0              // seconds of buffer below which a bitrate is not sustainable
+	drainingFactor      = 0.7              // throughput assumed when the buffer drains at a bitrate
+	startSafetyFactor   = 0.8              // share of the estimate the initial variant may use
+	rebufferPenalty     = 0.15             // safety lost per recent rebuffer
+	minSafetyFactor     = 0.3
+	ladderHeadroom      = 2.0 // variants up to this multiple of the estimate stay in the ladder
+)
+
+// deviceProfile is the initial quality and the ladder cap for a device class
+type deviceProfile struct {
+	profile   string
+	maxHeight int
+}
+
+// Bitrate ladder: 240p (512k), 360p (1.5M), 480p (2.5M), 720p (5M), 1080p (8M), 4K (15M)
+var deviceProfiles = map[string]deviceProfile{
+	utils.DeviceMobile:  {profile: "360p", maxHeight: 1080},
+	utils.DeviceTablet:  {profile: "720p", maxHeight: 1080},
+	utils.DeviceDesktop: {profile: "1080p", maxHeight: 2160},
+	utils.DeviceTV:      {profile: "4K", maxHeight: 2160},
+}
+
+var defaultDeviceProfile = deviceProfile{profile: "480p", maxHeight: 1080}
+
+// ThroughputEstimator keeps a smoothed bandwidth estimate per user and device in Redis
+type ThroughputEstimator struct {
+	cache *cache.RedisClient
+}
+
+// NewThroughputEstimator creates a new throughput estimator
+func NewThroughputEstimator(cache *cache.RedisClient) *ThroughputEstimator {
+	return &ThroughputEstimator{cache: cache}
+}
+
+// Estimate returns the stored estimate, or an empty one when nothing has been measured yet
+func (e *ThroughputEstimator) Estimate(ctx context.Context, userID, deviceID string) *models.ThroughputEstimate {
+	var estimate models.ThroughputEstimate
+	if err := e.cache.Get(ctx, throughputKey(userID, deviceID), &estimate); err != nil {
+		return &models.ThroughputEstimate{}
+	}
+	return &estimate
+}
+
+// ObserveBandwidth folds a player-measured throughput sample (bps) into the estimate
+func (e *ThroughputEstimator) ObserveBandwidth(ctx context.Context, userID, deviceID string, bandwidth int64) error {
+	if bandwidth <= 0 {
+		return nil
+	}
+	estimate := e.Estimate(ctx, userID, deviceID)
+	applyThroughputSample(estimate, float64(bandwidth), time.Now())
+	return e.save(ctx, userID, deviceID, estimate)
+}
+
+// ObserveQoE folds a QoE event into the estimate
+func (e *ThroughputEstimator) ObserveQoE(ctx context.Context, deviceID string, event *models.QoEEvent) error {
+	estimate := e.Estimate(ctx, event.UserID, deviceID)
+	if !applyQoEEvent(estimate, event, time.Now()) {
+		return nil
+	}
+	return e.save(ctx, event.UserID, deviceID, estimate)
+}
+
+func (e *ThroughputEstimator) save(ctx context.Context, userID, deviceID string, estimate *models.ThroughputEstimate) error {
+	if err := e.cache.Set(ctx, throughputKey(userID, deviceID), estimate, throughputTTL); err != nil {
+		return fmt.Errorf("failed to store throughput estimate: %w", err)
+	}
+	return nil
+}
+
+func throughputKey(userID, deviceID string) string {
+	return fmt.Sprintf("abr:throughput:%s:%s", userID, deviceID)
+}
+
+// applyThroughputSample updates the exponentially weighted throughput average
+func applyThroughputSample(estimate *models.ThroughputEstimate, bandwidth float64, now time.Time) {
+	decayRebuffers(estimate, now)
+	if estimate.Samples == 0 {
+		estimate.Bandwidth = bandwidth
+	} else {
+		estimate.Bandwidth = throughputSmoothing*bandwidth + (1-throughputSmoothing)*estimate.Bandwidth
+	}
+	estimate.Samples++
+	estimate.LastUpdated = now
+}
+
+// applyQoEEvent interprets a QoE event and reports whether the estimate changed.
+// A bitrate played with a healthy buffer is a lower bound on throughput, a bitrate
+// played while the buffer drains is an upper bound, and buffering or error events
+// count as rebuffers that make the initial variant more conservative.
+func applyQoEEvent(estimate *models.ThroughputEstimate, event *models.QoEEvent, now time.Time) bool {
+	changed := false
+
+	if event.Event == "buffering" || event.Event == "error" {
+		decayRebuffers(estimate, now)
+		estimate.Rebuffers++
+		estimate.LastUpdated = now
+		changed = true
+	}
+
+	if event.Bitrate > 0 {
+		bitrate := float64(event.Bitrate)
+		switch {
+		case event.Event == "buffering" || (event.BufferDuration > 0 && event.BufferDuration < lowBuffer):
+			// The link could not keep up, so never let this sample raise the estimate
+			sample := bitrate * drainingFactor
+			if estimate.Samples > 0 && sample > estimate.Bandwidth*drainingFactor {
+				sample = estimate.Bandwidth * drainingFactor
+			}
+			applyThroughputSample(estimate, sample, now)
+			changed = true
+		case event.BufferDuration >= healthyBuffer && bitrate > estimate.Bandwidth:
+			applyThroughputSample(estimate, bitrate, now)
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// decayRebuffers halves the rebuffer count every rebufferHalfLife
+func decayRebuffers(estimate *models.ThroughputEstimate, now time.Time) {
+	if estimate.Rebuffers == 0 || estimate.LastUpdated.IsZero() {
+		return
+	}
+	elapsed := now.Sub(estimate.LastUpdated)
+	if elapsed <= 0 {
+		return
+	}
+	estimate.Rebuffers *= math.Pow(0.5, float64(elapsed)/float64(rebufferHalfLife))
+	estimate.LastUpdated = now
+}
+
+// abrProfileFor combines the device class with the throughput estimate
+func abrProfileFor(deviceType string, estimate *models.ThroughputEstimate, now time.Time) *models.ABRProfile {
+	device, ok := deviceProfiles[deviceType]
+	if !ok {
+		device = defaultDeviceProfile
+	}
+	profile := &models.ABRProfile{
+		Profile:   device.profile,
+		MaxHeight: device.maxHeight,
+	}
+	if estimate == nil || estimate.Samples == 0 {
+		return profile
+	}
+
+	decayed := *estimate
+	decayRebuffers(&decayed, now)
+	safety := math.Max(minSafetyFactor, startSafetyFactor-rebufferPenalty*decayed.Rebuffers)
+
+	profile.StartBandwidth = int(decayed.Bandwidth * safety)
+	profile.MaxBandwidth = int(decayed.Bandwidth * ladderHeadroom)
+	return profile
+}
+
+// SelectABRProfile selects the ABR profile for a viewer from their device class and
+// the throughput measured on previous sessions
+func (s *StreamingService) SelectABRProfile(ctx context.Context, userID, deviceID, deviceType string) *models.ABRProfile {
+	return abrProfileFor(deviceType, s.abr.Estimate(ctx, userID, deviceID), time.Now())
+}
+
+// resolveDeviceType takes the device class from the token, falling back to the
+// viewer's active session on the same device
+func (s *StreamingService) resolveDeviceType(ctx context.Context, claims *models.StreamingClaims) string {
+	if deviceType := utils.NormalizeDeviceType(claims.DeviceType); deviceType != "" {
+		return deviceType
+	}
+	if claims.DeviceID == "" {
+		return ""
+	}
+
+	sessions, err := s.repo.GetActiveSessions(ctx, claims.UserID)
+	if err != nil {
+		return ""
+	}
+	for _, session := range sessions {
+		if session.DeviceID == claims.DeviceID && session.DeviceType != "" {
+			return session.DeviceType
+		}
+	}
+	return ""
+}
+
+// capLadder drops video renditions the viewer cannot use: taller than the device
+// class needs or far beyond the measured throughput. The lowest video rendition is
+// always kept so playback can start on a poor connection. I-frame renditions are
+// capped by height only, as they are fetched sparsely while seeking.
+func capLadder(renditions []models.Rendition, abr *models.ABRProfile) []models.Rendition {
+	if abr == nil {
+		return renditions
+	}
+
+	var audioBandwidth int
+	lowest := -1
+	for i, r := range renditions {
+		switch r.Type {
+		case "audio":
+			if r.Bandwidth > audioBandwidth {
+				audioBandwidth = r.Bandwidth
+			}
+		case "video":
+			if lowest < 0 || r.Bandwidth < renditions[lowest].Bandwidth {
+				lowest = i
+			}
+		}
+	}
+
+	capped := make([]models.Rendition, 0, len(renditions))
+	for i, r := range renditions {
+		if r.Type == "video" && i != lowest {
+			if abr.MaxHeight > 0 && r.Height > abr.MaxHeight {
+				continue
+			}
+			if abr.MaxBandwidth > 0 && r.Bandwidth+audioBandwidth > abr.MaxBandwidth {
+				continue
+			}
+		}
+		if r.Type == "iframe" && abr.MaxHeight > 0 && r.Height > abr.MaxHeight {
+			continue
+		}
+		capped = append(capped, r)
+	}
+	return capped
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+func testLadder() []models.Rendition {
+	return []models.Rendition{
+		{Name: "2160p", Type: "video", Bandwidth: 15000000, Width: 3840, Height: 2160},
+		{Name: "1080p", Type: "video", Bandwidth: 8000000, Width: 1920, Height: 1080},
+		{Name: "720p", Type: "video", Bandwidth: 5000000, Width: 1280, Height: 720},
+		{Name: "360p", Type: "video", Bandwidth: 1500000, Width: 640, Height: 360},
+		{Name: "audio-en", Type: "audio", Bandwidth: 128000, Language: "en", Default: true},
+	}
+}
+
+func variantNames(manifest *models.Manifest) []string {
+	var names []string
+	for _, v := range manifest.Variants {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+func TestThroughputSamplesAreSmoothed(t *testing.T) {
+	now := time.Now()
+	estimate := &models.ThroughputEstimate{}
+
+	applyThroughputSample(estimate, 10000000, now)
+	if estimate.Bandwidth != 10000000 {
+		t.Fatalf("first sample should seed the estimate, got %v", estimate.Bandwidth)
+	}
+
+	applyThroughputSample(estimate, 2000000, now)
+	if estimate.Bandwidth != 7600000 || estimate.Samples != 2 {
+		t.Fatalf("expected EWMA of 7.6M over 2 samples, got %v over %d", estimate.Bandwidth, estimate.Samples)
+	}
+}
+
+func TestQoEEventsAdjustEstimate(t *testing.T) {
+	now := time.Now()
+	estimate := &models.ThroughputEstimate{Bandwidth: 4000000, Samples: 3, LastUpdated: now}
+
+	// A bitrate sustained with a healthy buffer raises the estimate
+	if !applyQoEEvent(estimate, &models.QoEEvent{Event: "play", Bitrate: 8000000, BufferDuration: 20}, now) {
+		t.Fatalf("expected healthy playback above the estimate to update it")
+	}
+	if estimate.Bandwidth <= 4000000 {
+		t.Fatalf("expected estimate to rise, got %v", estimate.Bandwidth)
+	}
+
+	// A pause with no bitrate information changes nothing
+	if applyQoEEvent(estimate, &models.QoEEvent{Event: "pause"}, now) {
+		t.Fatalf("expected pause to leave the estimate untouched")
+	}
+
+	// Buffering lowers the estimate and counts as a rebuffer
+	before := estimate.Bandwidth
+	applyQoEEvent(estimate, &models.QoEEvent{Event: "buffering", Bitrate: 8000000}, now)
+	if estimate.Bandwidth >= before || estimate.Rebuffers != 1 {
+		t.Fatalf("expected buffering to lower the estimate and record a rebuffer, got %+v", estimate)
+	}
+}
+
+func TestRebuffersDecay(t *testing.T) {
+	now := time.Now()
+	estimate := &models.ThroughputEstimate{Bandwidth: 5000000, Samples: 1, Rebuffers: 2, LastUpdated: now.Add(-rebufferHalfLife)}
+
+	decayRebuffers(estimate, now)
+	if estimate.Rebuffers < 0.99 || estimate.Rebuffers > 1.01 {
+		t.Fatalf("expected rebuffers to halve after one half-life, got %v", estimate.Rebuffers)
+	}
+}
+
+func TestABRProfileUsesDeviceAndThroughput(t *testing.T) {
+	now := time.Now()
+
+	profile := abrProfileFor("mobile", nil, now)
+	if profile.Profile != "360p" || profile.MaxHeight != 1080 || profile.MaxBandwidth != 0 || profile.StartBandwidth != 0 {
+		t.Fatalf("unexpected profile without an estimate: %+v", profile)
+	}
+
+	profile = abrProfileFor("unknown", &models.ThroughputEstimate{Bandwidth: 10000000, Samples: 4, LastUpdated: now}, now)
+	if profile.Profile != "480p" || profile.StartBandwidth != 8000000 || profile.MaxBandwidth != 20000000 {
+		t.Fatalf("unexpected profile with an estimate: %+v", profile)
+	}
+
+	profile = abrProfileFor("tv", &models.ThroughputEstimate{Bandwidth: 10000000, Samples: 4, Rebuffers: 10, LastUpdated: now}, now)
+	if profile.StartBandwidth != 3000000 {
+		t.Fatalf("expected rebuffers to floor the start budget at 3M, got %d", profile.StartBandwidth)
+	}
+}
+
+func TestBuildManifestCapsLadderAndOrdersInitialVariant(t *testing.T) {
+	abr := &models.ABRProfile{Profile: "4K", MaxHeight: 2160, MaxBandwidth: 10000000, StartBandwidth: 6000000}
+
+	manifest := buildManifest("c1", "hls", testLadder(), abr, func(name string) string { return name + ".m3u8" })
+
+	got := variantNames(manifest)
+	want := []string{"720p", "1080p", "360p"}
+	if len(got) != len(want) {
+		t.Fatalf("expected variants %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected variants %v, got %v", want, got)
+		}
+	}
+}
+
+func TestBuildManifestKeepsLowestVariantOnPoorConnection(t *testing.T) {
+	abr := &models.ABRProfile{Profile: "1080p", MaxHeight: 2160, MaxBandwidth: 500000, StartBandwidth: 200000}
+
+	manifest := buildManifest("c1", "hls", testLadder(), abr, func(name string) string { return name + ".m3u8" })
+
+	got := variantNames(manifest)
+	if len(got) != 1 || got[0] != "360p" {
+		t.Fatalf("expected only the lowest variant, got %v", got)
+	}
+}
+
+func TestBuildManifestVariantsCarryAudio(t *testing.T) {
+	renditions := []models.Rendition{
+		{Name: "360p", Type: "video", Bandwidth: 1500000, AverageBandwidth: 1200000, Width: 640, Height: 360, Codecs: "avc1.4d401e", FrameRate: 25},
+		{Name: "720p", Type: "video", Bandwidth: 5000000, Width: 1280, Height: 720, Codecs: "avc1.64001f", FrameRate: 29.97},
+		{Name: "audio-es", Type: "audio", Bandwidth: 192000, AverageBandwidth: 160000, Codecs: "ec-3", Language: "es", Channels: 6},
+		{Name: "audio-en", Type: "audio", Bandwidth: 128000, AverageBandwidth: 120000, Codecs: "mp4a.40.2", Language: "en", Default: true},
+		{Name: "subs-en", Type: "subtitle", Language: "en", Label: "English"},
+	}
+
+	manifest := buildManifest("c1", "hls", renditions, nil, func(name string) string { return "p/" + name + ".m3u8" })
+
+	if got := variantNames(manifest); len(got) != 2 || got[0] != "720p" || got[1] != "360p" {
+		t.Fatalf("expected variants highest bandwidth first, got %v", got)
+	}
+	// Bandwidth covers the largest audio track; codecs name the default one
+	want := models.Variant{Name: "360p", Bandwidth: 1692000, AverageBandwidth: 1360000, Resolution: "640x360",
+		Codec: "avc1.4d401e,mp4a.40.2", FrameRate: 25, URL: "p/360p.m3u8"}
+	if got := manifest.Variants[1]; got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if manifest.Variants[0].AverageBandwidth != 0 {
+		t.Fatalf("expected no average bandwidth without one for the video, got %d", manifest.Variants[0].AverageBandwidth)
+	}
+	if len(manifest.AudioTracks) != 2 || manifest.AudioTracks[1].URL != "p/audio-en.m3u8" || !manifest.AudioTracks[1].Default {
+		t.Fatalf("expected both audio tracks, got %+v", manifest.AudioTracks)
+	}
+	if len(manifest.Subtitles) != 1 || manifest.Subtitles[0].URL != "p/subs-en.m3u8" {
+		t.Fatalf("expected the subtitle track, got %+v", manifest.Subtitles)
+	}
+}
+
+func TestCapLadderHonoursDeviceHeight(t *testing.T) {
+	capped := capLadder(testLadder(), &models.ABRProfile{Profile: "360p", MaxHeight: 1080})
+
+	for _, r := range capped {
+		if r.Name == "2160p" {
+			t.Fatalf("expected 2160p to be dropped for a 1080p device cap")
+		}
+	}
+	if len(capped) != 4 {
+		t.Fatalf("expected 3 video and 1 audio rendition, got %d", len(capped))
+	}
+}
+
+func TestBuildManifestTrickPlayTracks(t *testing.T) {
+	renditions := append(testLadder(),
+		models.Rendition{Name: "iframe-2160p", Type: "iframe", Bandwidth: 900000, Width: 3840, Height: 2160},
+		models.Rendition{Name: "iframe-360p", Type: "iframe", Bandwidth: 100000, Width: 640, Height: 360},
+		models.Rendition{Name: "iframe-1080p", Type: "iframe", Bandwidth: 500000, Width: 1920, Height: 1080},
+		models.Rendition{Name: "thumbs-320", Type: "thumbnail", Bandwidth: 20000, Width: 320, Height: 180, TileLayout: "5x5"},
+	)
+	abr := &models.ABRProfile{Profile: "1080p", MaxHeight: 1080}
+
+	manifest := buildManifest("c1", "hls", renditions, abr, func(name string) string { return name + ".m3u8" })
+
+	iframes := manifest.IFrameVariants
+	if len(iframes) != 2 || iframes[0].Name != "iframe-1080p" || iframes[1].Name != "iframe-360p" {
+		t.Fatalf("expected I-frame variants within the height cap, highest first, got %+v", iframes)
+	}
+	if len(manifest.ImageStreams) != 1 || manifest.ImageStreams[0].Resolution != "320x180" || manifest.ImageStreams[0].Codec != "jpeg" {
+		t.Fatalf("expected one jpeg image stream with the tile size, got %+v", manifest.ImageStreams)
+	}
+	if len(manifest.Variants) != 3 {
+		t.Fatalf("expected trick play tracks to stay out of the variants, got %v", variantNames(manifest))
+	}
+}
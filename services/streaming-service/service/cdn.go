@@ -0,0 +1,104 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/streamverse/streaming-service/internal/cdn"
+	"github.com/streamverse/streaming-service/models"
+)
+
+// segmentTokenTTL bounds CDN tokens on segment URLs. VOD media playlists are
+// fetched once, so the token has to outlast a long film watched with pauses.
+const segmentTokenTTL = 6 * time.Hour
+
+// rankCDNs orders the CDNs a viewer may play from, preferred first. Without a CDN
+// config content is served unsigned from the default origin.
+func (s *StreamingService) rankCDNs(claims *models.StreamingClaims, clientIP string) []*cdn.Provider {
+	var country string
+	if s.geo != nil {
+		country, _ = s.geo.Country(clientIP)
+	}
+	if ranked := s.cdn.Rank(claims.TenantID, claims.ContentID, country, claims.TokenID); len(ranked) > 0 {
+		return ranked
+	}
+	return []*cdn.Provider{{Name: "default", BaseURL: s.getCDNBaseURL(), Signer: cdn.NopSigner{}}}
+}
+
+// manifestCDNs returns the CDNs written into a manifest: every ranked CDN under
+// content steering, otherwise only the preferred one
+func (s *StreamingService) manifestCDNs(claims *models.StreamingClaims, clientIP string) []*cdn.Provider {
+	ranked := s.rankCDNs(claims, clientIP)
+	if !s.cdn.ContentSteering() {
+		return ranked[:1]
+	}
+	return ranked
+}
+
+// cdnForPathway returns the CDN a player steered to, or the preferred CDN when the
+// pathway is empty or not offered to this viewer
+func (s *StreamingService) cdnForPathway(claims *models.StreamingClaims, clientIP, pathway string) *cdn.Provider {
+	ranked := s.rankCDNs(claims, clientIP)
+	for _, provider := range ranked {
+		if provider.Name == pathway {
+			return provider
+		}
+	}
+	return ranked[0]
+}
+
+// SteeringManifest answers a content steering request with the current CDN
+// priority for the viewer. format is "dash" for DASH players, otherwise HLS.
+func (s *StreamingService) SteeringManifest(claims *models.StreamingClaims, clientIP, format string) *models.SteeringManifest {
+	var priority []string
+	for _, provider := range s.rankCDNs(claims, clientIP) {
+		priority = append(priority, provider.Name)
+	}
+
+	manifest := &models.SteeringManifest{Version: 1, TTL: int(s.cdn.SteeringTTL().Seconds())}
+	if format == "dash" {
+		manifest.ServiceLocationPriority = priority
+	} else {
+		manifest.PathwayPriority = priority
+	}
+	return manifest
+}
+
+// renditionToken issues one path-prefix token authorizing every segment of a rendition
+func renditionToken(provider *cdn.Provider, contentID, renditionName string) (string, error) {
+	base, err := url.Parse(provider.BaseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid CDN base URL: %w", err)
+	}
+	prefix := path.Join("/", base.Path, contentID, renditionName) + "/"
+
+	token, err := provider.Signer.PrefixToken(prefix, time.Now().Add(segmentTokenTTL))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign %s segments: %w", provider.Name, err)
+	}
+	return token, nil
+}
+
+// renditionTokens issues segment tokens for every rendition on every listed CDN.
+// DASH segment templates are shared by all service locations, so the tokens of
+// all CDNs are sent together and each CDN validates its own parameters.
+func renditionTokens(providers []*cdn.Provider, contentID string, renditions []models.Rendition) (map[string]string, error) {
+	tokens := make(map[string]string, len(renditions))
+	for _, r := range renditions {
+		var parts []string
+		for _, provider := range providers {
+			token, err := renditionToken(provider, contentID, r.Name)
+			if err != nil {
+				return nil, err
+			}
+			if token != "" {
+				parts = append(parts, token)
+			}
+		}
+		tokens[r.Name] = strings.Join(parts, "&")
+	}
+	return tokens, nil
+}
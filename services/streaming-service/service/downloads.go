@@ -0,0 +1,390 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/streamverse/streaming-service/internal/license"
+	"github.com/streamverse/streaming-service/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrDownloadsNotIncluded is returned when the plan has no offline downloads
+	ErrDownloadsNotIncluded = errors.New("plan does not include downloads")
+	// ErrDownloadDeviceLimit is returned when the plan's download devices all hold downloads
+	ErrDownloadDeviceLimit = errors.New("download device limit reached")
+	// ErrDownloadLimit is returned when the account holds as many downloads as the plan allows
+	ErrDownloadLimit = errors.New("download limit reached")
+	// ErrDownloadNotFound is returned for an unknown download or one of another user
+	ErrDownloadNotFound = errors.New("download not found")
+	// ErrDownloadRevoked is returned for a download revoked by removing its device
+	ErrDownloadRevoked = errors.New("download has been revoked")
+	// ErrDeviceRequired is returned when a download is requested without a device ID
+	ErrDeviceRequired = errors.New("a device ID is required for downloads")
+)
+
+const (
+	// downloadFeature is the plan feature that includes offline downloads
+	downloadFeature = "downloads"
+	// downloadTokenTTL is how long a device may fetch a download's segments and license
+	downloadTokenTTL = 6 * time.Hour
+)
+
+type downloadStore interface {
+	CreateDownload(ctx context.Context, download *models.Download) (bool, error)
+	GetDownload(ctx context.Context, id string) (*models.Download, error)
+	FindActiveDownload(ctx context.Context, userID, contentID, deviceID string) (*models.Download, error)
+	ListActiveDownloads(ctx context.Context, userID string) ([]models.Download, error)
+	ListDownloads(ctx context.Context, userID, deviceID string) ([]models.Download, error)
+	RenewDownload(ctx context.Context, download *models.Download) (bool, error)
+	DeleteDownload(ctx context.Context, userID string, id primitive.ObjectID) (bool, error)
+	RevokeDeviceDownloads(ctx context.Context, userID, deviceID, reason string) (int64, error)
+}
+
+// DownloadRequest asks for a title to be downloaded to a device
+type DownloadRequest struct {
+	UserID      string
+	ContentID   string
+	DeviceID    string
+	DeviceType  string
+	DRMSecurity string // "hardware" or "software" DRM, empty when unknown
+	TenantID    string
+	ClientIP    string
+	Quality     string // optional cap below the entitlement, e.g. "720p" to save storage
+}
+
+// DownloadTicket is what a device needs to download a title: a token for the
+// playback proxy, the renditions to fetch and the persistent license it will get
+type DownloadTicket struct {
+	Download      *models.Download       `json:"download"`
+	Token         string                 `json:"token"`
+	ExpiresIn     int                    `json:"expiresIn"` // seconds
+	HLSURL        string                 `json:"hlsUrl,omitempty"`
+	DASHURL       string                 `json:"dashUrl,omitempty"`
+	Tracks        []models.DownloadTrack `json:"tracks"`
+	EstimatedSize int64                  `json:"estimatedSize"`     // bytes
+	License       *license.Policy        `json:"license,omitempty"` // nil for unprotected titles
+}
+
+// downloadQuota is a plan's download allowance; zero values are unlimited
+type downloadQuota struct {
+	devices   int
+	downloads int
+}
+
+// DownloadManager issues offline downloads within the plan's quotas, tracks them
+// per device and revokes them when a device is removed
+type DownloadManager struct {
+	streaming *StreamingService
+	store     downloadStore
+	licenses  license.Config
+}
+
+// NewDownloadManager creates a download manager
+func NewDownloadManager(streamingService *StreamingService, store downloadStore, licenses license.Config) *DownloadManager {
+	return &DownloadManager{
+		streaming: streamingService,
+		store:     store,
+		licenses:  licenses,
+	}
+}
+
+// RequestDownload checks the plan includes downloads and has room for one more on
+// the device, and returns a ticket for it. Requesting a title the device already
+// holds renews that download instead, which is how devices extend offline licenses.
+func (m *DownloadManager) RequestDownload(ctx context.Context, req *DownloadRequest) (*DownloadTicket, error) {
+	if req.DeviceID == "" {
+		return nil, ErrDeviceRequired
+	}
+
+	content, err := m.streaming.contentClient.GetContent(ctx, req.ContentID)
+	if err != nil {
+		return nil, fmt.Errorf("content not found: %w", err)
+	}
+	if err := m.streaming.checkGeoRestrictions(content, req.ClientIP); err != nil {
+		return nil, err
+	}
+
+	subscription, err := m.streaming.paymentClient.GetSubscription(ctx, req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check subscription: %w", err)
+	}
+	if !subscription.GetIsActive() {
+		return nil, ErrNoActiveSubscription
+	}
+	if !slices.Contains(subscription.GetFeatures(), downloadFeature) {
+		return nil, ErrDownloadsNotIncluded
+	}
+
+	renditions, err := m.streaming.getRenditions(ctx, req.ContentID)
+	if err != nil {
+		return nil, err
+	}
+	decision := decideQuality(renditions, subscription.GetQuality(), req.DRMSecurity, content.IsDrmProtected)
+	maxHeight := decision.MaxHeight
+	if req.Quality != "" {
+		if height := profileHeight(req.Quality); maxHeight == 0 || height < maxHeight {
+			maxHeight = height
+		}
+	}
+	tracks, height := downloadTracks(renditions, maxHeight)
+
+	now := time.Now()
+	var policy *license.Policy
+	licenseExpiresAt := now.Add(m.licenses.OfflineLicenseDuration)
+	if content.IsDrmProtected {
+		// The license itself is issued under policy-service's decision, DRM level included
+		policy, err = m.licenses.DecideOffline(license.Grant{
+			Reason:    "subscription",
+			MaxHeight: height,
+		}, now)
+		if err != nil {
+			return nil, err
+		}
+		licenseExpiresAt = now.Add(time.Duration(policy.LicenseDuration) * time.Second)
+	}
+
+	download, err := m.store.FindActiveDownload(ctx, req.UserID, req.ContentID, req.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load download: %w", err)
+	}
+	if download != nil {
+		download.Quality = qualityName(height)
+		download.MaxHeight = height
+		download.LicenseExpiresAt = licenseExpiresAt
+		download.UpdatedAt = now
+		renewed, err := m.store.RenewDownload(ctx, download)
+		if err != nil {
+			return nil, fmt.Errorf("failed to renew download: %w", err)
+		}
+		if !renewed {
+			return nil, ErrDownloadRevoked
+		}
+	} else {
+		download = &models.Download{
+			ID:               primitive.NewObjectID(),
+			UserID:           req.UserID,
+			ContentID:        req.ContentID,
+			DeviceID:         req.DeviceID,
+			Quality:          qualityName(height),
+			MaxHeight:        height,
+			State:            models.DownloadStateActive,
+			LicenseExpiresAt: licenseExpiresAt,
+			CreatedAt:        now,
+			UpdatedAt:        now,
+		}
+		quota := downloadQuota{
+			devices:   int(subscription.GetMaxDownloadDevices()),
+			downloads: int(subscription.GetMaxDownloads()),
+		}
+		if err := m.admit(ctx, download, quota); err != nil {
+			return nil, err
+		}
+	}
+
+	token, err := m.downloadToken(download, req, now)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket := &DownloadTicket{
+		Download:  download,
+		Token:     token,
+		ExpiresIn: int(downloadTokenTTL / time.Second),
+		Tracks:    tracks,
+		License:   policy,
+	}
+	for _, track := range tracks {
+		ticket.EstimatedSize += track.EstimatedSize
+	}
+	return ticket, nil
+}
+
+// admit stores a new download within the plan's quotas. The quotas are checked
+// again after the insert against the downloads created before it, so when two
+// devices race for the last slot only the earlier download keeps it.
+func (m *DownloadManager) admit(ctx context.Context, download *models.Download, quota downloadQuota) error {
+	active, err := m.store.ListActiveDownloads(ctx, download.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to load downloads: %w", err)
+	}
+	if err := quota.check(active, download); err != nil {
+		return err
+	}
+
+	created, err := m.store.CreateDownload(ctx, download)
+	if err != nil {
+		return fmt.Errorf("failed to create download: %w", err)
+	}
+	if !created {
+		// The device requested the title concurrently; share that download
+		existing, err := m.store.FindActiveDownload(ctx, download.UserID, download.ContentID, download.DeviceID)
+		if err != nil || existing == nil {
+			return fmt.Errorf("failed to load download: %w", err)
+		}
+		*download = *existing
+		return nil
+	}
+
+	active, err = m.store.ListActiveDownloads(ctx, download.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to load downloads: %w", err)
+	}
+	earlier := active
+	for i := range active {
+		if active[i].ID == download.ID {
+			earlier = active[:i]
+			break
+		}
+	}
+	if err := quota.check(earlier, download); err != nil {
+		if _, deleteErr := m.store.DeleteDownload(ctx, download.UserID, download.ID); deleteErr != nil {
+			// Log error; the download stays over quota until the user deletes it
+		}
+		return err
+	}
+	return nil
+}
+
+// check reports whether download fits the quota alongside the active downloads
+func (q downloadQuota) check(active []models.Download, download *models.Download) error {
+	if q.downloads > 0 && len(active) >= q.downloads {
+		return ErrDownloadLimit
+	}
+	devices := map[string]bool{download.DeviceID: true}
+	for _, d := range active {
+		devices[d.DeviceID] = true
+	}
+	if q.devices > 0 && len(devices) > q.devices {
+		return ErrDownloadDeviceLimit
+	}
+	return nil
+}
+
+// downloadTracks picks the renditions a device downloads: the tallest video within
+// maxHeight (or the lowest when none fits) with every audio and subtitle track.
+// It returns the tracks and the height of the video.
+func downloadTracks(renditions []models.Rendition, maxHeight int) ([]models.DownloadTrack, int) {
+	video := -1
+	for i, r := range renditions {
+		if r.Type != "video" || (maxHeight > 0 && r.Height > maxHeight) {
+			continue
+		}
+		if video < 0 || r.Height > renditions[video].Height ||
+			(r.Height == renditions[video].Height && r.Bandwidth > renditions[video].Bandwidth) {
+			video = i
+		}
+	}
+	if video < 0 {
+		for i, r := range renditions {
+			if r.Type == "video" && (video < 0 || r.Height < renditions[video].Height) {
+				video = i
+			}
+		}
+	}
+
+	var tracks []models.DownloadTrack
+	height := 0
+	for i, r := range renditions {
+		// Trick play tracks are for streaming; downloads play from local storage.
+		// Embedded captions come with the video.
+		if (r.Type == "video" && i != video) || r.Type == "iframe" || r.Type == "thumbnail" || r.Type == "caption" {
+			continue
+		}
+		track := models.DownloadTrack{
+			Name:          r.Name,
+			Type:          r.Type,
+			Language:      r.Language,
+			Bandwidth:     r.Bandwidth,
+			EstimatedSize: int64(float64(r.Bandwidth) * r.TotalDuration() / 8),
+		}
+		if r.Type == "video" {
+			track.Resolution = r.Resolution()
+			height = r.Height
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, height
+}
+
+// downloadToken signs a playback token for fetching a download. It carries the
+// download so the license proxy issues a persistent license for it.
+func (m *DownloadManager) downloadToken(download *models.Download, req *DownloadRequest, now time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"content_id":  download.ContentID,
+		"user_id":     download.UserID,
+		"tenant_id":   req.TenantID,
+		"download_id": download.ID.Hex(),
+		"quality":     download.Quality,
+		"max_height":  download.MaxHeight,
+		"ip":          req.ClientIP,
+		"device_id":   download.DeviceID,
+		"device_type": req.DeviceType,
+		"jti":         primitive.NewObjectID().Hex(),
+		"exp":         jwt.NewNumericDate(now.Add(downloadTokenTTL)),
+		"nbf":         jwt.NewNumericDate(now),
+		"iat":         jwt.NewNumericDate(now),
+		"aud":         tokenAudience,
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(m.streaming.jwtSecret))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return token, nil
+}
+
+// ListDownloads returns a user's downloads, optionally only those of one device.
+// Devices sync with it and delete downloads that were revoked.
+func (m *DownloadManager) ListDownloads(ctx context.Context, userID, deviceID string) ([]models.Download, error) {
+	return m.store.ListDownloads(ctx, userID, deviceID)
+}
+
+// DeleteDownload removes a download the user deleted from a device, freeing its quota
+func (m *DownloadManager) DeleteDownload(ctx context.Context, userID, downloadID string) error {
+	id, err := primitive.ObjectIDFromHex(downloadID)
+	if err != nil {
+		return ErrDownloadNotFound
+	}
+	deleted, err := m.store.DeleteDownload(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrDownloadNotFound
+	}
+	return nil
+}
+
+// RevokeDevice revokes every download of a device removed from the account, so
+// their licenses are no longer issued or renewed, and returns how many there were
+func (m *DownloadManager) RevokeDevice(ctx context.Context, userID, deviceID string) (int64, error) {
+	if deviceID == "" {
+		return 0, ErrDeviceRequired
+	}
+	return m.store.RevokeDeviceDownloads(ctx, userID, deviceID, models.DownloadRevokedDeviceRemoved)
+}
+
+// authorizeLicense returns the download a download token was issued for, as long
+// as it is still active on the token's device
+func (m *DownloadManager) authorizeLicense(ctx context.Context, claims *models.StreamingClaims) (*models.Download, error) {
+	download, err := m.store.GetDownload(ctx, claims.DownloadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load download: %w", err)
+	}
+	if download == nil || download.UserID != claims.UserID || download.ContentID != claims.ContentID {
+		return nil, ErrDownloadNotFound
+	}
+	if download.State != models.DownloadStateActive {
+		return nil, ErrDownloadRevoked
+	}
+	if download.DeviceID != claims.DeviceID {
+		return nil, fmt.Errorf("%w: download belongs to another device", ErrTokenMismatch)
+	}
+	return download, nil
+}
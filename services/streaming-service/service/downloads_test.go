@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/streamverse/streaming-service/internal/clients/policy"
+	"github.com/streamverse/streaming-service/internal/license"
+	"github.com/streamverse/streaming-service/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryDownloadStore keeps downloads in creation order
+type memoryDownloadStore struct {
+	downloads []models.Download
+	// beforeCreate runs inside CreateDownload, to interleave a concurrent request
+	beforeCreate func()
+}
+
+func (s *memoryDownloadStore) CreateDownload(ctx context.Context, download *models.Download) (bool, error) {
+	if s.beforeCreate != nil {
+		hook := s.beforeCreate
+		s.beforeCreate = nil
+		hook()
+	}
+	if d, _ := s.FindActiveDownload(ctx, download.UserID, download.ContentID, download.DeviceID); d != nil {
+		return false, nil
+	}
+	s.downloads = append(s.downloads, *download)
+	return true, nil
+}
+
+func (s *memoryDownloadStore) GetDownload(ctx context.Context, id string) (*models.Download, error) {
+	for i := range s.downloads {
+		if s.downloads[i].ID.Hex() == id {
+			d := s.downloads[i]
+			return &d, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryDownloadStore) FindActiveDownload(ctx context.Context, userID, contentID, deviceID string) (*models.Download, error) {
+	for i := range s.downloads {
+		d := s.downloads[i]
+		if d.UserID == userID && d.ContentID == contentID && d.DeviceID == deviceID && d.State == models.DownloadStateActive {
+			return &d, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryDownloadStore) ListActiveDownloads(ctx context.Context, userID string) ([]models.Download, error) {
+	var active []models.Download
+	for _, d := range s.downloads {
+		if d.UserID == userID && d.State == models.DownloadStateActive {
+			active = append(active, d)
+		}
+	}
+	return active, nil
+}
+
+func (s *memoryDownloadStore) ListDownloads(ctx context.Context, userID, deviceID string) ([]models.Download, error) {
+	var downloads []models.Download
+	for _, d := range s.downloads {
+		if d.UserID == userID && (deviceID == "" || d.DeviceID == deviceID) {
+			downloads = append(downloads, d)
+		}
+	}
+	return downloads, nil
+}
+
+func (s *memoryDownloadStore) RenewDownload(ctx context.Context, download *models.Download) (bool, error) {
+	for i := range s.downloads {
+		if s.downloads[i].ID == download.ID && s.downloads[i].State == models.DownloadStateActive {
+			s.downloads[i] = *download
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryDownloadStore) DeleteDownload(ctx context.Context, userID string, id primitive.ObjectID) (bool, error) {
+	for i := range s.downloads {
+		if s.downloads[i].ID == id && s.downloads[i].UserID == userID {
+			s.downloads = append(s.downloads[:i], s.downloads[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryDownloadStore) RevokeDeviceDownloads(ctx context.Context, userID, deviceID, reason string) (int64, error) {
+	var revoked int64
+	for i := range s.downloads {
+		d := &s.downloads[i]
+		if d.UserID == userID && d.DeviceID == deviceID && d.State == models.DownloadStateActive {
+			d.State, d.RevokeReason = models.DownloadStateRevoked, reason
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func newTestDownload(contentID, deviceID string) *models.Download {
+	return &models.Download{
+		ID:        primitive.NewObjectID(),
+		UserID:    "u1",
+		ContentID: contentID,
+		DeviceID:  deviceID,
+		State:     models.DownloadStateActive,
+		MaxHeight: 720,
+	}
+}
+
+func TestAdmitEnforcesDownloadQuotas(t *testing.T) {
+	store := &memoryDownloadStore{}
+	manager := NewDownloadManager(&StreamingService{}, store, license.Config{})
+	quota := downloadQuota{devices: 2, downloads: 3}
+	ctx := context.Background()
+
+	for _, d := range []*models.Download{newTestDownload("c1", "d1"), newTestDownload("c2", "d1"), newTestDownload("c1", "d2")} {
+		if err := manager.admit(ctx, d, quota); err != nil {
+			t.Fatalf("expected download within quota, got %v", err)
+		}
+	}
+	if err := manager.admit(ctx, newTestDownload("c3", "d2"), quota); !errors.Is(err, ErrDownloadLimit) {
+		t.Fatalf("expected download limit, got %v", err)
+	}
+
+	if err := manager.DeleteDownload(ctx, "u1", store.downloads[0].ID.Hex()); err != nil {
+		t.Fatalf("DeleteDownload: %v", err)
+	}
+	if err := manager.admit(ctx, newTestDownload("c3", "d3"), quota); !errors.Is(err, ErrDownloadDeviceLimit) {
+		t.Fatalf("expected device limit, got %v", err)
+	}
+	if err := manager.admit(ctx, newTestDownload("c3", "d2"), quota); err != nil {
+		t.Fatalf("expected a deleted download to free its slot, got %v", err)
+	}
+}
+
+func TestAdmitRaceKeepsEarlierDownload(t *testing.T) {
+	store := &memoryDownloadStore{}
+	manager := NewDownloadManager(&StreamingService{}, store, license.Config{})
+	quota := downloadQuota{downloads: 1}
+	ctx := context.Background()
+
+	// Another device takes the last slot between the quota check and the insert
+	store.beforeCreate = func() {
+		store.downloads = append(store.downloads, *newTestDownload("c2", "d2"))
+	}
+	if err := manager.admit(ctx, newTestDownload("c1", "d1"), quota); !errors.Is(err, ErrDownloadLimit) {
+		t.Fatalf("expected the later download to lose the race, got %v", err)
+	}
+	if len(store.downloads) != 1 || store.downloads[0].DeviceID != "d2" {
+		t.Fatalf("expected only the earlier download to remain, got %+v", store.downloads)
+	}
+
+	// The same device requesting concurrently shares one download
+	existing := newTestDownload("c3", "d3")
+	store.downloads = nil
+	store.beforeCreate = func() {
+		store.downloads = append(store.downloads, *existing)
+	}
+	duplicate := newTestDownload("c3", "d3")
+	if err := manager.admit(ctx, duplicate, downloadQuota{}); err != nil || duplicate.ID != existing.ID {
+		t.Fatalf("expected the concurrent download to be shared, got %v", err)
+	}
+}
+
+func TestDownloadTracksPicksOneVideo(t *testing.T) {
+	segments := []models.Segment{{Duration: 100}}
+	renditions := []models.Rendition{
+		{Name: "360p", Type: "video", Width: 640, Height: 360, Bandwidth: 800000, Segments: segments},
+		{Name: "720p", Type: "video", Width: 1280, Height: 720, Bandwidth: 2400000, Segments: segments},
+		{Name: "1080p", Type: "video", Width: 1920, Height: 1080, Bandwidth: 4800000, Segments: segments},
+		{Name: "audio_en", Type: "audio", Language: "en", Bandwidth: 128000, Segments: segments},
+		{Name: "subs_en", Type: "subtitle", Language: "en"},
+	}
+
+	tracks, height := downloadTracks(renditions, 720)
+	if height != 720 || len(tracks) != 3 || tracks[0].Name != "720p" || tracks[0].Resolution != "1280x720" {
+		t.Fatalf("expected 720p with audio and subtitles, got %d %+v", height, tracks)
+	}
+	if tracks[0].EstimatedSize != 30000000 {
+		t.Fatalf("expected size from bandwidth and duration, got %d", tracks[0].EstimatedSize)
+	}
+
+	if _, height := downloadTracks(renditions, 0); height != 1080 {
+		t.Fatalf("expected the best video when uncapped, got %d", height)
+	}
+	if _, height := downloadTracks(renditions, 240); height != 360 {
+		t.Fatalf("expected the lowest video when none fits, got %d", height)
+	}
+}
+
+func TestLicenseProxyDownloadTokens(t *testing.T) {
+	store := &memoryDownloadStore{}
+	download := newTestDownload("c1", "d1")
+	store.downloads = append(store.downloads, *download)
+
+	streaming := &StreamingService{jwtSecret: "secret"}
+	config := license.Config{LicenseDuration: 24 * time.Hour, RentalPlaybackWindow: 48 * time.Hour, OfflineLicenseDuration: 30 * 24 * time.Hour}
+	manager := NewDownloadManager(streaming, store, config)
+	upstream := &license.FakeUpstream{}
+	evaluator := &stubEvaluator{decision: &policy.Decision{HasAccess: true, Reason: "subscription", DRMLevel: "2"}}
+	proxy := NewLicenseProxy(streaming, stubEntitlements{}, evaluator, upstream, config, manager)
+
+	ctx := context.Background()
+	req := &LicenseRequest{
+		System:    license.SystemWidevine,
+		Challenge: []byte("challenge"),
+		PlaybackToken: signTestToken(t, "secret", jwt.MapClaims{
+			"user_id":     "u1",
+			"content_id":  "c1",
+			"device_id":   "d1",
+			"download_id": download.ID.Hex(),
+			"aud":         tokenAudience,
+			"exp":         jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}),
+		UserID:   "u1",
+		DeviceID: "d1",
+	}
+	if _, err := proxy.Acquire(ctx, req); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	sent := upstream.Requests[0].Policy
+	if !sent.Persistent || sent.LicenseDuration != 30*24*3600 || sent.HDCP != license.HDCPV1 {
+		t.Fatalf("expected a 30-day persistent license for a 720p download, got %+v", sent)
+	}
+
+	if revoked, _ := manager.RevokeDevice(ctx, "u1", "d1"); revoked != 1 {
+		t.Fatalf("expected one download revoked, got %d", revoked)
+	}
+	if _, err := proxy.Acquire(ctx, req); !errors.Is(err, ErrDownloadRevoked) {
+		t.Fatalf("expected revoked download to be refused a license, got %v", err)
+	}
+}
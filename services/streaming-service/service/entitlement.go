@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/streamverse/streaming-service/internal/license"
+	"github.com/streamverse/streaming-service/models"
+	"github.com/streamverse/streaming-service/utils"
+)
+
+// softwareDRMMaxHeight caps protected video on devices without hardware-backed DRM
+const softwareDRMMaxHeight = license.SoftwareMaxHeight
+
+// Reasons a quality decision is capped below the best rendition of a title
+const (
+	QualityLimitPlan   = "plan"
+	QualityLimitDevice = "device_security"
+)
+
+// qualityLimit is one cap on video height and why it applies
+type qualityLimit struct {
+	reason string
+	height int
+}
+
+// decideQuality works out the tallest video a viewer may receive: the plan's
+// quality and, for protected content, whether the device has hardware-backed DRM.
+// Each limit that binds is recorded so clients can offer an upgrade for plan limits
+// but not for device limits.
+//
+// The device's security is what the player reports, so the device limit only
+// spares honest software players renditions they could not play. Licenses enforce
+// it: keys of video above softwareDRMMaxHeight need a higher security level than
+// software CDMs have.
+func decideQuality(renditions []models.Rendition, planQuality, deviceSecurity string, protected bool) *models.QualityDecision {
+	contentHeight := 0
+	for _, r := range renditions {
+		if r.Type == "video" && r.Height > contentHeight {
+			contentHeight = r.Height
+		}
+	}
+
+	decision := &models.QualityDecision{
+		PlanQuality:    planQuality,
+		DeviceSecurity: deviceSecurity,
+		ContentQuality: qualityName(contentHeight),
+	}
+
+	// Device limits come first so they win ties: upgrading the plan would not help
+	var limits []qualityLimit
+	if protected && deviceSecurity != utils.DRMSecurityHardware {
+		limits = append(limits, qualityLimit{QualityLimitDevice, softwareDRMMaxHeight})
+	}
+	if planQuality != "" {
+		limits = append(limits, qualityLimit{QualityLimitPlan, profileHeight(planQuality)})
+	}
+
+	maxHeight := contentHeight
+	for _, limit := range limits {
+		if maxHeight == 0 || limit.height < maxHeight {
+			maxHeight = limit.height
+			decision.LimitedBy = limit.reason
+		}
+	}
+	if contentHeight > 0 && maxHeight >= contentHeight {
+		decision.LimitedBy = ""
+	}
+
+	decision.MaxHeight = maxHeight
+	decision.MaxQuality = qualityName(maxHeight)
+	decision.UpgradeAvailable = decision.LimitedBy == QualityLimitPlan
+	return decision
+}
+
+// entitledRenditions drops video renditions taller than the token's entitlement.
+// The lowest video rendition is always kept, as in capLadder.
+func entitledRenditions(renditions []models.Rendition, claims *models.StreamingClaims) []models.Rendition {
+	if claims.MaxHeight <= 0 {
+		return renditions
+	}
+	return capLadder(renditions, &models.ABRProfile{MaxHeight: claims.MaxHeight})
+}
+
+// qualityName is the inverse of profileHeight
+func qualityName(height int) string {
+	switch {
+	case height <= 0:
+		return ""
+	case height >= 2160:
+		return "4K"
+	default:
+		return fmt.Sprintf("%dp", height)
+	}
+}
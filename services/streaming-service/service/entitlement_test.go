@@ -0,0 +1,57 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/streamverse/streaming-service/models"
+	"github.com/streamverse/streaming-service/utils"
+)
+
+func TestDecideQualityPlanLimit(t *testing.T) {
+	decision := decideQuality(testLadder(), "720p", utils.DRMSecurityHardware, true)
+	if decision.MaxHeight != 720 || decision.LimitedBy != QualityLimitPlan || !decision.UpgradeAvailable {
+		t.Fatalf("expected plan cap at 720p with upgrade, got %+v", decision)
+	}
+	if decision.ContentQuality != "4K" {
+		t.Fatalf("expected 4K content, got %+v", decision)
+	}
+}
+
+func TestDecideQualityDeviceLimit(t *testing.T) {
+	decision := decideQuality(testLadder(), "4K", utils.DRMSecurityHardware, true)
+	if decision.MaxHeight != 2160 || decision.LimitedBy != "" || decision.UpgradeAvailable {
+		t.Fatalf("expected uncapped 4K on hardware DRM, got %+v", decision)
+	}
+
+	decision = decideQuality(testLadder(), "4K", "", true)
+	if decision.MaxHeight != softwareDRMMaxHeight || decision.LimitedBy != QualityLimitDevice || decision.UpgradeAvailable {
+		t.Fatalf("expected device cap without upgrade, got %+v", decision)
+	}
+
+	// Device and plan both allow 720p: the device binds, so upgrading would not help
+	decision = decideQuality(testLadder(), "720p", utils.DRMSecuritySoftware, true)
+	if decision.MaxHeight != 720 || decision.LimitedBy != QualityLimitDevice || decision.UpgradeAvailable {
+		t.Fatalf("expected tie to be attributed to the device, got %+v", decision)
+	}
+}
+
+func TestDecideQualityClearContent(t *testing.T) {
+	decision := decideQuality(testLadder(), "4K", utils.DRMSecuritySoftware, false)
+	if decision.MaxHeight != 2160 || decision.LimitedBy != "" {
+		t.Fatalf("expected clear content uncapped by DRM, got %+v", decision)
+	}
+}
+
+func TestEntitledRenditions(t *testing.T) {
+	var names []string
+	for _, r := range entitledRenditions(testLadder(), &models.StreamingClaims{MaxHeight: 720}) {
+		names = append(names, r.Name)
+	}
+	if len(names) != 3 || names[0] != "720p" || names[1] != "360p" || names[2] != "audio-en" {
+		t.Fatalf("expected ladder cut at 720p, got %v", names)
+	}
+
+	if got := entitledRenditions(testLadder(), &models.StreamingClaims{}); len(got) != len(testLadder()) {
+		t.Fatalf("expected tokens without an entitlement to keep the full ladder")
+	}
+}
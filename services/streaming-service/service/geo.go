@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	content_proto "github.com/streamverse/proto/gen/go/content"
+)
+
+// ErrGeoBlocked is returned when content is not licensed in the viewer's territory
+var ErrGeoBlocked = errors.New("content is not available in your territory")
+
+// checkGeoRestrictions resolves the client IP to a country and applies the
+// content's territory lists and the platform-wide GEO_BLOCKED_COUNTRIES list.
+// Without a GeoIP database nothing is enforced.
+func (s *StreamingService) checkGeoRestrictions(content *content_proto.GetContentResponse, clientIP string) error {
+	if s.geo == nil {
+		return nil
+	}
+
+	country, err := s.geo.Country(clientIP)
+	if err != nil {
+		// Unparseable or unknown addresses are treated as an unknown country
+		country = ""
+	}
+
+	if !territoryAllowed(country, content.GetAllowedTerritories(), content.GetBlockedTerritories()) {
+		return ErrGeoBlocked
+	}
+	return nil
+}
+
+// territoryAllowed reports whether a country may play content. Deny lists win over
+// allow lists. An unknown country passes deny lists but not an allow list, since
+// content licensed to a few territories must not leak to unlocated viewers.
+func territoryAllowed(country string, allowed, blocked []string) bool {
+	country = strings.ToUpper(strings.TrimSpace(country))
+
+	if country != "" {
+		if containsTerritory(blocked, country) {
+			return false
+		}
+		if containsTerritory(strings.Split(os.Getenv("GEO_BLOCKED_COUNTRIES"), ","), country) {
+			return false
+		}
+	}
+
+	if len(allowed) == 0 {
+		return true
+	}
+	return country != "" && containsTerritory(allowed, country)
+}
+
+func containsTerritory(territories []string, country string) bool {
+	for _, territory := range territories {
+		if strings.ToUpper(strings.TrimSpace(territory)) == country {
+			return true
+		}
+	}
+	return false
+}
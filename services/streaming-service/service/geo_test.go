@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	content_proto "github.com/streamverse/proto/gen/go/content"
+	"github.com/streamverse/streaming-service/models"
+)
+
+type staticContent map[string]*content_proto.GetContentResponse
+
+func (c staticContent) GetContent(ctx context.Context, id string) (*content_proto.GetContentResponse, error) {
+	content, ok := c[id]
+	if !ok {
+		return nil, errors.New("content not found")
+	}
+	return content, nil
+}
+
+type staticLocator map[string]string
+
+func (l staticLocator) Country(ip string) (string, error) {
+	country, ok := l[ip]
+	if !ok {
+		return "", errors.New("address not found")
+	}
+	return country, nil
+}
+
+func TestTerritoryAllowed(t *testing.T) {
+	t.Setenv("GEO_BLOCKED_COUNTRIES", "KP")
+
+	cases := []struct {
+		country          string
+		allowed, blocked []string
+		want             bool
+	}{
+		{"US", nil, nil, true},
+		{"", nil, []string{"US"}, true},
+		{"kp", nil, nil, false},
+		{"US", []string{"us", "CA"}, nil, true},
+		{"GB", []string{"US", "CA"}, nil, false},
+		{"", []string{"US"}, nil, false},
+		{"CA", []string{"US", "CA"}, []string{"CA"}, false},
+	}
+	for _, tc := range cases {
+		if got := territoryAllowed(tc.country, tc.allowed, tc.blocked); got != tc.want {
+			t.Fatalf("country %q allowed=%v blocked=%v: expected %v, got %v", tc.country, tc.allowed, tc.blocked, tc.want, got)
+		}
+	}
+}
+
+func TestCheckGeoRestrictionsResolvesClientIP(t *testing.T) {
+	content := &content_proto.GetContentResponse{AllowedTerritories: []string{"US"}}
+
+	s := &StreamingService{geo: staticLocator{"203.0.113.7": "US", "198.51.100.1": "FR"}}
+	if err := s.checkGeoRestrictions(content, "203.0.113.7"); err != nil {
+		t.Fatalf("expected US viewer to pass, got %v", err)
+	}
+	if err := s.checkGeoRestrictions(content, "198.51.100.1"); !errors.Is(err, ErrGeoBlocked) {
+		t.Fatalf("expected FR viewer to be blocked, got %v", err)
+	}
+	if err := s.checkGeoRestrictions(content, "10.0.0.1"); !errors.Is(err, ErrGeoBlocked) {
+		t.Fatalf("expected unlocated viewer to be blocked by the allow list, got %v", err)
+	}
+
+	unconfigured := &StreamingService{}
+	if err := unconfigured.checkGeoRestrictions(content, "198.51.100.1"); err != nil {
+		t.Fatalf("expected no enforcement without a GeoIP database, got %v", err)
+	}
+}
+
+func TestMediaPlaylistGeoBlocked(t *testing.T) {
+	s := &StreamingService{
+		contentClient: staticContent{"c1": {Id: "c1", BlockedTerritories: []string{"FR"}}},
+		geo:           staticLocator{"198.51.100.1": "FR"},
+	}
+
+	// A token issued elsewhere does not unlock the media playlists in a blocked region
+	claims := &models.StreamingClaims{UserID: "u1", ContentID: "c1"}
+	if _, err := s.GenerateHLSMediaPlaylist(context.Background(), "c1", "720p", claims, "198.51.100.1", ""); !errors.Is(err, ErrGeoBlocked) {
+		t.Fatalf("expected the media playlist to be geo-blocked, got %v", err)
+	}
+}
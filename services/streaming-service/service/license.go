@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/streamverse/streaming-service/internal/clients/policy"
+	"github.com/streamverse/streaming-service/internal/license"
+)
+
+var (
+	// ErrLicenseUnauthorized is returned when the playback token does not authorize
+	// a license; it wraps the token error
+	ErrLicenseUnauthorized = errors.New("playback token rejected")
+	// ErrLicenseDenied is returned when policy-service does not entitle the viewer to the content
+	ErrLicenseDenied = errors.New("license denied")
+)
+
+// EntitlementSource returns a user's subscription and purchase records
+type EntitlementSource interface {
+	GetUserEntitlements(ctx context.Context, userID, authHeader string) ([]map[string]interface{}, error)
+}
+
+// EntitlementEvaluator decides whether a user may play content
+type EntitlementEvaluator interface {
+	EvaluateEntitlement(ctx context.Context, input policy.Input, authHeader string) (*policy.Decision, error)
+}
+
+// LicenseRequest is a player's license request
+type LicenseRequest struct {
+	System        string // widevine, playready, fairplay
+	Challenge     []byte
+	PlaybackToken string // token the manifest was issued with
+	AuthHeader    string // the viewer's bearer token, forwarded to payment and policy services
+	UserID        string // authenticated viewer
+	ClientIP      string
+	DeviceID      string
+}
+
+// LicenseProxy authorizes license requests against the playback token and
+// policy-service, sets the license policy and forwards the challenge to the DRM
+// vendor's license server
+type LicenseProxy struct {
+	streaming    *StreamingService
+	entitlements EntitlementSource
+	policy       EntitlementEvaluator
+	upstream     license.Upstream
+	config       license.Config
+	downloads    *DownloadManager
+}
+
+// NewLicenseProxy creates a license proxy. Download tokens are refused when
+// downloads is nil.
+func NewLicenseProxy(streamingService *StreamingService, entitlements EntitlementSource, evaluator EntitlementEvaluator, upstream license.Upstream, config license.Config, downloads *DownloadManager) *LicenseProxy {
+	return &LicenseProxy{
+		streaming:    streamingService,
+		entitlements: entitlements,
+		policy:       evaluator,
+		upstream:     upstream,
+		config:       config,
+		downloads:    downloads,
+	}
+}
+
+// Acquire returns a license for the challenge. The playback token must be valid
+// for the client and belong to the authenticated viewer, and the viewer must still
+// be entitled to the content: a cancelled subscription or expired rental stops
+// licenses even while the token is valid. Download tokens get a persistent license
+// while the download is active on the device.
+func (p *LicenseProxy) Acquire(ctx context.Context, req *LicenseRequest) ([]byte, error) {
+	switch req.System {
+	case license.SystemWidevine, license.SystemPlayReady, license.SystemFairPlay:
+	default:
+		return nil, license.ErrUnsupportedSystem
+	}
+	if len(req.Challenge) == 0 {
+		return nil, fmt.Errorf("license challenge is required")
+	}
+
+	claims, err := p.streaming.AuthorizePlaybackToken(ctx, req.PlaybackToken, req.ClientIP, req.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLicenseUnauthorized, err)
+	}
+	if claims.UserID != req.UserID {
+		return nil, fmt.Errorf("%w: %w: issued to another user", ErrLicenseUnauthorized, ErrTokenMismatch)
+	}
+	decide, maxHeight := p.config.Decide, claims.MaxHeight
+	if claims.DownloadID != "" {
+		if p.downloads == nil {
+			return nil, fmt.Errorf("%w: downloads are not enabled", ErrLicenseDenied)
+		}
+		download, err := p.downloads.authorizeLicense(ctx, claims)
+		if err != nil {
+			return nil, err
+		}
+		decide, maxHeight = p.config.DecideOffline, download.MaxHeight
+	}
+
+	records, err := p.entitlements.GetUserEntitlements(ctx, claims.UserID, req.AuthHeader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load entitlements: %w", err)
+	}
+	var country string
+	if p.streaming.geo != nil {
+		country, _ = p.streaming.geo.Country(req.ClientIP)
+	}
+	decision, err := p.policy.EvaluateEntitlement(ctx, policy.Input{
+		ContentID:      claims.ContentID,
+		UserID:         claims.UserID,
+		CountryCode:    country,
+		IsDRMProtected: true,
+		Entitlements:   records,
+	}, req.AuthHeader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate entitlement: %w", err)
+	}
+	if !decision.HasAccess {
+		if decision.Reason == "geo_blocked" {
+			return nil, ErrGeoBlocked
+		}
+		return nil, fmt.Errorf("%w: %s", ErrLicenseDenied, decision.Reason)
+	}
+
+	licensePolicy, err := decide(license.Grant{
+		Reason:    decision.Reason,
+		ExpiresAt: decision.ExpiresAt,
+		DRMLevel:  decision.DRMLevel,
+		MaxHeight: maxHeight,
+	}, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return p.upstream.Acquire(ctx, &license.Request{
+		System:    req.System,
+		Challenge: req.Challenge,
+		ContentID: claims.ContentID,
+		UserID:    claims.UserID,
+		SessionID: claims.SessionID,
+		Policy:    licensePolicy,
+	})
+}
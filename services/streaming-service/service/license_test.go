@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	content_proto "github.com/streamverse/proto/gen/go/content"
+	"github.com/streamverse/streaming-service/internal/clients/policy"
+	"github.com/streamverse/streaming-service/internal/license"
+	"github.com/streamverse/streaming-service/models"
+)
+
+type stubEntitlements struct{}
+
+func (stubEntitlements) GetUserEntitlements(ctx context.Context, userID, authHeader string) ([]map[string]interface{}, error) {
+	return []map[string]interface{}{{"type": "subscription", "status": "active"}}, nil
+}
+
+type stubEvaluator struct {
+	decision *policy.Decision
+	input    policy.Input
+}
+
+func (e *stubEvaluator) EvaluateEntitlement(ctx context.Context, input policy.Input, authHeader string) (*policy.Decision, error) {
+	e.input = input
+	return e.decision, nil
+}
+
+func newTestLicenseProxy(decision *policy.Decision) (*LicenseProxy, *stubEvaluator, *license.FakeUpstream) {
+	evaluator := &stubEvaluator{decision: decision}
+	upstream := &license.FakeUpstream{}
+	config := license.Config{LicenseDuration: 24 * time.Hour, RentalPlaybackWindow: 48 * time.Hour}
+	return NewLicenseProxy(&StreamingService{jwtSecret: "secret"}, stubEntitlements{}, evaluator, upstream, config, nil), evaluator, upstream
+}
+
+func testPlaybackToken(t *testing.T) string {
+	return signTestToken(t, "secret", jwt.MapClaims{
+		"user_id":    "u1",
+		"content_id": "c1",
+		"max_height": 1080,
+		"aud":        tokenAudience,
+		"exp":        jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+}
+
+func TestLicenseProxyForwardsPolicy(t *testing.T) {
+	proxy, evaluator, upstream := newTestLicenseProxy(&policy.Decision{HasAccess: true, Reason: "subscription", DRMLevel: "1"})
+
+	got, err := proxy.Acquire(context.Background(), &LicenseRequest{
+		System:        license.SystemWidevine,
+		Challenge:     []byte("challenge"),
+		PlaybackToken: testPlaybackToken(t),
+		UserID:        "u1",
+	})
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if string(got) != "license:widevine:c1" {
+		t.Fatalf("unexpected license %q", got)
+	}
+	if evaluator.input.ContentID != "c1" || !evaluator.input.IsDRMProtected || len(evaluator.input.Entitlements) != 1 {
+		t.Fatalf("unexpected policy input %+v", evaluator.input)
+	}
+
+	sent := upstream.Requests[0].Policy
+	// A 1080p token licenses FHD on hardware DRM but not UHD, whatever the plan's level
+	if len(sent.Tracks) != 4 || sent.Tracks[3] != (license.TrackPolicy{TrackType: models.TrackTypeFHD, SecurityLevel: license.SecurityLevelHardware}) ||
+		sent.HDCP != license.HDCPV1 || sent.Rental {
+		t.Fatalf("unexpected license policy %+v", sent)
+	}
+}
+
+func TestLicenseProxyRejects(t *testing.T) {
+	ctx := context.Background()
+
+	proxy, _, upstream := newTestLicenseProxy(&policy.Decision{HasAccess: false, Reason: "no_entitlement"})
+	req := &LicenseRequest{System: license.SystemPlayReady, Challenge: []byte("challenge"), PlaybackToken: testPlaybackToken(t), UserID: "u1"}
+	if _, err := proxy.Acquire(ctx, req); !errors.Is(err, ErrLicenseDenied) {
+		t.Fatalf("expected denial, got %v", err)
+	}
+
+	req.UserID = "u2"
+	if _, err := proxy.Acquire(ctx, req); !errors.Is(err, ErrLicenseUnauthorized) || !errors.Is(err, ErrTokenMismatch) {
+		t.Fatalf("expected another user's token to be rejected, got %v", err)
+	}
+
+	req.UserID, req.PlaybackToken = "u1", "not-a-token"
+	if _, err := proxy.Acquire(ctx, req); !errors.Is(err, ErrLicenseUnauthorized) {
+		t.Fatalf("expected invalid token to be rejected, got %v", err)
+	}
+
+	req.System = "clearkey"
+	if _, err := proxy.Acquire(ctx, req); !errors.Is(err, license.ErrUnsupportedSystem) {
+		t.Fatalf("expected unsupported system, got %v", err)
+	}
+
+	expired := time.Now().Add(-time.Hour)
+	proxy, _, _ = newTestLicenseProxy(&policy.Decision{HasAccess: true, Reason: "purchased", ExpiresAt: &expired})
+	req = &LicenseRequest{System: license.SystemFairPlay, Challenge: []byte("spc"), PlaybackToken: testPlaybackToken(t), UserID: "u1"}
+	if _, err := proxy.Acquire(ctx, req); !errors.Is(err, license.ErrRentalExpired) {
+		t.Fatalf("expected expired rental, got %v", err)
+	}
+
+	if len(upstream.Requests) != 0 {
+		t.Fatalf("expected no license requests upstream, got %d", len(upstream.Requests))
+	}
+}
+
+func TestManifestLicenseURLPointsAtProxy(t *testing.T) {
+	s := NewStreamingService(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, "", TokenBindingConfig{}, "https://api.streamverse.io/", "secret")
+	content := &content_proto.GetContentResponse{IsDrmProtected: true, DrmType: "fairplay"}
+
+	for format, want := range map[string]string{
+		"dash": "https://api.streamverse.io/streaming/license/widevine",
+		"hls":  "https://api.streamverse.io/streaming/license/fairplay",
+	} {
+		config, err := s.drmConfig(context.Background(), content, "content-1", format, nil)
+		if err != nil {
+			t.Fatalf("drm config: %v", err)
+		}
+		if config.LicenseURL != want {
+			t.Fatalf("expected %s manifest to use license proxy %s, got %s", format, want, config.LicenseURL)
+		}
+	}
+}
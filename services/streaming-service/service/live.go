@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+	"github.com/streamverse/streaming-service/utils"
+)
+
+// liveTargetLatency is the glass-to-glass latency advertised to LL-DASH players
+const liveTargetLatency = 3 * time.Second
+
+// StartLiveStream registers a live channel reported by the packager
+func (s *StreamingService) StartLiveStream(ctx context.Context, stream *models.LiveStream) error {
+	if stream.PartTarget <= 0 || stream.PartTarget >= stream.TargetDuration {
+		return fmt.Errorf("part target must be positive and shorter than the target duration")
+	}
+	s.live.StartStream(*stream)
+	return nil
+}
+
+// StopLiveStream takes a live channel off air
+func (s *StreamingService) StopLiveStream(ctx context.Context, channelID string) {
+	s.live.StopStream(channelID)
+}
+
+// AppendLivePart publishes a new part (CMAF chunk) of a live rendition
+func (s *StreamingService) AppendLivePart(ctx context.Context, channelID, rendition string, part models.LivePart) error {
+	return s.live.AppendPart(channelID, rendition, part)
+}
+
+// CompleteLiveSegment publishes a completed segment of a live rendition
+func (s *StreamingService) CompleteLiveSegment(ctx context.Context, channelID, rendition string, segment models.LiveSegment) error {
+	return s.live.CompleteSegment(channelID, rendition, segment)
+}
+
+// liveStream returns a live channel as a viewer may play it. Channels are catalog
+// content, so the viewer's territory must be allowed, and renditions beyond the
+// quality their token grants are left out.
+func (s *StreamingService) liveStream(ctx context.Context, channelID string, claims *models.StreamingClaims, clientIP string) (*models.LiveStream, error) {
+	stream, err := s.live.Stream(channelID)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.contentClient.GetContent(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLiveStreamNotFound, err)
+	}
+	if err := s.checkGeoRestrictions(content, clientIP); err != nil {
+		return nil, err
+	}
+	stream.Renditions = entitledRenditions(stream.Renditions, claims)
+	return stream, nil
+}
+
+// GenerateLiveHLSManifest generates the multivariant playlist of a live channel
+func (s *StreamingService) GenerateLiveHLSManifest(ctx context.Context, channelID string, claims *models.StreamingClaims, clientIP string) (string, error) {
+	stream, err := s.liveStream(ctx, channelID, claims, clientIP)
+	if err != nil {
+		return "", err
+	}
+
+	deviceType := s.resolveDeviceType(ctx, claims)
+	abr := s.SelectABRProfile(ctx, claims.UserID, claims.DeviceID, deviceType)
+
+	manifest := buildManifest(channelID, "hls", stream.Renditions, abr, func(name string) string {
+		return name + ".m3u8"
+	})
+	return utils.GenerateHLSManifest(manifest), nil
+}
+
+// GenerateLLHLSPlaylist generates a low-latency media playlist for a live rendition.
+// A non-negative msn (and optionally part) turns the request into a blocking reload.
+func (s *StreamingService) GenerateLLHLSPlaylist(ctx context.Context, channelID, rendition string, claims *models.StreamingClaims, clientIP string, msn int64, part int) (string, error) {
+	stream, err := s.liveStream(ctx, channelID, claims, clientIP)
+	if err != nil {
+		return "", err
+	}
+	// Renditions beyond the plan are not served even when requested directly
+	if !slices.ContainsFunc(stream.Renditions, func(r models.Rendition) bool { return r.Name == rendition }) {
+		return "", fmt.Errorf("%w: no rendition %s", ErrLiveStreamNotFound, rendition)
+	}
+
+	playlist, err := s.live.Playlist(ctx, channelID, rendition, msn, part)
+	if err != nil {
+		return "", err
+	}
+
+	return utils.GenerateLLHLSMediaPlaylist(s.getLiveCDNBaseURL(channelID)+"/"+rendition, playlist), nil
+}
+
+// GenerateLLDASHManifest generates a dynamic low-latency MPD for a live channel
+func (s *StreamingService) GenerateLLDASHManifest(ctx context.Context, channelID string, claims *models.StreamingClaims, clientIP string) (string, error) {
+	stream, err := s.liveStream(ctx, channelID, claims, clientIP)
+	if err != nil {
+		return "", err
+	}
+
+	return utils.GenerateLiveDASHManifest(stream, utils.LiveDASHOptions{
+		BaseURL:       s.getLiveCDNBaseURL(channelID) + "/",
+		PublishTime:   time.Now(),
+		TargetLatency: liveTargetLatency,
+	})
+}
+
+func (s *StreamingService) getLiveCDNBaseURL(channelID string) string {
+	return fmt.Sprintf("https://cdn.streamverse.com/live/%s", channelID)
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+const defaultLiveWindowSegments = 10
+
+var (
+	// ErrLiveStreamNotFound is returned for channels or renditions that are not on air
+	ErrLiveStreamNotFound = errors.New("live stream not found")
+	// ErrLiveRequestTooFar is returned when a blocking reload asks for a segment more than two ahead
+	ErrLiveRequestTooFar = errors.New("requested media sequence is too far in the future")
+	// ErrLiveReloadTimeout is returned when a blocking reload is not satisfied in time
+	ErrLiveReloadTimeout = errors.New("timed out waiting for requested part")
+)
+
+type liveRendition struct {
+	rendition models.Rendition
+	segments  []models.LiveSegment
+	pending   []models.LivePart
+	nextMSN   int64
+}
+
+type liveChannel struct {
+	stream     models.LiveStream
+	renditions map[string]*liveRendition
+	order      []string
+	updated    chan struct{} // closed and replaced on every change
+}
+
+// LiveTracker holds the sliding window of each live channel as reported by the
+// packager and lets playlist requests block until a requested part exists.
+// State is per process, so packagers must publish to every streaming replica
+// that serves a channel (or channels must be routed to a single replica).
+type LiveTracker struct {
+	mu       sync.Mutex
+	channels map[string]*liveChannel
+}
+
+// NewLiveTracker creates a new live tracker
+func NewLiveTracker() *LiveTracker {
+	return &LiveTracker{
+		channels: make(map[string]*liveChannel),
+	}
+}
+
+// StartStream registers (or restarts) a live channel with its renditions
+func (t *LiveTracker) StartStream(stream models.LiveStream) {
+	if stream.WindowSegments <= 0 {
+		stream.WindowSegments = defaultLiveWindowSegments
+	}
+	if stream.AvailabilityStartTime.IsZero() {
+		stream.AvailabilityStartTime = time.Now().UTC()
+	}
+
+	ch := &liveChannel{
+		stream:     stream,
+		renditions: make(map[string]*liveRendition, len(stream.Renditions)),
+		updated:    make(chan struct{}),
+	}
+	for _, r := range stream.Renditions {
+		ch.renditions[r.Name] = &liveRendition{rendition: r, nextMSN: int64(r.StartNumber)}
+		ch.order = append(ch.order, r.Name)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if old, ok := t.channels[stream.ChannelID]; ok {
+		close(old.updated)
+	}
+	t.channels[stream.ChannelID] = ch
+}
+
+// StopStream takes a channel off air
+func (t *LiveTracker) StopStream(channelID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ch, ok := t.channels[channelID]; ok {
+		close(ch.updated)
+		delete(t.channels, channelID)
+	}
+}
+
+// Stream returns the configuration of a live channel
+func (t *LiveTracker) Stream(channelID string) (*models.LiveStream, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ch, ok := t.channels[channelID]
+	if !ok {
+		return nil, ErrLiveStreamNotFound
+	}
+	stream := ch.stream
+	stream.Renditions = append([]models.Rendition(nil), ch.stream.Renditions...)
+	return &stream, nil
+}
+
+// AppendPart publishes a new part of the segment currently in progress
+func (t *LiveTracker) AppendPart(channelID, renditionName string, part models.LivePart) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ch, lr, err := t.lookup(channelID, renditionName)
+	if err != nil {
+		return err
+	}
+	if part.MSN != lr.nextMSN {
+		return fmt.Errorf("part for segment %d is out of order, expected segment %d", part.MSN, lr.nextMSN)
+	}
+
+	lr.pending = append(lr.pending, part)
+	ch.notify()
+	return nil
+}
+
+// CompleteSegment closes the segment in progress and slides the window
+func (t *LiveTracker) CompleteSegment(channelID, renditionName string, segment models.LiveSegment) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ch, lr, err := t.lookup(channelID, renditionName)
+	if err != nil {
+		return err
+	}
+	if segment.MSN != lr.nextMSN {
+		return fmt.Errorf("segment %d is out of order, expected segment %d", segment.MSN, lr.nextMSN)
+	}
+
+	if len(segment.Parts) == 0 {
+		segment.Parts = lr.pending
+	}
+	if segment.ProgramDateTime.IsZero() {
+		segment.ProgramDateTime = time.Now().UTC().Add(-time.Duration(segment.Duration * float64(time.Second)))
+	}
+
+	lr.segments = append(lr.segments, segment)
+	if excess := len(lr.segments) - ch.stream.WindowSegments; excess > 0 {
+		lr.segments = append([]models.LiveSegment(nil), lr.segments[excess:]...)
+	}
+	lr.pending = nil
+	lr.nextMSN = segment.MSN + 1
+
+	ch.notify()
+	return nil
+}
+
+// Playlist returns a snapshot of a rendition's window. When msn is non-negative the
+// call blocks, as an LL-HLS blocking playlist reload, until segment msn is complete
+// or, if part is non-negative, until that part of segment msn has been published.
+func (t *LiveTracker) Playlist(ctx context.Context, channelID, renditionName string, msn int64, part int) (*models.LivePlaylist, error) {
+	var timeout <-chan time.Time
+
+	for {
+		t.mu.Lock()
+		ch, lr, err := t.lookup(channelID, renditionName)
+		if err != nil {
+			t.mu.Unlock()
+			return nil, err
+		}
+
+		if msn < 0 || msn < lr.nextMSN || (msn == lr.nextMSN && part >= 0 && part < len(lr.pending)) {
+			playlist := ch.snapshot(lr)
+			t.mu.Unlock()
+			return playlist, nil
+		}
+		if msn > lr.nextMSN+2 {
+			t.mu.Unlock()
+			return nil, ErrLiveRequestTooFar
+		}
+
+		if timeout == nil {
+			// Block for at most three target durations, as the LL-HLS spec recommends
+			wait := time.Duration(3 * ch.stream.TargetDuration * float64(time.Second))
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		updated := ch.updated
+		t.mu.Unlock()
+
+		select {
+		case <-updated:
+		case <-timeout:
+			return nil, ErrLiveReloadTimeout
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (t *LiveTracker) lookup(channelID, renditionName string) (*liveChannel, *liveRendition, error) {
+	ch, ok := t.channels[channelID]
+	if !ok {
+		return nil, nil, ErrLiveStreamNotFound
+	}
+	lr, ok := ch.renditions[renditionName]
+	if !ok {
+		return nil, nil, ErrLiveStreamNotFound
+	}
+	return ch, lr, nil
+}
+
+func (ch *liveChannel) notify() {
+	close(ch.updated)
+	ch.updated = make(chan struct{})
+}
+
+// snapshot copies a rendition window so it can be rendered without holding the lock
+func (ch *liveChannel) snapshot(lr *liveRendition) *models.LivePlaylist {
+	playlist := &models.LivePlaylist{
+		ChannelID:      ch.stream.ChannelID,
+		Rendition:      lr.rendition,
+		TargetDuration: ch.stream.TargetDuration,
+		PartTarget:     ch.stream.PartTarget,
+		Segments:       append([]models.LiveSegment(nil), lr.segments...),
+		PendingParts:   append([]models.LivePart(nil), lr.pending...),
+		NextMSN:        lr.nextMSN,
+	}
+
+	if ch.stream.PartTemplate != "" {
+		playlist.PreloadHintURI = expandPartTemplate(ch.stream.PartTemplate, lr.nextMSN, len(lr.pending))
+	}
+
+	for _, name := range ch.order {
+		if name == lr.rendition.Name {
+			continue
+		}
+		other := ch.renditions[name]
+		if other.rendition.Type == "subtitle" {
+			continue
+		}
+		report := models.RenditionReport{Name: name, LastMSN: other.nextMSN, LastPart: len(other.pending) - 1}
+		if len(other.pending) == 0 && len(other.segments) > 0 {
+			last := other.segments[len(other.segments)-1]
+			report.LastMSN = last.MSN
+			report.LastPart = len(last.Parts) - 1
+		}
+		if report.LastPart < 0 {
+			continue
+		}
+		playlist.Reports = append(playlist.Reports, report)
+	}
+
+	return playlist
+}
+
+func expandPartTemplate(template string, msn int64, part int) string {
+	uri := strings.ReplaceAll(template, "$Number$", strconv.FormatInt(msn, 10))
+	return strings.ReplaceAll(uri, "$Part$", strconv.Itoa(part))
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+func newTestLiveTracker() *LiveTracker {
+	tracker := NewLiveTracker()
+	tracker.StartStream(models.LiveStream{
+		ChannelID:      "sports-1",
+		TargetDuration: 2,
+		PartTarget:     0.5,
+		WindowSegments: 3,
+		PartTemplate:   "segment_$Number$.$Part$.m4s",
+		Renditions: []models.Rendition{
+			{Name: "1080p", Type: "video", StartNumber: 100},
+			{Name: "720p", Type: "video", StartNumber: 100},
+		},
+	})
+	return tracker
+}
+
+func TestLiveTrackerBlockingReloadWaitsForPart(t *testing.T) {
+	tracker := newTestLiveTracker()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = tracker.AppendPart("sports-1", "1080p", models.LivePart{MSN: 100, URI: "segment_100.0.m4s", Duration: 0.5, Independent: true})
+	}()
+
+	playlist, err := tracker.Playlist(context.Background(), "sports-1", "1080p", 100, 0)
+	if err != nil {
+		t.Fatalf("expected playlist once part is published, got %v", err)
+	}
+	if len(playlist.PendingParts) != 1 {
+		t.Fatalf("expected 1 pending part, got %d", len(playlist.PendingParts))
+	}
+	if playlist.PreloadHintURI != "segment_100.1.m4s" {
+		t.Fatalf("unexpected preload hint %q", playlist.PreloadHintURI)
+	}
+}
+
+func TestLiveTrackerRejectsRequestsTooFarAhead(t *testing.T) {
+	tracker := newTestLiveTracker()
+
+	_, err := tracker.Playlist(context.Background(), "sports-1", "1080p", 103, -1)
+	if !errors.Is(err, ErrLiveRequestTooFar) {
+		t.Fatalf("expected ErrLiveRequestTooFar, got %v", err)
+	}
+}
+
+func TestLiveTrackerSlidesWindowAndReportsRenditions(t *testing.T) {
+	tracker := newTestLiveTracker()
+
+	for msn := int64(100); msn < 105; msn++ {
+		for _, name := range []string{"1080p", "720p"} {
+			if err := tracker.AppendPart("sports-1", name, models.LivePart{MSN: msn, URI: "p.m4s", Duration: 0.5}); err != nil {
+				t.Fatalf("append part failed: %v", err)
+			}
+			if err := tracker.CompleteSegment("sports-1", name, models.LiveSegment{MSN: msn, URI: "s.m4s", Duration: 2}); err != nil {
+				t.Fatalf("complete segment failed: %v", err)
+			}
+		}
+	}
+
+	playlist, err := tracker.Playlist(context.Background(), "sports-1", "1080p", 104, -1)
+	if err != nil {
+		t.Fatalf("expected playlist, got %v", err)
+	}
+	if len(playlist.Segments) != 3 || playlist.Segments[0].MSN != 102 {
+		t.Fatalf("expected window of 3 segments starting at 102, got %+v", playlist.Segments)
+	}
+	if len(playlist.Reports) != 1 || playlist.Reports[0].Name != "720p" || playlist.Reports[0].LastMSN != 104 || playlist.Reports[0].LastPart != 0 {
+		t.Fatalf("unexpected rendition reports %+v", playlist.Reports)
+	}
+
+	if err := tracker.AppendPart("sports-1", "1080p", models.LivePart{MSN: 104, URI: "late.m4s", Duration: 0.5}); err == nil {
+		t.Fatalf("expected out of order part to be rejected")
+	}
+}
+
+func TestLiveTrackerBlockingReloadTimesOut(t *testing.T) {
+	tracker := NewLiveTracker()
+	tracker.StartStream(models.LiveStream{
+		ChannelID:      "sports-2",
+		TargetDuration: 0.01,
+		PartTarget:     0.005,
+		Renditions:     []models.Rendition{{Name: "720p", Type: "video"}},
+	})
+
+	_, err := tracker.Playlist(context.Background(), "sports-2", "720p", 1, -1)
+	if !errors.Is(err, ErrLiveReloadTimeout) {
+		t.Fatalf("expected ErrLiveReloadTimeout, got %v", err)
+	}
+}
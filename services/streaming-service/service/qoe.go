@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+// SubmitQoE submits QoE metrics to the QoE pipeline and the ABR throughput
+// estimator. Events naming a playback session must come from the session's viewer
+// and are the only ones counted towards the health of the tenant's CDNs.
+func (s *StreamingService) SubmitQoE(ctx context.Context, event *models.QoEEvent, tenantID string) error {
+	deviceID := event.DeviceID
+	if event.SessionID != "" {
+		session, err := s.repo.GetSession(ctx, event.SessionID)
+		if err != nil || session.UserID != event.UserID || session.ContentID != event.ContentID {
+			return ErrInvalidSession
+		}
+		if deviceID == "" {
+			deviceID = session.DeviceID
+		}
+	}
+	// Player events also drive the session state; stale or out-of-order events are ignored
+	if state := sessionStateForEvent(event.Event); state != "" && event.SessionID != "" {
+		if _, err := s.TransitionSession(ctx, event.SessionID, state); err != nil {
+			// Log error
+		}
+	}
+	// Errors and rebuffers reported against a CDN feed its health for steering
+	s.cdn.Record(tenantID, event.ContentID, event.SessionID, event.CDN, event.Event, time.Now())
+	// Bandwidth estimation is best effort and must not fail QoE submission
+	if err := s.abr.ObserveQoE(ctx, deviceID, event); err != nil {
+		// Log error
+	}
+
+	return s.qoe.Submit(ctx, event)
+}
+
+// GetSessionQoE retrieves the aggregated QoE metrics of a playback session
+func (s *StreamingService) GetSessionQoE(ctx context.Context, sessionID string) (*models.SessionQoE, error) {
+	return s.qoeRepo.GetSessionQoE(ctx, sessionID)
+}
+
+// GetContentQoE retrieves the QoE rollup of a content item across sessions
+func (s *StreamingService) GetContentQoE(ctx context.Context, contentID string) (*models.ContentQoE, error) {
+	return s.qoeRepo.GetContentQoE(ctx, contentID)
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/streamverse/common-go/logger"
+	"go.uber.org/zap"
+)
+
+// SessionReaperConfig controls expiry of playback sessions that stopped sending heartbeats.
+type SessionReaperConfig struct {
+	TTL       time.Duration // heartbeat age after which a session is ended
+	Interval  time.Duration
+	BatchSize int
+}
+
+// SessionReaperConfigFromEnv builds reaper config from environment variables.
+func SessionReaperConfigFromEnv() SessionReaperConfig {
+	cfg := SessionReaperConfig{
+		TTL:       2 * time.Minute,
+		Interval:  30 * time.Second,
+		BatchSize: 200,
+	}
+
+	if v := strings.TrimSpace(os.Getenv("SESSION_TTL")); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			cfg.TTL = parsed
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("SESSION_REAPER_INTERVAL")); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			cfg.Interval = parsed
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("SESSION_REAPER_BATCH_SIZE")); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			cfg.BatchSize = parsed
+		}
+	}
+
+	return cfg
+}
+
+// SessionReaper periodically ends abandoned playback sessions and frees their stream slots.
+type SessionReaper struct {
+	streamingService *StreamingService
+	logger           *logger.Logger
+	config           SessionReaperConfig
+}
+
+// NewSessionReaper creates a session reaper.
+func NewSessionReaper(streamingService *StreamingService, log *logger.Logger, config SessionReaperConfig) *SessionReaper {
+	return &SessionReaper{
+		streamingService: streamingService,
+		logger:           log,
+		config:           config,
+	}
+}
+
+// Start runs the reaper loop until context cancellation.
+func (r *SessionReaper) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	r.runOnce(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Session reaper stopped")
+			return
+		case <-ticker.C:
+			r.runOnce(ctx)
+		}
+	}
+}
+
+func (r *SessionReaper) runOnce(ctx context.Context) {
+	ended, err := r.streamingService.ReapStaleSessions(ctx, r.config.TTL, int64(r.config.BatchSize))
+	if err != nil {
+		r.logger.Error("Session reaper failed", logger.Error(err))
+		return
+	}
+	if ended > 0 {
+		r.logger.Info("Session reaper ended stale sessions", zap.Int("sessions", ended))
+	}
+}
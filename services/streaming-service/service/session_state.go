@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/streamverse/streaming-service/internal/playback"
+	"github.com/streamverse/streaming-service/models"
+)
+
+var (
+	// ErrInvalidSessionTransition is returned when a session cannot move to the requested state
+	ErrInvalidSessionTransition = errors.New("invalid session state transition")
+	// ErrSessionEnded is returned for updates to a session that has already ended
+	ErrSessionEnded = errors.New("playback session has ended")
+	// ErrInvalidSession is returned when a session does not belong to the viewer or title
+	ErrInvalidSession = errors.New("playback session does not match the request")
+)
+
+// sessionTransitions lists the states each session state may move to. Ended is
+// terminal; any other state may end.
+var sessionTransitions = map[string][]string{
+	models.SessionStateStarting: {models.SessionStatePlaying, models.SessionStateStalled, models.SessionStateEnded},
+	models.SessionStatePlaying:  {models.SessionStatePaused, models.SessionStateStalled, models.SessionStateEnded},
+	models.SessionStatePaused:   {models.SessionStatePlaying, models.SessionStateStalled, models.SessionStateEnded},
+	models.SessionStateStalled:  {models.SessionStatePlaying, models.SessionStatePaused, models.SessionStateEnded},
+	models.SessionStateEnded:    {},
+}
+
+// canTransition reports whether a session may move from one state to another
+func canTransition(from, to string) bool {
+	for _, state := range sessionTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+// statesBefore returns the states from which a session may move to the given state
+func statesBefore(to string) []string {
+	var from []string
+	for _, state := range []string{
+		models.SessionStateStarting,
+		models.SessionStatePlaying,
+		models.SessionStatePaused,
+		models.SessionStateStalled,
+	} {
+		if canTransition(state, to) {
+			from = append(from, state)
+		}
+	}
+	return from
+}
+
+// sessionStateForEvent maps player QoE events onto session states. Ended is left
+// out: a player finishing the content ends the session explicitly.
+func sessionStateForEvent(event string) string {
+	switch event {
+	case "play":
+		return models.SessionStatePlaying
+	case "pause":
+		return models.SessionStatePaused
+	case "buffering":
+		return models.SessionStateStalled
+	}
+	return ""
+}
+
+// TransitionSession moves a playback session to a new state. Staying in the
+// current state is not an error.
+func (s *StreamingService) TransitionSession(ctx context.Context, sessionID, state string) (*models.PlaybackSession, error) {
+	if state == models.SessionStateEnded {
+		return s.endSession(ctx, sessionID, models.SessionEndUser, time.Now())
+	}
+	if _, ok := sessionTransitions[state]; !ok || state == models.SessionStateStarting {
+		return nil, ErrInvalidSessionTransition
+	}
+
+	session, err := s.repo.TransitionState(ctx, sessionID, state, statesBefore(state))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update session state: %w", err)
+	}
+	if session != nil {
+		return session, nil
+	}
+
+	// Nothing matched: find out whether the session is missing, ended or already there
+	current, err := s.repo.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	switch current.State {
+	case state:
+		return current, nil
+	case models.SessionStateEnded:
+		return nil, ErrSessionEnded
+	}
+	return nil, ErrInvalidSessionTransition
+}
+
+// endSession ends a session once, frees its stream slot and emits ended QoE and
+// playback events carrying the final position. endedAt is when playback actually
+// stopped, which for abandoned sessions is the last heartbeat rather than the time
+// they are reaped.
+func (s *StreamingService) endSession(ctx context.Context, sessionID, reason string, endedAt time.Time) (*models.PlaybackSession, error) {
+	session, err := s.repo.EndSession(ctx, sessionID, reason, endedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to end session: %w", err)
+	}
+	if session == nil {
+		return nil, ErrSessionEnded
+	}
+
+	if err := s.limiter.Release(ctx, sessionID); err != nil {
+		return nil, fmt.Errorf("failed to release stream slot: %w", err)
+	}
+
+	event := &models.QoEEvent{
+		SessionID: sessionID,
+		UserID:    session.UserID,
+		ContentID: session.ContentID,
+		DeviceID:  session.DeviceID,
+		Event:     "ended",
+		Position:  session.Position,
+		Timestamp: endedAt,
+	}
+	if err := s.qoe.Submit(ctx, event); err != nil {
+		// Log error
+	}
+	if err := s.publishPlayback(ctx, playback.EventEnded, session, endedAt); err != nil {
+		// Log error
+	}
+	return session, nil
+}
+
+// ReapStaleSessions marks sessions stalled once their heartbeat is half a TTL old and
+// ends those that missed heartbeats for a full TTL. It returns how many were ended.
+func (s *StreamingService) ReapStaleSessions(ctx context.Context, ttl time.Duration, limit int64) (int, error) {
+	now := time.Now()
+	if _, err := s.repo.MarkStalledSessions(ctx, now.Add(-ttl/2)); err != nil {
+		return 0, fmt.Errorf("failed to mark stalled sessions: %w", err)
+	}
+
+	sessions, err := s.repo.GetExpiredSessions(ctx, now.Add(-ttl), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list expired sessions: %w", err)
+	}
+
+	ended := 0
+	for _, session := range sessions {
+		_, err := s.endSession(ctx, session.ID.Hex(), models.SessionEndTimeout, session.LastHeartbeat)
+		if errors.Is(err, ErrSessionEnded) {
+			// Ended concurrently by the player or another replica
+			continue
+		}
+		if err != nil {
+			return ended, err
+		}
+		ended++
+	}
+	return ended, nil
+}
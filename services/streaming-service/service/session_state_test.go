@@ -0,0 +1,70 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+func TestSessionTransitions(t *testing.T) {
+	cases := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{models.SessionStateStarting, models.SessionStatePlaying, true},
+		{models.SessionStateStarting, models.SessionStatePaused, false},
+		{models.SessionStatePlaying, models.SessionStatePaused, true},
+		{models.SessionStatePlaying, models.SessionStateStalled, true},
+		{models.SessionStatePaused, models.SessionStatePlaying, true},
+		{models.SessionStateStalled, models.SessionStatePlaying, true},
+		{models.SessionStateStalled, models.SessionStateEnded, true},
+		{models.SessionStatePlaying, models.SessionStateStarting, false},
+		{models.SessionStateEnded, models.SessionStatePlaying, false},
+		{models.SessionStateEnded, models.SessionStateEnded, false},
+	}
+	for _, tc := range cases {
+		if got := canTransition(tc.from, tc.to); got != tc.allowed {
+			t.Fatalf("%s -> %s: expected allowed=%v, got %v", tc.from, tc.to, tc.allowed, got)
+		}
+	}
+}
+
+func TestStatesBefore(t *testing.T) {
+	want := []string{models.SessionStatePlaying, models.SessionStateStalled}
+	if got := statesBefore(models.SessionStatePaused); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected paused to be reachable from %v, got %v", want, got)
+	}
+	if got := statesBefore(models.SessionStateEnded); len(got) != 4 {
+		t.Fatalf("expected every live state to be able to end, got %v", got)
+	}
+}
+
+func TestSessionStateForEvent(t *testing.T) {
+	cases := map[string]string{
+		"play":      models.SessionStatePlaying,
+		"pause":     models.SessionStatePaused,
+		"buffering": models.SessionStateStalled,
+		"ended":     "",
+		"seek":      "",
+	}
+	for event, want := range cases {
+		if got := sessionStateForEvent(event); got != want {
+			t.Fatalf("event %q: expected state %q, got %q", event, want, got)
+		}
+	}
+}
+
+func TestSessionReaperConfigFromEnv(t *testing.T) {
+	t.Setenv("SESSION_TTL", "90s")
+	t.Setenv("SESSION_REAPER_INTERVAL", "invalid")
+
+	cfg := SessionReaperConfigFromEnv()
+	if cfg.TTL != 90*time.Second {
+		t.Fatalf("expected TTL from env, got %s", cfg.TTL)
+	}
+	if cfg.Interval != 30*time.Second {
+		t.Fatalf("expected default interval for invalid value, got %s", cfg.Interval)
+	}
+}
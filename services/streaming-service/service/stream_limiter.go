@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+// Policies applied when a user starts a stream with every slot in use
+const (
+	StreamLimitReject     = "reject"      // refuse the new stream
+	StreamLimitKickOldest = "kick_oldest" // stop the longest-running stream and start the new one
+)
+
+const (
+	// streamHeartbeatTimeout is how long a stream keeps its slot without a heartbeat
+	streamHeartbeatTimeout = 2 * time.Minute
+	streamReserveAttempts  = 3
+)
+
+var (
+	// ErrStreamLimitReached is returned when every stream slot of the plan is in use
+	ErrStreamLimitReached = errors.New("concurrent stream limit reached")
+	// ErrStreamEvicted is returned for heartbeats of a session that lost its slot to another device
+	ErrStreamEvicted = errors.New("stream was stopped by playback on another device")
+	// ErrNoActiveSubscription is returned when the user has no plan allowing playback
+	ErrNoActiveSubscription = errors.New("an active subscription is required")
+)
+
+type streamLeaseStore interface {
+	AcquireLease(ctx context.Context, lease *models.StreamLease) (bool, error)
+	TakeOverStaleLease(ctx context.Context, lease *models.StreamLease, cutoff time.Time) (*models.StreamLease, error)
+	ReplaceLease(ctx context.Context, lease *models.StreamLease, expectedSessionID string) (*models.StreamLease, error)
+	ListLeases(ctx context.Context, userID string) ([]models.StreamLease, error)
+	RefreshLease(ctx context.Context, sessionID string, maxSlots int) (bool, error)
+	ReleaseLease(ctx context.Context, sessionID string) error
+}
+
+// StreamLimiter enforces a plan's concurrent stream limit with one lease per slot.
+// Every slot change is an atomic insert or compare-and-swap in the store, so two
+// devices starting at the same instant can never both take the last slot.
+type StreamLimiter struct {
+	store  streamLeaseStore
+	policy string
+}
+
+// NewStreamLimiter creates a new stream limiter; unknown policies fall back to reject
+func NewStreamLimiter(store streamLeaseStore, policy string) *StreamLimiter {
+	if policy != StreamLimitKickOldest {
+		policy = StreamLimitReject
+	}
+	return &StreamLimiter{store: store, policy: policy}
+}
+
+// Reserve claims a slot for a new session and returns the session it displaced, if
+// any. A device that is already streaming reuses its own slot; otherwise a free or
+// stale slot is taken, and with the kick-oldest policy the longest-running stream
+// gives up its slot.
+func (l *StreamLimiter) Reserve(ctx context.Context, userID, sessionID, deviceID string, maxStreams int) (string, error) {
+	if maxStreams <= 0 {
+		return "", ErrNoActiveSubscription
+	}
+
+	for attempt := 0; attempt < streamReserveAttempts; attempt++ {
+		now := time.Now()
+		lease := &models.StreamLease{
+			UserID:        userID,
+			SessionID:     sessionID,
+			DeviceID:      deviceID,
+			StartedAt:     now,
+			LastHeartbeat: now,
+		}
+
+		leases, err := l.store.ListLeases(ctx, userID)
+		if err != nil {
+			return "", fmt.Errorf("failed to list stream leases: %w", err)
+		}
+
+		// The same device starting new playback replaces its previous stream
+		if deviceID != "" {
+			for _, existing := range leases {
+				if existing.DeviceID != deviceID || existing.Slot >= maxStreams {
+					continue
+				}
+				lease.ID, lease.Slot = existing.ID, existing.Slot
+				previous, err := l.store.ReplaceLease(ctx, lease, existing.SessionID)
+				if err != nil {
+					return "", fmt.Errorf("failed to replace stream lease: %w", err)
+				}
+				if previous != nil {
+					return previous.SessionID, nil
+				}
+			}
+		}
+
+		for slot := 0; slot < maxStreams; slot++ {
+			lease.ID, lease.Slot = leaseID(userID, slot), slot
+			acquired, err := l.store.AcquireLease(ctx, lease)
+			if err != nil {
+				return "", fmt.Errorf("failed to acquire stream lease: %w", err)
+			}
+			if acquired {
+				return "", nil
+			}
+		}
+
+		cutoff := now.Add(-streamHeartbeatTimeout)
+		for slot := 0; slot < maxStreams; slot++ {
+			lease.ID, lease.Slot = leaseID(userID, slot), slot
+			previous, err := l.store.TakeOverStaleLease(ctx, lease, cutoff)
+			if err != nil {
+				return "", fmt.Errorf("failed to take over stream lease: %w", err)
+			}
+			if previous != nil {
+				return previous.SessionID, nil
+			}
+		}
+
+		if l.policy != StreamLimitKickOldest {
+			return "", ErrStreamLimitReached
+		}
+
+		var oldest *models.StreamLease
+		for i := range leases {
+			if leases[i].Slot < maxStreams && (oldest == nil || leases[i].StartedAt.Before(oldest.StartedAt)) {
+				oldest = &leases[i]
+			}
+		}
+		if oldest == nil {
+			// Slots were taken between listing and acquiring; look again
+			continue
+		}
+		lease.ID, lease.Slot = oldest.ID, oldest.Slot
+		previous, err := l.store.ReplaceLease(ctx, lease, oldest.SessionID)
+		if err != nil {
+			return "", fmt.Errorf("failed to replace stream lease: %w", err)
+		}
+		if previous != nil {
+			return previous.SessionID, nil
+		}
+		// Another device won the race for that slot; try again
+	}
+
+	return "", ErrStreamLimitReached
+}
+
+// Refresh keeps a session's slot alive. After a downgrade the plan allows fewer
+// streams than there are slots, so a slot at or above maxStreams is released
+// instead and the session must stop; 0 leaves the limit unchecked.
+func (l *StreamLimiter) Refresh(ctx context.Context, sessionID string, maxStreams int) error {
+	held, err := l.store.RefreshLease(ctx, sessionID, maxStreams)
+	if err != nil {
+		return fmt.Errorf("failed to refresh stream lease: %w", err)
+	}
+	if !held {
+		if err := l.store.ReleaseLease(ctx, sessionID); err != nil {
+			return fmt.Errorf("failed to release stream lease: %w", err)
+		}
+		return ErrStreamEvicted
+	}
+	return nil
+}
+
+// Release frees a session's slot
+func (l *StreamLimiter) Release(ctx context.Context, sessionID string) error {
+	return l.store.ReleaseLease(ctx, sessionID)
+}
+
+func leaseID(userID string, slot int) string {
+	return fmt.Sprintf("%s:%d", userID, slot)
+}
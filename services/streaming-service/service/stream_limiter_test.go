@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+// memoryLeaseStore mimics the atomic per-document operations of the Mongo store
+type memoryLeaseStore struct {
+	mu     sync.Mutex
+	leases map[string]models.StreamLease
+}
+
+func newMemoryLeaseStore() *memoryLeaseStore {
+	return &memoryLeaseStore{leases: make(map[string]models.StreamLease)}
+}
+
+func (s *memoryLeaseStore) AcquireLease(ctx context.Context, lease *models.StreamLease) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.leases[lease.ID]; ok {
+		return false, nil
+	}
+	s.leases[lease.ID] = *lease
+	return true, nil
+}
+
+func (s *memoryLeaseStore) TakeOverStaleLease(ctx context.Context, lease *models.StreamLease, cutoff time.Time) (*models.StreamLease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, ok := s.leases[lease.ID]
+	if !ok || !previous.LastHeartbeat.Before(cutoff) {
+		return nil, nil
+	}
+	s.leases[lease.ID] = *lease
+	return &previous, nil
+}
+
+func (s *memoryLeaseStore) ReplaceLease(ctx context.Context, lease *models.StreamLease, expectedSessionID string) (*models.StreamLease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, ok := s.leases[lease.ID]
+	if !ok || previous.SessionID != expectedSessionID {
+		return nil, nil
+	}
+	s.leases[lease.ID] = *lease
+	return &previous, nil
+}
+
+func (s *memoryLeaseStore) ListLeases(ctx context.Context, userID string) ([]models.StreamLease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var leases []models.StreamLease
+	for _, lease := range s.leases {
+		if lease.UserID == userID {
+			leases = append(leases, lease)
+		}
+	}
+	return leases, nil
+}
+
+func (s *memoryLeaseStore) RefreshLease(ctx context.Context, sessionID string, maxSlots int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, lease := range s.leases {
+		if lease.SessionID == sessionID && (maxSlots <= 0 || lease.Slot < maxSlots) {
+			lease.LastHeartbeat = time.Now()
+			s.leases[id] = lease
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryLeaseStore) ReleaseLease(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, lease := range s.leases {
+		if lease.SessionID == sessionID {
+			delete(s.leases, id)
+		}
+	}
+	return nil
+}
+
+func TestStreamLimiterRejectsBeyondPlanLimit(t *testing.T) {
+	limiter := NewStreamLimiter(newMemoryLeaseStore(), StreamLimitReject)
+	ctx := context.Background()
+
+	for i, device := range []string{"tv", "phone"} {
+		if _, err := limiter.Reserve(ctx, "u1", fmt.Sprintf("s%d", i), device, 2); err != nil {
+			t.Fatalf("expected slot for %s, got %v", device, err)
+		}
+	}
+	if _, err := limiter.Reserve(ctx, "u1", "s2", "tablet", 2); !errors.Is(err, ErrStreamLimitReached) {
+		t.Fatalf("expected ErrStreamLimitReached, got %v", err)
+	}
+
+	if err := limiter.Release(ctx, "s0"); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if _, err := limiter.Reserve(ctx, "u1", "s2", "tablet", 2); err != nil {
+		t.Fatalf("expected released slot to be reusable, got %v", err)
+	}
+}
+
+func TestStreamLimiterRefreshAfterDowngrade(t *testing.T) {
+	store := newMemoryLeaseStore()
+	limiter := NewStreamLimiter(store, StreamLimitReject)
+	ctx := context.Background()
+
+	for i, device := range []string{"tv", "phone"} {
+		if _, err := limiter.Reserve(ctx, "u1", fmt.Sprintf("s%d", i), device, 2); err != nil {
+			t.Fatalf("expected slot for %s, got %v", device, err)
+		}
+	}
+
+	// The plan drops to one stream: the second slot is gone
+	if err := limiter.Refresh(ctx, "s1", 1); !errors.Is(err, ErrStreamEvicted) {
+		t.Fatalf("expected the stream beyond the new limit to be evicted, got %v", err)
+	}
+	if err := limiter.Refresh(ctx, "s0", 1); err != nil {
+		t.Fatalf("expected the stream within the new limit to continue, got %v", err)
+	}
+	if leases, _ := store.ListLeases(ctx, "u1"); len(leases) != 1 || leases[0].SessionID != "s0" {
+		t.Fatalf("expected only the first slot to be held, got %+v", leases)
+	}
+}
+
+func TestStreamLimiterKicksOldestDevice(t *testing.T) {
+	store := newMemoryLeaseStore()
+	limiter := NewStreamLimiter(store, StreamLimitKickOldest)
+	ctx := context.Background()
+
+	if _, err := limiter.Reserve(ctx, "u1", "old", "tv", 1); err != nil {
+		t.Fatalf("reserve failed: %v", err)
+	}
+	evicted, err := limiter.Reserve(ctx, "u1", "new", "phone", 1)
+	if err != nil || evicted != "old" {
+		t.Fatalf("expected the old session to be kicked, got %q, %v", evicted, err)
+	}
+	if err := limiter.Refresh(ctx, "old", 1); !errors.Is(err, ErrStreamEvicted) {
+		t.Fatalf("expected evicted session heartbeat to fail, got %v", err)
+	}
+	if err := limiter.Refresh(ctx, "new", 1); err != nil {
+		t.Fatalf("expected new session heartbeat to succeed, got %v", err)
+	}
+}
+
+func TestStreamLimiterIgnoresStaleHeartbeats(t *testing.T) {
+	store := newMemoryLeaseStore()
+	limiter := NewStreamLimiter(store, StreamLimitReject)
+	ctx := context.Background()
+
+	stale := time.Now().Add(-2 * streamHeartbeatTimeout)
+	store.leases[leaseID("u1", 0)] = models.StreamLease{ID: leaseID("u1", 0), UserID: "u1", SessionID: "gone", DeviceID: "tv", StartedAt: stale, LastHeartbeat: stale}
+
+	evicted, err := limiter.Reserve(ctx, "u1", "s1", "phone", 1)
+	if err != nil || evicted != "gone" {
+		t.Fatalf("expected stale slot to be taken over, got %q, %v", evicted, err)
+	}
+}
+
+func TestStreamLimiterReusesSlotForSameDevice(t *testing.T) {
+	limiter := NewStreamLimiter(newMemoryLeaseStore(), StreamLimitReject)
+	ctx := context.Background()
+
+	if _, err := limiter.Reserve(ctx, "u1", "first", "tv", 1); err != nil {
+		t.Fatalf("reserve failed: %v", err)
+	}
+	evicted, err := limiter.Reserve(ctx, "u1", "second", "tv", 1)
+	if err != nil || evicted != "first" {
+		t.Fatalf("expected the device's previous stream to be replaced, got %q, %v", evicted, err)
+	}
+}
+
+func TestStreamLimiterIsRaceSafe(t *testing.T) {
+	limiter := NewStreamLimiter(newMemoryLeaseStore(), StreamLimitReject)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	granted := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := limiter.Reserve(ctx, "u1", fmt.Sprintf("s%d", i), fmt.Sprintf("d%d", i), 2); err == nil {
+				mu.Lock()
+				granted++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if granted != 2 {
+		t.Fatalf("expected exactly 2 streams for a 2-stream plan, got %d", granted)
+	}
+}
@@ -3,58 +3,170 @@ package service
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/streamverse/common-go/cache"
 	content_proto "github.com/streamverse/proto/gen/go/content"
+	"github.com/streamverse/streaming-service/internal/cdn"
 	"github.com/streamverse/streaming-service/internal/clients/content"
 	"github.com/streamverse/streaming-service/internal/clients/payment"
+	"github.com/streamverse/streaming-service/internal/clients/user"
+	"github.com/streamverse/streaming-service/internal/drm"
+	"github.com/streamverse/streaming-service/internal/geoip"
+	"github.com/streamverse/streaming-service/internal/playback"
+	"github.com/streamverse/streaming-service/internal/qoe"
 	"github.com/streamverse/streaming-service/models"
 	"github.com/streamverse/streaming-service/repository"
+	"github.com/streamverse/streaming-service/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// SessionStore persists playback sessions
+type SessionStore interface {
+	CreateSession(ctx context.Context, session *models.PlaybackSession) (*models.PlaybackSession, error)
+	GetSession(ctx context.Context, sessionID string) (*models.PlaybackSession, error)
+	UpdatePosition(ctx context.Context, sessionID string, position int64) (*models.PlaybackSession, error)
+	UpdateHeartbeat(ctx context.Context, sessionID string, bandwidth int64) (bool, error)
+	TransitionState(ctx context.Context, sessionID, state string, from []string) (*models.PlaybackSession, error)
+	EndSession(ctx context.Context, sessionID, reason string, endedAt time.Time) (*models.PlaybackSession, error)
+	MarkStalledSessions(ctx context.Context, cutoff time.Time) (int64, error)
+	GetExpiredSessions(ctx context.Context, cutoff time.Time, limit int64) ([]models.PlaybackSession, error)
+	GetActiveSessions(ctx context.Context, userID string) ([]models.PlaybackSession, error)
+}
+
+// contentSource returns catalog metadata of content
+type contentSource interface {
+	GetContent(ctx context.Context, id string) (*content_proto.GetContentResponse, error)
+}
+
 // StreamingService handles streaming business logic
 type StreamingService struct {
-	repo          *repository.StreamingRepository
-	contentClient *content.Client
+	repo          SessionStore
+	renditionRepo *repository.RenditionRepository
+	qoeRepo       *repository.QoERepository
+	contentClient contentSource
 	paymentClient *payment.Client
+	userClient    *user.Client
 	cache         *cache.RedisClient
+	qoe           *qoe.Pipeline
+	playback      playback.Publisher
+	geo           geoip.Locator
+	cdn           *cdn.Providers
+	keys          *drm.Manager
+	live          *LiveTracker
+	abr           *ThroughputEstimator
+	limiter       *StreamLimiter
+	tokenBinding  TokenBindingConfig
+	publicURL     string
 	jwtSecret     string
 }
 
 // NewStreamingService creates a new streaming service
 func NewStreamingService(
-	repo *repository.StreamingRepository,
+	repo SessionStore,
+	renditionRepo *repository.RenditionRepository,
+	qoeRepo *repository.QoERepository,
+	leaseRepo *repository.StreamLeaseRepository,
 	contentClient *content.Client,
 	paymentClient *payment.Client,
+	userClient *user.Client,
 	cache *cache.RedisClient,
+	qoePipeline *qoe.Pipeline,
+	playbackPublisher playback.Publisher,
+	geoLocator geoip.Locator,
+	cdnProviders *cdn.Providers,
+	drmKeys *drm.Manager,
+	streamLimitPolicy string,
+	tokenBinding TokenBindingConfig,
+	publicURL string, // base URL players reach this service at, for the license proxy
 	jwtSecret string,
 ) *StreamingService {
 	return &StreamingService{
 		repo:          repo,
+		renditionRepo: renditionRepo,
+		qoeRepo:       qoeRepo,
 		contentClient: contentClient,
 		paymentClient: paymentClient,
+		userClient:    userClient,
 		cache:         cache,
+		qoe:           qoePipeline,
+		playback:      playbackPublisher,
+		geo:           geoLocator,
+		cdn:           cdnProviders,
+		keys:          drmKeys,
+		live:          NewLiveTracker(),
+		abr:           NewThroughputEstimator(cache),
+		limiter:       NewStreamLimiter(leaseRepo, streamLimitPolicy),
+		tokenBinding:  tokenBinding,
+		publicURL:     strings.TrimRight(publicURL, "/"),
 		jwtSecret:     jwtSecret,
 	}
 }
 
-// GenerateToken generates a JWT token for manifest access
-func (s *StreamingService) GenerateToken(ctx context.Context, contentID, userID, ip, deviceID string) (*models.StreamingToken, error) {
+// GenerateToken generates a JWT token for manifest access. The token carries the
+// quality the viewer is entitled to, from the plan and the device's DRM security,
+// so manifests are cut to it, the track preferences picking the default audio and
+// text tracks, and optionally the playback session it serves so it stops working
+// once the session ends.
+func (s *StreamingService) GenerateToken(ctx context.Context, contentID, userID, tenantID, sessionID, ip, deviceID, deviceType, drmSecurity string, tracks models.TrackPreferences) (*models.StreamingToken, error) {
+	content, err := s.contentClient.GetContent(ctx, contentID)
+	if err != nil {
+		return nil, fmt.Errorf("content not found: %w", err)
+	}
+	if err := s.checkGeoRestrictions(content, ip); err != nil {
+		return nil, err
+	}
+
+	subscription, err := s.paymentClient.GetSubscription(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check subscription: %w", err)
+	}
+	if !subscription.GetIsActive() {
+		return nil, ErrNoActiveSubscription
+	}
+
+	if sessionID != "" {
+		session, err := s.repo.GetSession(ctx, sessionID)
+		if err != nil || session.UserID != userID || session.ContentID != contentID {
+			return nil, ErrInvalidSession
+		}
+		if session.State == models.SessionStateEnded {
+			return nil, ErrSessionEnded
+		}
+	}
+
+	renditions, err := s.getRenditions(ctx, contentID)
+	if err != nil {
+		// Log error; the decision then only reflects the plan and device
+	}
+	decision := decideQuality(renditions, subscription.GetQuality(), drmSecurity, content.IsDrmProtected)
+
 	now := time.Now()
 	expiresIn := 3600 // 1 hour
 
 	claims := jwt.MapClaims{
-		"content_id": contentID,
-		"user_id":    userID,
-		"ip":         ip,
-		"device_id":  deviceID,
-		"exp":        jwt.NewNumericDate(now.Add(time.Duration(expiresIn) * time.Second)),
-		"nbf":        jwt.NewNumericDate(now),
-		"iat":        jwt.NewNumericDate(now),
-		"aud":        "cdn.streamverse.io",
+		"content_id":  contentID,
+		"user_id":     userID,
+		"tenant_id":   tenantID,
+		"sid":         sessionID,
+		"quality":     subscription.GetQuality(),
+		"max_height":  decision.MaxHeight,
+		"ip":          ip,
+		"device_id":   deviceID,
+		"device_type": deviceType,
+		"sub_lang":    tracks.SubtitleLanguage,
+		"audio_lang":  tracks.AudioLanguage,
+		"cc":          tracks.ClosedCaptions,
+		"ad":          tracks.AudioDescription,
+		"jti":         primitive.NewObjectID().Hex(),
+		"exp":         jwt.NewNumericDate(now.Add(time.Duration(expiresIn) * time.Second)),
+		"nbf":         jwt.NewNumericDate(now),
+		"iat":         jwt.NewNumericDate(now),
+		"aud":         tokenAudience,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -64,161 +176,424 @@ func (s *StreamingService) GenerateToken(ctx context.Context, contentID, userID,
 	}
 
 	return &models.StreamingToken{
-		Token:     tokenString,
-		ExpiresIn: expiresIn,
-		ContentID: contentID,
+		Token:       tokenString,
+		ExpiresIn:   expiresIn,
+		ContentID:   contentID,
+		SessionID:   sessionID,
+		Quality:     subscription.GetQuality(),
+		Entitlement: decision,
 	}, nil
 }
 
 // ValidateToken validates a token and returns user_id
 func (s *StreamingService) ValidateToken(ctx context.Context, tokenString string) (string, error) {
+	claims, err := s.ParseToken(ctx, tokenString)
+	if err != nil {
+		return "", err
+	}
+	return claims.UserID, nil
+}
+
+// ParseToken validates a token and returns the viewer details bound into it
+func (s *StreamingService) ParseToken(ctx context.Context, tokenString string) (*models.StreamingClaims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(s.jwtSecret), nil
-	})
+	}, jwt.WithAudience(tokenAudience), jwt.WithExpirationRequired())
 
 	if err != nil {
-		return "", fmt.Errorf("invalid token: %w", err)
+		return nil, fmt.Errorf("invalid token: %w", err)
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		userID, ok := claims["user_id"].(string)
 		if !ok {
-			return "", fmt.Errorf("invalid token claims")
+			return nil, fmt.Errorf("invalid token claims")
+		}
+		tokenID, _ := claims["jti"].(string)
+		contentID, _ := claims["content_id"].(string)
+		tenantID, _ := claims["tenant_id"].(string)
+		sessionID, _ := claims["sid"].(string)
+		downloadID, _ := claims["download_id"].(string)
+		quality, _ := claims["quality"].(string)
+		maxHeight, _ := claims["max_height"].(float64)
+		ip, _ := claims["ip"].(string)
+		deviceID, _ := claims["device_id"].(string)
+		deviceType, _ := claims["device_type"].(string)
+		subtitleLanguage, _ := claims["sub_lang"].(string)
+		audioLanguage, _ := claims["audio_lang"].(string)
+		closedCaptions, _ := claims["cc"].(bool)
+		audioDescription, _ := claims["ad"].(bool)
+		parsed := &models.StreamingClaims{
+			TokenID:    tokenID,
+			UserID:     userID,
+			ContentID:  contentID,
+			SessionID:  sessionID,
+			DownloadID: downloadID,
+			TenantID:   tenantID,
+			Quality:    quality,
+			MaxHeight:  int(maxHeight),
+			IP:         ip,
+			DeviceID:   deviceID,
+			DeviceType: deviceType,
+			Tracks: models.TrackPreferences{
+				SubtitleLanguage: subtitleLanguage,
+				AudioLanguage:    audioLanguage,
+				ClosedCaptions:   closedCaptions,
+				AudioDescription: audioDescription,
+			},
 		}
-		return userID, nil
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			parsed.ExpiresAt = exp.Time
+		}
+		return parsed, nil
 	}
 
-	return "", fmt.Errorf("invalid token")
+	return nil, fmt.Errorf("invalid token")
 }
 
-// GenerateHLSManifest generates an HLS manifest
-func (s *StreamingService) GenerateHLSManifest(ctx context.Context, contentID, userID string) (string, error) {
-	// Try cache first
-	cacheKey := fmt.Sprintf("manifest:hls:%s:%s", contentID, userID)
-	var manifest string
-	if err := s.cache.Get(ctx, cacheKey, &manifest); err == nil {
-		return manifest, nil
-	}
-
+// GenerateHLSManifest generates an HLS multivariant playlist. Media playlist URIs
+// are emitted relative to playlistBase so they resolve under the same manifest token.
+// Under content steering every CDN becomes a pathway steered through steeringURI.
+func (s *StreamingService) GenerateHLSManifest(ctx context.Context, contentID string, claims *models.StreamingClaims, clientIP, playlistBase, steeringURI string) (string, error) {
 	// Get content metadata
-	_, err := s.contentClient.GetContent(ctx, contentID)
+	content, err := s.contentClient.GetContent(ctx, contentID)
 	if err != nil {
 		return "", fmt.Errorf("content not found: %w", err)
 	}
+	if err := s.checkGeoRestrictions(content, clientIP); err != nil {
+		return "", err
+	}
+
+	renditions, err := s.getRenditions(ctx, contentID)
+	if err != nil {
+		return "", err
+	}
 
 	// Select ABR profile based on device/network
-	deviceType := s.detectDeviceType(ctx, userID) // TODO: Get from session or device info
-	selectedProfile := s.SelectABRProfile(ctx, userID, deviceType)
-
-	// Generate HLS manifest
-	manifest = fmt.Sprintf(`#EXTM3U
-#EXT-X-VERSION:3
-#EXT-X-TARGETDURATION:6
-#EXTINF:6.0,
-%s/%s/segment_%d.ts
-#EXT-X-ENDLIST
-`, s.getCDNBaseURL(), selectedProfile, 0)
-
-	// Cache result (TTL: 5 minutes)
-	if err := s.cache.Set(ctx, cacheKey, manifest, 5*time.Minute); err != nil {
-		// Log error
+	deviceType := s.resolveDeviceType(ctx, claims)
+	abr := s.SelectABRProfile(ctx, claims.UserID, claims.DeviceID, deviceType)
+
+	renditions = selectDefaultTracks(entitledRenditions(renditions, claims), claims.Tracks)
+	manifest := buildManifest(contentID, "hls", renditions, abr, func(name string) string {
+		return playlistBase + name + ".m3u8"
+	})
+	if content.IsDrmProtected {
+		if manifest.DRMConfig, err = s.drmConfig(ctx, content, contentID, "hls", renditions); err != nil {
+			return "", err
+		}
+	}
+	if providers := s.manifestCDNs(claims, clientIP); len(providers) > 1 {
+		manifest.Steering = &models.ContentSteering{ServerURI: steeringURI}
+		for _, provider := range providers {
+			manifest.Steering.Pathways = append(manifest.Steering.Pathways, provider.Name)
+		}
+	}
+
+	return utils.GenerateHLSManifest(manifest), nil
+}
+
+// GenerateHLSMediaPlaylist generates the media playlist for a single rendition.
+// Segment URLs point at the CDN of the pathway the player chose, or the viewer's
+// preferred CDN, and share one token for the rendition.
+func (s *StreamingService) GenerateHLSMediaPlaylist(ctx context.Context, contentID, renditionName string, claims *models.StreamingClaims, clientIP, pathway string) (string, error) {
+	content, err := s.contentClient.GetContent(ctx, contentID)
+	if err != nil {
+		return "", fmt.Errorf("content not found: %w", err)
+	}
+	if err := s.checkGeoRestrictions(content, clientIP); err != nil {
+		return "", err
 	}
 
-	return manifest, nil
+	renditions, err := s.getRenditions(ctx, contentID)
+	if err != nil {
+		return "", err
+	}
+	// Renditions beyond the plan are not served even when requested directly
+	renditions = entitledRenditions(renditions, claims)
+
+	for i := range renditions {
+		if renditions[i].Name == renditionName {
+			provider := s.cdnForPathway(claims, clientIP, pathway)
+			token, err := renditionToken(provider, contentID, renditionName)
+			if err != nil {
+				return "", err
+			}
+			var drmConfig *models.DRMConfig
+			if content.IsDrmProtected {
+				if drmConfig, err = s.drmConfig(ctx, content, contentID, "hls", renditions[i:i+1]); err != nil {
+					return "", err
+				}
+			}
+			baseURL := fmt.Sprintf("%s/%s/%s", provider.BaseURL, contentID, renditionName)
+			return utils.GenerateHLSMediaPlaylist(baseURL, &renditions[i], token, drmConfig), nil
+		}
+	}
+
+	return "", fmt.Errorf("rendition not found")
 }
 
-// GenerateDASHManifest generates a DASH manifest
-func (s *StreamingService) GenerateDASHManifest(ctx context.Context, contentID, userID string) (string, error) {
-	// Try cache first
-	cacheKey := fmt.Sprintf("manifest:dash:%s:%s", contentID, userID)
-	var manifest string
-	if err := s.cache.Get(ctx, cacheKey, &manifest); err == nil {
-		return manifest, nil
+// GenerateThumbnailVTT generates the WebVTT thumbnail track web players use for
+// scrubbing previews, from the sprite rendition with the largest tiles. Sprite URLs
+// point at the same CDN as media playlists.
+func (s *StreamingService) GenerateThumbnailVTT(ctx context.Context, contentID string, claims *models.StreamingClaims, clientIP, pathway string) (string, error) {
+	renditions, err := s.getRenditions(ctx, contentID)
+	if err != nil {
+		return "", err
+	}
+
+	var sprites *models.Rendition
+	for i := range renditions {
+		if renditions[i].Type == "thumbnail" && (sprites == nil || renditions[i].Width > sprites.Width) {
+			sprites = &renditions[i]
+		}
+	}
+	if sprites == nil {
+		return "", fmt.Errorf("no thumbnails available for content %s", contentID)
+	}
+
+	provider := s.cdnForPathway(claims, clientIP, pathway)
+	token, err := renditionToken(provider, contentID, sprites.Name)
+	if err != nil {
+		return "", err
 	}
+	baseURL := fmt.Sprintf("%s/%s/%s", provider.BaseURL, contentID, sprites.Name)
+	return utils.GenerateThumbnailVTT(baseURL, sprites, token), nil
+}
 
+// GenerateDASHManifest generates a DASH manifest. Under content steering every CDN
+// becomes a service location steered through steeringURI.
+func (s *StreamingService) GenerateDASHManifest(ctx context.Context, contentID string, claims *models.StreamingClaims, clientIP, steeringURI string) (string, error) {
 	// Get content metadata
-	_, err := s.contentClient.GetContent(ctx, contentID)
+	content, err := s.contentClient.GetContent(ctx, contentID)
 	if err != nil {
 		return "", fmt.Errorf("content not found: %w", err)
 	}
+	if err := s.checkGeoRestrictions(content, clientIP); err != nil {
+		return "", err
+	}
 
-	// Select ABR profile
-	deviceType := s.detectDeviceType(ctx, userID)
-	selectedProfile := s.SelectABRProfile(ctx, userID, deviceType)
-
-	// Generate DASH manifest XML (simplified)
-	manifest = fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static" mediaPresentationDuration="PT0H0M0S">
-  <Period>
-    <AdaptationSet>
-      <Representation id="%s" bandwidth="5000000" width="1920" height="1080">
-        <BaseURL>%s/%s/</BaseURL>
-      </Representation>
-    </AdaptationSet>
-  </Period>
-</MPD>`, selectedProfile, s.getCDNBaseURL(), selectedProfile)
-
-	// Cache result (TTL: 5 minutes)
-	if err := s.cache.Set(ctx, cacheKey, manifest, 5*time.Minute); err != nil {
-		// Log error
+	renditions, err := s.getRenditions(ctx, contentID)
+	if err != nil {
+		return "", err
 	}
 
-	return manifest, nil
+	// DASH has no variant ordering, so the ABR profile only caps the ladder
+	deviceType := s.resolveDeviceType(ctx, claims)
+	renditions = capLadder(entitledRenditions(renditions, claims), s.SelectABRProfile(ctx, claims.UserID, claims.DeviceID, deviceType))
+	renditions = selectDefaultTracks(renditions, claims.Tracks)
+
+	providers := s.manifestCDNs(claims, clientIP)
+	tokens, err := renditionTokens(providers, contentID, renditions)
+	if err != nil {
+		return "", err
+	}
+
+	opts := utils.DASHOptions{
+		BaseURL:      fmt.Sprintf("%s/%s/", providers[0].BaseURL, contentID),
+		SegmentQuery: func(renditionName string) string { return tokens[renditionName] },
+	}
+	if len(providers) > 1 {
+		for _, provider := range providers {
+			opts.ServiceLocations = append(opts.ServiceLocations, utils.ServiceLocation{
+				ID:      provider.Name,
+				BaseURL: fmt.Sprintf("%s/%s/", provider.BaseURL, contentID),
+			})
+		}
+		opts.SteeringURI = steeringURI + "?format=dash"
+	}
+	if content.IsDrmProtected {
+		if opts.DRMConfig, err = s.drmConfig(ctx, content, contentID, "dash", renditions); err != nil {
+			return "", err
+		}
+	}
+
+	return utils.GenerateDASHManifest(renditions, opts)
 }
 
-// SelectABRProfile selects ABR profile based on device and network
-func (s *StreamingService) SelectABRProfile(ctx context.Context, userID, deviceType string) string {
-	// Bitrate ladder: 240p (512k), 360p (1.5M), 480p (2.5M), 720p (5M), 1080p (8M), 4K (15M)
+// Helper methods
+func (s *StreamingService) getCDNBaseURL() string {
+	return "https://cdn.streamverse.com/videos"
+}
 
-	// Detect device type and select initial profile
-	switch deviceType {
-	case "mobile":
-		return "360p" // 1.5M
-	case "tablet":
-		return "720p" // 5M
-	case "desktop":
-		return "1080p" // 8M
-	case "tv":
-		return "4K" // 15M
-	default:
-		return "480p" // 2.5M default
+// getRenditions loads the packaged renditions of a content item (cached for 5 minutes)
+func (s *StreamingService) getRenditions(ctx context.Context, contentID string) ([]models.Rendition, error) {
+	cacheKey := fmt.Sprintf("renditions:%s", contentID)
+	var renditions []models.Rendition
+	if err := s.cache.Get(ctx, cacheKey, &renditions); err == nil && len(renditions) > 0 {
+		return renditions, nil
 	}
 
-	// TODO: Estimate bandwidth from previous QoE events
-	// TODO: Adapt based on buffer level and rebuffer events
+	renditions, err := s.renditionRepo.GetRenditionsByContent(ctx, contentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load renditions: %w", err)
+	}
+	if len(renditions) == 0 {
+		return nil, fmt.Errorf("no renditions available for content %s", contentID)
+	}
+
+	if err := s.cache.Set(ctx, cacheKey, renditions, 5*time.Minute); err != nil {
+		// Log error
+	}
+
+	return renditions, nil
 }
 
-// SubmitQoE submits QoE metrics
-func (s *StreamingService) SubmitQoE(ctx context.Context, event *models.QoEEvent) error {
-	// TODO: Send to Kafka topic "qoe-events" for Analytics Service
-	// For now, just log it
-	fmt.Printf("QoE Event: %+v\n", event)
-	return nil
+// buildManifest turns stored renditions into a protocol-neutral manifest description.
+// The ladder is capped to the ABR profile, and video variants are ordered highest
+// bandwidth first, except that the best variant fitting the profile's initial
+// quality and bandwidth budget is moved to the front so players start on it.
+func buildManifest(contentID, protocol string, renditions []models.Rendition, abr *models.ABRProfile, uriFor func(name string) string) *models.Manifest {
+	manifest := &models.Manifest{
+		ContentID: contentID,
+		Protocol:  protocol,
+	}
+	renditions = capLadder(renditions, abr)
+
+	// Variant bandwidth must cover the alternate audio it plays with
+	var audioCodecs string
+	var audioBandwidth, audioAverageBandwidth int
+	for _, r := range renditions {
+		switch r.Type {
+		case "audio":
+			if audioCodecs == "" || r.Default {
+				audioCodecs = r.Codecs
+			}
+			if r.Bandwidth > audioBandwidth {
+				audioBandwidth = r.Bandwidth
+			}
+			if r.AverageBandwidth > audioAverageBandwidth {
+				audioAverageBandwidth = r.AverageBandwidth
+			}
+			manifest.AudioTracks = append(manifest.AudioTracks, models.AudioTrack{
+				Name:     r.Name,
+				Language: r.Language,
+				Label:    r.Label,
+				Kind:     r.Kind,
+				Channels: r.Channels,
+				URL:      uriFor(r.Name),
+				Default:  r.Default,
+			})
+		case "subtitle":
+			manifest.Subtitles = append(manifest.Subtitles, models.Subtitle{
+				Language: r.Language,
+				Label:    r.Label,
+				Kind:     r.Kind,
+				URL:      uriFor(r.Name),
+				Default:  r.Default,
+			})
+		case "caption":
+			manifest.ClosedCaptions = append(manifest.ClosedCaptions, models.ClosedCaption{
+				Name:       r.Name,
+				Language:   r.Language,
+				Label:      r.Label,
+				InstreamID: r.InstreamID,
+				Default:    r.Default,
+			})
+		case "iframe":
+			manifest.IFrameVariants = append(manifest.IFrameVariants, models.Variant{
+				Name:       r.Name,
+				Bandwidth:  r.Bandwidth,
+				Resolution: r.Resolution(),
+				Codec:      r.Codecs,
+				URL:        uriFor(r.Name),
+			})
+		case "thumbnail":
+			codec := r.Codecs
+			if codec == "" {
+				codec = "jpeg"
+			}
+			manifest.ImageStreams = append(manifest.ImageStreams, models.Variant{
+				Name:       r.Name,
+				Bandwidth:  r.Bandwidth,
+				Resolution: r.Resolution(),
+				Codec:      codec,
+				URL:        uriFor(r.Name),
+			})
+		}
+	}
+	sort.SliceStable(manifest.IFrameVariants, func(i, j int) bool {
+		return manifest.IFrameVariants[i].Bandwidth > manifest.IFrameVariants[j].Bandwidth
+	})
+
+	for _, r := range renditions {
+		if r.Type != "video" {
+			continue
+		}
+		codecs := r.Codecs
+		if audioCodecs != "" {
+			codecs += "," + audioCodecs
+		}
+		averageBandwidth := r.AverageBandwidth
+		if averageBandwidth > 0 {
+			averageBandwidth += audioAverageBandwidth
+		}
+		manifest.Variants = append(manifest.Variants, models.Variant{
+			Name:             r.Name,
+			Bandwidth:        r.Bandwidth + audioBandwidth,
+			AverageBandwidth: averageBandwidth,
+			Resolution:       r.Resolution(),
+			Codec:            codecs,
+			FrameRate:        r.FrameRate,
+			URL:              uriFor(r.Name),
+		})
+	}
+	sort.SliceStable(manifest.Variants, func(i, j int) bool {
+		return manifest.Variants[i].Bandwidth > manifest.Variants[j].Bandwidth
+	})
+
+	// Promote the initial variant for the selected profile, falling back to the
+	// lowest variant when nothing fits the bandwidth budget
+	if abr != nil && len(manifest.Variants) > 0 {
+		maxHeight := profileHeight(abr.Profile)
+		initial := len(manifest.Variants) - 1
+		for i, v := range manifest.Variants {
+			if variantHeight(v.Resolution) <= maxHeight && (abr.StartBandwidth == 0 || v.Bandwidth <= abr.StartBandwidth) {
+				initial = i
+				break
+			}
+		}
+		promoted := manifest.Variants[initial]
+		copy(manifest.Variants[1:initial+1], manifest.Variants[:initial])
+		manifest.Variants[0] = promoted
+	}
+
+	return manifest
 }
 
-// Helper methods
-func (s *StreamingService) detectDeviceType(ctx context.Context, userID string) string {
-	// TODO: Get device type from device registry or session
-	return "desktop" // Default
+// profileHeight converts an ABR profile name ("720p", "4K") to a pixel height
+func profileHeight(profile string) int {
+	if profile == "4K" {
+		return 2160
+	}
+	height, err := strconv.Atoi(strings.TrimSuffix(profile, "p"))
+	if err != nil {
+		return 480
+	}
+	return height
 }
 
-func (s *StreamingService) getCDNBaseURL() string {
-	return "https://cdn.streamverse.com/videos"
+// variantHeight extracts the height from a "WxH" resolution
+func variantHeight(resolution string) int {
+	_, heightStr, found := strings.Cut(resolution, "x")
+	if !found {
+		return 0
+	}
+	height, _ := strconv.Atoi(heightStr)
+	return height
 }
 
 // CreateSession creates a new playback session
-func (s *StreamingService) CreateSession(ctx context.Context, userID, contentID, deviceID string) (*models.PlaybackSession, error) {
-	// Check subscription and concurrent stream limits
-	streams, err := s.paymentClient.CheckConcurrentStreams(ctx, userID)
+func (s *StreamingService) CreateSession(ctx context.Context, userID, profileID, contentID, deviceID, deviceType string) (*models.PlaybackSession, error) {
+	// Check subscription; the plan decides how many streams may run at once
+	subscription, err := s.paymentClient.GetSubscription(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check stream limits: %w", err)
+		return nil, fmt.Errorf("failed to check subscription: %w", err)
 	}
-
-	if streams.GetActiveStreams() >= 4 { // Assuming max 4 concurrent streams
-		return nil, fmt.Errorf("concurrent stream limit reached")
+	if !subscription.GetIsActive() {
+		return nil, ErrNoActiveSubscription
 	}
 
 	// Get content metadata
@@ -230,25 +605,46 @@ func (s *StreamingService) CreateSession(ctx context.Context, userID, contentID,
 	// Generate stream URL (simplified - would integrate with CDN)
 	streamURL := s.generateStreamURL(contentID)
 
+	sessionID := primitive.NewObjectID()
+	evicted, err := s.limiter.Reserve(ctx, userID, sessionID.Hex(), deviceID, int(subscription.GetMaxStreams()))
+	if err != nil {
+		return nil, err
+	}
+	if evicted != "" {
+		// The slot has already moved to the new session; this only records the old one as ended
+		if _, err := s.endSession(ctx, evicted, models.SessionEndEvicted, time.Now()); err != nil {
+			// Log error
+		}
+	}
+
 	session := &models.PlaybackSession{
-		ID:            primitive.NewObjectID(),
+		ID:            sessionID,
 		UserID:        userID,
+		ProfileID:     profileID,
 		ContentID:     contentID,
 		Position:      0,
 		Duration:      content.Duration,
 		Quality:       "auto",
 		LastHeartbeat: time.Now(),
 		DeviceID:      deviceID,
+		DeviceType:    deviceType,
 		StreamURL:     streamURL,
+		State:         models.SessionStateStarting,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
 
-	return s.repo.CreateSession(ctx, session)
+	created, err := s.repo.CreateSession(ctx, session)
+	if err != nil {
+		_ = s.limiter.Release(ctx, sessionID.Hex())
+		return nil, err
+	}
+	return created, nil
 }
 
-// GetManifest generates HLS or DASH manifest
-func (s *StreamingService) GetManifest(ctx context.Context, contentID, format string, userID string) (*models.StreamManifest, error) {
+// GetManifest generates HLS or DASH manifest, listing only the qualities the
+// viewer's plan and device DRM security allow
+func (s *StreamingService) GetManifest(ctx context.Context, contentID, format, userID, clientIP, drmSecurity string) (*models.StreamManifest, error) {
 	// Get content metadata
 	content, err := s.contentClient.GetContent(ctx, contentID)
 	if err != nil {
@@ -256,18 +652,32 @@ func (s *StreamingService) GetManifest(ctx context.Context, contentID, format st
 	}
 
 	// Check geo-restrictions
-	if err := s.checkGeoRestrictions(ctx, contentID, userID); err != nil {
+	if err := s.checkGeoRestrictions(content, clientIP); err != nil {
 		return nil, err
 	}
 
+	subscription, err := s.paymentClient.GetSubscription(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check subscription: %w", err)
+	}
+	if !subscription.GetIsActive() {
+		return nil, ErrNoActiveSubscription
+	}
+
 	// Generate manifest URL
 	manifestURL := s.generateManifestURL(contentID, format)
 
-	// Get quality levels
-	qualities := s.getQualityLevels(contentID)
+	// Get quality levels the viewer is entitled to
+	renditions, err := s.getRenditions(ctx, contentID)
+	if err != nil {
+		return nil, err
+	}
+	decision := decideQuality(renditions, subscription.GetQuality(), drmSecurity, content.IsDrmProtected)
+	renditions = capLadder(renditions, &models.ABRProfile{MaxHeight: decision.MaxHeight})
+	qualities := s.getQualityLevels(contentID, renditions)
 
 	// Get subtitles
-	subtitles := s.getSubtitles(contentID)
+	subtitles := s.getSubtitles(contentID, renditions)
 
 	// Get DRM info if protected
 	var drmInfo *models.DRMInfo
@@ -282,22 +692,105 @@ func (s *StreamingService) GetManifest(ctx context.Context, contentID, format st
 		Qualities:   qualities,
 		Subtitles:   subtitles,
 		DRMInfo:     drmInfo,
+		Entitlement: decision,
 	}, nil
 }
 
-// UpdateSessionPosition updates playback position
-func (s *StreamingService) UpdateSessionPosition(ctx context.Context, sessionID string, position int64) error {
-	return s.repo.UpdatePosition(ctx, sessionID, position)
+// UpdateSessionPosition updates the playback position of a user's session and
+// publishes it for watch history
+func (s *StreamingService) UpdateSessionPosition(ctx context.Context, sessionID, userID string, position int64) error {
+	if _, err := s.ownSession(ctx, sessionID, userID); err != nil {
+		return err
+	}
+	session, err := s.repo.UpdatePosition(ctx, sessionID, position)
+	if err != nil {
+		return err
+	}
+	// Watch history catches up on the next update or at session end if this fails
+	if err := s.publishPlayback(ctx, playback.EventPosition, session, time.Now()); err != nil {
+		// Log error
+	}
+	return nil
 }
 
-// SendHeartbeat updates session heartbeat
-func (s *StreamingService) SendHeartbeat(ctx context.Context, sessionID string) error {
-	return s.repo.UpdateHeartbeat(ctx, sessionID)
+// publishPlayback publishes the session's position as a playback event
+func (s *StreamingService) publishPlayback(ctx context.Context, eventType string, session *models.PlaybackSession, at time.Time) error {
+	return s.playback.Publish(ctx, &playback.Event{
+		Type:      eventType,
+		SessionID: session.ID.Hex(),
+		UserID:    session.UserID,
+		ProfileID: session.ProfileID,
+		ContentID: session.ContentID,
+		Position:  session.Position,
+		Duration:  session.Duration,
+		Timestamp: at,
+	})
 }
 
-// EndSession ends a playback session
-func (s *StreamingService) EndSession(ctx context.Context, sessionID string) error {
-	return s.repo.DeleteSession(ctx, sessionID)
+// SendHeartbeat updates the heartbeat of a user's session and, when the player
+// reports them, the measured bandwidth (bps) used for ABR selection and the
+// player state
+func (s *StreamingService) SendHeartbeat(ctx context.Context, sessionID, userID string, bandwidth int64, state string) error {
+	session, err := s.ownSession(ctx, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	updated, err := s.repo.UpdateHeartbeat(ctx, sessionID, bandwidth)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrSessionEnded
+	}
+	// A session that lost its stream slot, or whose slot a downgraded plan no
+	// longer has, must stop playing. The limit is left unchecked when the
+	// subscription cannot be read.
+	maxStreams := 0
+	if subscription, err := s.paymentClient.GetSubscription(ctx, userID); err == nil {
+		maxStreams = int(subscription.GetMaxStreams())
+	}
+	if err := s.limiter.Refresh(ctx, sessionID, maxStreams); err != nil {
+		return err
+	}
+	if state != "" {
+		if _, err := s.TransitionSession(ctx, sessionID, state); err != nil {
+			return err
+		}
+	}
+	if bandwidth <= 0 {
+		return nil
+	}
+
+	if err := s.abr.ObserveBandwidth(ctx, session.UserID, session.DeviceID, bandwidth); err != nil {
+		// Log error
+	}
+	return nil
+}
+
+// GetSession retrieves a playback session
+func (s *StreamingService) GetSession(ctx context.Context, sessionID string) (*models.PlaybackSession, error) {
+	return s.repo.GetSession(ctx, sessionID)
+}
+
+// ownSession retrieves a playback session, which must belong to userID
+func (s *StreamingService) ownSession(ctx context.Context, sessionID, userID string) (*models.PlaybackSession, error) {
+	session, err := s.repo.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != userID {
+		return nil, ErrInvalidSession
+	}
+	return session, nil
+}
+
+// EndSession ends a user's playback session at the player's request
+func (s *StreamingService) EndSession(ctx context.Context, sessionID, userID string) error {
+	if _, err := s.ownSession(ctx, sessionID, userID); err != nil {
+		return err
+	}
+	_, err := s.endSession(ctx, sessionID, models.SessionEndUser, time.Now())
+	return err
 }
 
 // Helper methods
@@ -313,34 +806,99 @@ func (s *StreamingService) generateManifestURL(contentID, format string) string
 	return fmt.Sprintf("https://cdn.streamverse.com/videos/%s/master.m3u8", contentID)
 }
 
-func (s *StreamingService) getQualityLevels(contentID string) []models.QualityLevel {
-	return []models.QualityLevel{
-		{ID: "1080p", Resolution: "1920x1080", Bitrate: 5000000, Codec: "h264", URL: fmt.Sprintf("https://cdn.streamverse.com/videos/%s/1080p.m3u8", contentID)},
-		{ID: "720p", Resolution: "1280x720", Bitrate: 3000000, Codec: "h264", URL: fmt.Sprintf("https://cdn.streamverse.com/videos/%s/720p.m3u8", contentID)},
-		{ID: "480p", Resolution: "854x480", Bitrate: 1500000, Codec: "h264", URL: fmt.Sprintf("https://cdn.streamverse.com/videos/%s/480p.m3u8", contentID)},
+func (s *StreamingService) getQualityLevels(contentID string, renditions []models.Rendition) []models.QualityLevel {
+	var qualities []models.QualityLevel
+	for _, r := range renditions {
+		if r.Type != "video" {
+			continue
+		}
+		qualities = append(qualities, models.QualityLevel{
+			ID:         r.Name,
+			Resolution: r.Resolution(),
+			Bitrate:    r.Bandwidth,
+			Codec:      r.Codecs,
+			URL:        fmt.Sprintf("%s/%s/%s.m3u8", s.getCDNBaseURL(), contentID, r.Name),
+		})
 	}
+	return qualities
 }
 
-func (s *StreamingService) getSubtitles(contentID string) []models.SubtitleTrack {
-	return []models.SubtitleTrack{
-		{Language: "en", Label: "English", URL: fmt.Sprintf("https://cdn.streamverse.com/subtitles/%s/en.vtt", contentID), Format: "vtt"},
+// getSubtitles lists the subtitle tracks of the catalog, pointing at the single
+// WebVTT file of each track where there is one
+func (s *StreamingService) getSubtitles(contentID string, renditions []models.Rendition) []models.SubtitleTrack {
+	subtitles := []models.SubtitleTrack{}
+	for _, r := range renditions {
+		if r.Type != "subtitle" {
+			continue
+		}
+		url := fmt.Sprintf("%s/%s/%s.m3u8", s.getCDNBaseURL(), contentID, r.Name)
+		if r.SidecarURI != "" {
+			url = fmt.Sprintf("%s/%s/%s/%s", s.getCDNBaseURL(), contentID, r.Name, r.SidecarURI)
+		}
+		subtitles = append(subtitles, models.SubtitleTrack{
+			Language: r.Language,
+			Label:    r.Label,
+			Kind:     r.Kind,
+			URL:      url,
+			Format:   "vtt",
+			Default:  r.Default,
+		})
 	}
+	return subtitles
 }
 
-func (s *StreamingService) checkGeoRestrictions(ctx context.Context, contentID, userID string) error {
-	// TODO: Implement IP geolocation check
-	return nil
-}
-
+// getDRMInfo points players at the license proxy of this service, which checks
+// the playback token and entitlement before a license is issued
 func (s *StreamingService) getDRMInfo(content *content_proto.GetContentResponse, format string) *models.DRMInfo {
 	drmType := getDRMType(content, format)
 	return &models.DRMInfo{
 		Type:           drmType,
-		LicenseURL:     fmt.Sprintf("https://drm.streamverse.com/license/%s", drmType),
+		LicenseURL:     fmt.Sprintf("%s/streaming/license/%s", s.publicURL, drmType),
 		CertificateURL: getCertificateURL(drmType),
 	}
 }
 
+// drmConfig describes the protection of a title for a manifest: the license server
+// and the key ID and PSSH boxes of each track type among renditions. Keys of
+// tracks the viewer is not entitled to stay out of the manifest.
+func (s *StreamingService) drmConfig(ctx context.Context, content *content_proto.GetContentResponse, contentID, format string, renditions []models.Rendition) (*models.DRMConfig, error) {
+	drmInfo := s.getDRMInfo(content, format)
+	config := &models.DRMConfig{Type: drmInfo.Type, LicenseURL: drmInfo.LicenseURL}
+	if s.keys == nil {
+		return config, nil
+	}
+
+	keys, err := s.keys.Keys(ctx, contentID)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]bool)
+	for i := range renditions {
+		listed[renditions[i].TrackType()] = true
+	}
+	var used []drm.Key
+	for _, key := range keys {
+		if listed[key.TrackType] {
+			used = append(used, key)
+		}
+	}
+
+	config.Scheme = s.keys.Scheme()
+	if config.TrackKeys, err = drm.TrackKeys(contentID, config.Scheme, used); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ExportCPIX returns the CPIX document with the content keys of a title for
+// packagers, generating the keys on first use
+func (s *StreamingService) ExportCPIX(ctx context.Context, contentID string) ([]byte, error) {
+	if s.keys == nil {
+		return nil, fmt.Errorf("content key management is not configured")
+	}
+	return s.keys.CPIX(ctx, contentID)
+}
+
 func getDRMType(content *content_proto.GetContentResponse, format string) string {
 	if format == "dash" {
 		return "widevine"
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+	"github.com/streamverse/streaming-service/utils"
+)
+
+// tokenAudience is the aud claim of manifest tokens
+const tokenAudience = "cdn.streamverse.io"
+
+var (
+	// ErrTokenMismatch is returned when a valid token is used for another content item, network or device
+	ErrTokenMismatch = errors.New("token is not valid for this request")
+	// ErrTokenRevoked is returned for tokens killed through the revocation list
+	ErrTokenRevoked = errors.New("token has been revoked")
+)
+
+// TokenBindingConfig controls which request properties a manifest token is bound to.
+// Content binding is always enforced.
+type TokenBindingConfig struct {
+	BindIP           bool
+	IPv4Prefix       int // leading bits of the client address that must match the token
+	IPv6Prefix       int
+	MobileIPv4Prefix int // mobile networks move clients between nearby addresses
+	MobileIPv6Prefix int
+	BindDevice       bool // require the X-Device-ID of the request to match the token
+}
+
+// TokenBindingConfigFromEnv builds token binding config from environment variables.
+func TokenBindingConfigFromEnv() TokenBindingConfig {
+	cfg := TokenBindingConfig{
+		BindIP:           strings.EqualFold(strings.TrimSpace(os.Getenv("STREAM_TOKEN_BIND_IP")), "true"),
+		IPv4Prefix:       32,
+		IPv6Prefix:       64,
+		MobileIPv4Prefix: 24,
+		MobileIPv6Prefix: 48,
+		BindDevice:       strings.EqualFold(strings.TrimSpace(os.Getenv("STREAM_TOKEN_BIND_DEVICE")), "true"),
+	}
+
+	prefix := func(name string, max int, target *int) {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 && parsed <= max {
+				*target = parsed
+			}
+		}
+	}
+	prefix("STREAM_TOKEN_IPV4_PREFIX", 32, &cfg.IPv4Prefix)
+	prefix("STREAM_TOKEN_IPV6_PREFIX", 128, &cfg.IPv6Prefix)
+	prefix("STREAM_TOKEN_MOBILE_IPV4_PREFIX", 32, &cfg.MobileIPv4Prefix)
+	prefix("STREAM_TOKEN_MOBILE_IPV6_PREFIX", 128, &cfg.MobileIPv6Prefix)
+
+	return cfg
+}
+
+// AuthorizeToken validates a manifest token and checks that it was issued for the
+// requested content and, when binding is enabled, for the client's network and device
+func (s *StreamingService) AuthorizeToken(ctx context.Context, tokenString, contentID, clientIP, deviceID string) (*models.StreamingClaims, error) {
+	claims, err := s.ParseToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.ContentID != contentID {
+		return nil, fmt.Errorf("%w: issued for other content", ErrTokenMismatch)
+	}
+	if err := s.authorizeClaims(ctx, claims, clientIP, deviceID); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// AuthorizePlaybackToken validates a token that alone identifies the content, as
+// on the playback proxy routes, applying the same network, device and session checks
+func (s *StreamingService) AuthorizePlaybackToken(ctx context.Context, tokenString, clientIP, deviceID string) (*models.StreamingClaims, error) {
+	claims, err := s.ParseToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeClaims(ctx, claims, clientIP, deviceID); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// authorizeClaims applies network and device binding, the revocation list and,
+// for tokens issued to a playback session, the session's liveness
+func (s *StreamingService) authorizeClaims(ctx context.Context, claims *models.StreamingClaims, clientIP, deviceID string) error {
+	if s.tokenBinding.BindIP && claims.IP != "" && !s.tokenBinding.ipMatches(claims, clientIP) {
+		return fmt.Errorf("%w: client network changed", ErrTokenMismatch)
+	}
+	if s.tokenBinding.BindDevice && claims.DeviceID != "" && claims.DeviceID != deviceID {
+		return fmt.Errorf("%w: issued for another device", ErrTokenMismatch)
+	}
+
+	if s.isTokenRevoked(ctx, claims.TokenID) {
+		return ErrTokenRevoked
+	}
+	if claims.SessionID != "" {
+		session, err := s.repo.GetSession(ctx, claims.SessionID)
+		if err != nil {
+			return fmt.Errorf("%w: unknown playback session", ErrTokenMismatch)
+		}
+		if session.State == models.SessionStateEnded {
+			return ErrSessionEnded
+		}
+	}
+	return nil
+}
+
+// RevokeToken adds a token to the revocation list until it expires
+func (s *StreamingService) RevokeToken(ctx context.Context, tokenString string) (*models.StreamingClaims, error) {
+	claims, err := s.ParseToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenID == "" {
+		return nil, fmt.Errorf("token has no ID and cannot be revoked")
+	}
+
+	ttl := time.Until(claims.ExpiresAt)
+	if ttl <= 0 {
+		return claims, nil
+	}
+	if err := s.cache.Set(ctx, revokedTokenKey(claims.TokenID), true, ttl); err != nil {
+		return nil, fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return claims, nil
+}
+
+// isTokenRevoked checks the revocation list. Manifests keep being served while
+// Redis is unavailable; revocation is a support tool, not the primary control.
+func (s *StreamingService) isTokenRevoked(ctx context.Context, tokenID string) bool {
+	if tokenID == "" {
+		return false
+	}
+	var revoked bool
+	if err := s.cache.Get(ctx, revokedTokenKey(tokenID), &revoked); err != nil {
+		return false
+	}
+	return revoked
+}
+
+func revokedTokenKey(tokenID string) string {
+	return fmt.Sprintf("stream:token:revoked:%s", tokenID)
+}
+
+// ipMatches compares the client address with the one the token was issued to,
+// using the wider mobile prefix for mobile and tablet devices
+func (c TokenBindingConfig) ipMatches(claims *models.StreamingClaims, clientIP string) bool {
+	v4, v6 := c.IPv4Prefix, c.IPv6Prefix
+	switch utils.NormalizeDeviceType(claims.DeviceType) {
+	case utils.DeviceMobile, utils.DeviceTablet:
+		v4, v6 = c.MobileIPv4Prefix, c.MobileIPv6Prefix
+	}
+	return sameNetwork(claims.IP, clientIP, v4, v6)
+}
+
+// sameNetwork reports whether two addresses share their leading prefix bits
+func sameNetwork(a, b string, v4Prefix, v6Prefix int) bool {
+	ipA, ipB := net.ParseIP(strings.TrimSpace(a)), net.ParseIP(strings.TrimSpace(b))
+	if ipA == nil || ipB == nil {
+		return false
+	}
+
+	if a4, b4 := ipA.To4(), ipB.To4(); a4 != nil || b4 != nil {
+		if a4 == nil || b4 == nil {
+			return false
+		}
+		mask := net.CIDRMask(v4Prefix, 32)
+		return a4.Mask(mask).Equal(b4.Mask(mask))
+	}
+
+	mask := net.CIDRMask(v6Prefix, 128)
+	return ipA.Mask(mask).Equal(ipB.Mask(mask))
+}
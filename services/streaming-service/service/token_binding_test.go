@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signTestToken(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestSameNetwork(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		v4, v6   int
+		expected bool
+	}{
+		{"203.0.113.7", "203.0.113.7", 32, 64, true},
+		{"203.0.113.7", "203.0.113.99", 32, 64, false},
+		{"203.0.113.7", "203.0.113.99", 24, 64, true},
+		{"203.0.113.7", "::ffff:203.0.113.7", 32, 64, true},
+		{"2001:db8:1:2::1", "2001:db8:1:2::ff", 32, 64, true},
+		{"2001:db8:1:2::1", "2001:db8:1:3::1", 32, 64, false},
+		{"203.0.113.7", "2001:db8::1", 0, 0, false},
+		{"203.0.113.7", "not-an-ip", 0, 0, false},
+	}
+	for _, tc := range cases {
+		if got := sameNetwork(tc.a, tc.b, tc.v4, tc.v6); got != tc.expected {
+			t.Fatalf("%s vs %s (/%d, /%d): expected %v, got %v", tc.a, tc.b, tc.v4, tc.v6, tc.expected, got)
+		}
+	}
+}
+
+func TestAuthorizeTokenBindsClaims(t *testing.T) {
+	s := &StreamingService{
+		jwtSecret: "secret",
+		tokenBinding: TokenBindingConfig{
+			BindIP:           true,
+			IPv4Prefix:       32,
+			MobileIPv4Prefix: 24,
+			BindDevice:       true,
+		},
+	}
+	claims := jwt.MapClaims{
+		"user_id":     "u1",
+		"content_id":  "c1",
+		"ip":          "203.0.113.7",
+		"device_id":   "d1",
+		"device_type": "mobile",
+		"aud":         tokenAudience,
+		"exp":         jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	token := signTestToken(t, "secret", claims)
+	ctx := context.Background()
+
+	if _, err := s.AuthorizeToken(ctx, token, "c1", "203.0.113.50", "d1"); err != nil {
+		t.Fatalf("expected mobile client within /24 to pass, got %v", err)
+	}
+	if _, err := s.AuthorizeToken(ctx, token, "c2", "203.0.113.7", "d1"); !errors.Is(err, ErrTokenMismatch) {
+		t.Fatalf("expected content mismatch, got %v", err)
+	}
+	if _, err := s.AuthorizeToken(ctx, token, "c1", "198.51.100.1", "d1"); !errors.Is(err, ErrTokenMismatch) {
+		t.Fatalf("expected network mismatch, got %v", err)
+	}
+	if _, err := s.AuthorizeToken(ctx, token, "c1", "203.0.113.7", "d2"); !errors.Is(err, ErrTokenMismatch) {
+		t.Fatalf("expected device mismatch, got %v", err)
+	}
+
+	claims["aud"] = "other.example.com"
+	if _, err := s.AuthorizeToken(ctx, signTestToken(t, "secret", claims), "c1", "203.0.113.7", "d1"); err == nil {
+		t.Fatalf("expected a token for another audience to be rejected")
+	}
+}
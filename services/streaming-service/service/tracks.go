@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+// TrackPreferences returns the viewer's track preferences from user-service. The
+// catalog defaults are used when they cannot be loaded.
+func (s *StreamingService) TrackPreferences(ctx context.Context, authHeader string) models.TrackPreferences {
+	if s.userClient == nil {
+		return models.TrackPreferences{}
+	}
+	prefs, err := s.userClient.GetPreferences(ctx, authHeader)
+	if err != nil {
+		// Log error
+		return models.TrackPreferences{}
+	}
+	return models.TrackPreferences{
+		SubtitleLanguage: prefs.SubtitleLanguage,
+		AudioLanguage:    prefs.AudioLanguage,
+		ClosedCaptions:   prefs.ClosedCaptions,
+		AudioDescription: prefs.AudioDescription,
+	}
+}
+
+// selectDefaultTracks sets the default flags of the audio, subtitle and caption
+// renditions from the viewer's preferences. In each group the best match for a
+// preference becomes the only default; groups no preference applies to keep the
+// catalog defaults. Forced narratives are never the default: players select them
+// by the audio language. The renditions are copied, not changed in place.
+func selectDefaultTracks(renditions []models.Rendition, prefs models.TrackPreferences) []models.Rendition {
+	selected := make([]models.Rendition, len(renditions))
+	copy(selected, renditions)
+
+	var audio, subtitles, captions []*models.Rendition
+	for i := range selected {
+		switch selected[i].Type {
+		case "audio":
+			audio = append(audio, &selected[i])
+		case "subtitle":
+			if selected[i].Kind == models.KindForced {
+				selected[i].Default = false
+				continue
+			}
+			subtitles = append(subtitles, &selected[i])
+		case "caption":
+			captions = append(captions, &selected[i])
+		}
+	}
+
+	// Audio: the preferred language, else the language of the catalog default,
+	// as an audio description only when the viewer asks for one
+	audioLanguage := prefs.AudioLanguage
+	if audioLanguage == "" || !hasLanguage(audio, audioLanguage) {
+		audioLanguage = defaultLanguage(audio)
+	}
+	if prefs.AudioLanguage != "" || prefs.AudioDescription {
+		setDefault(audio, bestTrack(audio, audioLanguage, func(r *models.Rendition) int {
+			score := 0
+			if (r.Kind == models.KindDescription) == prefs.AudioDescription {
+				score += 4
+			}
+			if r.Default {
+				score += 2
+			}
+			if r.Kind != models.KindDub {
+				score++
+			}
+			return score
+		}))
+	}
+
+	// Text: captions viewers without a subtitle language get them in the audio language
+	textLanguage := prefs.SubtitleLanguage
+	if textLanguage == "" && prefs.ClosedCaptions {
+		textLanguage = audioLanguage
+	}
+	if textLanguage != "" {
+		best := bestTrack(subtitles, textLanguage, func(r *models.Rendition) int {
+			if (r.Kind == models.KindCaptions) == prefs.ClosedCaptions {
+				return 1
+			}
+			return 0
+		})
+		// Embedded captions stand in when there is no caption file, before plain subtitles
+		if prefs.ClosedCaptions {
+			var cc *models.Rendition
+			if best == nil || best.Kind != models.KindCaptions {
+				cc = bestTrack(captions, textLanguage, func(r *models.Rendition) int { return 0 })
+			}
+			if cc != nil {
+				best = nil
+			}
+			setDefault(captions, cc)
+		}
+		setDefault(subtitles, best)
+	}
+
+	return selected
+}
+
+// bestTrack returns the highest scoring track in a language, the first on ties,
+// or nil when no track has the language
+func bestTrack(tracks []*models.Rendition, language string, score func(r *models.Rendition) int) *models.Rendition {
+	var best *models.Rendition
+	bestScore := -1
+	for _, r := range tracks {
+		if !sameLanguage(r.Language, language) {
+			continue
+		}
+		if s := score(r); s > bestScore {
+			best, bestScore = r, s
+		}
+	}
+	return best
+}
+
+// setDefault makes best the only default track, or clears the defaults when it is nil
+func setDefault(tracks []*models.Rendition, best *models.Rendition) {
+	for _, r := range tracks {
+		r.Default = r == best
+	}
+}
+
+func hasLanguage(tracks []*models.Rendition, language string) bool {
+	for _, r := range tracks {
+		if sameLanguage(r.Language, language) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultLanguage returns the language of the catalog default, or of the first track
+func defaultLanguage(tracks []*models.Rendition) string {
+	for _, r := range tracks {
+		if r.Default {
+			return r.Language
+		}
+	}
+	if len(tracks) > 0 {
+		return tracks[0].Language
+	}
+	return ""
+}
+
+// sameLanguage compares the primary subtags of two BCP 47 tags, so "en" matches "en-GB"
+func sameLanguage(a, b string) bool {
+	primary := func(tag string) string {
+		tag, _, _ = strings.Cut(tag, "-")
+		return strings.ToLower(tag)
+	}
+	return a != "" && primary(a) == primary(b)
+}
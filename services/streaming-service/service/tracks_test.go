@@ -0,0 +1,70 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+func testTracks() []models.Rendition {
+	return []models.Rendition{
+		{Name: "720p", Type: "video", Height: 720},
+		{Name: "audio-en", Type: "audio", Language: "en", Kind: models.KindMain, Default: true},
+		{Name: "audio-en-ad", Type: "audio", Language: "en", Kind: models.KindDescription},
+		{Name: "audio-fr", Type: "audio", Language: "fr", Kind: models.KindDub},
+		{Name: "subs-en", Type: "subtitle", Language: "en", Kind: models.KindSubtitles, Default: true},
+		{Name: "subs-en-captions", Type: "subtitle", Language: "en", Kind: models.KindCaptions},
+		{Name: "subs-fr", Type: "subtitle", Language: "fr", Kind: models.KindSubtitles},
+		{Name: "subs-fr-forced", Type: "subtitle", Language: "fr", Kind: models.KindForced, Default: true},
+		{Name: "cc1", Type: "caption", Language: "en", Kind: models.KindCEA608, InstreamID: "CC1"},
+	}
+}
+
+func defaults(renditions []models.Rendition) map[string]bool {
+	selected := make(map[string]bool)
+	for _, r := range renditions {
+		if r.Default {
+			selected[r.Name] = true
+		}
+	}
+	return selected
+}
+
+func TestSelectDefaultTracksKeepsCatalogDefaults(t *testing.T) {
+	renditions := testTracks()
+	got := defaults(selectDefaultTracks(renditions, models.TrackPreferences{}))
+	if len(got) != 2 || !got["audio-en"] || !got["subs-en"] {
+		t.Fatalf("expected the catalog defaults without forced narratives, got %v", got)
+	}
+	if !renditions[7].Default {
+		t.Fatalf("expected the input renditions to be left unchanged")
+	}
+}
+
+func TestSelectDefaultTracksLanguages(t *testing.T) {
+	got := defaults(selectDefaultTracks(testTracks(), models.TrackPreferences{AudioLanguage: "fr-CA", SubtitleLanguage: "fr"}))
+	if len(got) != 2 || !got["audio-fr"] || !got["subs-fr"] {
+		t.Fatalf("expected French audio and subtitles, got %v", got)
+	}
+
+	// No track in the language: no subtitles rather than the wrong ones
+	got = defaults(selectDefaultTracks(testTracks(), models.TrackPreferences{AudioLanguage: "de", SubtitleLanguage: "de"}))
+	if len(got) != 1 || !got["audio-en"] {
+		t.Fatalf("expected the original audio and no subtitles, got %v", got)
+	}
+}
+
+func TestSelectDefaultTracksAccessibility(t *testing.T) {
+	got := defaults(selectDefaultTracks(testTracks(), models.TrackPreferences{ClosedCaptions: true, AudioDescription: true}))
+	if len(got) != 2 || !got["audio-en-ad"] || !got["subs-en-captions"] {
+		t.Fatalf("expected audio description and SDH captions, got %v", got)
+	}
+
+	// Embedded captions stand in when there is no caption file in the language
+	renditions := testTracks()[:5]
+	renditions = append(renditions, testTracks()[8])
+	got = defaults(selectDefaultTracks(renditions, models.TrackPreferences{ClosedCaptions: true}))
+	if len(got) != 2 || !got["audio-en"] || !got["cc1"] {
+		t.Fatalf("expected CEA-608 captions by default, got %v", got)
+	}
+}
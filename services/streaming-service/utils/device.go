@@ -0,0 +1,73 @@
+package utils
+
+import "strings"
+
+// Device classes used for ABR profile selection
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceTV      = "tv"
+)
+
+// NormalizeDeviceType maps a client-reported device type to a known device class,
+// returning "" for anything unrecognised
+func NormalizeDeviceType(deviceType string) string {
+	switch strings.ToLower(strings.TrimSpace(deviceType)) {
+	case "mobile", "phone", "smartphone":
+		return DeviceMobile
+	case "tablet":
+		return DeviceTablet
+	case "desktop", "web", "browser":
+		return DeviceDesktop
+	case "tv", "ctv", "smarttv", "stb", "console":
+		return DeviceTV
+	default:
+		return ""
+	}
+}
+
+// DeviceTypeFromUserAgent guesses the device class from a User-Agent header
+func DeviceTypeFromUserAgent(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case ua == "":
+		return ""
+	case containsAny(ua, "smart-tv", "smarttv", "appletv", "apple tv", "roku", "tizen", "web0s", "webos", "bravia", "crkey", "aftb", "aftm", "aftt", "playstation", "xbox"):
+		return DeviceTV
+	case containsAny(ua, "ipad", "tablet", "kindle", "silk/") || (strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		return DeviceTablet
+	case containsAny(ua, "mobi", "iphone", "ipod", "android"):
+		return DeviceMobile
+	default:
+		return DeviceDesktop
+	}
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// DRM security levels a device can play protected content at
+const (
+	DRMSecurityHardware = "hardware" // Widevine L1, hardware-secure FairPlay, PlayReady SL3000
+	DRMSecuritySoftware = "software" // Widevine L2/L3, software FairPlay, PlayReady SL2000
+)
+
+// NormalizeDRMSecurity maps a client-reported DRM security level to hardware or
+// software, returning "" for anything unrecognised
+func NormalizeDRMSecurity(level string) string {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "l1", "hw", "hardware", "hw_secure", "sl3000":
+		return DRMSecurityHardware
+	case "l2", "l3", "sw", "software", "sw_secure", "sl2000", "sl150":
+		return DRMSecuritySoftware
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+// GenerateLLHLSMediaPlaylist generates a low-latency HLS media playlist for a live
+// rendition window. Segment and part URIs are resolved against baseURL, the
+// rendition's location on the CDN.
+func GenerateLLHLSMediaPlaylist(baseURL string, playlist *models.LivePlaylist) string {
+	baseURL = strings.TrimRight(baseURL, "/")
+
+	mediaSequence := playlist.NextMSN
+	if len(playlist.Segments) > 0 {
+		mediaSequence = playlist.Segments[0].MSN
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:9\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(playlist.TargetDuration)))
+	fmt.Fprintf(&b, "#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=%.3f\n", 3*playlist.PartTarget)
+	fmt.Fprintf(&b, "#EXT-X-PART-INF:PART-TARGET=%.3f\n", playlist.PartTarget)
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", mediaSequence)
+
+	if playlist.Rendition.InitSegment != "" {
+		fmt.Fprintf(&b, "#EXT-X-MAP:URI=\"%s/%s\"\n", baseURL, playlist.Rendition.InitSegment)
+	}
+
+	// Parts only need to be listed for segments within three target durations of the live edge
+	partsFrom := len(playlist.Segments)
+	var tail float64
+	for i := len(playlist.Segments) - 1; i >= 0 && tail < 3*playlist.TargetDuration; i-- {
+		tail += playlist.Segments[i].Duration
+		partsFrom = i
+	}
+
+	for i, segment := range playlist.Segments {
+		if i == 0 && !segment.ProgramDateTime.IsZero() {
+			fmt.Fprintf(&b, "#EXT-X-PROGRAM-DATE-TIME:%s\n", segment.ProgramDateTime.UTC().Format(time.RFC3339Nano))
+		}
+		if i >= partsFrom {
+			writeParts(&b, baseURL, segment.Parts)
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n", segment.Duration)
+		fmt.Fprintf(&b, "%s/%s\n", baseURL, segment.URI)
+	}
+
+	writeParts(&b, baseURL, playlist.PendingParts)
+
+	if playlist.PreloadHintURI != "" {
+		fmt.Fprintf(&b, "#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"%s/%s\"\n", baseURL, playlist.PreloadHintURI)
+	}
+
+	for _, report := range playlist.Reports {
+		fmt.Fprintf(&b, "#EXT-X-RENDITION-REPORT:URI=\"%s.m3u8\",LAST-MSN=%d,LAST-PART=%d\n",
+			report.Name, report.LastMSN, report.LastPart)
+	}
+
+	return b.String()
+}
+
+func writeParts(b *strings.Builder, baseURL string, parts []models.LivePart) {
+	for _, part := range parts {
+		fmt.Fprintf(b, "#EXT-X-PART:DURATION=%.3f,URI=\"%s/%s\"", part.Duration, baseURL, part.URI)
+		if part.Independent {
+			b.WriteString(",INDEPENDENT=YES")
+		}
+		b.WriteString("\n")
+	}
+}
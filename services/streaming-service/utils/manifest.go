@@ -2,55 +2,289 @@ package utils
 
 import (
 	"fmt"
+	"math"
+	"net/url"
+	"strings"
+
 	"github.com/streamverse/streaming-service/models"
 )
 
-// GenerateHLSManifest generates an HLS manifest (.m3u8)
-func GenerateHLSManifest(baseURL string, variants []models.Variant, subtitles []models.Subtitle, drmConfig *models.DRMConfig) string {
-	manifest := "#EXTM3U\n"
-	manifest += "#EXT-X-VERSION:6\n"
-	manifest += "#EXT-X-INDEPENDENT-SEGMENTS\n"
+const (
+	hlsAudioGroupID    = "audio"
+	hlsSubtitleGroupID = "subs"
+	hlsCaptionGroupID  = "cc"
+)
+
+// Media characteristics (Apple UTIs) of accessibility tracks
+const (
+	hlsCharacteristicsSDH              = "public.accessibility.transcribes-spoken-dialog,public.accessibility.describes-music-and-sound"
+	hlsCharacteristicsAudioDescription = "public.accessibility.describes-video"
+)
+
+// GenerateHLSManifest generates an HLS multivariant playlist (.m3u8)
+func GenerateHLSManifest(manifest *models.Manifest) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:6\n")
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+
+	if drm := manifest.DRMConfig; drm != nil && len(drm.TrackKeys) > 0 {
+		// Session keys let players fetch licenses for every track before loading media playlists
+		for i := range drm.TrackKeys {
+			writeHLSKeys(&b, "EXT-X-SESSION-KEY", drm, &drm.TrackKeys[i])
+		}
+	} else if drm != nil {
+		fmt.Fprintf(&b, "#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI=\"%s\",KEYFORMAT=\"%s\",KEYFORMATVERSIONS=\"1\"\n",
+			drm.LicenseURL, hlsKeyFormat(drm.Type))
+	}
+
+	// With content steering every pathway repeats the renditions against its own CDN
+	if steering := manifest.Steering; steering != nil && len(steering.Pathways) > 0 {
+		fmt.Fprintf(&b, "#EXT-X-CONTENT-STEERING:SERVER-URI=\"%s\",PATHWAY-ID=\"%s\"\n", steering.ServerURI, steering.Pathways[0])
+		for _, pathway := range steering.Pathways {
+			writeHLSRenditions(&b, manifest, pathway)
+		}
+	} else {
+		writeHLSRenditions(&b, manifest, "")
+	}
+
+	return b.String()
+}
+
+// writeHLSRenditions writes the alternate renditions and variants of a multivariant
+// playlist. For a content steering pathway, rendition groups are suffixed with the
+// pathway and playlist URIs carry it in the pathway query parameter.
+func writeHLSRenditions(b *strings.Builder, manifest *models.Manifest, pathway string) {
+	audioGroup, subtitleGroup, captionGroup := hlsAudioGroupID, hlsSubtitleGroupID, hlsCaptionGroupID
+	uri := func(u string) string { return u }
+	if pathway != "" {
+		audioGroup += "-" + pathway
+		subtitleGroup += "-" + pathway
+		captionGroup += "-" + pathway
+		uri = func(u string) string { return appendQuery(u, "pathway="+url.QueryEscape(pathway)) }
+	}
+
+	// Alternate audio renditions. Players without a language preference only pick
+	// tracks by themselves (AUTOSELECT) when they have a language or are the default.
+	for _, audio := range manifest.AudioTracks {
+		fmt.Fprintf(b, "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"%s\",NAME=\"%s\",LANGUAGE=\"%s\",DEFAULT=%s,AUTOSELECT=%s",
+			audioGroup, audio.Label, audio.Language, hlsBool(audio.Default), hlsBool(audio.Default || audio.Language != ""))
+		if audio.Kind == models.KindDescription {
+			fmt.Fprintf(b, ",CHARACTERISTICS=\"%s\"", hlsCharacteristicsAudioDescription)
+		}
+		if audio.Channels > 0 {
+			fmt.Fprintf(b, ",CHANNELS=\"%d\"", audio.Channels)
+		}
+		fmt.Fprintf(b, ",URI=\"%s\"\n", uri(audio.URL))
+	}
 
-	if drmConfig != nil {
-		manifest += fmt.Sprintf("#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"%s\",KEYFORMAT=\"%s\",KEYFORMATVERSIONS=\"1\"\n",
-			drmConfig.LicenseURL, drmConfig.Type)
+	// Subtitle renditions. Forced narratives are shown by players matching them to
+	// the audio language, whatever the subtitle choice.
+	for _, subtitle := range manifest.Subtitles {
+		fmt.Fprintf(b, "#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"%s\",NAME=\"%s\",LANGUAGE=\"%s\",DEFAULT=%s,AUTOSELECT=%s",
+			subtitleGroup, subtitle.Label, subtitle.Language, hlsBool(subtitle.Default), hlsBool(subtitle.Default || subtitle.Language != ""))
+		switch subtitle.Kind {
+		case models.KindForced:
+			b.WriteString(",FORCED=YES")
+		case models.KindCaptions:
+			fmt.Fprintf(b, ",CHARACTERISTICS=\"%s\"", hlsCharacteristicsSDH)
+		}
+		fmt.Fprintf(b, ",URI=\"%s\"\n", uri(subtitle.URL))
 	}
 
-	// Add variants
-	for _, variant := range variants {
-		manifest += fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%s,CODECS=\"%s\"\n",
-			variant.Bandwidth, variant.Resolution, variant.Codec)
-		manifest += variant.URL + "\n"
+	// Closed captions carried in the video segments
+	for _, cc := range manifest.ClosedCaptions {
+		fmt.Fprintf(b, "#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID=\"%s\",NAME=\"%s\",LANGUAGE=\"%s\",DEFAULT=%s,AUTOSELECT=%s,INSTREAM-ID=\"%s\",CHARACTERISTICS=\"%s\"\n",
+			captionGroup, cc.Label, cc.Language, hlsBool(cc.Default), hlsBool(cc.Default || cc.Language != ""), cc.InstreamID, hlsCharacteristicsSDH)
 	}
 
-	// Add subtitles
-	for _, subtitle := range subtitles {
-		manifest += fmt.Sprintf("#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",NAME=\"%s\",LANGUAGE=\"%s\",URI=\"%s\"\n",
-			subtitle.Label, subtitle.Language, subtitle.URL)
+	// Variants, in the order the caller wants players to try them
+	for _, variant := range manifest.Variants {
+		fmt.Fprintf(b, "#EXT-X-STREAM-INF:BANDWIDTH=%d", variant.Bandwidth)
+		if variant.AverageBandwidth > 0 {
+			fmt.Fprintf(b, ",AVERAGE-BANDWIDTH=%d", variant.AverageBandwidth)
+		}
+		if variant.Resolution != "" {
+			fmt.Fprintf(b, ",RESOLUTION=%s", variant.Resolution)
+		}
+		fmt.Fprintf(b, ",CODECS=\"%s\"", variant.Codec)
+		if variant.FrameRate > 0 {
+			fmt.Fprintf(b, ",FRAME-RATE=%.3f", variant.FrameRate)
+		}
+		if len(manifest.AudioTracks) > 0 {
+			fmt.Fprintf(b, ",AUDIO=\"%s\"", audioGroup)
+		}
+		if len(manifest.Subtitles) > 0 {
+			fmt.Fprintf(b, ",SUBTITLES=\"%s\"", subtitleGroup)
+		}
+		if len(manifest.ClosedCaptions) > 0 {
+			fmt.Fprintf(b, ",CLOSED-CAPTIONS=\"%s\"", captionGroup)
+		}
+		if pathway != "" {
+			fmt.Fprintf(b, ",PATHWAY-ID=\"%s\"", pathway)
+		}
+		b.WriteString("\n")
+		b.WriteString(uri(variant.URL) + "\n")
 	}
 
-	return manifest
+	// Trick play: I-frame playlists for fast forward and rewind, and thumbnail
+	// sprites for scrubbing previews on TV clients
+	for _, variant := range manifest.IFrameVariants {
+		fmt.Fprintf(b, "#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=%d", variant.Bandwidth)
+		if variant.Resolution != "" {
+			fmt.Fprintf(b, ",RESOLUTION=%s", variant.Resolution)
+		}
+		fmt.Fprintf(b, ",CODECS=\"%s\"", variant.Codec)
+		if pathway != "" {
+			fmt.Fprintf(b, ",PATHWAY-ID=\"%s\"", pathway)
+		}
+		fmt.Fprintf(b, ",URI=\"%s\"\n", uri(variant.URL))
+	}
+	for _, image := range manifest.ImageStreams {
+		fmt.Fprintf(b, "#EXT-X-IMAGE-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%s,CODECS=\"%s\"", image.Bandwidth, image.Resolution, image.Codec)
+		if pathway != "" {
+			fmt.Fprintf(b, ",PATHWAY-ID=\"%s\"", pathway)
+		}
+		fmt.Fprintf(b, ",URI=\"%s\"\n", uri(image.URL))
+	}
 }
 
-// GenerateDASHManifest generates a DASH manifest (.mpd)
-func GenerateDASHManifest(baseURL string, variants []models.Variant, subtitles []models.Subtitle, drmConfig *models.DRMConfig) string {
-	manifest := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"
-	manifest += "<MPD xmlns=\"urn:mpeg:dash:schema:mpd:2011\" type=\"static\" mediaPresentationDuration=\"PT0H10M0S\" minBufferTime=\"PT1.5S\" profiles=\"urn:mpeg:dash:profile:isoff-on-demand:2011\">\n"
-	manifest += "<Period>\n"
-	manifest += "<AdaptationSet>\n"
+// GenerateHLSMediaPlaylist generates a VOD media playlist for a single rendition.
+// Segment URIs are resolved against baseURL, the rendition's location on the CDN,
+// and carry query, the CDN token authorizing the rendition, when it is set. When
+// drm has a key for the rendition's track type, it is signalled with EXT-X-KEY.
+func GenerateHLSMediaPlaylist(baseURL string, rendition *models.Rendition, query string, drm *models.DRMConfig) string {
+	baseURL = strings.TrimRight(baseURL, "/")
 
-	// Add variants
-	for _, variant := range variants {
-		manifest += fmt.Sprintf("<Representation id=\"%s\" bandwidth=\"%d\" width=\"1920\" height=\"1080\" codecs=\"%s\">\n",
-			variant.Resolution, variant.Bandwidth, variant.Codec)
-		manifest += fmt.Sprintf("<BaseURL>%s</BaseURL>\n", variant.URL)
-		manifest += "</Representation>\n"
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	switch {
+	case rendition.InitSegment != "" || rendition.Type == "thumbnail":
+		b.WriteString("#EXT-X-VERSION:7\n")
+	case rendition.Type == "iframe":
+		b.WriteString("#EXT-X-VERSION:4\n")
+	default:
+		b.WriteString("#EXT-X-VERSION:3\n")
+	}
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", targetDuration(rendition.Segments))
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	switch rendition.Type {
+	case "iframe":
+		b.WriteString("#EXT-X-I-FRAMES-ONLY\n")
+	case "thumbnail":
+		b.WriteString("#EXT-X-IMAGES-ONLY\n")
 	}
 
-	manifest += "</AdaptationSet>\n"
-	manifest += "</Period>\n"
-	manifest += "</MPD>\n"
+	if drm != nil {
+		if key := drm.KeyFor(rendition.TrackType()); key != nil {
+			writeHLSKeys(&b, "EXT-X-KEY", drm, key)
+		}
+	}
 
-	return manifest
+	if rendition.InitSegment != "" {
+		fmt.Fprintf(&b, "#EXT-X-MAP:URI=\"%s\"\n", appendQuery(baseURL+"/"+rendition.InitSegment, query))
+	}
+
+	columns, rows := rendition.Tiles()
+	for _, segment := range rendition.Segments {
+		if rendition.Type == "thumbnail" {
+			fmt.Fprintf(&b, "#EXT-X-TILES:RESOLUTION=%s,LAYOUT=%dx%d,DURATION=%.3f\n",
+				rendition.Resolution(), columns, rows, rendition.TileDuration())
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n", segment.Duration)
+		b.WriteString(appendQuery(baseURL+"/"+segment.URI, query) + "\n")
+	}
+
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String()
 }
 
+// GenerateThumbnailVTT generates a WebVTT thumbnail track for a sprite rendition,
+// as web players expect: one cue per tile, addressing the tile within its sprite
+// with a spatial media fragment. Sprite URLs are resolved like media segments.
+func GenerateThumbnailVTT(baseURL string, rendition *models.Rendition, query string) string {
+	baseURL = strings.TrimRight(baseURL, "/")
+	columns, rows := rendition.Tiles()
+	interval := int64(math.Round(rendition.TileDuration() * 1000))
+
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	if interval <= 0 {
+		return b.String()
+	}
+
+	// Work in milliseconds from the running total so cue times never drift
+	var elapsed float64
+	var start int64
+	for _, segment := range rendition.Segments {
+		elapsed += segment.Duration
+		end := int64(math.Round(elapsed * 1000))
+		sprite := appendQuery(baseURL+"/"+segment.URI, query)
+		for tile := 0; tile < columns*rows; tile++ {
+			from := start + int64(tile)*interval
+			if from >= end {
+				break
+			}
+			to := min(from+interval, end)
+			fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n", vttTimestamp(from), vttTimestamp(to), sprite,
+				tile%columns*rendition.Width, tile/columns*rendition.Height, rendition.Width, rendition.Height)
+		}
+		start = end
+	}
+	return b.String()
+}
+
+// vttTimestamp formats milliseconds as a WebVTT timestamp, e.g. "00:01:02.500"
+func vttTimestamp(ms int64) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// targetDuration is the longest segment duration rounded to the nearest integer,
+// as required for EXT-X-TARGETDURATION.
+func targetDuration(segments []models.Segment) int {
+	target := 1
+	for _, segment := range segments {
+		if d := int(math.Round(segment.Duration)); d > target {
+			target = d
+		}
+	}
+	return target
+}
+
+// writeHLSKeys writes one key tag per DRM system able to play the key: FairPlay
+// through its skd:// URI (cbcs only) and Widevine through its PSSH box. PlayReady
+// clients play DASH.
+func writeHLSKeys(b *strings.Builder, tag string, drm *models.DRMConfig, key *models.TrackKey) {
+	method := "SAMPLE-AES-CTR"
+	if drm.Scheme == "cbcs" {
+		method = "SAMPLE-AES"
+		fmt.Fprintf(b, "#%s:METHOD=%s,URI=\"skd://%s\",KEYFORMAT=\"%s\",KEYFORMATVERSIONS=\"1\"\n",
+			tag, method, strings.ReplaceAll(key.KeyID, "-", ""), hlsKeyFormat("fairplay"))
+	}
+	if pssh := key.PSSH["widevine"]; pssh != "" {
+		fmt.Fprintf(b, "#%s:METHOD=%s,URI=\"data:text/plain;base64,%s\",KEYID=0x%s,KEYFORMAT=\"%s\",KEYFORMATVERSIONS=\"1\"\n",
+			tag, method, pssh, strings.ReplaceAll(key.KeyID, "-", ""), hlsKeyFormat("widevine"))
+	}
+}
+
+func hlsKeyFormat(drmType string) string {
+	switch drmType {
+	case "fairplay":
+		return "com.apple.streamingkeydelivery"
+	case "widevine":
+		return "urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed"
+	case "playready":
+		return "com.microsoft.playready"
+	default:
+		return "identity"
+	}
+}
+
+func hlsBool(value bool) string {
+	if value {
+		return "YES"
+	}
+	return "NO"
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+func TestGenerateHLSManifestTrickPlay(t *testing.T) {
+	manifest := &models.Manifest{
+		Variants:       []models.Variant{{Name: "720p", Bandwidth: 5000000, Resolution: "1280x720", Codec: "avc1.64001f", URL: "720p.m3u8"}},
+		IFrameVariants: []models.Variant{{Name: "iframe-720p", Bandwidth: 400000, Resolution: "1280x720", Codec: "avc1.64001f", URL: "iframe-720p.m3u8"}},
+		ImageStreams:   []models.Variant{{Name: "thumbs-320", Bandwidth: 20000, Resolution: "320x180", Codec: "jpeg", URL: "thumbs-320.m3u8"}},
+		Steering:       &models.ContentSteering{ServerURI: "steering.json", Pathways: []string{"cdn-a"}},
+	}
+
+	got := GenerateHLSManifest(manifest)
+	for _, want := range []string{
+		`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=400000,RESOLUTION=1280x720,CODECS="avc1.64001f",PATHWAY-ID="cdn-a",URI="iframe-720p.m3u8?pathway=cdn-a"`,
+		`#EXT-X-IMAGE-STREAM-INF:BANDWIDTH=20000,RESOLUTION=320x180,CODECS="jpeg",PATHWAY-ID="cdn-a",URI="thumbs-320.m3u8?pathway=cdn-a"`,
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Fatalf("expected %s in playlist:\n%s", want, got)
+		}
+	}
+}
+
+func TestGenerateHLSMediaPlaylistTrickPlay(t *testing.T) {
+	var iframes, sprites *models.Rendition
+	for _, r := range testTrickPlayRenditions() {
+		switch r.Type {
+		case "iframe":
+			if iframes == nil {
+				iframes = &r
+			}
+		case "thumbnail":
+			sprites = &r
+		}
+	}
+	drm := &models.DRMConfig{Type: "widevine", Scheme: "cbcs", TrackKeys: []models.TrackKey{{TrackType: models.TrackTypeHD, KeyID: "2d6c9b9f-63b5-4fe7-8c1d-1c6f4e2d8b02"}}}
+
+	got := GenerateHLSMediaPlaylist("https://cdn/c1/iframe-720p", iframes, "token=t", drm)
+	if !strings.Contains(got, "#EXT-X-I-FRAMES-ONLY\n") || !strings.Contains(got, "skd://2d6c9b9f63b54fe78c1d1c6f4e2d8b02") {
+		t.Fatalf("expected an encrypted I-frame-only playlist, got:\n%s", got)
+	}
+
+	got = GenerateHLSMediaPlaylist("https://cdn/c1/thumbs-320", sprites, "token=t", drm)
+	want := "#EXT-X-IMAGES-ONLY\n" +
+		"#EXT-X-TILES:RESOLUTION=320x180,LAYOUT=2x2,DURATION=4.000\n#EXTINF:16.000,\nhttps://cdn/c1/thumbs-320/tile_1.jpg?token=t\n" +
+		"#EXT-X-TILES:RESOLUTION=320x180,LAYOUT=2x2,DURATION=4.000\n#EXTINF:4.500,\nhttps://cdn/c1/thumbs-320/tile_2.jpg?token=t\n"
+	if !strings.Contains(got, want) || strings.Contains(got, "EXT-X-KEY") {
+		t.Fatalf("expected a clear image playlist with tiles, got:\n%s", got)
+	}
+}
+
+func TestGenerateThumbnailVTT(t *testing.T) {
+	sprites := testTrickPlayRenditions()[2]
+
+	got := GenerateThumbnailVTT("https://cdn/c1/thumbs-320/", &sprites, "token=t")
+	cues := strings.Split(strings.TrimPrefix(got, "WEBVTT\n\n"), "\n\n")
+	if len(cues) != 6 {
+		t.Fatalf("expected 4 tiles in the first sprite and 2 in the last, got %d:\n%s", len(cues), got)
+	}
+	if cues[3] != "00:00:12.000 --> 00:00:16.000\nhttps://cdn/c1/thumbs-320/tile_1.jpg?token=t#xywh=320,180,320,180" {
+		t.Fatalf("unexpected last tile of the first sprite %q", cues[3])
+	}
+	if cues[5] != "00:00:20.000 --> 00:00:20.500\nhttps://cdn/c1/thumbs-320/tile_2.jpg?token=t#xywh=320,0,320,180\n" {
+		t.Fatalf("expected the final cue to end with the sprite, got %q", cues[5])
+	}
+}
+
+func TestGenerateHLSManifestAccessibilityTracks(t *testing.T) {
+	manifest := &models.Manifest{
+		Variants: []models.Variant{{Name: "720p", Bandwidth: 5000000, Codec: "avc1.64001f,mp4a.40.2", URL: "720p.m3u8"}},
+		AudioTracks: []models.AudioTrack{
+			{Name: "audio-en", Language: "en", Label: "English", URL: "audio-en.m3u8", Default: true},
+			{Name: "audio-en-ad", Language: "en", Label: "English (AD)", Kind: models.KindDescription, URL: "audio-en-ad.m3u8"},
+		},
+		Subtitles: []models.Subtitle{
+			{Language: "en", Label: "English (SDH)", Kind: models.KindCaptions, URL: "subs-en-captions.m3u8"},
+			{Language: "fr", Label: "Français (forced)", Kind: models.KindForced, URL: "subs-fr-forced.m3u8"},
+			{Label: "Commentary", URL: "subs-commentary.m3u8"},
+		},
+		ClosedCaptions: []models.ClosedCaption{{Name: "cc1", Language: "en", Label: "English CC", InstreamID: "CC1", Default: true}},
+	}
+
+	got := GenerateHLSManifest(manifest)
+	for _, want := range []string{
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="English (AD)",LANGUAGE="en",DEFAULT=NO,AUTOSELECT=YES,CHARACTERISTICS="public.accessibility.describes-video",URI="audio-en-ad.m3u8"`,
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English (SDH)",LANGUAGE="en",DEFAULT=NO,AUTOSELECT=YES,CHARACTERISTICS="` + hlsCharacteristicsSDH + `",URI="subs-en-captions.m3u8"`,
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="Français (forced)",LANGUAGE="fr",DEFAULT=NO,AUTOSELECT=YES,FORCED=YES,URI="subs-fr-forced.m3u8"`,
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="Commentary",LANGUAGE="",DEFAULT=NO,AUTOSELECT=NO,URI="subs-commentary.m3u8"`,
+		`#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="English CC",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,INSTREAM-ID="CC1",CHARACTERISTICS="` + hlsCharacteristicsSDH + `"`,
+		`#EXT-X-STREAM-INF:BANDWIDTH=5000000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="audio",SUBTITLES="subs",CLOSED-CAPTIONS="cc"`,
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Fatalf("expected %s in playlist:\n%s", want, got)
+		}
+	}
+}
+
+func TestGenerateHLSManifestABRLadder(t *testing.T) {
+	manifest := &models.Manifest{
+		Variants: []models.Variant{
+			{Name: "720p", Bandwidth: 5128000, AverageBandwidth: 4200000, Resolution: "1280x720", Codec: "avc1.64001f,mp4a.40.2", FrameRate: 29.97, URL: "720p.m3u8"},
+			{Name: "1080p", Bandwidth: 8128000, Resolution: "1920x1080", Codec: "avc1.640028,mp4a.40.2", FrameRate: 29.97, URL: "1080p.m3u8"},
+		},
+		AudioTracks: []models.AudioTrack{
+			{Name: "audio-en", Language: "en", Label: "English", Channels: 2, URL: "audio-en.m3u8", Default: true},
+			{Name: "audio-es", Language: "es", Label: "Español", Channels: 6, URL: "audio-es.m3u8"},
+		},
+		Subtitles: []models.Subtitle{{Language: "en", Label: "English", URL: "subs-en.m3u8", Default: true}},
+	}
+
+	got := GenerateHLSManifest(manifest)
+	want := "#EXTM3U\n#EXT-X-VERSION:6\n#EXT-X-INDEPENDENT-SEGMENTS\n" +
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="2",URI="audio-en.m3u8"` + "\n" +
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="Español",LANGUAGE="es",DEFAULT=NO,AUTOSELECT=YES,CHANNELS="6",URI="audio-es.m3u8"` + "\n" +
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,URI="subs-en.m3u8"` + "\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=5128000,AVERAGE-BANDWIDTH=4200000,RESOLUTION=1280x720,CODECS="avc1.64001f,mp4a.40.2",FRAME-RATE=29.970,AUDIO="audio",SUBTITLES="subs"` + "\n720p.m3u8\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=8128000,RESOLUTION=1920x1080,CODECS="avc1.640028,mp4a.40.2",FRAME-RATE=29.970,AUDIO="audio",SUBTITLES="subs"` + "\n1080p.m3u8\n"
+	if got != want {
+		t.Fatalf("unexpected playlist:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGenerateHLSMediaPlaylist(t *testing.T) {
+	renditions := testRenditions()
+
+	got := GenerateHLSMediaPlaylist("https://cdn/c1/720p/", &renditions[1], "token=t", nil)
+	want := "#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:6\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXT-X-INDEPENDENT-SEGMENTS\n" +
+		`#EXT-X-MAP:URI="https://cdn/c1/720p/init.mp4?token=t"` + "\n" +
+		"#EXTINF:6.000,\nhttps://cdn/c1/720p/segment_1.m4s?token=t\n" +
+		"#EXTINF:6.000,\nhttps://cdn/c1/720p/segment_2.m4s?token=t\n" +
+		"#EXTINF:6.000,\nhttps://cdn/c1/720p/segment_3.m4s?token=t\n" +
+		"#EXTINF:2.500,\nhttps://cdn/c1/720p/segment_4.m4s?token=t\n" +
+		"#EXT-X-ENDLIST\n"
+	if got != want {
+		t.Fatalf("unexpected playlist:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Target duration is the longest segment rounded to the nearest second, and
+	// without an init segment or a token segments are listed as they are
+	audio := renditions[3]
+	audio.InitSegment = ""
+	got = GenerateHLSMediaPlaylist("https://cdn/c1/audio-en", &audio, "", nil)
+	if !strings.Contains(got, "#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:6\n") || !strings.Contains(got, "#EXTINF:6.016,\nhttps://cdn/c1/audio-en/segment_1.m4s\n") || strings.Contains(got, "EXT-X-MAP") {
+		t.Fatalf("unexpected playlist:\n%s", got)
+	}
+}
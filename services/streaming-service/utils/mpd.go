@@ -0,0 +1,692 @@
+package utils
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+// DRM system identifiers used in DASH ContentProtection elements
+const (
+	mp4ProtectionScheme = "urn:mpeg:dash:mp4protection:2011"
+	widevineSystemID    = "urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed"
+	playReadySystemID   = "urn:uuid:9a04f079-9840-4286-ab92-e65be0885f95"
+	defaultTimescale    = 1000
+)
+
+// DASH-IF descriptors for trick play
+const (
+	trickModeScheme     = "http://dashif.org/guidelines/trickmode"
+	thumbnailTileScheme = "http://dashif.org/thumbnail_tile"
+)
+
+// Role and accessibility schemes of audio and text tracks. Audio descriptions and
+// captions for the hard of hearing are flagged with the TV-Anytime audio purpose
+// values DVB-DASH uses; CEA-608/708 captions carried in the video with the SCTE
+// 214 schemes.
+const (
+	roleScheme         = "urn:mpeg:dash:role:2011"
+	audioPurposeScheme = "urn:tva:metadata:cs:AudioPurposeCS:2007"
+	cea608Scheme       = "urn:scte:dash:cc:cea-608:2015"
+	cea708Scheme       = "urn:scte:dash:cc:cea-708:2015"
+)
+
+// MPD is the root element of a DASH media presentation description
+type MPD struct {
+	XMLName                   xml.Name            `xml:"MPD"`
+	XMLNS                     string              `xml:"xmlns,attr"`
+	XMLNSCenc                 string              `xml:"xmlns:cenc,attr,omitempty"`
+	Type                      string              `xml:"type,attr"`
+	Profiles                  string              `xml:"profiles,attr"`
+	MinBufferTime             string              `xml:"minBufferTime,attr"`
+	MediaPresentationDuration string              `xml:"mediaPresentationDuration,attr,omitempty"`
+	AvailabilityStartTime     string              `xml:"availabilityStartTime,attr,omitempty"`
+	PublishTime               string              `xml:"publishTime,attr,omitempty"`
+	MinimumUpdatePeriod       string              `xml:"minimumUpdatePeriod,attr,omitempty"`
+	TimeShiftBufferDepth      string              `xml:"timeShiftBufferDepth,attr,omitempty"`
+	MaxSegmentDuration        string              `xml:"maxSegmentDuration,attr,omitempty"`
+	ContentSteering           *ContentSteering    `xml:"ContentSteering,omitempty"`
+	BaseURLs                  []BaseURL           `xml:"BaseURL"`
+	ServiceDescription        *ServiceDescription `xml:"ServiceDescription,omitempty"`
+	Periods                   []Period            `xml:"Period"`
+	UTCTiming                 *Descriptor         `xml:"UTCTiming,omitempty"`
+}
+
+// ContentSteering points players at the steering server that orders service locations
+type ContentSteering struct {
+	DefaultServiceLocation string `xml:"defaultServiceLocation,attr"`
+	QueryBeforeStart       bool   `xml:"queryBeforeStart,attr"`
+	URI                    string `xml:",chardata"`
+}
+
+// BaseURL is a content location, tagged with its CDN under content steering
+type BaseURL struct {
+	ServiceLocation string `xml:"serviceLocation,attr,omitempty"`
+	URL             string `xml:",chardata"`
+}
+
+// ServiceDescription carries the low-latency playback targets of a live presentation
+type ServiceDescription struct {
+	ID           int           `xml:"id,attr"`
+	Latency      *Latency      `xml:"Latency"`
+	PlaybackRate *PlaybackRate `xml:"PlaybackRate"`
+}
+
+// Latency is the target live latency in milliseconds
+type Latency struct {
+	Target int `xml:"target,attr"`
+	Min    int `xml:"min,attr"`
+	Max    int `xml:"max,attr"`
+}
+
+// PlaybackRate bounds the catch-up speed players may use to hold the latency target
+type PlaybackRate struct {
+	Min float64 `xml:"min,attr"`
+	Max float64 `xml:"max,attr"`
+}
+
+// Period is a time span of the presentation
+type Period struct {
+	ID             string          `xml:"id,attr,omitempty"`
+	Start          string          `xml:"start,attr,omitempty"`
+	AdaptationSets []AdaptationSet `xml:"AdaptationSet"`
+}
+
+// AdaptationSet groups interchangeable representations of one media component
+type AdaptationSet struct {
+	ID                 int                 `xml:"id,attr"`
+	ContentType        string              `xml:"contentType,attr"`
+	MimeType           string              `xml:"mimeType,attr"`
+	Lang               string              `xml:"lang,attr,omitempty"`
+	SegmentAlignment   bool                `xml:"segmentAlignment,attr,omitempty"`
+	StartWithSAP       int                 `xml:"startWithSAP,attr,omitempty"`
+	MaxWidth           int                 `xml:"maxWidth,attr,omitempty"`
+	MaxHeight          int                 `xml:"maxHeight,attr,omitempty"`
+	ContentProtections []ContentProtection `xml:"ContentProtection"`
+	Essentials         []Descriptor        `xml:"EssentialProperty"`
+	Properties         []Descriptor        `xml:"SupplementalProperty"`
+	Accessibilities    []Descriptor        `xml:"Accessibility"`
+	Roles              []Descriptor        `xml:"Role"`
+	Labels             []string            `xml:"Label,omitempty"`
+	Representations    []Representation    `xml:"Representation"`
+
+	trackType string // key track type when video is split by key
+	kind      string // track kind of audio and text sets
+}
+
+// ContentProtection signals a DRM or encryption scheme
+type ContentProtection struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr,omitempty"`
+	DefaultKID  string `xml:"cenc:default_KID,attr,omitempty"`
+	PSSH        string `xml:"cenc:pssh,omitempty"`
+}
+
+// Descriptor is a generic scheme/value element such as Role
+type Descriptor struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr"`
+}
+
+// Representation is a single encoded version of a media component
+type Representation struct {
+	ID                        string           `xml:"id,attr"`
+	Bandwidth                 int              `xml:"bandwidth,attr"`
+	Codecs                    string           `xml:"codecs,attr,omitempty"`
+	Width                     int              `xml:"width,attr,omitempty"`
+	Height                    int              `xml:"height,attr,omitempty"`
+	FrameRate                 string           `xml:"frameRate,attr,omitempty"`
+	CodingDependency          string           `xml:"codingDependency,attr,omitempty"` // "false" for I-frame-only trick play
+	AudioChannelConfiguration *Descriptor      `xml:"AudioChannelConfiguration,omitempty"`
+	Essentials                []Descriptor     `xml:"EssentialProperty"`
+	BaseURL                   string           `xml:"BaseURL,omitempty"`
+	SegmentTemplate           *SegmentTemplate `xml:"SegmentTemplate,omitempty"`
+}
+
+// SegmentTemplate addresses segments by number, either with an explicit timeline
+// (on demand) or with a fixed duration (live)
+type SegmentTemplate struct {
+	Timescale                int              `xml:"timescale,attr"`
+	Duration                 int              `xml:"duration,attr,omitempty"`
+	Initialization           string           `xml:"initialization,attr,omitempty"`
+	Media                    string           `xml:"media,attr"`
+	StartNumber              int              `xml:"startNumber,attr"`
+	AvailabilityTimeOffset   float64          `xml:"availabilityTimeOffset,attr,omitempty"`
+	AvailabilityTimeComplete string           `xml:"availabilityTimeComplete,attr,omitempty"`
+	SegmentTimeline          *SegmentTimeline `xml:"SegmentTimeline"`
+}
+
+// SegmentTimeline lists segment start times and durations
+type SegmentTimeline struct {
+	Segments []S `xml:"S"`
+}
+
+// S is a SegmentTimeline entry; R repeats the same duration R more times
+type S struct {
+	T *int64 `xml:"t,attr"`
+	D int64  `xml:"d,attr"`
+	R int    `xml:"r,attr,omitempty"`
+}
+
+// DASHOptions controls MPD generation
+type DASHOptions struct {
+	BaseURL   string            // content location; rendition paths are relative to it
+	DRMConfig *models.DRMConfig // nil for clear content
+	// SegmentQuery returns the CDN token query appended to a rendition's segment
+	// URLs, or nil when segments are unsigned
+	SegmentQuery func(renditionName string) string
+	// ServiceLocations lists one content location per CDN, preferred first, for
+	// players to steer between through SteeringURI; BaseURL is used when empty
+	ServiceLocations []ServiceLocation
+	SteeringURI      string
+}
+
+// ServiceLocation is the content location on one CDN
+type ServiceLocation struct {
+	ID      string
+	BaseURL string
+}
+
+// GenerateDASHManifest generates a static DASH manifest (.mpd) from stored renditions
+func GenerateDASHManifest(renditions []models.Rendition, opts DASHOptions) (string, error) {
+	mpd := BuildMPD(renditions, opts)
+
+	out, err := xml.MarshalIndent(mpd, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal MPD: %w", err)
+	}
+
+	return xml.Header + string(out) + "\n", nil
+}
+
+// LiveDASHOptions controls low-latency live MPD generation
+type LiveDASHOptions struct {
+	BaseURL       string            // channel location; rendition paths are relative to it
+	DRMConfig     *models.DRMConfig // nil for clear content
+	PublishTime   time.Time
+	TargetLatency time.Duration
+}
+
+// GenerateLiveDASHManifest generates a dynamic low-latency DASH manifest for a live stream
+func GenerateLiveDASHManifest(stream *models.LiveStream, opts LiveDASHOptions) (string, error) {
+	mpd := BuildLiveMPD(stream, opts)
+
+	out, err := xml.MarshalIndent(mpd, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal MPD: %w", err)
+	}
+
+	return xml.Header + string(out) + "\n", nil
+}
+
+// BuildLiveMPD assembles a dynamic MPD for chunked CMAF delivery. Segments are
+// addressed by $Number$ from the availability start time, and availabilityTimeOffset
+// lets players request a segment as soon as its first chunk is published.
+func BuildLiveMPD(stream *models.LiveStream, opts LiveDASHOptions) *MPD {
+	mpd := BuildMPD(stream.Renditions, DASHOptions{BaseURL: opts.BaseURL, DRMConfig: opts.DRMConfig})
+	mpd.Type = "dynamic"
+	mpd.Profiles = "urn:mpeg:dash:profile:isoff-live:2011,http://dashif.org/guidelines/dash-if-low-latency"
+	mpd.MediaPresentationDuration = ""
+	mpd.AvailabilityStartTime = stream.AvailabilityStartTime.UTC().Format(time.RFC3339)
+	mpd.PublishTime = opts.PublishTime.UTC().Format(time.RFC3339)
+	mpd.MinimumUpdatePeriod = FormatISODuration(stream.TargetDuration)
+	mpd.TimeShiftBufferDepth = FormatISODuration(stream.TargetDuration * float64(stream.WindowSegments))
+	mpd.MaxSegmentDuration = FormatISODuration(stream.TargetDuration)
+	mpd.MinBufferTime = FormatISODuration(stream.PartTarget)
+
+	target := int(opts.TargetLatency.Milliseconds())
+	mpd.ServiceDescription = &ServiceDescription{
+		Latency:      &Latency{Target: target, Min: target * 2 / 3, Max: target * 4 / 3},
+		PlaybackRate: &PlaybackRate{Min: 0.96, Max: 1.04},
+	}
+	// The MPD is generated per request, so the publish time doubles as the clock reference
+	mpd.UTCTiming = &Descriptor{
+		SchemeIDURI: "urn:mpeg:dash:utc:direct:2014",
+		Value:       opts.PublishTime.UTC().Format("2006-01-02T15:04:05.000Z"),
+	}
+
+	templates := make(map[string]*SegmentTemplate, len(stream.Renditions))
+	for i := range stream.Renditions {
+		r := &stream.Renditions[i]
+		templates[r.Name] = liveSegmentTemplate(r, stream)
+	}
+
+	for p := range mpd.Periods {
+		sets := mpd.Periods[p].AdaptationSets
+		for a := range sets {
+			for i := range sets[a].Representations {
+				rep := &sets[a].Representations[i]
+				rep.SegmentTemplate = templates[rep.ID]
+				rep.BaseURL = ""
+			}
+		}
+	}
+
+	return mpd
+}
+
+// liveSegmentTemplate builds a $Number$ template with a fixed segment duration
+func liveSegmentTemplate(r *models.Rendition, stream *models.LiveStream) *SegmentTemplate {
+	template := segmentTemplate(r)
+	template.SegmentTimeline = nil
+	template.Duration = int(math.Round(stream.TargetDuration * float64(template.Timescale)))
+	if offset := stream.TargetDuration - stream.PartTarget; offset > 0 {
+		template.AvailabilityTimeOffset = math.Round(offset*1000) / 1000
+	}
+	template.AvailabilityTimeComplete = "false"
+	return template
+}
+
+// BuildMPD assembles the MPD document: one video AdaptationSet, and one audio and
+// one text AdaptationSet per language and track kind. When the DRM config has per-track keys,
+// video is split into one AdaptationSet per key, since an AdaptationSet has a
+// single default KID, and players are told they may switch between them.
+// I-frame renditions form trick mode AdaptationSets tied to their video set, and
+// thumbnail sprites an image AdaptationSet for scrubbing previews.
+func BuildMPD(renditions []models.Rendition, opts DASHOptions) *MPD {
+	mpd := &MPD{
+		XMLNS:         "urn:mpeg:dash:schema:mpd:2011",
+		Type:          "static",
+		Profiles:      "urn:mpeg:dash:profile:isoff-live:2011",
+		MinBufferTime: "PT2S",
+	}
+	if len(opts.ServiceLocations) > 0 {
+		for _, location := range opts.ServiceLocations {
+			mpd.BaseURLs = append(mpd.BaseURLs, BaseURL{ServiceLocation: location.ID, URL: location.BaseURL})
+		}
+		if opts.SteeringURI != "" {
+			mpd.ContentSteering = &ContentSteering{
+				DefaultServiceLocation: opts.ServiceLocations[0].ID,
+				QueryBeforeStart:       true,
+				URI:                    opts.SteeringURI,
+			}
+		}
+	} else if opts.BaseURL != "" {
+		mpd.BaseURLs = []BaseURL{{URL: opts.BaseURL}}
+	}
+
+	if len(contentProtections(opts.DRMConfig, "")) > 0 {
+		mpd.XMLNSCenc = "urn:mpeg:cenc:2013"
+	}
+	splitByKey := opts.DRMConfig != nil && len(opts.DRMConfig.TrackKeys) > 0
+
+	var duration float64
+	var video, trick, audio, text, images []AdaptationSet
+	var captions []Descriptor
+
+	for i := range renditions {
+		r := &renditions[i]
+		if d := r.TotalDuration(); r.Type != "subtitle" && d > duration {
+			duration = d
+		}
+
+		switch r.Type {
+		case "video":
+			var trackType string
+			if splitByKey {
+				trackType = r.TrackType()
+			}
+			video := videoSet(&video, trackType)
+			if r.Width > video.MaxWidth {
+				video.MaxWidth = r.Width
+			}
+			if r.Height > video.MaxHeight {
+				video.MaxHeight = r.Height
+			}
+			video.Representations = append(video.Representations, Representation{
+				ID:              r.Name,
+				Bandwidth:       r.Bandwidth,
+				Codecs:          r.Codecs,
+				Width:           r.Width,
+				Height:          r.Height,
+				FrameRate:       dashFrameRate(r.FrameRate),
+				SegmentTemplate: segmentTemplate(r),
+			})
+		case "iframe":
+			var trackType string
+			if splitByKey {
+				trackType = r.TrackType()
+			}
+			set := videoSet(&trick, trackType)
+			set.Representations = append(set.Representations, Representation{
+				ID:               r.Name,
+				Bandwidth:        r.Bandwidth,
+				Codecs:           r.Codecs,
+				Width:            r.Width,
+				Height:           r.Height,
+				FrameRate:        dashFrameRate(r.FrameRate),
+				CodingDependency: "false",
+				SegmentTemplate:  segmentTemplate(r),
+			})
+		case "thumbnail":
+			if len(images) == 0 {
+				images = append(images, AdaptationSet{ContentType: "image", MimeType: "image/jpeg"})
+			}
+			columns, rows := r.Tiles()
+			images[0].Representations = append(images[0].Representations, Representation{
+				ID:              r.Name,
+				Bandwidth:       r.Bandwidth,
+				Width:           r.Width * columns,
+				Height:          r.Height * rows,
+				Essentials:      []Descriptor{{SchemeIDURI: thumbnailTileScheme, Value: fmt.Sprintf("%dx%d", columns, rows)}},
+				SegmentTemplate: segmentTemplate(r),
+			})
+		case "audio":
+			kind := r.Kind
+			if kind == models.KindMain {
+				kind = ""
+			}
+			set := languageSet(&audio, "audio", "audio/mp4", r.Language, kind)
+			set.SegmentAlignment = true
+			set.StartWithSAP = 1
+			if r.Default {
+				addDescriptor(&set.Roles, roleScheme, "main")
+			}
+			switch kind {
+			case models.KindDub:
+				addDescriptor(&set.Roles, roleScheme, "dub")
+			case models.KindDescription:
+				addDescriptor(&set.Roles, roleScheme, "description")
+				addDescriptor(&set.Accessibilities, audioPurposeScheme, "1")
+			}
+			rep := Representation{
+				ID:              r.Name,
+				Bandwidth:       r.Bandwidth,
+				Codecs:          r.Codecs,
+				SegmentTemplate: segmentTemplate(r),
+			}
+			if r.Channels > 0 {
+				rep.AudioChannelConfiguration = &Descriptor{
+					SchemeIDURI: "urn:mpeg:dash:23003:3:audio_channel_configuration:2011",
+					Value:       fmt.Sprintf("%d", r.Channels),
+				}
+			}
+			set.Representations = append(set.Representations, rep)
+		case "subtitle":
+			kind := r.Kind
+			if kind == models.KindSubtitles {
+				kind = ""
+			}
+			set := languageSet(&text, "text", "text/vtt", r.Language, kind)
+			if r.Label != "" && len(set.Labels) == 0 {
+				set.Labels = append(set.Labels, r.Label)
+			}
+			switch kind {
+			case models.KindCaptions:
+				addDescriptor(&set.Roles, roleScheme, "caption")
+				addDescriptor(&set.Accessibilities, audioPurposeScheme, "2")
+			case models.KindForced:
+				addDescriptor(&set.Roles, roleScheme, "forced-subtitle")
+			default:
+				addDescriptor(&set.Roles, roleScheme, "subtitle")
+			}
+			if r.Default {
+				addDescriptor(&set.Roles, roleScheme, "main")
+			}
+			// The whole track as one file, as DASH players expect for WebVTT
+			rep := Representation{ID: r.Name, Bandwidth: r.Bandwidth}
+			if r.SidecarURI != "" {
+				rep.BaseURL = r.Name + "/" + r.SidecarURI
+			} else if len(r.Segments) > 0 {
+				rep.BaseURL = r.Name + "/" + r.Segments[0].URI
+			}
+			set.Representations = append(set.Representations, rep)
+		case "caption":
+			captions = append(captions, ceaDescriptor(r))
+		}
+	}
+	// Embedded captions are signalled on every video set, grouped per standard
+	captions = mergeCEADescriptors(captions)
+
+	period := Period{ID: "0", Start: "PT0S"}
+	for _, set := range video {
+		set.ContentProtections = contentProtections(opts.DRMConfig, set.trackType)
+		set.Accessibilities = captions
+		period.AdaptationSets = append(period.AdaptationSets, set)
+	}
+	// Trick mode sets follow the video sets, whose IDs are their indexes, and point
+	// at the set sharing their key, or the first one
+	if len(video) > 0 {
+		for _, set := range trick {
+			main := 0
+			for i := range video {
+				if video[i].trackType == set.trackType {
+					main = i
+				}
+			}
+			set.ContentProtections = contentProtections(opts.DRMConfig, set.trackType)
+			set.Essentials = []Descriptor{{SchemeIDURI: trickModeScheme, Value: fmt.Sprintf("%d", main)}}
+			period.AdaptationSets = append(period.AdaptationSets, set)
+		}
+	}
+	audioTrackType := ""
+	if splitByKey {
+		audioTrackType = models.TrackTypeAudio
+	}
+	for _, set := range audio {
+		set.ContentProtections = contentProtections(opts.DRMConfig, audioTrackType)
+		period.AdaptationSets = append(period.AdaptationSets, set)
+	}
+	period.AdaptationSets = append(period.AdaptationSets, text...)
+	period.AdaptationSets = append(period.AdaptationSets, images...)
+	for i := range period.AdaptationSets {
+		period.AdaptationSets[i].ID = i
+		if opts.SegmentQuery != nil {
+			signRepresentations(period.AdaptationSets[i].Representations, opts.SegmentQuery)
+		}
+	}
+	// Video sets come first, so their IDs are their indexes
+	if len(video) > 1 {
+		for i := range video {
+			var others []string
+			for j := range video {
+				if j != i {
+					others = append(others, fmt.Sprintf("%d", j))
+				}
+			}
+			period.AdaptationSets[i].Properties = append(period.AdaptationSets[i].Properties, Descriptor{
+				SchemeIDURI: "urn:mpeg:dash:adaptation-set-switching:2016",
+				Value:       strings.Join(others, ","),
+			})
+		}
+	}
+
+	mpd.MediaPresentationDuration = FormatISODuration(duration)
+	mpd.Periods = []Period{period}
+	return mpd
+}
+
+// signRepresentations appends each representation's token query to its segment URLs
+func signRepresentations(reps []Representation, segmentQuery func(string) string) {
+	for i := range reps {
+		query := segmentQuery(reps[i].ID)
+		if query == "" {
+			continue
+		}
+		if reps[i].BaseURL != "" {
+			reps[i].BaseURL = appendQuery(reps[i].BaseURL, query)
+		}
+		if template := reps[i].SegmentTemplate; template != nil {
+			template.Media = appendQuery(template.Media, query)
+			if template.Initialization != "" {
+				template.Initialization = appendQuery(template.Initialization, query)
+			}
+		}
+	}
+}
+
+// appendQuery adds an encoded query string to a URI
+func appendQuery(uri, query string) string {
+	if query == "" {
+		return uri
+	}
+	if strings.Contains(uri, "?") {
+		return uri + "&" + query
+	}
+	return uri + "?" + query
+}
+
+// videoSet returns the video AdaptationSet for a key track type, creating it if needed
+func videoSet(sets *[]AdaptationSet, trackType string) *AdaptationSet {
+	for i := range *sets {
+		if (*sets)[i].trackType == trackType {
+			return &(*sets)[i]
+		}
+	}
+	*sets = append(*sets, AdaptationSet{
+		ContentType:      "video",
+		MimeType:         "video/mp4",
+		SegmentAlignment: true,
+		StartWithSAP:     1,
+		trackType:        trackType,
+	})
+	return &(*sets)[len(*sets)-1]
+}
+
+// languageSet returns the AdaptationSet for a language and track kind, creating it if needed
+func languageSet(sets *[]AdaptationSet, contentType, mimeType, lang, kind string) *AdaptationSet {
+	for i := range *sets {
+		if (*sets)[i].Lang == lang && (*sets)[i].kind == kind {
+			return &(*sets)[i]
+		}
+	}
+	*sets = append(*sets, AdaptationSet{ContentType: contentType, MimeType: mimeType, Lang: lang, kind: kind})
+	return &(*sets)[len(*sets)-1]
+}
+
+// addDescriptor appends a descriptor unless it is already present
+func addDescriptor(descriptors *[]Descriptor, scheme, value string) {
+	for _, d := range *descriptors {
+		if d.SchemeIDURI == scheme && d.Value == value {
+			return
+		}
+	}
+	*descriptors = append(*descriptors, Descriptor{SchemeIDURI: scheme, Value: value})
+}
+
+// ceaDescriptor describes one caption service: "CC1=en" for CEA-608, "1=lang:en"
+// for CEA-708
+func ceaDescriptor(r *models.Rendition) Descriptor {
+	if r.Kind == models.KindCEA708 {
+		return Descriptor{SchemeIDURI: cea708Scheme, Value: strings.TrimPrefix(r.InstreamID, "SERVICE") + "=lang:" + r.Language}
+	}
+	return Descriptor{SchemeIDURI: cea608Scheme, Value: r.InstreamID + "=" + r.Language}
+}
+
+// mergeCEADescriptors joins the caption services of each standard into one
+// descriptor, e.g. "CC1=en;CC3=es"
+func mergeCEADescriptors(services []Descriptor) []Descriptor {
+	var merged []Descriptor
+	for _, service := range services {
+		found := false
+		for i := range merged {
+			if merged[i].SchemeIDURI == service.SchemeIDURI {
+				merged[i].Value += ";" + service.Value
+				found = true
+			}
+		}
+		if !found {
+			merged = append(merged, service)
+		}
+	}
+	return merged
+}
+
+// segmentTemplate builds a $Number$ template with a run-length encoded timeline
+func segmentTemplate(r *models.Rendition) *SegmentTemplate {
+	media := r.SegmentTemplate
+	if media == "" {
+		media = "segment_$Number$.m4s"
+		if r.Type == "thumbnail" {
+			media = "tile_$Number$.jpg"
+		}
+	}
+
+	template := &SegmentTemplate{
+		Timescale:       r.Timescale,
+		Media:           r.Name + "/" + media,
+		StartNumber:     r.StartNumber,
+		SegmentTimeline: &SegmentTimeline{},
+	}
+	if template.Timescale <= 0 {
+		template.Timescale = defaultTimescale
+	}
+	if r.InitSegment != "" {
+		template.Initialization = r.Name + "/" + r.InitSegment
+	}
+
+	// Accumulate in ticks from the running total so rounding never drifts
+	var elapsed float64
+	var prevEnd int64
+	for i, segment := range r.Segments {
+		elapsed += segment.Duration
+		end := int64(math.Round(elapsed * float64(template.Timescale)))
+		d := end - prevEnd
+		prevEnd = end
+
+		timeline := template.SegmentTimeline.Segments
+		if n := len(timeline); n > 0 && timeline[n-1].D == d {
+			timeline[n-1].R++
+			continue
+		}
+		entry := S{D: d}
+		if i == 0 {
+			start := int64(0)
+			entry.T = &start
+		}
+		template.SegmentTimeline.Segments = append(timeline, entry)
+	}
+
+	return template
+}
+
+// contentProtections returns the common encryption signalling plus Widevine and
+// PlayReady systems, using the key of trackType when the config has one
+func contentProtections(drm *models.DRMConfig, trackType string) []ContentProtection {
+	if drm == nil || drm.Type == "fairplay" {
+		return nil
+	}
+
+	var defaultKID string
+	if len(drm.KeyIDs) > 0 {
+		defaultKID = strings.ToLower(drm.KeyIDs[0])
+	}
+	pssh := drm.PSSH
+	if key := drm.KeyFor(trackType); key != nil {
+		defaultKID, pssh = strings.ToLower(key.KeyID), key.PSSH
+	}
+	scheme := drm.Scheme
+	if scheme == "" {
+		scheme = "cenc"
+	}
+
+	return []ContentProtection{
+		{SchemeIDURI: mp4ProtectionScheme, Value: scheme, DefaultKID: defaultKID},
+		{SchemeIDURI: widevineSystemID, Value: "Widevine", PSSH: pssh["widevine"]},
+		{SchemeIDURI: playReadySystemID, Value: "MSPR 2.0", PSSH: pssh["playready"]},
+	}
+}
+
+// dashFrameRate renders a frame rate, using the NTSC fractional form where it applies
+func dashFrameRate(fps float64) string {
+	if fps <= 0 {
+		return ""
+	}
+	if whole := math.Round(fps); math.Abs(fps-whole) < 0.001 {
+		return fmt.Sprintf("%d", int(whole))
+	}
+	return fmt.Sprintf("%d/1001", int(math.Round(fps*1001)))
+}
+
+// FormatISODuration formats seconds as an ISO 8601 duration, e.g. "PT1H2M3.500S"
+func FormatISODuration(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	hours := ms / 3600000
+	minutes := (ms % 3600000) / 60000
+	secs := float64(ms%60000) / 1000
+	return fmt.Sprintf("PT%dH%dM%.3fS", hours, minutes, secs)
+}
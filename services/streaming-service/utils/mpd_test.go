@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/streamverse/streaming-service/models"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func testRenditions() []models.Rendition {
+	videoSegments := []models.Segment{
+		{URI: "segment_1.m4s", Duration: 6},
+		{URI: "segment_2.m4s", Duration: 6},
+		{URI: "segment_3.m4s", Duration: 6},
+		{URI: "segment_4.m4s", Duration: 2.5},
+	}
+
+	return []models.Rendition{
+		{Name: "1080p", Type: "video", Bandwidth: 8000000, Width: 1920, Height: 1080, Codecs: "avc1.640028", FrameRate: 29.97,
+			InitSegment: "init.mp4", SegmentTemplate: "segment_$Number$.m4s", StartNumber: 1, Timescale: 90000, Segments: videoSegments},
+		{Name: "720p", Type: "video", Bandwidth: 5000000, Width: 1280, Height: 720, Codecs: "avc1.64001f", FrameRate: 29.97,
+			InitSegment: "init.mp4", SegmentTemplate: "segment_$Number$.m4s", StartNumber: 1, Timescale: 90000, Segments: videoSegments},
+		{Name: "480p", Type: "video", Bandwidth: 2500000, Width: 854, Height: 480, Codecs: "avc1.4d401e", FrameRate: 25,
+			InitSegment: "init.mp4", SegmentTemplate: "segment_$Number$.m4s", StartNumber: 1, Timescale: 90000, Segments: videoSegments},
+		{Name: "audio-en", Type: "audio", Bandwidth: 128000, Codecs: "mp4a.40.2", Language: "en", Label: "English", Channels: 2, Default: true,
+			InitSegment: "init.mp4", StartNumber: 1, Timescale: 48000, Segments: []models.Segment{
+				{URI: "segment_1.m4s", Duration: 6.016},
+				{URI: "segment_2.m4s", Duration: 5.995},
+				{URI: "segment_3.m4s", Duration: 6.016},
+				{URI: "segment_4.m4s", Duration: 2.473},
+			}},
+		{Name: "audio-es", Type: "audio", Bandwidth: 128000, Codecs: "mp4a.40.2", Language: "es", Label: "Español", Channels: 6,
+			InitSegment: "init.mp4", StartNumber: 1, Timescale: 48000, Segments: []models.Segment{
+				{URI: "segment_1.m4s", Duration: 6},
+				{URI: "segment_2.m4s", Duration: 6},
+				{URI: "segment_3.m4s", Duration: 6},
+				{URI: "segment_4.m4s", Duration: 2.5},
+			}},
+		{Name: "subs-en", Type: "subtitle", Language: "en", Label: "English", Default: true,
+			Segments: []models.Segment{{URI: "en.vtt", Duration: 20.5}}},
+	}
+}
+
+// testTrickPlayRenditions returns I-frame and thumbnail sprite renditions for testRenditions
+func testTrickPlayRenditions() []models.Rendition {
+	iframeSegments := []models.Segment{
+		{URI: "iframe_1.m4s", Duration: 6},
+		{URI: "iframe_2.m4s", Duration: 6},
+		{URI: "iframe_3.m4s", Duration: 6},
+		{URI: "iframe_4.m4s", Duration: 2.5},
+	}
+
+	return []models.Rendition{
+		{Name: "iframe-720p", Type: "iframe", Bandwidth: 400000, Width: 1280, Height: 720, Codecs: "avc1.64001f",
+			InitSegment: "init.mp4", SegmentTemplate: "iframe_$Number$.m4s", StartNumber: 1, Timescale: 90000, Segments: iframeSegments},
+		{Name: "iframe-480p", Type: "iframe", Bandwidth: 150000, Width: 854, Height: 480, Codecs: "avc1.4d401e",
+			InitSegment: "init.mp4", SegmentTemplate: "iframe_$Number$.m4s", StartNumber: 1, Timescale: 90000, Segments: iframeSegments},
+		{Name: "thumbs-320", Type: "thumbnail", Bandwidth: 20000, Width: 320, Height: 180, Codecs: "jpeg", TileLayout: "2x2", StartNumber: 1,
+			Segments: []models.Segment{{URI: "tile_1.jpg", Duration: 16}, {URI: "tile_2.jpg", Duration: 4.5}}},
+	}
+}
+
+func TestGenerateDASHManifestGolden(t *testing.T) {
+	tests := []struct {
+		name      string
+		golden    string
+		opts      DASHOptions
+		trickPlay bool
+	}{
+		{
+			name:   "clear content",
+			golden: "clear.mpd",
+			opts:   DASHOptions{BaseURL: "https://cdn.streamverse.com/videos/content-1/"},
+		},
+		{
+			name:   "widevine and playready protected",
+			golden: "protected.mpd",
+			opts: DASHOptions{
+				BaseURL: "https://cdn.streamverse.com/videos/content-1/",
+				DRMConfig: &models.DRMConfig{
+					Type:       "widevine",
+					LicenseURL: "https://drm.streamverse.com/license/widevine",
+					KeyIDs:     []string{"9EB4050D-E44B-4802-932E-27D75083E266"},
+					PSSH: map[string]string{
+						"widevine":  "AAAAW3Bzc2gAAAAA7e+LqXnWSs6jyCfc1R0h7QAAADsIARIQnrQFDeRLSAKTLifXUIPiZhoNd2lkZXZpbmVfdGVzdCIQZmtqM2xqYVNkZmFsa3IzaioCSEQyAA==",
+						"playready": "AAAAQHBzc2gAAAAAmgTweZhAQoarkuZb4IhflQAAACAQAAAAnrQFDeRLSAKTLifXUIPiZg==",
+					},
+				},
+			},
+		},
+		{
+			name:   "per-track keys",
+			golden: "track_keys.mpd",
+			opts: DASHOptions{
+				BaseURL: "https://cdn.streamverse.com/videos/content-1/",
+				DRMConfig: &models.DRMConfig{
+					Type:       "widevine",
+					LicenseURL: "https://drm.streamverse.com/license/widevine",
+					Scheme:     "cbcs",
+					TrackKeys: []models.TrackKey{
+						{TrackType: models.TrackTypeSD, KeyID: "1c5b8a8e-52a4-4ed6-9b0c-0b5f3d1c7a01", PSSH: map[string]string{"widevine": "c2Qtd2lkZXZpbmU=", "playready": "c2QtcGxheXJlYWR5"}},
+						{TrackType: models.TrackTypeHD, KeyID: "2d6c9b9f-63b5-4fe7-8c1d-1c6f4e2d8b02", PSSH: map[string]string{"widevine": "aGQtd2lkZXZpbmU=", "playready": "aGQtcGxheXJlYWR5"}},
+						{TrackType: models.TrackTypeFHD, KeyID: "4f8ebdb0-85d7-41a9-ae3f-3e8a6a4fad04", PSSH: map[string]string{"widevine": "ZmhkLXdpZGV2aW5l", "playready": "ZmhkLXBsYXlyZWFkeQ=="}},
+						{TrackType: models.TrackTypeAudio, KeyID: "3e7dacaf-74c6-40f8-9d2e-2d7f5f3e9c03", PSSH: map[string]string{"widevine": "YXVkaW8td2lkZXZpbmU=", "playready": "YXVkaW8tcGxheXJlYWR5"}},
+					},
+				},
+			},
+		},
+		{
+			name:      "trick play and thumbnails with per-track keys",
+			golden:    "trick_play.mpd",
+			trickPlay: true,
+			opts: DASHOptions{
+				BaseURL: "https://cdn.streamverse.com/videos/content-1/",
+				DRMConfig: &models.DRMConfig{
+					Type:       "widevine",
+					LicenseURL: "https://drm.streamverse.com/license/widevine",
+					Scheme:     "cbcs",
+					TrackKeys: []models.TrackKey{
+						{TrackType: models.TrackTypeSD, KeyID: "1c5b8a8e-52a4-4ed6-9b0c-0b5f3d1c7a01", PSSH: map[string]string{"widevine": "c2Qtd2lkZXZpbmU=", "playready": "c2QtcGxheXJlYWR5"}},
+						{TrackType: models.TrackTypeHD, KeyID: "2d6c9b9f-63b5-4fe7-8c1d-1c6f4e2d8b02", PSSH: map[string]string{"widevine": "aGQtd2lkZXZpbmU=", "playready": "aGQtcGxheXJlYWR5"}},
+						{TrackType: models.TrackTypeFHD, KeyID: "4f8ebdb0-85d7-41a9-ae3f-3e8a6a4fad04", PSSH: map[string]string{"widevine": "ZmhkLXdpZGV2aW5l", "playready": "ZmhkLXBsYXlyZWFkeQ=="}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renditions := testRenditions()
+			if tt.trickPlay {
+				renditions = append(renditions, testTrickPlayRenditions()...)
+			}
+			got, err := GenerateDASHManifest(renditions, tt.opts)
+			if err != nil {
+				t.Fatalf("expected manifest, got error %v", err)
+			}
+
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatalf("failed to update golden file: %v", err)
+				}
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read golden file: %v", err)
+			}
+			if got != string(want) {
+				t.Fatalf("manifest does not match %s (run with -update to regenerate)\n--- got ---\n%s", path, got)
+			}
+		})
+	}
+}
+
+func TestSegmentTemplateRunLengthEncodesTimeline(t *testing.T) {
+	rendition := &models.Rendition{
+		Name:      "720p",
+		Timescale: 1000,
+		Segments: []models.Segment{
+			{Duration: 4}, {Duration: 4}, {Duration: 4}, {Duration: 3.5}, {Duration: 4},
+		},
+	}
+
+	timeline := segmentTemplate(rendition).SegmentTimeline.Segments
+	if len(timeline) != 3 {
+		t.Fatalf("expected 3 timeline entries, got %d", len(timeline))
+	}
+	if timeline[0].T == nil || *timeline[0].T != 0 || timeline[0].D != 4000 || timeline[0].R != 2 {
+		t.Fatalf("unexpected first entry: %+v", timeline[0])
+	}
+	if timeline[1].T != nil || timeline[1].D != 3500 || timeline[1].R != 0 {
+		t.Fatalf("unexpected second entry: %+v", timeline[1])
+	}
+}
+
+func TestFormatISODuration(t *testing.T) {
+	if got := FormatISODuration(3723.5); got != "PT1H2M3.500S" {
+		t.Fatalf("expected PT1H2M3.500S, got %s", got)
+	}
+	if got := FormatISODuration(0); got != "PT0H0M0.000S" {
+		t.Fatalf("expected PT0H0M0.000S, got %s", got)
+	}
+}
+
+func TestBuildMPDTrackRolesAndCaptions(t *testing.T) {
+	renditions := []models.Rendition{
+		{Name: "720p", Type: "video", Width: 1280, Height: 720, Codecs: "avc1.64001f"},
+		{Name: "audio-en", Type: "audio", Language: "en", Kind: models.KindMain, Default: true},
+		{Name: "audio-en-ad", Type: "audio", Language: "en", Kind: models.KindDescription},
+		{Name: "subs-en", Type: "subtitle", Language: "en", SidecarURI: "subtitles.vtt", Segments: []models.Segment{{URI: "segment_1.vtt", Duration: 6}}},
+		{Name: "subs-en-captions", Type: "subtitle", Language: "en", Kind: models.KindCaptions, Default: true},
+		{Name: "cc1", Type: "caption", Language: "en", Kind: models.KindCEA608, InstreamID: "CC1"},
+		{Name: "cc3", Type: "caption", Language: "es", Kind: models.KindCEA608, InstreamID: "CC3"},
+		{Name: "service1", Type: "caption", Language: "en", Kind: models.KindCEA708, InstreamID: "SERVICE1"},
+	}
+
+	sets := BuildMPD(renditions, DASHOptions{}).Periods[0].AdaptationSets
+	if len(sets) != 5 {
+		t.Fatalf("expected video, two audio and two text sets, got %d", len(sets))
+	}
+	wantCaptions := []Descriptor{{SchemeIDURI: cea608Scheme, Value: "CC1=en;CC3=es"}, {SchemeIDURI: cea708Scheme, Value: "1=lang:en"}}
+	if fmt.Sprint(sets[0].Accessibilities) != fmt.Sprint(wantCaptions) {
+		t.Fatalf("expected CEA captions on the video set, got %+v", sets[0].Accessibilities)
+	}
+	if fmt.Sprint(sets[2].Roles) != fmt.Sprint([]Descriptor{{roleScheme, "description"}}) ||
+		fmt.Sprint(sets[2].Accessibilities) != fmt.Sprint([]Descriptor{{audioPurposeScheme, "1"}}) {
+		t.Fatalf("expected an audio description set, got %+v", sets[2])
+	}
+	if sets[3].Representations[0].BaseURL != "subs-en/subtitles.vtt" {
+		t.Fatalf("expected the sidecar file for DASH, got %q", sets[3].Representations[0].BaseURL)
+	}
+	if fmt.Sprint(sets[4].Roles) != fmt.Sprint([]Descriptor{{roleScheme, "caption"}, {roleScheme, "main"}}) ||
+		fmt.Sprint(sets[4].Accessibilities) != fmt.Sprint([]Descriptor{{audioPurposeScheme, "2"}}) {
+		t.Fatalf("expected a default SDH caption set, got %+v", sets[4])
+	}
+}
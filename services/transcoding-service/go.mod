@@ -8,6 +8,7 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/streamverse/common-go v0.0.0
 	go.mongodb.org/mongo-driver v1.13.1
+	golang.org/x/text v0.9.0
 )
 
 require (
@@ -47,7 +48,6 @@ require (
 	golang.org/x/net v0.10.0 // indirect
 	golang.org/x/sync v0.0.0-20220722155255-886fb9371eb4 // indirect
 	golang.org/x/sys v0.8.0 // indirect
-	golang.org/x/text v0.9.0 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
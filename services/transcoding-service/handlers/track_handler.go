@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/streamverse/common-go/errors"
+	"github.com/streamverse/common-go/logger"
+	"github.com/streamverse/transcoding-service/service"
+)
+
+// maxSubtitleFileSize bounds uploaded subtitle files
+const maxSubtitleFileSize = 10 << 20
+
+// TrackHandler handles HTTP requests for the track catalog
+type TrackHandler struct {
+	catalog *service.TrackCatalog
+	logger  *logger.Logger
+}
+
+// NewTrackHandler creates a new track handler
+func NewTrackHandler(catalog *service.TrackCatalog, logger *logger.Logger) *TrackHandler {
+	return &TrackHandler{
+		catalog: catalog,
+		logger:  logger,
+	}
+}
+
+// ListTracks handles GET /transcode/content/:content_id/tracks
+func (h *TrackHandler) ListTracks(c *gin.Context) {
+	tracks, err := h.catalog.ListTracks(c.Request.Context(), c.Param("content_id"))
+	if err != nil {
+		h.logger.Error("Failed to list tracks", logger.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to list tracks"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tracks": tracks})
+}
+
+// UploadSubtitles handles POST /transcode/content/:content_id/tracks/subtitles.
+// The multipart form carries the SRT, WebVTT or TTML file and its language,
+// label, kind, name and default fields.
+func (h *TrackHandler) UploadSubtitles(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError("file is required"))
+		return
+	}
+	if fileHeader.Size > maxSubtitleFileSize {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError("subtitle file is too large"))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError("Failed to read file"))
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxSubtitleFileSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError("Failed to read file"))
+		return
+	}
+
+	isDefault, _ := strconv.ParseBool(c.PostForm("default"))
+	upload := &service.SubtitleUpload{
+		Name:     c.PostForm("name"),
+		FileName: fileHeader.Filename,
+		Data:     data,
+		Language: c.PostForm("language"),
+		Label:    c.PostForm("label"),
+		Kind:     c.PostForm("kind"),
+		Default:  isDefault,
+	}
+
+	track, err := h.catalog.UploadSubtitles(c.Request.Context(), c.Param("content_id"), upload)
+	if err != nil {
+		h.handleError(c, "Failed to upload subtitles", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, track)
+}
+
+// DeclareClosedCaptions handles POST /transcode/content/:content_id/tracks/captions
+func (h *TrackHandler) DeclareClosedCaptions(c *gin.Context) {
+	var req struct {
+		Name       string `json:"name"`
+		Kind       string `json:"kind" binding:"required"`        // "cea-608" or "cea-708"
+		InstreamID string `json:"instream_id" binding:"required"` // "CC1"-"CC4" or "SERVICE1"-"SERVICE63"
+		Language   string `json:"language"`
+		Label      string `json:"label"`
+		Default    bool   `json:"default"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+		return
+	}
+
+	track, err := h.catalog.DeclareClosedCaptions(c.Request.Context(), c.Param("content_id"), &service.ClosedCaptionTrack{
+		Name:       req.Name,
+		Kind:       req.Kind,
+		InstreamID: req.InstreamID,
+		Language:   req.Language,
+		Label:      req.Label,
+		Default:    req.Default,
+	})
+	if err != nil {
+		h.handleError(c, "Failed to declare closed captions", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, track)
+}
+
+// UpdateTrack handles PATCH /transcode/content/:content_id/tracks/:name
+func (h *TrackHandler) UpdateTrack(c *gin.Context) {
+	var req struct {
+		Kind     *string `json:"kind"`
+		Language *string `json:"language"`
+		Label    *string `json:"label"`
+		Default  *bool   `json:"default"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+		return
+	}
+
+	track, err := h.catalog.UpdateTrack(c.Request.Context(), c.Param("content_id"), c.Param("name"), &service.TrackUpdate{
+		Kind:     req.Kind,
+		Language: req.Language,
+		Label:    req.Label,
+		Default:  req.Default,
+	})
+	if err != nil {
+		h.handleError(c, "Failed to update track", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, track)
+}
+
+// DeleteTrack handles DELETE /transcode/content/:content_id/tracks/:name
+func (h *TrackHandler) DeleteTrack(c *gin.Context) {
+	if err := h.catalog.DeleteTrack(c.Request.Context(), c.Param("content_id"), c.Param("name")); err != nil {
+		h.handleError(c, "Failed to delete track", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Track deleted"})
+}
+
+// handleError maps track catalog errors to responses
+func (h *TrackHandler) handleError(c *gin.Context, message string, err error) {
+	switch {
+	case stderrors.Is(err, service.ErrInvalidTrack):
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+	case stderrors.Is(err, service.ErrTrackNotFound):
+		c.JSON(http.StatusNotFound, errors.NewNotFoundError("Track not found"))
+	case stderrors.Is(err, service.ErrTrackNotUploaded):
+		c.JSON(http.StatusConflict, errors.NewConflictError("Only uploaded tracks can be deleted"))
+	default:
+		h.logger.Error(message, logger.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.NewInternalError(message))
+	}
+}
@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	stderrors "errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/streamverse/common-go/errors"
 	"github.com/streamverse/common-go/logger"
+	"github.com/streamverse/transcoding-service/models"
 	"github.com/streamverse/transcoding-service/service"
 )
 
@@ -31,6 +33,7 @@ func (h *TranscodingHandler) SubmitTranscodeJob(c *gin.Context) {
 		InputURL     string   `json:"input_url" binding:"required"`
 		QualityLevels []string `json:"quality_levels"`
 		Priority     int      `json:"priority"`
+		TrickPlay    *models.TrickPlayOptions `json:"trick_play"` // defaults to I-frames and 10s thumbnail sprites
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
@@ -43,8 +46,12 @@ func (h *TranscodingHandler) SubmitTranscodeJob(c *gin.Context) {
 	if req.Priority == 0 {
 		req.Priority = 5
 	}
+	trickPlay := models.DefaultTrickPlayOptions()
+	if req.TrickPlay != nil {
+		trickPlay = *req.TrickPlay
+	}
 
-	job, err := h.service.CreateJob(c.Request.Context(), req.ContentID, req.InputURL, req.QualityLevels, req.Priority)
+	job, err := h.service.CreateJob(c.Request.Context(), req.ContentID, req.InputURL, req.QualityLevels, req.Priority, trickPlay)
 	if err != nil {
 		h.logger.Error("Failed to create job", logger.Error(err))
 		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
@@ -68,6 +75,33 @@ func (h *TranscodingHandler) GetTranscodeJobStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, job)
 }
 
+// CompleteTranscodeJob handles POST /transcode/jobs/{job_id}/complete
+// Called by the packager once outputs are uploaded, with per-rendition segment metadata.
+func (h *TranscodingHandler) CompleteTranscodeJob(c *gin.Context) {
+	jobID := c.Param("job_id")
+
+	var req struct {
+		OutputURL  string             `json:"output_url" binding:"required"`
+		Renditions []models.Rendition `json:"renditions" binding:"required,dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+		return
+	}
+
+	if err := h.service.CompleteJob(c.Request.Context(), jobID, req.OutputURL, req.Renditions); err != nil {
+		if stderrors.Is(err, service.ErrInvalidRendition) {
+			c.JSON(http.StatusBadRequest, errors.NewInvalidInputError(err.Error()))
+			return
+		}
+		h.logger.Error("Failed to complete job", logger.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to complete job"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Job completed"})
+}
+
 // ListTranscodeJobs handles GET /transcode/jobs - Issue #15
 func (h *TranscodingHandler) ListTranscodeJobs(c *gin.Context) {
 	status := c.Query("status") // filter by status: queued, processing, done, failed
@@ -0,0 +1,266 @@
+// Package captions converts uploaded subtitle files to WebVTT and segments them
+// for HLS. SRT, WebVTT and TTML (including the IMSC1 text profile) are read.
+package captions
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Source formats
+const (
+	FormatSRT    = "srt"
+	FormatWebVTT = "vtt"
+	FormatTTML   = "ttml"
+)
+
+// Cue is one timed caption
+type Cue struct {
+	Start    time.Duration
+	End      time.Duration
+	Settings string // WebVTT cue settings, e.g. "line:0"
+	Text     string // WebVTT cue text, lines separated by "\n"
+}
+
+// Segment is one WebVTT file of a segmented track
+type Segment struct {
+	Number   int
+	Duration float64 // seconds
+	Data     []byte
+}
+
+// DetectFormat returns the format of a subtitle file from its name, falling back
+// to its content
+func DetectFormat(fileName string, data []byte) string {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".srt":
+		return FormatSRT
+	case ".vtt":
+		return FormatWebVTT
+	case ".ttml", ".dfxp", ".xml":
+		return FormatTTML
+	}
+
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, utf8BOM), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("WEBVTT")):
+		return FormatWebVTT
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return FormatTTML
+	default:
+		return FormatSRT
+	}
+}
+
+// Parse reads the cues of a subtitle file, sorted by start time
+func Parse(data []byte, format string) ([]Cue, error) {
+	var cues []Cue
+	var err error
+	switch format {
+	case FormatSRT:
+		cues, err = ParseSRT(data)
+	case FormatWebVTT:
+		cues, err = ParseWebVTT(data)
+	case FormatTTML:
+		cues, err = ParseTTML(data)
+	default:
+		return nil, fmt.Errorf("unsupported subtitle format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("no cues found")
+	}
+
+	sortCues(cues)
+	return cues, nil
+}
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// normalize strips a byte order mark and converts line endings to "\n"
+func normalize(data []byte) string {
+	s := string(bytes.TrimPrefix(data, utf8BOM))
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\r", "\n")
+}
+
+var (
+	srtTiming   = regexp.MustCompile(`^(\d+:\d{2}:\d{2}[,.]\d{1,3})\s*-->\s*(\d+:\d{2}:\d{2}[,.]\d{1,3})`)
+	srtFontTag  = regexp.MustCompile(`(?i)</?font[^>]*>`)
+	srtASSTag   = regexp.MustCompile(`\{\\[^}]*\}`)
+	srtTopAlign = regexp.MustCompile(`\{\\an[789]\}`)
+)
+
+// ParseSRT reads SubRip cues. Font tags, which WebVTT lacks, are dropped, and
+// top-aligned ({\an8}) cues are moved to the top of the picture.
+func ParseSRT(data []byte) ([]Cue, error) {
+	var cues []Cue
+	for _, block := range strings.Split(normalize(data), "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		// The cue index line is optional in practice
+		if len(lines) > 0 && !srtTiming.MatchString(lines[0]) {
+			lines = lines[1:]
+		}
+		if len(lines) == 0 || strings.TrimSpace(lines[0]) == "" {
+			continue
+		}
+
+		m := srtTiming.FindStringSubmatch(lines[0])
+		if m == nil {
+			return nil, fmt.Errorf("invalid SRT timing %q", lines[0])
+		}
+		start, err := parseClock(strings.Replace(m[1], ",", ".", 1))
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(strings.Replace(m[2], ",", ".", 1))
+		if err != nil {
+			return nil, err
+		}
+
+		text := strings.Join(lines[1:], "\n")
+		cue := Cue{Start: start, End: end}
+		if srtTopAlign.MatchString(text) {
+			cue.Settings = "line:0"
+		}
+		text = srtASSTag.ReplaceAllString(srtFontTag.ReplaceAllString(text, ""), "")
+		cue.Text = strings.TrimSpace(text)
+		if cue.Text != "" {
+			cues = append(cues, cue)
+		}
+	}
+	return cues, nil
+}
+
+var vttTiming = regexp.MustCompile(`^((?:\d+:)?\d{2}:\d{2}\.\d{3})\s+-->\s+((?:\d+:)?\d{2}:\d{2}\.\d{3})(.*)$`)
+
+// ParseWebVTT reads WebVTT cues, skipping NOTE, STYLE and REGION blocks
+func ParseWebVTT(data []byte) ([]Cue, error) {
+	blocks := strings.Split(normalize(data), "\n\n")
+	if !strings.HasPrefix(strings.TrimLeft(blocks[0], " \n"), "WEBVTT") {
+		return nil, fmt.Errorf("missing WEBVTT header")
+	}
+
+	var cues []Cue
+	for _, block := range blocks[1:] {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		// Skip an optional cue identifier
+		if len(lines) > 1 && !strings.Contains(lines[0], "-->") {
+			lines = lines[1:]
+		}
+		m := vttTiming.FindStringSubmatch(lines[0])
+		if m == nil {
+			continue
+		}
+
+		start, err := parseClock(m[1])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(m[2])
+		if err != nil {
+			return nil, err
+		}
+		if text := strings.TrimSpace(strings.Join(lines[1:], "\n")); text != "" {
+			cues = append(cues, Cue{Start: start, End: end, Settings: strings.TrimSpace(m[3]), Text: text})
+		}
+	}
+	return cues, nil
+}
+
+// parseClock parses "hh:mm:ss.fff" or "mm:ss.fff"
+func parseClock(s string) (time.Duration, error) {
+	var h, m int
+	var sec float64
+	parts := strings.Split(s, ":")
+	var err error
+	switch len(parts) {
+	case 3:
+		_, err = fmt.Sscanf(s, "%d:%d:%f", &h, &m, &sec)
+	case 2:
+		_, err = fmt.Sscanf(s, "%d:%f", &m, &sec)
+	default:
+		err = fmt.Errorf("too many fields")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q: %w", s, err)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + seconds(sec), nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Round(s*1000)) * time.Millisecond
+}
+
+// WriteWebVTT renders cues as a WebVTT file
+func WriteWebVTT(cues []Cue) []byte {
+	var b bytes.Buffer
+	b.WriteString("WEBVTT\n")
+	writeCues(&b, cues)
+	return b.Bytes()
+}
+
+func writeCues(b *bytes.Buffer, cues []Cue) {
+	for _, cue := range cues {
+		fmt.Fprintf(b, "\n%s --> %s", timestamp(cue.Start), timestamp(cue.End))
+		if cue.Settings != "" {
+			b.WriteString(" " + cue.Settings)
+		}
+		b.WriteString("\n" + cue.Text + "\n")
+	}
+}
+
+// timestamp formats a WebVTT timestamp, e.g. "00:01:02.500"
+func timestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// SegmentWebVTT splits cues into WebVTT segments of segmentDuration covering
+// total, the duration of the media, as HLS requires. Cues crossing a boundary are
+// repeated in every segment they overlap. Cue times stay on the media timeline,
+// which the CMAF packager starts at zero, so every segment maps LOCAL zero to
+// MPEG-TS zero.
+func SegmentWebVTT(cues []Cue, segmentDuration, total time.Duration) []Segment {
+	if segmentDuration <= 0 {
+		return nil
+	}
+	for _, cue := range cues {
+		total = max(total, cue.End)
+	}
+
+	var segments []Segment
+	for start := time.Duration(0); start < total; start += segmentDuration {
+		end := min(start+segmentDuration, total)
+
+		var b bytes.Buffer
+		b.WriteString("WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:0,LOCAL:00:00:00.000\n")
+		var overlapping []Cue
+		for _, cue := range cues {
+			if cue.Start < end && cue.End > start {
+				overlapping = append(overlapping, cue)
+			}
+		}
+		writeCues(&b, overlapping)
+
+		segments = append(segments, Segment{
+			Number:   len(segments) + 1,
+			Duration: (end - start).Seconds(),
+			Data:     b.Bytes(),
+		})
+	}
+	return segments
+}
+
+// sortCues orders cues by start time, keeping the source order of ties
+func sortCues(cues []Cue) {
+	sort.SliceStable(cues, func(i, j int) bool { return cues[i].Start < cues[j].Start })
+}
@@ -0,0 +1,110 @@
+package captions
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSRT(t *testing.T) {
+	srt := "\xef\xbb\xbf1\r\n00:00:01,000 --> 00:00:03,500\r\n<font color=\"#ffff00\">Hello</font>\r\n<i>there</i>\r\n\r\n" +
+		"2\r\n00:00:04,000 --> 00:00:05,000\r\n{\\an8}Top of the picture\r\n"
+
+	cues, err := Parse([]byte(srt), DetectFormat("movie.en.srt", nil))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(cues) != 2 {
+		t.Fatalf("expected 2 cues, got %+v", cues)
+	}
+	if cues[0].Start != time.Second || cues[0].End != 3500*time.Millisecond || cues[0].Text != "Hello\n<i>there</i>" {
+		t.Fatalf("unexpected first cue %+v", cues[0])
+	}
+	if cues[1].Settings != "line:0" || cues[1].Text != "Top of the picture" {
+		t.Fatalf("expected the top-aligned cue to move to the top, got %+v", cues[1])
+	}
+
+	want := "WEBVTT\n\n00:00:01.000 --> 00:00:03.500\nHello\n<i>there</i>\n\n00:00:04.000 --> 00:00:05.000 line:0\nTop of the picture\n"
+	if got := string(WriteWebVTT(cues)); got != want {
+		t.Fatalf("unexpected WebVTT:\n%s", got)
+	}
+}
+
+func TestParseWebVTTSkipsMetadataBlocks(t *testing.T) {
+	vtt := "WEBVTT - English\n\nNOTE translated by the studio\n\nSTYLE\n::cue { color: yellow }\n\n" +
+		"intro\n00:05.000 --> 00:07.250 align:start\nFirst line\n\n01:00:00.000 --> 01:00:02.000\nLast line\n"
+
+	cues, err := Parse([]byte(vtt), DetectFormat("upload", []byte(vtt)))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(cues) != 2 || cues[0].Start != 5*time.Second || cues[0].Settings != "align:start" || cues[1].Start != time.Hour {
+		t.Fatalf("unexpected cues %+v", cues)
+	}
+}
+
+func TestParseTTML(t *testing.T) {
+	ttml := `<?xml version="1.0" encoding="UTF-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" xmlns:tts="http://www.w3.org/ns/ttml#styling"
+    ttp:frameRate="25" ttp:tickRate="10000000" xml:lang="en">
+  <body>
+    <div begin="10s">
+      <p begin="00:00:01.000" end="00:00:03.000">Hello
+        <span tts:fontStyle="italic">world</span><br/>Fish &amp; chips</p>
+      <p begin="00:00:04:12" dur="20000000t">Frames and ticks</p>
+    </div>
+  </body>
+</tt>`
+
+	cues, err := Parse([]byte(ttml), DetectFormat("movie.ttml", nil))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(cues) != 2 {
+		t.Fatalf("expected 2 cues, got %+v", cues)
+	}
+	if cues[0].Start != 11*time.Second || cues[0].End != 13*time.Second || cues[0].Text != "Hello <i>world</i>\nFish &amp; chips" {
+		t.Fatalf("unexpected first cue %+v", cues[0])
+	}
+	if cues[1].Start != 14480*time.Millisecond || cues[1].End != 16480*time.Millisecond {
+		t.Fatalf("expected frame and tick times relative to the div, got %+v", cues[1])
+	}
+}
+
+func TestParseRejectsInvalidFiles(t *testing.T) {
+	if _, err := Parse([]byte("1\nnot a timing\nText\n"), FormatSRT); err == nil {
+		t.Fatalf("expected invalid SRT timing to fail")
+	}
+	if _, err := Parse([]byte(`<tt><body><p begin="1s">No end</p></body></tt>`), FormatTTML); err == nil {
+		t.Fatalf("expected a paragraph without an end to fail")
+	}
+	if _, err := Parse([]byte("WEBVTT\n"), FormatWebVTT); err == nil {
+		t.Fatalf("expected a file without cues to fail")
+	}
+}
+
+func TestSegmentWebVTT(t *testing.T) {
+	cues := []Cue{
+		{Start: time.Second, End: 2 * time.Second, Text: "first"},
+		{Start: 5 * time.Second, End: 7 * time.Second, Text: "across"},
+	}
+
+	segments := SegmentWebVTT(cues, 6*time.Second, 14*time.Second)
+	if len(segments) != 3 || segments[2].Duration != 2 {
+		t.Fatalf("expected segments covering the media, got %+v", segments)
+	}
+	for i, want := range [][]string{{"first", "across"}, {"across"}, nil} {
+		data := string(segments[i].Data)
+		if !strings.HasPrefix(data, "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:0,LOCAL:00:00:00.000\n") {
+			t.Fatalf("segment %d has no timestamp map:\n%s", i+1, data)
+		}
+		if got := strings.Count(data, "-->"); got != len(want) {
+			t.Fatalf("segment %d: expected %d cues, got:\n%s", i+1, len(want), data)
+		}
+		for _, text := range want {
+			if !strings.Contains(data, text) {
+				t.Fatalf("segment %d: expected cue %q, got:\n%s", i+1, text, data)
+			}
+		}
+	}
+}
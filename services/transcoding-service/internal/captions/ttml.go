@@ -0,0 +1,258 @@
+package captions
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ttmlTiming holds the document parameters time expressions depend on
+type ttmlTiming struct {
+	frameRate float64
+	tickRate  float64
+}
+
+// ttmlElement is an open timed element: its begin on the document timeline and
+// its end, when it has one
+type ttmlElement struct {
+	name   string
+	begin  time.Duration
+	end    time.Duration
+	hasEnd bool
+	// styles the element opened in the cue text, closed with it
+	closeTags string
+}
+
+// ParseTTML reads the paragraphs of a TTML document, such as an IMSC1 text
+// profile file, as cues. Timing is inherited through body, div and span as the
+// TTML time containment rules describe; italic and bold spans are kept as WebVTT
+// <i> and <b> tags, and other styling and layout is dropped.
+func ParseTTML(data []byte) ([]Cue, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	timing := ttmlTiming{frameRate: 30, tickRate: 1}
+
+	var stack []ttmlElement
+	var cues []Cue
+	var text strings.Builder
+	inParagraph := false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid TTML: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "tt" {
+				timing = ttmlTimingOf(t)
+			}
+			if t.Name.Local == "br" {
+				if inParagraph {
+					text.WriteString("\n")
+				}
+				stack = append(stack, ttmlElement{name: "br"})
+				continue
+			}
+
+			element := ttmlElement{name: t.Name.Local}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				element.begin, element.end, element.hasEnd = parent.begin, parent.end, parent.hasEnd
+			}
+			if err := applyTTMLTiming(&element, t, timing); err != nil {
+				return nil, err
+			}
+			switch t.Name.Local {
+			case "p":
+				inParagraph = true
+				text.Reset()
+			case "span":
+				if inParagraph {
+					element.closeTags = openTTMLStyles(&text, t)
+				}
+			}
+			stack = append(stack, element)
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+			element := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			switch element.name {
+			case "span":
+				text.WriteString(element.closeTags)
+			case "p":
+				inParagraph = false
+				if !element.hasEnd {
+					return nil, fmt.Errorf("TTML paragraph at %s has no end time", timestamp(element.begin))
+				}
+				if cueText := cleanTTMLText(text.String()); cueText != "" {
+					cues = append(cues, Cue{Start: element.begin, End: element.end, Text: cueText})
+				}
+			}
+
+		case xml.CharData:
+			// Source line breaks are whitespace; only <br/> breaks a cue line
+			if inParagraph {
+				text.WriteString(strings.NewReplacer("\r", " ", "\n", " ").Replace(string(t)))
+			}
+		}
+	}
+	return cues, nil
+}
+
+// ttmlTimingOf reads ttp:frameRate, ttp:frameRateMultiplier and ttp:tickRate
+func ttmlTimingOf(tt xml.StartElement) ttmlTiming {
+	timing := ttmlTiming{frameRate: 30, tickRate: 1}
+	multiplier := 1.0
+	for _, attr := range tt.Attr {
+		switch attr.Name.Local {
+		case "frameRate":
+			if v, err := strconv.ParseFloat(attr.Value, 64); err == nil && v > 0 {
+				timing.frameRate = v
+			}
+		case "frameRateMultiplier":
+			var num, den float64
+			if _, err := fmt.Sscanf(attr.Value, "%g %g", &num, &den); err == nil && num > 0 && den > 0 {
+				multiplier = num / den
+			}
+		case "tickRate":
+			if v, err := strconv.ParseFloat(attr.Value, 64); err == nil && v > 0 {
+				timing.tickRate = v
+			}
+		}
+	}
+	timing.frameRate *= multiplier
+	return timing
+}
+
+// applyTTMLTiming resolves begin, end and dur against the parent's begin
+func applyTTMLTiming(element *ttmlElement, start xml.StartElement, timing ttmlTiming) error {
+	parentBegin := element.begin
+	var dur time.Duration
+	hasDur := false
+
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "begin", "end", "dur":
+			offset, err := parseTTMLTime(attr.Value, timing)
+			if err != nil {
+				return err
+			}
+			switch attr.Name.Local {
+			case "begin":
+				element.begin = parentBegin + offset
+			case "end":
+				element.end, element.hasEnd = parentBegin+offset, true
+			case "dur":
+				dur, hasDur = offset, true
+			}
+		}
+	}
+	if hasDur && (!element.hasEnd || element.begin+dur < element.end) {
+		element.end, element.hasEnd = element.begin+dur, true
+	}
+	return nil
+}
+
+var (
+	ttmlClockTime  = regexp.MustCompile(`^(\d+):(\d{2}):(\d{2})(?:\.(\d+)|:(\d+)(?:\.(\d+))?)?$`)
+	ttmlOffsetTime = regexp.MustCompile(`^(\d+(?:\.\d+)?)(h|m|s|ms|f|t)$`)
+)
+
+// parseTTMLTime parses a clock time ("00:01:02.500", "00:01:02:12") or an offset
+// time ("62.5s", "1500ms", "30f", "900000t")
+func parseTTMLTime(value string, timing ttmlTiming) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if m := ttmlClockTime.FindStringSubmatch(value); m != nil {
+		h, _ := strconv.Atoi(m[1])
+		mins, _ := strconv.Atoi(m[2])
+		sec, _ := strconv.ParseFloat(m[3], 64)
+		switch {
+		case m[4] != "":
+			fraction, _ := strconv.ParseFloat("0."+m[4], 64)
+			sec += fraction
+		case m[5] != "":
+			frames, _ := strconv.ParseFloat(m[5], 64)
+			if m[6] != "" {
+				subFrames, _ := strconv.ParseFloat("0."+m[6], 64)
+				frames += subFrames
+			}
+			sec += frames / timing.frameRate
+		}
+		return time.Duration(h)*time.Hour + time.Duration(mins)*time.Minute + seconds(sec), nil
+	}
+
+	if m := ttmlOffsetTime.FindStringSubmatch(value); m != nil {
+		v, _ := strconv.ParseFloat(m[1], 64)
+		switch m[2] {
+		case "h":
+			v *= 3600
+		case "m":
+			v *= 60
+		case "ms":
+			v /= 1000
+		case "f":
+			v /= timing.frameRate
+		case "t":
+			v /= timing.tickRate
+		}
+		return seconds(v), nil
+	}
+
+	return 0, fmt.Errorf("invalid TTML time expression %q", value)
+}
+
+// openTTMLStyles writes the WebVTT tags for a span's italic and bold styles and
+// returns the tags closing them
+func openTTMLStyles(text *strings.Builder, span xml.StartElement) string {
+	var closeTags string
+	for _, attr := range span.Attr {
+		switch {
+		case attr.Name.Local == "fontStyle" && attr.Value == "italic":
+			text.WriteString("<i>")
+			closeTags = "</i>" + closeTags
+		case attr.Name.Local == "fontWeight" && attr.Value == "bold":
+			text.WriteString("<b>")
+			closeTags = "</b>" + closeTags
+		}
+	}
+	return closeTags
+}
+
+var ttmlWhitespace = regexp.MustCompile(`[ \t]+`)
+
+// cleanTTMLText collapses source whitespace, as TTML's default xml:space does,
+// while keeping the line breaks from <br/>
+func cleanTTMLText(text string) string {
+	lines := strings.Split(text, "\n")
+	var kept []string
+	for _, line := range lines {
+		line = strings.TrimSpace(ttmlWhitespace.ReplaceAllString(line, " "))
+		if line != "" {
+			kept = append(kept, escapeCueText(line))
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// escapeCueText escapes "&" and "<" outside the <i> and <b> tags added for styles
+func escapeCueText(line string) string {
+	line = strings.ReplaceAll(line, "&", "&amp;")
+	line = strings.ReplaceAll(line, "<", "&lt;")
+	for _, tag := range []string{"i", "b"} {
+		line = strings.ReplaceAll(line, "&lt;"+tag+">", "<"+tag+">")
+		line = strings.ReplaceAll(line, "&lt;/"+tag+">", "</"+tag+">")
+	}
+	return line
+}
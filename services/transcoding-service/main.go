@@ -52,13 +52,21 @@ func main() {
 	}
 	s3Client := s3.New(sess)
 
+	// Packaged media bucket, served by the CDN origin
+	mediaBucket := os.Getenv("MEDIA_BUCKET")
+	if mediaBucket == "" {
+		mediaBucket = "streamverse-media"
+	}
+
 	// Initialize repository
-	transcodingRepo := repository.NewTranscodingRepository(db, s3Client)
+	transcodingRepo := repository.NewTranscodingRepository(db, s3Client, mediaBucket)
 
 	// Initialize service
 	transcodingService := service.NewTranscodingService(transcodingRepo)
+	trackCatalog := service.NewTrackCatalog(transcodingRepo)
 
 	// Initialize handlers
+	trackHandler := transcodingHandler.NewTrackHandler(trackCatalog, log)
 	transcodingHandler := transcodingHandler.NewTranscodingHandler(transcodingService, log)
 
 	// Setup router
@@ -77,6 +85,7 @@ func main() {
 	{
 		api.POST("/jobs", transcodingHandler.SubmitTranscodeJob)        // POST /transcode/jobs
 		api.GET("/jobs/:job_id", transcodingHandler.GetTranscodeJobStatus) // GET /transcode/jobs/{job_id}
+		api.POST("/jobs/:job_id/complete", transcodingHandler.CompleteTranscodeJob) // POST /transcode/jobs/{job_id}/complete
 		api.GET("/jobs", transcodingHandler.ListTranscodeJobs)          // GET /transcode/jobs (with filters)
 		api.GET("/profiles", transcodingHandler.ListProfiles)           // GET /transcode/profiles
 		api.POST("/profiles", transcodingHandler.CreateProfile)         // POST /transcode/profiles
@@ -85,6 +94,13 @@ func main() {
 		api.POST("/uploads", transcodingHandler.InitiateUpload)
 		api.POST("/uploads/:upload_id/parts", transcodingHandler.UploadPart)
 		api.POST("/uploads/:upload_id/complete", transcodingHandler.CompleteUpload)
+
+		// Track catalog: subtitles, closed captions and audio track metadata
+		api.GET("/content/:content_id/tracks", trackHandler.ListTracks)
+		api.POST("/content/:content_id/tracks/subtitles", trackHandler.UploadSubtitles)
+		api.POST("/content/:content_id/tracks/captions", trackHandler.DeclareClosedCaptions)
+		api.PATCH("/content/:content_id/tracks/:name", trackHandler.UpdateTrack)
+		api.DELETE("/content/:content_id/tracks/:name", trackHandler.DeleteTrack)
 	}
 
 	// Start server
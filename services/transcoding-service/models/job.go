@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -16,12 +19,66 @@ type TranscodingJob struct {
 	Progress    float64            `bson:"progress" json:"progress"` // 0-100
 	Priority    int                `bson:"priority" json:"priority"` // 1-10
 	QualityLevels []string          `bson:"quality_levels" json:"qualityLevels"` // ["1080p", "720p", "480p"]
+	TrickPlay   TrickPlayOptions   `bson:"trick_play" json:"trickPlay"`
 	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
 	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
 	UpdatedAt   time.Time          `bson:"updated_at" json:"updatedAt"`
 	CompletedAt *time.Time         `bson:"completed_at,omitempty" json:"completedAt,omitempty"`
 }
 
+// TrickPlayOptions tells the packager which scrubbing outputs to produce next to
+// the bitrate ladder. They are reported back as "iframe" and "thumbnail" renditions.
+type TrickPlayOptions struct {
+	IFrames           bool    `bson:"iframes" json:"iframes"`                      // I-frame-only rendition per video rendition
+	Thumbnails        bool    `bson:"thumbnails" json:"thumbnails"`                // JPEG sprite sheets
+	ThumbnailInterval float64 `bson:"thumbnail_interval" json:"thumbnailInterval"` // seconds between thumbnails
+	ThumbnailWidth    int     `bson:"thumbnail_width" json:"thumbnailWidth"`       // tile size in pixels
+	ThumbnailHeight   int     `bson:"thumbnail_height" json:"thumbnailHeight"`
+	TileLayout        string  `bson:"tile_layout" json:"tileLayout"` // tiles per sprite, "<columns>x<rows>"
+}
+
+// DefaultTrickPlayOptions returns the trick-play outputs produced when a job does not specify any
+func DefaultTrickPlayOptions() TrickPlayOptions {
+	return TrickPlayOptions{
+		IFrames:           true,
+		Thumbnails:        true,
+		ThumbnailInterval: 10,
+		ThumbnailWidth:    320,
+		ThumbnailHeight:   180,
+		TileLayout:        "5x5",
+	}
+}
+
+// Validate checks that the requested thumbnail sprites can be packaged
+func (o TrickPlayOptions) Validate() error {
+	if !o.Thumbnails {
+		return nil
+	}
+	if o.ThumbnailInterval <= 0 {
+		return fmt.Errorf("thumbnail interval must be positive")
+	}
+	if o.ThumbnailWidth <= 0 || o.ThumbnailHeight <= 0 {
+		return fmt.Errorf("thumbnail size must be positive")
+	}
+	_, _, err := ParseTileLayout(o.TileLayout)
+	return err
+}
+
+// ParseTileLayout parses a "<columns>x<rows>" sprite layout
+func ParseTileLayout(layout string) (columns, rows int, err error) {
+	c, r, ok := strings.Cut(layout, "x")
+	if ok {
+		columns, err = strconv.Atoi(c)
+		if err == nil {
+			rows, err = strconv.Atoi(r)
+		}
+	}
+	if !ok || err != nil || columns <= 0 || rows <= 0 {
+		return 0, 0, fmt.Errorf("invalid tile layout %q", layout)
+	}
+	return columns, rows, nil
+}
+
 // ThumbnailJob represents a thumbnail generation job
 type ThumbnailJob struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
@@ -33,3 +90,107 @@ type ThumbnailJob struct {
 	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
 	UpdatedAt   time.Time          `bson:"updated_at" json:"updatedAt"`
 }
+
+// Rendition is one packaged output of a transcoding job. Renditions are stored in
+// the shared "renditions" collection, which streaming-service reads to build
+// HLS/DASH manifests.
+type Rendition struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ContentID        string             `bson:"content_id" json:"contentId"`
+	JobID            string             `bson:"job_id" json:"jobId"`
+	Name             string             `bson:"name" json:"name" binding:"required"`  // unique per content: "1080p", "audio-en", "subs-en"
+	Type             string             `bson:"type" json:"type" binding:"required"`  // "video", "audio", "subtitle", "caption", "iframe", "thumbnail"
+	Kind             string             `bson:"kind,omitempty" json:"kind,omitempty"` // audio, subtitle and caption kinds below
+	Bandwidth        int                `bson:"bandwidth" json:"bandwidth"`           // peak bps
+	AverageBandwidth int                `bson:"average_bandwidth" json:"averageBandwidth"`
+	Width            int                `bson:"width,omitempty" json:"width,omitempty"`            // tile width for thumbnails
+	Height           int                `bson:"height,omitempty" json:"height,omitempty"`          // tile height for thumbnails
+	TileLayout       string             `bson:"tile_layout,omitempty" json:"tileLayout,omitempty"` // thumbnails only: tiles per sprite, e.g. "5x5"
+	Codecs           string             `bson:"codecs" json:"codecs"`                              // RFC 6381, e.g. "avc1.640028"
+	FrameRate        float64            `bson:"frame_rate,omitempty" json:"frameRate,omitempty"`
+	Language         string             `bson:"language,omitempty" json:"language,omitempty"`
+	Label            string             `bson:"label,omitempty" json:"label,omitempty"`
+	Channels         int                `bson:"channels,omitempty" json:"channels,omitempty"`
+	Default          bool               `bson:"default" json:"default"`
+	InstreamID       string             `bson:"instream_id,omitempty" json:"instreamId,omitempty"` // captions: "CC1"-"CC4" (608) or "SERVICE1"-"SERVICE63" (708)
+	SidecarURI       string             `bson:"sidecar_uri,omitempty" json:"sidecarUri,omitempty"` // subtitles: the whole track as one WebVTT file
+	Source           string             `bson:"source,omitempty" json:"source,omitempty"`          // "upload" for tracks added through the track catalog
+	InitSegment      string             `bson:"init_segment,omitempty" json:"initSegment,omitempty"`
+	SegmentTemplate  string             `bson:"segment_template,omitempty" json:"segmentTemplate,omitempty"` // DASH media template, e.g. "segment_$Number$.m4s"
+	StartNumber      int                `bson:"start_number" json:"startNumber"`                             // $Number$ of the first segment
+	Timescale        int                `bson:"timescale,omitempty" json:"timescale,omitempty"`              // ticks per second, defaults to 1000
+	Segments         []Segment          `bson:"segments" json:"segments"`
+	CreatedAt        time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+// Validate checks the fields manifests need for trick-play renditions
+func (r *Rendition) Validate() error {
+	switch r.Type {
+	case "iframe":
+		if r.Height == 0 {
+			return fmt.Errorf("rendition %s: iframe renditions need a resolution", r.Name)
+		}
+	case "thumbnail":
+		if r.Width == 0 || r.Height == 0 {
+			return fmt.Errorf("rendition %s: thumbnail renditions need a tile size", r.Name)
+		}
+		if _, _, err := ParseTileLayout(r.TileLayout); err != nil {
+			return fmt.Errorf("rendition %s: %w", r.Name, err)
+		}
+	case "audio":
+		if r.Kind != "" && r.Kind != KindMain && r.Kind != KindDub && r.Kind != KindDescription {
+			return fmt.Errorf("rendition %s: invalid audio kind %q", r.Name, r.Kind)
+		}
+	case "subtitle":
+		if r.Kind != "" && r.Kind != KindSubtitles && r.Kind != KindCaptions && r.Kind != KindForced {
+			return fmt.Errorf("rendition %s: invalid subtitle kind %q", r.Name, r.Kind)
+		}
+	case "caption":
+		if !validInstreamID(r.Kind, r.InstreamID) {
+			return fmt.Errorf("rendition %s: invalid %s channel %q", r.Name, r.Kind, r.InstreamID)
+		}
+	}
+	return nil
+}
+
+// validInstreamID checks a closed caption channel: CC1-CC4 for CEA-608 and
+// SERVICE1-SERVICE63 for CEA-708
+func validInstreamID(kind, id string) bool {
+	switch kind {
+	case KindCEA608:
+		n, err := strconv.Atoi(strings.TrimPrefix(id, "CC"))
+		return strings.HasPrefix(id, "CC") && err == nil && n >= 1 && n <= 4
+	case KindCEA708:
+		n, err := strconv.Atoi(strings.TrimPrefix(id, "SERVICE"))
+		return strings.HasPrefix(id, "SERVICE") && err == nil && n >= 1 && n <= 63
+	default:
+		return false
+	}
+}
+
+// Track kinds. Audio is the original mix, a dub or an audio description; text
+// tracks are subtitles, captions for the deaf and hard of hearing (SDH) or forced
+// narratives translating on-screen text; captions carried in the video are
+// CEA-608 or CEA-708.
+const (
+	KindMain        = "main"
+	KindDub         = "dub"
+	KindDescription = "description"
+
+	KindSubtitles = "subtitles"
+	KindCaptions  = "captions"
+	KindForced    = "forced"
+
+	KindCEA608 = "cea-608"
+	KindCEA708 = "cea-708"
+)
+
+// RenditionSourceUpload marks tracks uploaded through the track catalog, which
+// transcoding job outputs do not replace
+const RenditionSourceUpload = "upload"
+
+// Segment is a single media segment of a rendition
+type Segment struct {
+	URI      string  `bson:"uri" json:"uri"`           // relative to the rendition output path
+	Duration float64 `bson:"duration" json:"duration"` // seconds; for thumbnails, the time covered by the sprite
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"mime/multipart"
@@ -22,16 +23,21 @@ type TranscodingRepository struct {
 	jobCollection       *mongo.Collection
 	thumbnailCollection *mongo.Collection
 	uploadCollection    *mongo.Collection
+	renditionCollection *mongo.Collection
 	s3Client            *s3.S3
+	mediaBucket         string
 }
 
-// NewTranscodingRepository creates a new transcoding repository
-func NewTranscodingRepository(db *database.MongoDB, s3Client *s3.S3) *TranscodingRepository {
+// NewTranscodingRepository creates a new transcoding repository. Packaged media is
+// stored in mediaBucket under <content_id>/<rendition>/, the CDN origin layout.
+func NewTranscodingRepository(db *database.MongoDB, s3Client *s3.S3, mediaBucket string) *TranscodingRepository {
 	return &TranscodingRepository{
 		jobCollection:       db.Collection("transcoding_jobs"),
 		thumbnailCollection: db.Collection("thumbnail_jobs"),
 		uploadCollection:    db.Collection("multipart_uploads"),
+		renditionCollection: db.Collection("renditions"),
 		s3Client:            s3Client,
+		mediaBucket:         mediaBucket,
 	}
 }
 
@@ -122,6 +128,121 @@ func (r *TranscodingRepository) FailJob(ctx context.Context, jobID, errorMsg str
 	return err
 }
 
+// ReplaceRenditions replaces the stored renditions of a content item with a new set.
+// Tracks uploaded through the track catalog are kept.
+func (r *TranscodingRepository) ReplaceRenditions(ctx context.Context, contentID string, renditions []models.Rendition) error {
+	filter := bson.M{"content_id": contentID, "source": bson.M{"$ne": models.RenditionSourceUpload}}
+	if _, err := r.renditionCollection.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+	if len(renditions) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(renditions))
+	for i := range renditions {
+		docs[i] = renditions[i]
+	}
+
+	_, err := r.renditionCollection.InsertMany(ctx, docs)
+	return err
+}
+
+// ListTracks returns the audio, subtitle and caption renditions of a content item
+func (r *TranscodingRepository) ListTracks(ctx context.Context, contentID string) ([]models.Rendition, error) {
+	filter := bson.M{"content_id": contentID, "type": bson.M{"$in": []string{"audio", "subtitle", "caption"}}}
+	opts := options.Find().SetSort(bson.D{{Key: "type", Value: 1}, {Key: "name", Value: 1}})
+
+	cursor, err := r.renditionCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tracks := []models.Rendition{}
+	if err := cursor.All(ctx, &tracks); err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+// ListRenditions returns every rendition of a content item
+func (r *TranscodingRepository) ListRenditions(ctx context.Context, contentID string) ([]models.Rendition, error) {
+	cursor, err := r.renditionCollection.Find(ctx, bson.M{"content_id": contentID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var renditions []models.Rendition
+	if err := cursor.All(ctx, &renditions); err != nil {
+		return nil, err
+	}
+	return renditions, nil
+}
+
+// GetRendition returns a rendition by name, or nil when there is none
+func (r *TranscodingRepository) GetRendition(ctx context.Context, contentID, name string) (*models.Rendition, error) {
+	var rendition models.Rendition
+	err := r.renditionCollection.FindOne(ctx, bson.M{"content_id": contentID, "name": name}).Decode(&rendition)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rendition, nil
+}
+
+// SaveRendition inserts a rendition or replaces the one with the same name
+func (r *TranscodingRepository) SaveRendition(ctx context.Context, rendition *models.Rendition) error {
+	filter := bson.M{"content_id": rendition.ContentID, "name": rendition.Name}
+	if existing, err := r.GetRendition(ctx, rendition.ContentID, rendition.Name); err != nil {
+		return err
+	} else if existing != nil {
+		rendition.ID = existing.ID
+	}
+
+	_, err := r.renditionCollection.ReplaceOne(ctx, filter, rendition, options.Replace().SetUpsert(true))
+	return err
+}
+
+// UpdateTrack sets fields of a track, reporting whether it exists
+func (r *TranscodingRepository) UpdateTrack(ctx context.Context, contentID, name string, fields bson.M) (bool, error) {
+	result, err := r.renditionCollection.UpdateOne(ctx, bson.M{"content_id": contentID, "name": name}, bson.M{"$set": fields})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// ClearDefault unsets the default flag of the other tracks of a type
+func (r *TranscodingRepository) ClearDefault(ctx context.Context, contentID, trackType, keepName string) error {
+	filter := bson.M{"content_id": contentID, "type": trackType, "name": bson.M{"$ne": keepName}}
+	_, err := r.renditionCollection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"default": false}})
+	return err
+}
+
+// DeleteRendition deletes a rendition, reporting whether it existed
+func (r *TranscodingRepository) DeleteRendition(ctx context.Context, contentID, name string) (bool, error) {
+	result, err := r.renditionCollection.DeleteOne(ctx, bson.M{"content_id": contentID, "name": name})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// PutMedia stores a packaged file under <content_id>/<rendition>/<file> in the media bucket
+func (r *TranscodingRepository) PutMedia(ctx context.Context, contentID, rendition, file, contentType string, data []byte) error {
+	_, err := r.s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(r.mediaBucket),
+		Key:         aws.String(contentID + "/" + rendition + "/" + file),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
 // CreateThumbnailJob creates a thumbnail job
 func (r *TranscodingRepository) CreateThumbnailJob(ctx context.Context, job *models.ThumbnailJob) (*models.ThumbnailJob, error) {
 	_, err := r.thumbnailCollection.InsertOne(ctx, job)
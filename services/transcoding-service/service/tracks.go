@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/streamverse/transcoding-service/internal/captions"
+	"github.com/streamverse/transcoding-service/models"
+	"github.com/streamverse/transcoding-service/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/text/language"
+)
+
+// Errors returned by the track catalog
+var (
+	ErrTrackNotFound    = errors.New("track not found")
+	ErrInvalidTrack     = errors.New("invalid track")
+	ErrTrackNotUploaded = errors.New("track was produced by a transcoding job")
+)
+
+// trackNamePattern restricts track names, which become storage paths
+var trackNamePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// defaultSubtitleSegmentDuration is used when the content has no video segments yet
+const defaultSubtitleSegmentDuration = 6 * time.Second
+
+// SubtitleUpload is an SRT, WebVTT or TTML file added to the track catalog
+type SubtitleUpload struct {
+	Name     string // defaults to "subs-<language>", suffixed by the kind unless plain subtitles
+	FileName string
+	Data     []byte
+	Language string
+	Label    string
+	Kind     string // models.KindSubtitles (default), KindCaptions or KindForced
+	Default  bool
+}
+
+// ClosedCaptionTrack declares CEA-608/708 captions carried in the video stream
+type ClosedCaptionTrack struct {
+	Name       string // defaults to the lower-cased channel, e.g. "cc1"
+	Kind       string // models.KindCEA608 or KindCEA708
+	InstreamID string
+	Language   string
+	Label      string
+	Default    bool
+}
+
+// TrackUpdate changes the catalog metadata of a track; nil fields are kept
+type TrackUpdate struct {
+	Kind     *string
+	Language *string
+	Label    *string
+	Default  *bool
+}
+
+// TrackCatalog manages the audio, subtitle and caption tracks of content items
+type TrackCatalog struct {
+	repo *repository.TranscodingRepository
+}
+
+// NewTrackCatalog creates a new track catalog
+func NewTrackCatalog(repo *repository.TranscodingRepository) *TrackCatalog {
+	return &TrackCatalog{
+		repo: repo,
+	}
+}
+
+// ListTracks returns the audio, subtitle and caption tracks of a content item
+func (c *TrackCatalog) ListTracks(ctx context.Context, contentID string) ([]models.Rendition, error) {
+	return c.repo.ListTracks(ctx, contentID)
+}
+
+// UploadSubtitles converts a subtitle file to WebVTT, stores it segmented for HLS
+// alongside a single sidecar file for DASH, and adds it to the catalog. Segments
+// follow the video segment duration so they line up with the media playlists.
+func (c *TrackCatalog) UploadSubtitles(ctx context.Context, contentID string, upload *SubtitleUpload) (*models.Rendition, error) {
+	if upload.Language == "" {
+		return nil, fmt.Errorf("%w: language is required", ErrInvalidTrack)
+	}
+	if !validLanguage(upload.Language) {
+		return nil, fmt.Errorf("%w: language %q is not a BCP-47 tag", ErrInvalidTrack, upload.Language)
+	}
+	kind := upload.Kind
+	if kind == "" {
+		kind = models.KindSubtitles
+	}
+	name := upload.Name
+	if name == "" {
+		name = subtitleTrackName(upload.Language, kind)
+	}
+	if !trackNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: name %q may only contain lower-case letters, digits and hyphens", ErrInvalidTrack, name)
+	}
+
+	cues, err := captions.Parse(upload.Data, captions.DetectFormat(upload.FileName, upload.Data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTrack, err)
+	}
+
+	rendition := &models.Rendition{
+		ID:          primitive.NewObjectID(),
+		ContentID:   contentID,
+		Name:        name,
+		Type:        "subtitle",
+		Kind:        kind,
+		Language:    upload.Language,
+		Label:       upload.Label,
+		Default:     upload.Default && kind != models.KindForced,
+		SidecarURI:  "subtitles.vtt",
+		Source:      models.RenditionSourceUpload,
+		StartNumber: 1,
+		CreatedAt:   time.Now(),
+	}
+	if err := rendition.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTrack, err)
+	}
+
+	segmentDuration, total, err := c.segmentTiming(ctx, contentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range captions.SegmentWebVTT(cues, segmentDuration, total) {
+		uri := fmt.Sprintf("segment_%d.vtt", segment.Number)
+		if err := c.repo.PutMedia(ctx, contentID, name, uri, "text/vtt", segment.Data); err != nil {
+			return nil, err
+		}
+		rendition.Segments = append(rendition.Segments, models.Segment{URI: uri, Duration: segment.Duration})
+	}
+	if err := c.repo.PutMedia(ctx, contentID, name, rendition.SidecarURI, "text/vtt", captions.WriteWebVTT(cues)); err != nil {
+		return nil, err
+	}
+
+	if err := c.save(ctx, rendition); err != nil {
+		return nil, err
+	}
+	return rendition, nil
+}
+
+// DeclareClosedCaptions adds CEA-608/708 captions embedded in the video to the
+// catalog, so manifests can signal them
+func (c *TrackCatalog) DeclareClosedCaptions(ctx context.Context, contentID string, track *ClosedCaptionTrack) (*models.Rendition, error) {
+	instreamID := strings.ToUpper(track.InstreamID)
+	name := track.Name
+	if name == "" {
+		name = strings.ToLower(instreamID)
+	}
+
+	rendition := &models.Rendition{
+		ID:         primitive.NewObjectID(),
+		ContentID:  contentID,
+		Name:       name,
+		Type:       "caption",
+		Kind:       track.Kind,
+		InstreamID: instreamID,
+		Language:   track.Language,
+		Label:      track.Label,
+		Default:    track.Default,
+		Source:     models.RenditionSourceUpload,
+		CreatedAt:  time.Now(),
+	}
+	if err := rendition.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTrack, err)
+	}
+
+	if err := c.save(ctx, rendition); err != nil {
+		return nil, err
+	}
+	return rendition, nil
+}
+
+// UpdateTrack changes the kind, language, label or default flag of a track.
+// Making a track the default clears the flag on the other tracks of its type.
+func (c *TrackCatalog) UpdateTrack(ctx context.Context, contentID, name string, update *TrackUpdate) (*models.Rendition, error) {
+	rendition, err := c.getTrack(ctx, contentID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := bson.M{}
+	if update.Kind != nil {
+		rendition.Kind = *update.Kind
+		fields["kind"] = rendition.Kind
+	}
+	if update.Language != nil {
+		if *update.Language != "" && !validLanguage(*update.Language) {
+			return nil, fmt.Errorf("%w: language %q is not a BCP-47 tag", ErrInvalidTrack, *update.Language)
+		}
+		rendition.Language = *update.Language
+		fields["language"] = rendition.Language
+	}
+	if update.Label != nil {
+		rendition.Label = *update.Label
+		fields["label"] = rendition.Label
+	}
+	if update.Default != nil {
+		rendition.Default = *update.Default
+		fields["default"] = rendition.Default
+	}
+	if rendition.Type == "subtitle" && rendition.Kind == models.KindForced && rendition.Default {
+		return nil, fmt.Errorf("%w: forced subtitles cannot be the default track", ErrInvalidTrack)
+	}
+	if err := rendition.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTrack, err)
+	}
+	if len(fields) == 0 {
+		return rendition, nil
+	}
+
+	found, err := c.repo.UpdateTrack(ctx, contentID, name, fields)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrTrackNotFound
+	}
+	if rendition.Default {
+		if err := c.repo.ClearDefault(ctx, contentID, rendition.Type, name); err != nil {
+			return nil, err
+		}
+	}
+	return rendition, nil
+}
+
+// DeleteTrack removes an uploaded track from the catalog. Tracks produced by a
+// transcoding job are replaced by the next job instead.
+func (c *TrackCatalog) DeleteTrack(ctx context.Context, contentID, name string) error {
+	rendition, err := c.getTrack(ctx, contentID, name)
+	if err != nil {
+		return err
+	}
+	if rendition.Source != models.RenditionSourceUpload {
+		return ErrTrackNotUploaded
+	}
+
+	found, err := c.repo.DeleteRendition(ctx, contentID, name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrTrackNotFound
+	}
+	return nil
+}
+
+// getTrack returns an audio, subtitle or caption rendition
+func (c *TrackCatalog) getTrack(ctx context.Context, contentID, name string) (*models.Rendition, error) {
+	rendition, err := c.repo.GetRendition(ctx, contentID, name)
+	if err != nil {
+		return nil, err
+	}
+	if rendition == nil {
+		return nil, ErrTrackNotFound
+	}
+	switch rendition.Type {
+	case "audio", "subtitle", "caption":
+		return rendition, nil
+	default:
+		return nil, ErrTrackNotFound
+	}
+}
+
+// save stores a track, replacing one with the same name, and keeps a single
+// default per track type
+func (c *TrackCatalog) save(ctx context.Context, rendition *models.Rendition) error {
+	existing, err := c.repo.GetRendition(ctx, rendition.ContentID, rendition.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Source != models.RenditionSourceUpload {
+		return fmt.Errorf("%w: rendition %s was produced by a transcoding job", ErrInvalidTrack, rendition.Name)
+	}
+
+	if err := c.repo.SaveRendition(ctx, rendition); err != nil {
+		return err
+	}
+	if rendition.Default {
+		return c.repo.ClearDefault(ctx, rendition.ContentID, rendition.Type, rendition.Name)
+	}
+	return nil
+}
+
+// segmentTiming returns the segment duration of the content's video renditions
+// and the duration of the media
+func (c *TrackCatalog) segmentTiming(ctx context.Context, contentID string) (time.Duration, time.Duration, error) {
+	renditions, err := c.repo.ListRenditions(ctx, contentID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	segmentDuration := defaultSubtitleSegmentDuration
+	var total time.Duration
+	for _, r := range renditions {
+		if r.Type != "video" || len(r.Segments) == 0 {
+			continue
+		}
+		var duration float64
+		for _, segment := range r.Segments {
+			duration += segment.Duration
+		}
+		if d := time.Duration(math.Round(duration*1000)) * time.Millisecond; d > total {
+			total = d
+			if first := time.Duration(math.Round(r.Segments[0].Duration*1000)) * time.Millisecond; first > 0 {
+				segmentDuration = first
+			}
+		}
+	}
+	return segmentDuration, total, nil
+}
+
+// validLanguage reports whether tag is a well-formed BCP-47 language tag, e.g.
+// "en" or "pt-BR"
+func validLanguage(tag string) bool {
+	parsed, err := language.Raw.Parse(tag)
+	return err == nil && strings.EqualFold(parsed.String(), tag)
+}
+
+// subtitleTrackName returns the default name of an uploaded subtitle track, e.g.
+// "subs-en", "subs-en-captions" or "subs-fr-forced"
+func subtitleTrackName(language, kind string) string {
+	name := "subs-" + strings.ToLower(language)
+	if kind != models.KindSubtitles {
+		name += "-" + kind
+	}
+	return name
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"mime/multipart"
 	"time"
 
@@ -23,7 +25,11 @@ func NewTranscodingService(repo *repository.TranscodingRepository) *TranscodingS
 }
 
 // CreateJob creates a new transcoding job
-func (s *TranscodingService) CreateJob(ctx context.Context, contentID, inputURL string, qualityLevels []string, priority int) (*models.TranscodingJob, error) {
+func (s *TranscodingService) CreateJob(ctx context.Context, contentID, inputURL string, qualityLevels []string, priority int, trickPlay models.TrickPlayOptions) (*models.TranscodingJob, error) {
+	if err := trickPlay.Validate(); err != nil {
+		return nil, err
+	}
+
 	job := &models.TranscodingJob{
 		ID:            primitive.NewObjectID(),
 		ContentID:     contentID,
@@ -32,6 +38,7 @@ func (s *TranscodingService) CreateJob(ctx context.Context, contentID, inputURL
 		Progress:      0,
 		Priority:      priority,
 		QualityLevels: qualityLevels,
+		TrickPlay:     trickPlay,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
@@ -49,9 +56,34 @@ func (s *TranscodingService) UpdateJobProgress(ctx context.Context, jobID string
 	return s.repo.UpdateProgress(ctx, jobID, progress)
 }
 
-// CompleteJob marks job as completed
-func (s *TranscodingService) CompleteJob(ctx context.Context, jobID, outputURL string) error {
+// ErrInvalidRendition is returned when the packager reports a rendition manifests cannot use
+var ErrInvalidRendition = errors.New("invalid rendition")
+
+// CompleteJob marks job as completed and publishes its renditions for manifest generation
+func (s *TranscodingService) CompleteJob(ctx context.Context, jobID, outputURL string, renditions []models.Rendition) error {
+	for i := range renditions {
+		if err := renditions[i].Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRendition, err)
+		}
+	}
+
+	job, err := s.repo.GetJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+
 	now := time.Now()
+	for i := range renditions {
+		renditions[i].ID = primitive.NewObjectID()
+		renditions[i].ContentID = job.ContentID
+		renditions[i].JobID = jobID
+		renditions[i].CreatedAt = now
+	}
+
+	if err := s.repo.ReplaceRenditions(ctx, job.ContentID, renditions); err != nil {
+		return fmt.Errorf("failed to store renditions: %w", err)
+	}
+
 	return s.repo.UpdateStatus(ctx, jobID, "completed", &now, outputURL)
 }
 
@@ -90,6 +90,11 @@ func (h *UserHandler) GetPreferences(c *gin.Context) {
 	userID := c.Param("id")
 	currentUserID, _ := c.Get("user_id")
 
+	// Routed as /users/me/preferences, which has no id
+	if userID == "" {
+		userID, _ = currentUserID.(string)
+	}
+
 	if userID != currentUserID.(string) {
 		c.JSON(http.StatusForbidden, errors.NewUnauthorizedError("Access denied"))
 		return
@@ -110,6 +115,11 @@ func (h *UserHandler) UpdatePreferences(c *gin.Context) {
 	userID := c.Param("id")
 	currentUserID, _ := c.Get("user_id")
 
+	// Routed as /users/me/preferences, which has no id
+	if userID == "" {
+		userID, _ = currentUserID.(string)
+	}
+
 	if userID != currentUserID.(string) {
 		c.JSON(http.StatusForbidden, errors.NewUnauthorizedError("Access denied"))
 		return
@@ -231,7 +241,7 @@ func (h *UserHandler) GetContinueWatching(c *gin.Context) {
 	}
 
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	history, err := h.service.GetContinueWatching(c.Request.Context(), userID.(string), limit)
+	history, err := h.service.GetContinueWatching(c.Request.Context(), userID.(string), c.Query("profile_id"), limit)
 	if err != nil {
 		h.logger.Error("Failed to get continue watching", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, errors.NewInternalError("Failed to get continue watching"))
@@ -57,6 +57,12 @@ func main() {
 	// Initialize service
 	userService := service.NewUserService(userRepo, redisClient)
 
+	// Keep watch history in sync with streaming sessions
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	defer stopSync()
+	watchHistorySync := service.NewWatchHistorySync(userService, log, service.WatchHistorySyncConfigFromEnv())
+	go watchHistorySync.Start(syncCtx)
+
 	// Initialize handlers
 	userHandler := userHandler.NewUserHandler(userService, log)
 
@@ -121,5 +127,7 @@ func main() {
 		log.Fatal("Server forced to shutdown", zap.Error(err))
 	}
 
+	stopSync()
+
 	log.Info("Server exited")
 }
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Playback event types published by the streaming service
+const (
+	PlaybackEventPosition = "position"
+	PlaybackEventEnded    = "ended"
+)
+
+// PlaybackEvent is a playback progress update from the streaming service
+type PlaybackEvent struct {
+	Type      string    `json:"type"`
+	SessionID string    `json:"sessionId"`
+	UserID    string    `json:"userId"`
+	ProfileID string    `json:"profileId,omitempty"`
+	ContentID string    `json:"contentId"`
+	Position  int64     `json:"position"` // milliseconds
+	Duration  int64     `json:"duration"` // milliseconds
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -25,6 +25,9 @@ type UserPreferences struct {
 	UserID            string             `bson:"user_id" json:"userId"`
 	Language          string             `bson:"language" json:"language"`
 	SubtitleLanguage  string             `bson:"subtitle_language,omitempty" json:"subtitleLanguage,omitempty"`
+	AudioLanguage     string             `bson:"audio_language,omitempty" json:"audioLanguage,omitempty"`
+	ClosedCaptions    bool               `bson:"closed_captions" json:"closedCaptions"`       // prefer captions for the deaf and hard of hearing
+	AudioDescription  bool               `bson:"audio_description" json:"audioDescription"`   // prefer audio description tracks
 	ContentRating     string             `bson:"content_rating" json:"contentRating"` // G, PG, PG-13, R
 	Notifications     NotificationPrefs  `bson:"notifications" json:"notifications"`
 	Playback          PlaybackPrefs      `bson:"playback" json:"playback"`
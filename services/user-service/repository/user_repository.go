@@ -214,6 +214,41 @@ func (r *UserRepository) UpdateWatchHistory(ctx context.Context, history *models
 	return err
 }
 
+// UpsertWatchProgress records the latest position of a viewer in a content item.
+// Entries are kept per profile; events without a profile only match entries
+// without one. Progress older than the stored entry's watched_at is ignored, so
+// updates arriving out of order never move the position back.
+func (r *UserRepository) UpsertWatchProgress(ctx context.Context, history *models.WatchHistory) error {
+	filter := bson.M{
+		"user_id":    history.UserID,
+		"content_id": history.ContentID,
+	}
+	if history.ProfileID != "" {
+		filter["profile_id"] = history.ProfileID
+	} else {
+		filter["profile_id"] = bson.M{"$in": bson.A{"", nil}}
+	}
+
+	// An update pipeline compares against the stored entry within the one write
+	now := time.Now()
+	newer := bson.M{"$lt": bson.A{bson.M{"$ifNull": bson.A{"$watched_at", time.Time{}}}, history.WatchedAt}}
+	progress := func(field string, value interface{}) bson.M {
+		return bson.M{"$cond": bson.A{newer, bson.M{"$literal": value}, "$" + field}}
+	}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"position":   progress("position", history.Position),
+		"duration":   progress("duration", history.Duration),
+		"watched_at": progress("watched_at", history.WatchedAt),
+		"completed":  progress("completed", history.Completed),
+		"updated_at": progress("updated_at", now),
+		"created_at": bson.M{"$ifNull": bson.A{"$created_at", now}},
+	}}}}
+
+	opts := options.Update().SetUpsert(true)
+	_, err := r.historyCollection.UpdateOne(ctx, filter, update, opts)
+	return err
+}
+
 // GetWatchHistory retrieves watch history with pagination (Issue #12: max 1000 entries per request)
 func (r *UserRepository) GetWatchHistory(ctx context.Context, userID string, page, pageSize int) ([]models.WatchHistory, error) {
 	if pageSize > 1000 {
@@ -248,12 +283,15 @@ func (r *UserRepository) GetWatchHistory(ctx context.Context, userID string, pag
 	return history, nil
 }
 
-// GetContinueWatching retrieves continue watching items
-func (r *UserRepository) GetContinueWatching(ctx context.Context, userID string, limit int) ([]models.WatchHistory, error) {
+// GetContinueWatching retrieves continue watching items, optionally for one profile
+func (r *UserRepository) GetContinueWatching(ctx context.Context, userID, profileID string, limit int) ([]models.WatchHistory, error) {
 	filter := bson.M{
 		"user_id":  userID,
 		"completed": false,
 	}
+	if profileID != "" {
+		filter["profile_id"] = profileID
+	}
 	opts := options.Find().SetLimit(int64(limit)).SetSort(bson.D{{Key: "updated_at", Value: -1}})
 	cursor, err := r.historyCollection.Find(ctx, filter, opts)
 	if err != nil {
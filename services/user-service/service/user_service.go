@@ -12,15 +12,24 @@ import (
 
 // UserService handles user profile business logic
 type UserService struct {
-	repo  *repository.UserRepository
-	cache *cache.RedisClient
+	repo     *repository.UserRepository
+	progress watchProgressStore
+	cache    *cache.RedisClient
+}
+
+// watchProgressStore records playback progress from the streaming service, in
+// MongoDB in production. Entries are kept per user, profile and content item,
+// and progress older than an entry's watched_at is ignored.
+type watchProgressStore interface {
+	UpsertWatchProgress(ctx context.Context, history *models.WatchHistory) error
 }
 
 // NewUserService creates a new user service
 func NewUserService(repo *repository.UserRepository, cache *cache.RedisClient) *UserService {
 	return &UserService{
-		repo:  repo,
-		cache: cache,
+		repo:     repo,
+		progress: repo,
+		cache:    cache,
 	}
 }
 
@@ -100,12 +109,37 @@ func (s *UserService) GetWatchHistory(ctx context.Context, userID string, page,
 	return s.repo.GetWatchHistory(ctx, userID, page, pageSize)
 }
 
-// GetContinueWatching retrieves continue watching items
-func (s *UserService) GetContinueWatching(ctx context.Context, userID string, limit int) ([]models.WatchHistory, error) {
+// GetContinueWatching retrieves continue watching items, optionally for one profile
+func (s *UserService) GetContinueWatching(ctx context.Context, userID, profileID string, limit int) ([]models.WatchHistory, error) {
 	if limit <= 0 {
 		limit = 10
 	}
-	return s.repo.GetContinueWatching(ctx, userID, limit)
+	return s.repo.GetContinueWatching(ctx, userID, profileID, limit)
+}
+
+// ApplyPlaybackEvent updates watch history from a streaming service playback event.
+// Content counts as completed once the position passes completedThreshold of its
+// duration, so credits do not keep it in continue watching.
+func (s *UserService) ApplyPlaybackEvent(ctx context.Context, event *models.PlaybackEvent, completedThreshold float64) error {
+	if event.UserID == "" || event.ContentID == "" || event.Position <= 0 {
+		// Nothing was watched yet
+		return nil
+	}
+
+	watchedAt := event.Timestamp
+	if watchedAt.IsZero() {
+		watchedAt = time.Now()
+	}
+	history := &models.WatchHistory{
+		UserID:    event.UserID,
+		ProfileID: event.ProfileID,
+		ContentID: event.ContentID,
+		Position:  event.Position,
+		Duration:  event.Duration,
+		WatchedAt: watchedAt,
+		Completed: event.Duration > 0 && float64(event.Position) >= completedThreshold*float64(event.Duration),
+	}
+	return s.progress.UpsertWatchProgress(ctx, history)
 }
 
 // ClearWatchHistory clears watch history
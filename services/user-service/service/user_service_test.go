@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/streamverse/user-service/models"
+)
+
+// memoryProgressStore keeps watch progress in memory with the repository's
+// semantics: one entry per user, profile and content item, and progress older
+// than the entry ignored
+type memoryProgressStore struct {
+	entries map[string]models.WatchHistory
+}
+
+func (s *memoryProgressStore) UpsertWatchProgress(ctx context.Context, history *models.WatchHistory) error {
+	key := history.UserID + "/" + history.ProfileID + "/" + history.ContentID
+	if stored, ok := s.entries[key]; ok && !stored.WatchedAt.Before(history.WatchedAt) {
+		return nil
+	}
+	s.entries[key] = *history
+	return nil
+}
+
+func TestApplyPlaybackEvent(t *testing.T) {
+	start := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	event := func(profileID string, position int64, at time.Duration) models.PlaybackEvent {
+		return models.PlaybackEvent{Type: models.PlaybackEventPosition, SessionID: "s1", UserID: "user-1", ProfileID: profileID,
+			ContentID: "c1", Position: position, Duration: 100000, Timestamp: start.Add(at)}
+	}
+	type entry struct {
+		position  int64
+		completed bool
+	}
+	tests := []struct {
+		name   string
+		events []models.PlaybackEvent
+		want   map[string]entry // by profile
+	}{
+		{"below the threshold", []models.PlaybackEvent{event("", 94000, 0)}, map[string]entry{"": {94000, false}}},
+		{"at the threshold", []models.PlaybackEvent{event("", 95000, 0)}, map[string]entry{"": {95000, true}}},
+		{"no duration", []models.PlaybackEvent{{UserID: "user-1", ContentID: "c1", Position: 5000, Timestamp: start}}, map[string]entry{"": {5000, false}}},
+		{"nothing watched", []models.PlaybackEvent{event("", 0, 0)}, map[string]entry{}},
+		{"profiles kept apart", []models.PlaybackEvent{event("kids", 30000, 0), event("", 60000, time.Minute), event("kids", 40000, 2*time.Minute)},
+			map[string]entry{"kids": {40000, false}, "": {60000, false}}},
+		{"out of order", []models.PlaybackEvent{event("", 50000, 2*time.Minute), event("", 96000, 3*time.Minute), event("", 20000, time.Minute)},
+			map[string]entry{"": {96000, true}}},
+		{"rewatch after completion", []models.PlaybackEvent{event("", 96000, 0), event("", 10000, time.Hour)}, map[string]entry{"": {10000, false}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryProgressStore{entries: map[string]models.WatchHistory{}}
+			svc := &UserService{progress: store}
+			for i := range tt.events {
+				if err := svc.ApplyPlaybackEvent(context.Background(), &tt.events[i], 0.95); err != nil {
+					t.Fatalf("ApplyPlaybackEvent: %v", err)
+				}
+			}
+
+			if len(store.entries) != len(tt.want) {
+				t.Fatalf("expected %d entries, got %+v", len(tt.want), store.entries)
+			}
+			for profileID, want := range tt.want {
+				got, ok := store.entries["user-1/"+profileID+"/c1"]
+				if !ok || got.Position != want.position || got.Completed != want.completed || got.ProfileID != profileID {
+					t.Fatalf("expected profile %q at %+v, got %+v", profileID, want, got)
+				}
+			}
+		})
+	}
+}
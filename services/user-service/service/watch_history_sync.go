@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/streamverse/common-go/logger"
+	"github.com/streamverse/user-service/models"
+	"go.uber.org/zap"
+)
+
+const (
+	watchHistoryRetries    = 3
+	watchHistoryBackoff    = 500 * time.Millisecond
+	watchHistoryMaxBackoff = 30 * time.Second
+)
+
+// WatchHistorySyncConfig controls consumption of streaming service playback events.
+type WatchHistorySyncConfig struct {
+	Brokers            []string
+	Topic              string
+	GroupID            string
+	CompletedThreshold float64 // share of the duration after which content counts as watched
+}
+
+// WatchHistorySyncConfigFromEnv builds consumer config from environment variables.
+func WatchHistorySyncConfigFromEnv() WatchHistorySyncConfig {
+	cfg := WatchHistorySyncConfig{
+		Topic:              "playback-events",
+		GroupID:            "user-service-watch-history",
+		CompletedThreshold: 0.95,
+	}
+
+	for _, broker := range strings.Split(os.Getenv("KAFKA_BROKERS"), ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			cfg.Brokers = append(cfg.Brokers, broker)
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("PLAYBACK_EVENTS_TOPIC")); v != "" {
+		cfg.Topic = v
+	}
+	if v := strings.TrimSpace(os.Getenv("WATCH_HISTORY_CONSUMER_GROUP")); v != "" {
+		cfg.GroupID = v
+	}
+	if v := strings.TrimSpace(os.Getenv("WATCH_COMPLETED_THRESHOLD")); v != "" {
+		if parsed, err := strconv.ParseFloat(v, 64); err == nil && parsed > 0 && parsed <= 1 {
+			cfg.CompletedThreshold = parsed
+		}
+	}
+
+	return cfg
+}
+
+// WatchHistorySync keeps watch history in sync with playback sessions of the streaming service.
+type WatchHistorySync struct {
+	userService *UserService
+	logger      *logger.Logger
+	config      WatchHistorySyncConfig
+}
+
+// NewWatchHistorySync creates a watch history consumer.
+func NewWatchHistorySync(userService *UserService, log *logger.Logger, config WatchHistorySyncConfig) *WatchHistorySync {
+	return &WatchHistorySync{
+		userService: userService,
+		logger:      log,
+		config:      config,
+	}
+}
+
+// Start consumes playback events until context cancellation.
+func (w *WatchHistorySync) Start(ctx context.Context) {
+	if len(w.config.Brokers) == 0 {
+		w.logger.Info("Watch history sync disabled: no Kafka brokers configured")
+		return
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: w.config.Brokers,
+		Topic:   w.config.Topic,
+		GroupID: w.config.GroupID,
+	})
+	defer reader.Close()
+
+	// Fetch failures, such as unreachable brokers, back off up to a limit
+	backoff := watchHistoryBackoff
+	for {
+		message, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				w.logger.Error("Failed to fetch playback event", zap.Error(err), zap.Duration("retry_in", backoff))
+				select {
+				case <-ctx.Done():
+				case <-time.After(backoff):
+					backoff = min(2*backoff, watchHistoryMaxBackoff)
+					continue
+				}
+			}
+			w.logger.Info("Watch history sync stopped")
+			return
+		}
+		backoff = watchHistoryBackoff
+
+		w.handle(ctx, message)
+
+		if err := reader.CommitMessages(ctx, message); err != nil && ctx.Err() == nil {
+			w.logger.Error("Failed to commit playback event", zap.Error(err))
+		}
+	}
+}
+
+// handle applies one event, retrying storage failures. Events that still fail are
+// skipped; the session's next update or end event carries a newer position anyway.
+func (w *WatchHistorySync) handle(ctx context.Context, message kafka.Message) {
+	var event models.PlaybackEvent
+	if err := json.Unmarshal(message.Value, &event); err != nil {
+		w.logger.Error("Dropping malformed playback event", zap.Error(err))
+		return
+	}
+
+	var err error
+	for attempt := 0; attempt < watchHistoryRetries; attempt++ {
+		if err = w.userService.ApplyPlaybackEvent(ctx, &event, w.config.CompletedThreshold); err == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchHistoryBackoff << attempt):
+		}
+	}
+	w.logger.Error("Failed to update watch history",
+		zap.String("session_id", event.SessionID),
+		zap.String("content_id", event.ContentID),
+		zap.Error(err),
+	)
+}